@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// aliasesBucket maps alias names to the name of the command they stand for.
+// An alias is resolved when a command is run, so it always reaches the same
+// stored entry as its target.
+var aliasesBucket = []byte("aliases")
+
+// AddAlias stores alias as another name for the command target
+func (d *Database) AddAlias(alias, target string) error {
+	alias = strings.TrimSpace(alias)
+	if err := validateCommandName(alias); err != nil {
+		return err
+	}
+	return d.update("AddAlias", func(tx *bbolt.Tx) error {
+		if tx.Bucket(commandsBucket).Get([]byte(target)) == nil {
+			return fmt.Errorf("command '%s' not found", target)
+		}
+		if tx.Bucket(commandsBucket).Get([]byte(alias)) != nil {
+			return fmt.Errorf("'%s' is already the name of a command", alias)
+		}
+		b := tx.Bucket(aliasesBucket)
+		if existing := b.Get([]byte(alias)); existing != nil {
+			return fmt.Errorf("alias '%s' already points to '%s'", alias, existing)
+		}
+		return b.Put([]byte(alias), []byte(target))
+	})
+}
+
+// RemoveAlias deletes an alias, leaving its target alone
+func (d *Database) RemoveAlias(alias string) error {
+	return d.update("RemoveAlias", func(tx *bbolt.Tx) error {
+		b := tx.Bucket(aliasesBucket)
+		if b.Get([]byte(alias)) == nil {
+			return fmt.Errorf("alias '%s' not found", alias)
+		}
+		return b.Delete([]byte(alias))
+	})
+}
+
+// GetAliases returns every alias with the name of its target
+func (d *Database) GetAliases() (map[string]string, error) {
+	aliases := map[string]string{}
+	err := d.view("GetAliases", func(tx *bbolt.Tx) error {
+		return tx.Bucket(aliasesBucket).ForEach(func(k, v []byte) error {
+			aliases[string(k)] = string(v)
+			return nil
+		})
+	})
+	return aliases, err
+}
+
+// GetAliasTarget returns the command an alias stands for, or "" if name is
+// not an alias
+func (d *Database) GetAliasTarget(name string) (string, error) {
+	var target string
+	err := d.view("GetAliasTarget", func(tx *bbolt.Tx) error {
+		target = string(tx.Bucket(aliasesBucket).Get([]byte(name)))
+		return nil
+	})
+	return target, err
+}
+
+// aliasesOfTx returns the aliases of the command target within tx
+func aliasesOfTx(tx *bbolt.Tx, target string) ([]string, error) {
+	var aliases []string
+	err := tx.Bucket(aliasesBucket).ForEach(func(k, v []byte) error {
+		if string(v) == target {
+			aliases = append(aliases, string(k))
+		}
+		return nil
+	})
+	return aliases, err
+}
+
+// deleteAliasesTx removes the aliases of the command target within tx, so
+// they do not outlive it when it is deleted or renamed
+func deleteAliasesTx(tx *bbolt.Tx, target string) error {
+	aliases, err := aliasesOfTx(tx, target)
+	if err != nil {
+		return err
+	}
+	for _, alias := range aliases {
+		if err := tx.Bucket(aliasesBucket).Delete([]byte(alias)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
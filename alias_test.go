@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, name := range []string{"build", "lint"} {
+		if err := db.AddCommand(name, "", "true", ""); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+
+	if err := db.AddAlias("b", "build"); err != nil {
+		t.Fatalf("Failed to add alias: %v", err)
+	}
+	if name, err := resolveCommandName(db, "b", false); err != nil || name != "build" {
+		t.Errorf("Expected the alias to resolve to build, got %q, %v", name, err)
+	}
+
+	// Aliases and command names share one namespace
+	if err := db.AddAlias("b", "lint"); err == nil || !strings.Contains(err.Error(), "already points to 'build'") {
+		t.Errorf("Expected an error for a taken alias, got %v", err)
+	}
+	if err := db.AddAlias("lint", "build"); err == nil {
+		t.Error("Expected an error for an alias named like a command")
+	}
+	if err := db.AddCommand("b", "", "true", ""); err == nil || !strings.Contains(err.Error(), "alias of 'build'") {
+		t.Errorf("Expected an error for a command named like an alias, got %v", err)
+	}
+	if err := db.AddAlias("x", "missing"); err == nil {
+		t.Error("Expected an error for a missing target")
+	}
+
+	// Deleting the target removes its aliases
+	if err := db.AddAlias("l", "lint"); err != nil {
+		t.Fatalf("Failed to add alias: %v", err)
+	}
+	if err := db.DeleteCommand("build"); err != nil {
+		t.Fatalf("Failed to delete command: %v", err)
+	}
+	aliases, err := db.GetAliases()
+	if err != nil || len(aliases) != 1 || aliases["l"] != "lint" {
+		t.Errorf("Expected only the alias of lint to remain, got %v, %v", aliases, err)
+	}
+
+	if err := db.RemoveAlias("l"); err != nil {
+		t.Errorf("Failed to remove alias: %v", err)
+	}
+	if err := db.RemoveAlias("l"); err == nil {
+		t.Error("Expected an error removing a missing alias")
+	}
+}
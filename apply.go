@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// loadDeclaredCommands reads the commands declared in a file, an https URL
+// or stdin (-). Relative working directories in a local file are resolved
+// against the file's directory so the file can live in version control next
+// to the project it describes.
+func loadDeclaredCommands(source string) ([]Command, error) {
+	data, err := readImportSource(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", source, err)
+	}
+	doc, err := decodeExportDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	base := ""
+	if source != "-" && !isImportURL(source) {
+		abs, err := filepath.Abs(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve path: %v", err)
+		}
+		base = filepath.Dir(abs)
+	}
+
+	for i := range doc.Commands {
+		dir := strings.TrimSpace(doc.Commands[i].WorkingDir)
+		switch {
+		case dir == "" || filepath.IsAbs(dir):
+		case doc.Commands[i].Wsl != "" && isLinuxPath(dir):
+			// Resolved by the WSL distro
+		case strings.HasPrefix(dir, "~"):
+			resolved, err := resolveDirectory(dir)
+			if err != nil {
+				return nil, err
+			}
+			doc.Commands[i].WorkingDir = resolved
+		case base != "":
+			doc.Commands[i].WorkingDir = filepath.Join(base, dir)
+		}
+	}
+	return doc.Commands, nil
+}
+
+// PlanApply computes the changes needed to reconcile the database with the
+// commands declared in source. Undeclared commands are only deleted with prune.
+func PlanApply(db *Database, source string, prune bool) (ChangeSet, error) {
+	declared, err := loadDeclaredCommands(source)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+	current, err := db.GetAllCommands()
+	if err != nil {
+		return ChangeSet{}, fmt.Errorf("failed to get commands: %v", err)
+	}
+	return planChanges(current, declared, prune)
+}
+
+// Apply reconciles the database with the commands declared in source and
+// reports what it changed
+func Apply(db *Database, source string, prune bool) error {
+	cs, err := PlanApply(db, source, prune)
+	if err != nil {
+		return err
+	}
+	if cs.Empty() {
+		fmt.Println("Already up to date.")
+		return nil
+	}
+
+	cs.Print()
+	if err := db.ApplyChangeSet(cs); err != nil {
+		return fmt.Errorf("failed to apply changes: %v", err)
+	}
+	fmt.Println("Changes applied.")
+	return nil
+}
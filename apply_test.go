@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("manual", "Added by hand", "echo manual", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	path := filepath.Join(tempDir, "commands.yaml")
+	write := func(doc string) {
+		if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	write("commands:\n  - name: build\n    command: go build\n    working_dir: .\n")
+	if err := Apply(db, path, false); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	build, err := db.GetCommand("build")
+	if err != nil {
+		t.Fatalf("Expected 'build' to be created: %v", err)
+	}
+	if build.WorkingDir != tempDir {
+		t.Errorf("Relative working dir should resolve against the file, got '%s'", build.WorkingDir)
+	}
+
+	// Applying the same file again changes nothing
+	cs, err := PlanApply(db, path, false)
+	if err != nil {
+		t.Fatalf("PlanApply failed: %v", err)
+	}
+	if !cs.Empty() {
+		t.Errorf("Expected no changes on re-apply, got %+v", cs)
+	}
+
+	write("commands:\n  - name: build\n    command: go build -v\n    working_dir: .\n")
+	if err := Apply(db, path, true); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if build, _ := db.GetCommand("build"); build.Command != "go build -v" {
+		t.Errorf("Expected 'build' to be updated, got '%s'", build.Command)
+	}
+	if _, err := db.GetCommand("manual"); err == nil {
+		t.Error("Expected undeclared 'manual' to be pruned")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// backupTimeLayout stamps backup file names so they sort by age
+const backupTimeLayout = "20060102-150405"
+
+// BackupDir returns the directory holding database backups
+func (d *Database) BackupDir() string {
+	return filepath.Join(d.DataDir(), "backups")
+}
+
+// backupPrefix is what the file names of backups of the database start with
+func (d *Database) backupPrefix() string {
+	return strings.TrimSuffix(filepath.Base(d.Path()), filepath.Ext(d.Path())) + "-"
+}
+
+// Backups returns the paths of the backups of the database, oldest first
+func (d *Database) Backups() ([]string, error) {
+	entries, err := os.ReadDir(d.BackupDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasPrefix(entry.Name(), d.backupPrefix()) && strings.HasSuffix(entry.Name(), ".db") {
+			paths = append(paths, filepath.Join(d.BackupDir(), entry.Name()))
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// Backup writes a consistent copy of the database to a timestamped file in
+// the backup directory and returns its path. With keep above 0, only the
+// keep most recent backups are kept.
+func (d *Database) Backup(keep int, now time.Time) (string, error) {
+	if err := os.MkdirAll(d.BackupDir(), 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	// Backups taken within the same second get a counter
+	base := filepath.Join(d.BackupDir(), d.backupPrefix()+now.Format(backupTimeLayout))
+	path := base + ".db"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = fmt.Sprintf("%s.%d.db", base, i)
+	}
+
+	tmp := path + ".tmp"
+	err := d.view("Backup", func(tx *bbolt.Tx) error {
+		return tx.CopyFile(tmp, 0600)
+	})
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write backup: %v", err)
+	}
+
+	if keep > 0 {
+		backups, err := d.Backups()
+		if err != nil {
+			return path, fmt.Errorf("failed to list backups: %v", err)
+		}
+		for _, old := range backups[:max(len(backups)-keep, 0)] {
+			if err := os.Remove(old); err != nil {
+				return path, fmt.Errorf("failed to remove old backup: %v", err)
+			}
+		}
+	}
+	return path, nil
+}
+
+// resolveBackup returns the path of a backup given as a path or as a file
+// name in the backup directory
+func (d *Database) resolveBackup(backup string) (string, error) {
+	if _, err := os.Stat(backup); err == nil {
+		return backup, nil
+	}
+	path := filepath.Join(d.BackupDir(), filepath.Base(backup))
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("backup '%s' not found", backup)
+	}
+	return path, nil
+}
+
+// checkBackup makes sure path holds an afv database
+func checkBackup(path string) error {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("'%s' is not a database backup: %v", path, err)
+	}
+	defer db.Close()
+	return db.View(func(tx *bbolt.Tx) error {
+		if tx.Bucket(commandsBucket) == nil {
+			return fmt.Errorf("'%s' is not an afv database backup", path)
+		}
+		return nil
+	})
+}
+
+// Restore replaces the database with the backup at path, after backing up
+// the current database so the restore can be undone. It returns the path
+// of that backup.
+func (d *Database) Restore(path string, now time.Time) (string, error) {
+	if err := checkBackup(path); err != nil {
+		return "", err
+	}
+	current, err := d.Backup(0, now)
+	if err != nil {
+		return "", err
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return current, fmt.Errorf("failed to open backup: %v", err)
+	}
+	defer src.Close()
+
+	if err := d.Release(); err != nil {
+		return current, fmt.Errorf("failed to close database: %v", err)
+	}
+	dst, err := os.OpenFile(d.path, os.O_WRONLY|os.O_TRUNC, 0600)
+	if err == nil {
+		_, err = io.Copy(dst, src)
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to restore backup (the previous database is in %s): %v", current, err)
+	}
+	if reopenErr := d.Reopen(); reopenErr != nil {
+		return current, reopenErr
+	}
+	if err != nil {
+		return current, err
+	}
+	// Backups of older versions may lack buckets added since
+	if err := d.initBuckets(); err != nil {
+		return current, fmt.Errorf("failed to initialize buckets: %v", err)
+	}
+	return current, nil
+}
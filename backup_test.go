@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupRetention(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	var paths []string
+	for i := range 4 {
+		path, err := db.Backup(3, now.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatalf("Backup failed: %v", err)
+		}
+		paths = append(paths, path)
+	}
+	if filepath.Base(paths[0]) != "test-20261015-090000.db" {
+		t.Errorf("Unexpected backup name %s", paths[0])
+	}
+
+	backups, err := db.Backups()
+	if err != nil {
+		t.Fatalf("Backups failed: %v", err)
+	}
+	if len(backups) != 3 || backups[0] != paths[1] || backups[2] != paths[3] {
+		t.Errorf("Expected the three most recent backups, got %v", backups)
+	}
+
+	// A second backup within the same second does not overwrite the first
+	again, err := db.Backup(0, now.Add(3*time.Hour))
+	if err != nil || again == paths[3] {
+		t.Errorf("Expected a separate backup, got %s, %v", again, err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("build", "", "make", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	backup, err := db.Backup(0, now)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := db.AddCommand("lint", "", "golint", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	previous, err := db.Restore(backup, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if _, err := db.GetCommand("build"); err != nil {
+		t.Errorf("Expected the backed up command after restoring: %v", err)
+	}
+	if _, err := db.GetCommand("lint"); err == nil {
+		t.Error("Expected the later command to be gone after restoring")
+	}
+
+	// The database replaced by the restore was backed up first
+	if _, err := db.Restore(previous, now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if _, err := db.GetCommand("lint"); err != nil {
+		t.Errorf("Expected the restore to be undone: %v", err)
+	}
+
+	notDB := filepath.Join(tempDir, "notes.txt")
+	os.WriteFile(notDB, []byte("not a database"), 0600)
+	if _, err := db.Restore(notDB, now); err == nil {
+		t.Error("Expected an error restoring a file that is not a database")
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"go.etcd.io/bbolt"
+	"golang.org/x/term"
+)
+
+// batchSize is the number of commands AddCommands writes per transaction.
+// Every transaction costs an fsync, so large imports write in chunks instead
+// of one transaction per command.
+const batchSize = 500
+
+// AddCommands stores many new commands at once. All commands are validated
+// up front and nothing is written if any of them is invalid, duplicated or
+// already stored. The writes happen in transactions of batchSize commands;
+// progress, if set, is called after each one.
+func (d *Database) AddCommands(commands []Command, progress func(done, total int)) error {
+	normalized := make([]Command, 0, len(commands))
+	seen := make(map[string]bool, len(commands))
+	var errs []error
+	for _, cmd := range commands {
+		if err := normalizeCommand(&cmd); err != nil {
+			errs = append(errs, fmt.Errorf("command '%s': %v", cmd.Name, err))
+			continue
+		}
+		if seen[cmd.Name] {
+			errs = append(errs, fmt.Errorf("command '%s' appears more than once", cmd.Name))
+			continue
+		}
+		seen[cmd.Name] = true
+		normalized = append(normalized, cmd)
+	}
+
+	err := d.view("AddCommands", func(tx *bbolt.Tx) error {
+		b := tx.Bucket(commandsBucket)
+		for _, cmd := range normalized {
+			if b.Get([]byte(cmd.Name)) != nil {
+				errs = append(errs, fmt.Errorf("command '%s' already exists", cmd.Name))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	total := len(normalized)
+	for start := 0; start < total; start += batchSize {
+		batch := normalized[start:min(start+batchSize, total)]
+		err := d.update("AddCommands", func(tx *bbolt.Tx) error {
+			for _, cmd := range batch {
+				if err := insertCommandTx(tx, cmd); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed after storing %d of %d commands: %v", start, total, err)
+		}
+		if progress != nil {
+			progress(start+len(batch), total)
+		}
+	}
+	return nil
+}
+
+// terminalProgress returns a progress callback that redraws a counter on
+// stderr, or nil if stderr is not a terminal
+func terminalProgress(label string) func(done, total int) {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	return func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\r%s %d/%d", label, done, total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func generatedCommands(n int) []Command {
+	commands := make([]Command, n)
+	for i := range commands {
+		commands[i] = Command{Name: fmt.Sprintf("cmd-%04d", i), Command: fmt.Sprintf("echo %d", i), Tags: []string{"generated"}}
+	}
+	return commands
+}
+
+func TestAddCommandsInBatches(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	total := 2*batchSize + 7
+	var calls [][2]int
+	err := db.AddCommands(generatedCommands(total), func(done, total int) {
+		calls = append(calls, [2]int{done, total})
+	})
+	if err != nil {
+		t.Fatalf("AddCommands failed: %v", err)
+	}
+
+	expected := [][2]int{{batchSize, total}, {2 * batchSize, total}, {total, total}}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected progress %v, got %v", expected, calls)
+	}
+
+	commands, err := db.GetAllCommands()
+	if err != nil || len(commands) != total {
+		t.Fatalf("Expected %d stored commands, got %d, %v", total, len(commands), err)
+	}
+	if commands[0].CreatedAt == "" || commands[0].Description != "No description provided" {
+		t.Errorf("Expected commands to be normalized, got %+v", commands[0])
+	}
+	if tagged, _ := db.GetCommandsByTag("generated"); len(tagged) != total {
+		t.Errorf("Expected %d tagged commands, got %d", total, len(tagged))
+	}
+}
+
+func TestAddCommandsValidatesUpFront(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("existing", "", "true", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := generatedCommands(3)
+	commands = append(commands,
+		Command{Name: "nocmd"},
+		Command{Name: "cmd-0001", Command: "echo again"},
+		Command{Name: "existing", Command: "true"},
+	)
+	err := db.AddCommands(commands, nil)
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"'nocmd'", "'cmd-0001' appears more than once", "'existing' already exists"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got: %v", want, err)
+		}
+	}
+
+	stored, _ := db.GetAllCommands()
+	if len(stored) != 1 {
+		t.Errorf("Expected nothing to be written, got %d commands", len(stored))
+	}
+}
+
+func TestImportCommandsLarge(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	commands := generatedCommands(batchSize + 1)
+	// A repeated name within the import is renamed like any other collision
+	commands = append(commands, Command{Name: "cmd-0000", Command: "echo copy"})
+	result, err := ImportCommands(db, commands, ConflictRename, nil)
+	if err != nil {
+		t.Fatalf("ImportCommands failed: %v", err)
+	}
+	if len(result.Added) != batchSize+1 || result.Renamed["cmd-0000"] != "cmd-0000-2" {
+		t.Errorf("Unexpected result: %d added, renamed %v", len(result.Added), result.Renamed)
+	}
+	if cmd, err := db.GetCommand("cmd-0000-2"); err != nil || cmd.Command != "echo copy" {
+		t.Errorf("Expected renamed copy to be stored, got %+v, %v", cmd, err)
+	}
+}
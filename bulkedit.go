@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// bulkEditHeader explains the edit round trip at the top of the temp file
+const bulkEditHeader = `# Edit the commands below and save to apply all changes at once.
+# Removing an entry deletes the command, adding one creates it.
+# Leave the file unchanged to cancel.
+`
+
+// planBulkEdit validates an edited document against the commands that were
+// opened for editing. Added commands must not collide with commands outside
+// the edited selection.
+func planBulkEdit(db *Database, selected []Command, data []byte) (ChangeSet, error) {
+	doc, err := decodeExportDocument(data)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	cs, err := planChanges(selected, doc.Commands, true)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	for _, cmd := range cs.Create {
+		if _, err := db.GetCommand(cmd.Name); err == nil && !containsCommand(selected, cmd.Name) {
+			return ChangeSet{}, fmt.Errorf("command '%s' already exists outside the edited selection", cmd.Name)
+		}
+	}
+	return cs, nil
+}
+
+// containsCommand reports whether commands holds a command called name
+func containsCommand(commands []Command, name string) bool {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// BulkEdit opens the commands carrying tag (or all commands) in the user's
+// editor as a YAML document and applies the edits in one transaction. An
+// invalid document can be edited again until it validates or is discarded.
+func BulkEdit(db *Database, tag string) error {
+	selected, err := db.GetCommandsByTag(tag)
+	if err != nil {
+		return fmt.Errorf("failed to get commands: %v", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(bulkEditHeader)
+	if err := encodeExport(&buf, selected, "yaml", false); err != nil {
+		return err
+	}
+	original := buf.Bytes()
+
+	file, err := os.CreateTemp("", "afv-bulk-edit-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	path := file.Name()
+	defer os.Remove(path)
+	_, err = file.Write(original)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write temp file: %v", err)
+	}
+
+	for {
+		if err := openEditor(path); err != nil {
+			return err
+		}
+
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read edited file: %v", err)
+		}
+		if bytes.Equal(edited, original) {
+			fmt.Println("No changes.")
+			return nil
+		}
+
+		cs, err := planBulkEdit(db, selected, edited)
+		if err != nil {
+			fmt.Printf("Invalid document: %v\n", err)
+			if confirm("Edit again?") {
+				continue
+			}
+			fmt.Println("Changes discarded.")
+			return nil
+		}
+
+		if cs.Empty() {
+			fmt.Println("No changes.")
+			return nil
+		}
+
+		cs.Print()
+		if err := db.ApplyChangeSet(cs); err != nil {
+			return fmt.Errorf("failed to apply changes: %v", err)
+		}
+		fmt.Println("Changes applied.")
+		return nil
+	}
+}
+
+// editHeader explains the edit round trip of a single command
+const editHeader = `# Edit the command below and save to apply the changes.
+# Leave the file unchanged to cancel.
+`
+
+// parseEditedCommand validates the edited document of the command called
+// name. Unknown fields are rejected so typos do not get lost silently.
+func parseEditedCommand(name string, data []byte) (Command, error) {
+	var cmd Command
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cmd); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Command{}, fmt.Errorf("document is empty")
+		}
+		return Command{}, fmt.Errorf("failed to parse command: %v", err)
+	}
+	if cmd.Name != name {
+		return Command{}, fmt.Errorf("the name must stay '%s'", name)
+	}
+	if err := normalizeCommand(&cmd); err != nil {
+		return Command{}, err
+	}
+	return cmd, nil
+}
+
+// EditCommand opens a command in the user's editor as a YAML document and
+// stores the edited version. An invalid document can be edited again until
+// it validates or is discarded.
+func EditCommand(db *Database, name string) error {
+	existing, err := db.GetCommand(name)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(editHeader)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(exportCommand(*existing)); err != nil {
+		return err
+	}
+	enc.Close()
+	original := buf.Bytes()
+
+	file, err := os.CreateTemp("", "afv-edit-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	path := file.Name()
+	defer os.Remove(path)
+	_, err = file.Write(original)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write temp file: %v", err)
+	}
+
+	for {
+		if err := openEditor(path); err != nil {
+			return err
+		}
+
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read edited file: %v", err)
+		}
+		if bytes.Equal(edited, original) {
+			fmt.Println("No changes.")
+			return nil
+		}
+
+		cmd, err := parseEditedCommand(name, edited)
+		if err != nil {
+			fmt.Printf("Invalid command: %v\n", err)
+			if confirm("Edit again?") {
+				continue
+			}
+			fmt.Println("Changes discarded.")
+			return nil
+		}
+
+		if err := db.ReplaceCommand(cmd); err != nil {
+			return fmt.Errorf("failed to store command: %v", err)
+		}
+		fmt.Printf("Command '%s' updated successfully.\n", name)
+		return nil
+	}
+}
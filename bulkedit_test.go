@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestBulkEdit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("editor script requires a POSIX shell")
+	}
+
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, cmd := range []Command{
+		{Name: "up", Command: "docker compose up", Tags: []string{"docker"}},
+		{Name: "down", Command: "docker compose down", Tags: []string{"docker"}},
+		{Name: "build", Command: "go build"},
+	} {
+		if err := db.InsertCommand(cmd); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+
+	// The editor replaces the document with one that updates 'up', drops
+	// 'down' and adds 'logs'
+	edited := filepath.Join(tempDir, "edited.yaml")
+	doc := `commands:
+  - name: up
+    command: docker compose up -d
+    tags: [docker]
+  - name: logs
+    command: docker compose logs
+    tags: [docker]
+`
+	if err := os.WriteFile(edited, []byte(doc), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "cp "+edited)
+
+	if err := BulkEdit(db, "docker"); err != nil {
+		t.Fatalf("BulkEdit failed: %v", err)
+	}
+
+	up, err := db.GetCommand("up")
+	if err != nil || up.Command != "docker compose up -d" {
+		t.Errorf("Expected 'up' to be updated, got %+v (%v)", up, err)
+	}
+	if _, err := db.GetCommand("logs"); err != nil {
+		t.Errorf("Expected 'logs' to be created: %v", err)
+	}
+	if _, err := db.GetCommand("down"); err == nil {
+		t.Error("Expected 'down' to be deleted")
+	}
+	if _, err := db.GetCommand("build"); err != nil {
+		t.Errorf("Commands outside the tag must not be touched: %v", err)
+	}
+}
+
+func TestPlanBulkEditRejectsOutsideCollision(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("build", "Build", "go build", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	_, err := planBulkEdit(db, nil, []byte("commands:\n  - name: build\n    command: make\n"))
+	if err == nil {
+		t.Error("Expected error when adding a command that exists outside the selection")
+	}
+}
+
+func TestEditCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("editor script requires a POSIX shell")
+	}
+
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.InsertCommand(Command{Name: "build", Description: "Build it", Command: "go build"}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := db.RecordRun("build", time.Now()); err != nil {
+		t.Fatalf("Failed to record run: %v", err)
+	}
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i s/go.build/go-build-all/")
+	if err := EditCommand(db, "build"); err != nil {
+		t.Fatalf("EditCommand failed: %v", err)
+	}
+	cmd, err := db.GetCommand("build")
+	if err != nil || cmd.Command != "go-build-all" || cmd.Description != "Build it" {
+		t.Errorf("Expected only the command line to change, got %+v (%v)", cmd, err)
+	}
+	if cmd.RunCount != 1 {
+		t.Errorf("Expected the run statistics to be kept, got %d runs", cmd.RunCount)
+	}
+}
+
+func TestParseEditedCommand(t *testing.T) {
+	if _, err := parseEditedCommand("build", []byte("name: build\ncommand: go build\n")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	for _, doc := range []string{
+		"",
+		"name: compile\ncommand: go build\n",
+		"name: build\ncommand: go build\ndirectory: /src\n",
+		"name: build\n",
+	} {
+		if _, err := parseEditedCommand("build", []byte(doc)); err == nil {
+			t.Errorf("Expected an error for %q", doc)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// ChangeSet is a set of command writes applied in a single transaction
+type ChangeSet struct {
+	Create []Command
+	Update []Command
+	Delete []string
+
+	// previous holds the stored version of updated commands for diffs
+	previous map[string]Command
+}
+
+// Empty reports whether the change set would not modify anything
+func (c ChangeSet) Empty() bool {
+	return len(c.Create) == 0 && len(c.Update) == 0 && len(c.Delete) == 0
+}
+
+// Print lists the changes followed by a summary line
+func (c ChangeSet) Print() {
+	for _, cmd := range c.Create {
+		fmt.Printf("  + %s\n", cmd.Name)
+	}
+	for _, cmd := range c.Update {
+		fmt.Printf("  ~ %s\n", cmd.Name)
+	}
+	for _, name := range c.Delete {
+		fmt.Printf("  - %s\n", name)
+	}
+	fmt.Printf("%d to create, %d to update, %d to delete.\n", len(c.Create), len(c.Update), len(c.Delete))
+}
+
+// PrintDiff lists the changes with the fields each one sets, changes or
+// removes, followed by a summary line
+func (c ChangeSet) PrintDiff() {
+	for _, cmd := range c.Create {
+		fmt.Printf("  + %s\n", cmd.Name)
+		for _, field := range commandFields(cmd) {
+			fmt.Printf("      %s: %s\n", field.name, field.value)
+		}
+	}
+	for _, cmd := range c.Update {
+		fmt.Printf("  ~ %s\n", cmd.Name)
+		for _, change := range diffCommandFields(c.previous[cmd.Name], cmd) {
+			fmt.Printf("      %s\n", change)
+		}
+	}
+	for _, name := range c.Delete {
+		fmt.Printf("  - %s\n", name)
+	}
+	fmt.Printf("Plan: %d to create, %d to update, %d to delete.\n", len(c.Create), len(c.Update), len(c.Delete))
+}
+
+// commandField is a non-empty command field rendered as JSON
+type commandField struct {
+	name  string
+	value string
+}
+
+// commandFields returns the non-empty fields of cmd in declaration order,
+// named by their json tag
+func commandFields(cmd Command) []commandField {
+	var fields []commandField
+	v := reflect.ValueOf(cmd)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsZero() {
+			continue
+		}
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		value, err := json.Marshal(v.Field(i).Interface())
+		if err != nil {
+			value = []byte(fmt.Sprint(v.Field(i).Interface()))
+		}
+		fields = append(fields, commandField{name: name, value: string(value)})
+	}
+	return fields
+}
+
+// diffCommandFields describes the field-level changes from old to updated,
+// one line per field in declaration order
+func diffCommandFields(old, updated Command) []string {
+	before := map[string]string{}
+	for _, field := range commandFields(old) {
+		before[field.name] = field.value
+	}
+	after := map[string]string{}
+	for _, field := range commandFields(updated) {
+		after[field.name] = field.value
+	}
+
+	var changes []string
+	t := reflect.TypeOf(Command{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		oldValue, hadOld := before[name]
+		newValue, hasNew := after[name]
+		switch {
+		case hadOld && !hasNew:
+			changes = append(changes, fmt.Sprintf("- %s: %s", name, oldValue))
+		case !hadOld && hasNew:
+			changes = append(changes, fmt.Sprintf("+ %s: %s", name, newValue))
+		case oldValue != newValue:
+			changes = append(changes, fmt.Sprintf("~ %s: %s -> %s", name, oldValue, newValue))
+		}
+	}
+	return changes
+}
+
+// planChanges compares the desired commands with the current ones and
+// returns the writes needed to get from one to the other. Current commands
+// that are not desired are only deleted when prune is set.
+func planChanges(current, desired []Command, prune bool) (ChangeSet, error) {
+	cs := ChangeSet{previous: map[string]Command{}}
+
+	existing := make(map[string]Command, len(current))
+	for _, cmd := range current {
+		existing[cmd.Name] = cmd
+	}
+
+	declared := make(map[string]bool, len(desired))
+	for _, cmd := range desired {
+		cmd = exportCommand(cmd)
+		if err := normalizeCommand(&cmd); err != nil {
+			if cmd.Name == "" {
+				return ChangeSet{}, err
+			}
+			return ChangeSet{}, fmt.Errorf("command '%s': %v", cmd.Name, err)
+		}
+		if declared[cmd.Name] {
+			return ChangeSet{}, fmt.Errorf("command '%s' is declared more than once", cmd.Name)
+		}
+		declared[cmd.Name] = true
+
+		old, ok := existing[cmd.Name]
+		switch {
+		case !ok:
+			cs.Create = append(cs.Create, cmd)
+		case !reflect.DeepEqual(exportCommand(old), cmd):
+			cs.Update = append(cs.Update, cmd)
+			cs.previous[cmd.Name] = exportCommand(old)
+		}
+	}
+
+	if prune {
+		for _, cmd := range current {
+			if !declared[cmd.Name] {
+				cs.Delete = append(cs.Delete, cmd.Name)
+			}
+		}
+		slices.Sort(cs.Delete)
+	}
+	return cs, nil
+}
+
+// ApplyChangeSet performs all writes of a change set in one transaction, so
+// either every change is stored or none is. Commands must already be
+// normalized, as done by planChanges.
+func (d *Database) ApplyChangeSet(cs ChangeSet) error {
+	return d.update("ApplyChangeSet", func(tx *bbolt.Tx) error {
+		// Delete first so a command can be renamed within one change set
+		for _, name := range cs.Delete {
+			if err := deleteCommandTx(tx, name); err != nil {
+				return err
+			}
+		}
+		for _, cmd := range cs.Create {
+			if err := insertCommandTx(tx, cmd); err != nil {
+				return err
+			}
+		}
+		for _, cmd := range cs.Update {
+			if err := replaceCommandTx(tx, cmd); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestPlanChanges(t *testing.T) {
+	current := []Command{
+		{Name: "build", Description: "Build", Command: "go build", CreatedAt: "2025-01-01 10:00:00", RunCount: 3},
+		{Name: "test", Description: "Test", Command: "go test ./..."},
+		{Name: "old", Description: "Old", Command: "make old"},
+	}
+	desired := []Command{
+		{Name: "build", Description: "Build", Command: "go build"},
+		{Name: "test", Description: "Test", Command: "go test -race ./..."},
+		{Name: "lint", Command: "go vet ./..."},
+	}
+
+	cs, err := planChanges(current, desired, false)
+	if err != nil {
+		t.Fatalf("planChanges failed: %v", err)
+	}
+	if len(cs.Create) != 1 || cs.Create[0].Name != "lint" || cs.Create[0].Description != "No description provided" {
+		t.Errorf("Expected normalized 'lint' to be created, got %+v", cs.Create)
+	}
+	if len(cs.Update) != 1 || cs.Update[0].Name != "test" {
+		t.Errorf("Expected only 'test' to be updated, got %+v", cs.Update)
+	}
+	if len(cs.Delete) != 0 {
+		t.Errorf("Nothing should be deleted without prune, got %v", cs.Delete)
+	}
+
+	cs, err = planChanges(current, desired, true)
+	if err != nil {
+		t.Fatalf("planChanges failed: %v", err)
+	}
+	if !reflect.DeepEqual(cs.Delete, []string{"old"}) {
+		t.Errorf("Expected 'old' to be deleted with prune, got %v", cs.Delete)
+	}
+
+	if _, err := planChanges(nil, []Command{desired[0], desired[0]}, false); err == nil {
+		t.Error("Expected error for a command declared twice")
+	}
+	if _, err := planChanges(nil, []Command{{Name: "broken"}}, false); err == nil {
+		t.Error("Expected error for a command without command line")
+	}
+}
+
+func TestApplyChangeSetIsAtomic(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("keep", "Keep", "echo keep", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	// The second create collides with an existing command, so the first one
+	// must be rolled back
+	cs := ChangeSet{
+		Create: []Command{
+			{Name: "fresh", Description: "Fresh", Command: "echo fresh"},
+			{Name: "keep", Description: "Keep", Command: "echo again"},
+		},
+	}
+	if err := db.ApplyChangeSet(cs); err == nil {
+		t.Fatal("Expected error for creating an existing command")
+	}
+	if _, err := db.GetCommand("fresh"); err == nil {
+		t.Error("Failed change set should not have created 'fresh'")
+	}
+
+	cs = ChangeSet{
+		Create: []Command{{Name: "fresh", Description: "Fresh", Command: "echo fresh"}},
+		Update: []Command{{Name: "keep", Description: "Keep", Command: "echo kept"}},
+	}
+	if err := db.ApplyChangeSet(cs); err != nil {
+		t.Fatalf("ApplyChangeSet failed: %v", err)
+	}
+	keep, err := db.GetCommand("keep")
+	if err != nil {
+		t.Fatalf("GetCommand failed: %v", err)
+	}
+	if keep.Command != "echo kept" || keep.CreatedAt == "" {
+		t.Errorf("Update should change the command and keep its creation time, got %+v", keep)
+	}
+}
+
+func TestDiffCommandFields(t *testing.T) {
+	old := Command{Name: "test", Description: "Test", Command: "go test ./...", Cooldown: "1m"}
+	updated := Command{Name: "test", Description: "Test", Command: "go test -race ./...", Tags: []string{"ci"}}
+
+	changes := diffCommandFields(old, updated)
+	expected := []string{
+		`~ command: "go test ./..." -> "go test -race ./..."`,
+		`- cooldown: "1m"`,
+		`+ tags: ["ci"]`,
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Unexpected diff:\n got: %q\nwant: %q", changes, expected)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// RunCleanup executes the command's registered cleanup in dir
+func RunCleanup(command *Command, dir string) error {
+	if command.Cleanup == "" {
+		return fmt.Errorf("command '%s' has no cleanup registered", command.Name)
+	}
+
+	fmt.Printf("Running cleanup: %s\n", command.Cleanup)
+	cmd, _, err := newExecCmd(&Command{Command: command.Cleanup, Wsl: command.Wsl, PathPrepend: command.PathPrepend, RunAs: command.RunAs}, dir)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	s := startSpan("exec.cleanup", "name", command.Name, "command", command.Cleanup, "dir", dir)
+	logExec(cmd)
+	err = cmd.Run()
+	s.End(err)
+	if err != nil {
+		return fmt.Errorf("cleanup for '%s' failed: %v", command.Name, err)
+	}
+	return nil
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -15,50 +23,213 @@ func TestCLICommands(t *testing.T) {
 	// Build the binary for testing
 	binaryPath := buildTestBinary(t)
 	defer os.Remove(binaryPath)
-	
+
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "afvikle_cli_test_*")
 	if err != nil {
 		t.Fatalf("Failed to create temp directory: %v", err)
 	}
 	defer os.RemoveAll(tempDir)
-	
+
 	// Copy binary to temp directory to test database creation
 	testBinary := filepath.Join(tempDir, "afvikle"+filepath.Ext(binaryPath))
 	copyFile(t, binaryPath, testBinary)
-	
+
+	// Keep workspaces and the active workspace pointer out of the user's config
+	t.Setenv("AFV_CONFIG_DIR", filepath.Join(tempDir, "config"))
+	t.Setenv("AFV_WORKSPACE", "")
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(tempDir, "cache"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(tempDir, "data"))
+	t.Setenv("AFVIKLE_DB", "")
+
 	t.Run("Help Command", func(t *testing.T) {
 		testHelpCommand(t, testBinary)
 	})
-	
+
 	t.Run("Info Command Empty", func(t *testing.T) {
 		testInfoCommandEmpty(t, testBinary, tempDir)
 	})
-	
+
 	t.Run("List Command Empty", func(t *testing.T) {
 		testListCommandEmpty(t, testBinary)
 	})
-	
+
 	t.Run("Add Command", func(t *testing.T) {
 		testAddCommand(t, testBinary, tempDir)
 	})
-	
+
 	t.Run("List Command With Data", func(t *testing.T) {
 		testListCommandWithData(t, testBinary)
 	})
-	
+
 	t.Run("Run Command", func(t *testing.T) {
 		testRunCommand(t, testBinary)
 	})
-	
+
+	t.Run("Run Command Cooldown", func(t *testing.T) {
+		testRunCommandCooldown(t, testBinary)
+	})
+
+	t.Run("Run Confirm", func(t *testing.T) {
+		testRunConfirm(t, testBinary)
+	})
+
+	t.Run("Run Script", func(t *testing.T) {
+		testRunScript(t, testBinary)
+	})
+
+	t.Run("Run Steps", func(t *testing.T) {
+		testRunSteps(t, testBinary)
+	})
+
+	t.Run("Run Matrix", func(t *testing.T) {
+		testRunMatrix(t, testBinary)
+	})
+
+	t.Run("Run Repeat", func(t *testing.T) {
+		testRunRepeat(t, testBinary)
+	})
+
+	t.Run("Run Stdin File", func(t *testing.T) {
+		testRunStdinFile(t, testBinary)
+	})
+
+	t.Run("Run Timing", func(t *testing.T) {
+		testRunTiming(t, testBinary)
+	})
+
+	t.Run("Run Tag", func(t *testing.T) {
+		testRunTag(t, testBinary)
+	})
+
+	t.Run("Run Edit", func(t *testing.T) {
+		testRunEdit(t, testBinary)
+	})
+
+	t.Run("Run Sequence", func(t *testing.T) {
+		testRunSequence(t, testBinary)
+	})
+
+	t.Run("Service Commands", func(t *testing.T) {
+		testServiceCommands(t, testBinary)
+	})
+
+	t.Run("Cleanup Commands", func(t *testing.T) {
+		testCleanupCommands(t, testBinary)
+	})
+
+	t.Run("Exec Command", func(t *testing.T) {
+		testExecCommand(t, testBinary, tempDir)
+	})
+
+	t.Run("Export Import", func(t *testing.T) {
+		testExportImport(t, testBinary)
+	})
+
+	t.Run("Workspaces", func(t *testing.T) {
+		testWorkspaces(t, testBinary)
+	})
+
+	t.Run("Tag Commands", func(t *testing.T) {
+		testTagCommands(t, testBinary)
+	})
+
+	t.Run("Pin Commands", func(t *testing.T) {
+		testPinCommands(t, testBinary)
+	})
+
+	t.Run("Aliases", func(t *testing.T) {
+		testAliases(t, testBinary)
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		testTrash(t, testBinary)
+	})
+
+	t.Run("Import Dry Run", func(t *testing.T) {
+		testImportDryRun(t, testBinary)
+	})
+
+	t.Run("Backup Restore", func(t *testing.T) {
+		testBackupRestore(t, testBinary)
+	})
+
+	t.Run("Database Override", func(t *testing.T) {
+		testDatabaseOverride(t, testBinary, tempDir)
+	})
+
+	t.Run("Project File", func(t *testing.T) {
+		testProjectFile(t, testBinary, tempDir)
+	})
+
+	t.Run("Sync", func(t *testing.T) {
+		testSync(t, testBinary, tempDir)
+	})
+
+	t.Run("Import From Runners", func(t *testing.T) {
+		testImportFromRunners(t, testBinary, tempDir)
+	})
+
+	t.Run("Export Aliases", func(t *testing.T) {
+		testExportAliases(t, testBinary)
+	})
+
+	t.Run("Completion", func(t *testing.T) {
+		testCompletion(t, testBinary)
+	})
+
+	t.Run("Init", func(t *testing.T) {
+		testInit(t, testBinary, tempDir)
+	})
+
+	t.Run("TUI", func(t *testing.T) {
+		testTUI(t, testBinary)
+	})
+
+	t.Run("Shortcut", func(t *testing.T) {
+		testShortcut(t, testBinary)
+	})
+
+	t.Run("Verbose", func(t *testing.T) {
+		testVerbose(t, testBinary, tempDir)
+	})
+
+	t.Run("Concurrent Singleton", func(t *testing.T) {
+		testConcurrentSingleton(t, testBinary)
+	})
+
+	t.Run("Concurrent Exclusive", func(t *testing.T) {
+		testConcurrentExclusive(t, testBinary)
+	})
+
+	t.Run("ServeConcurrent", func(t *testing.T) {
+		testServeConcurrent(t, testBinary)
+	})
+
+	t.Run("Concurrent Parallel", func(t *testing.T) {
+		testConcurrentParallel(t, testBinary)
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		testNamespaces(t, testBinary)
+	})
+
+	t.Run("Show Command", func(t *testing.T) {
+		testShowCommand(t, testBinary)
+	})
+
+	t.Run("Update Command", func(t *testing.T) {
+		testUpdateCommand(t, testBinary)
+	})
+
 	t.Run("Delete Command", func(t *testing.T) {
 		testDeleteCommand(t, testBinary)
 	})
-	
+
 	t.Run("Delete All Commands", func(t *testing.T) {
 		testDeleteAllCommands(t, testBinary, tempDir)
 	})
-	
+
 	t.Run("Error Cases", func(t *testing.T) {
 		testErrorCases(t, testBinary)
 	})
@@ -70,22 +241,22 @@ func buildTestBinary(t *testing.T) string {
 	if os.Getenv("GOOS") == "windows" || (os.Getenv("GOOS") == "" && os.PathSeparator == '\\') {
 		binaryName += ".exe"
 	}
-	
+
 	tempDir, err := os.MkdirTemp("", "afvikle_build_*")
 	if err != nil {
 		t.Fatalf("Failed to create temp build directory: %v", err)
 	}
-	
+
 	binaryPath := filepath.Join(tempDir, binaryName)
-	
+
 	cmd := exec.Command("go", "build", "-o", binaryPath)
 	cmd.Dir = "." // Current directory
-	
+
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		t.Fatalf("Failed to build binary: %v\nOutput: %s", err, output)
 	}
-	
+
 	return binaryPath
 }
 
@@ -94,7 +265,7 @@ func copyFile(t *testing.T, src, dst string) {
 	if err != nil {
 		t.Fatalf("Failed to read source file: %v", err)
 	}
-	
+
 	err = os.WriteFile(dst, input, 0755)
 	if err != nil {
 		t.Fatalf("Failed to write destination file: %v", err)
@@ -103,23 +274,23 @@ func copyFile(t *testing.T, src, dst string) {
 
 func runCommand(t *testing.T, binary string, args ...string) (string, string, error) {
 	cmd := exec.Command(binary, args...)
-	
+
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	
+
 	err := cmd.Run()
 	return stdout.String(), stderr.String(), err
 }
 
 func runCommandWithInput(t *testing.T, binary string, input string, args ...string) (string, string, error) {
 	cmd := exec.Command(binary, args...)
-	
+
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	cmd.Stdin = strings.NewReader(input)
-	
+
 	err := cmd.Run()
 	return stdout.String(), stderr.String(), err
 }
@@ -129,11 +300,11 @@ func testHelpCommand(t *testing.T, binary string) {
 	if err != nil {
 		t.Errorf("Help command failed: %v\nStderr: %s", err, stderr)
 	}
-	
+
 	if !strings.Contains(stdout, "afv") {
 		t.Errorf("Help output should contain 'afv', got: %s", stdout)
 	}
-	
+
 	if !strings.Contains(stdout, "Available commands") {
 		t.Errorf("Help output should contain 'Available commands', got: %s", stdout)
 	}
@@ -144,12 +315,12 @@ func testInfoCommandEmpty(t *testing.T, binary string, tempDir string) {
 	if err != nil {
 		t.Errorf("Info command failed: %v\nStderr: %s", err, stderr)
 	}
-	
-	expectedPath := filepath.Join(tempDir, "afvikle.db")
+
+	expectedPath := filepath.Join(tempDir, "data", "afvikle", "afvikle.db")
 	if !strings.Contains(stdout, expectedPath) {
 		t.Errorf("Info output should contain database path '%s', got: %s", expectedPath, stdout)
 	}
-	
+
 	if !strings.Contains(stdout, "Total commands: 0") {
 		t.Errorf("Info output should show 0 commands, got: %s", stdout)
 	}
@@ -160,7 +331,7 @@ func testListCommandEmpty(t *testing.T, binary string) {
 	if err != nil {
 		t.Errorf("List command failed: %v\nStderr: %s", err, stderr)
 	}
-	
+
 	if !strings.Contains(stdout, "No commands found") {
 		t.Errorf("List output should indicate no commands found, got: %s", stdout)
 	}
@@ -172,31 +343,31 @@ func testAddCommand(t *testing.T, binary string, tempDir string) {
 	if err != nil {
 		t.Errorf("Add command failed: %v\nStderr: %s", err, stderr)
 	}
-	
+
 	if !strings.Contains(stdout, "Command 'test-cmd' added successfully") {
 		t.Errorf("Add output should confirm success, got: %s", stdout)
 	}
-	
+
 	// Test add command with working directory
 	stdout, stderr, err = runCommand(t, binary, "add", "--name", "test-cmd-dir", "--desc", "Test with dir", "--cmd", "echo hello", "--dir", tempDir)
 	if err != nil {
 		t.Errorf("Add command with dir failed: %v\nStderr: %s", err, stderr)
 	}
-	
+
 	if !strings.Contains(stdout, "Command 'test-cmd-dir' added successfully") {
 		t.Errorf("Add with dir output should confirm success, got: %s", stdout)
 	}
-	
+
 	if !strings.Contains(stdout, fmt.Sprintf("Working directory: %s", tempDir)) {
 		t.Errorf("Add with dir should show working directory, got: %s", stdout)
 	}
-	
+
 	// Test add command with current directory shortcut
 	stdout, stderr, err = runCommand(t, binary, "add", "--name", "test-cmd-current", "--desc", "Test current dir", "--cmd", "echo current", "--dir", ".")
 	if err != nil {
 		t.Errorf("Add command with current dir failed: %v\nStderr: %s", err, stderr)
 	}
-	
+
 	if !strings.Contains(stdout, "Command 'test-cmd-current' added successfully") {
 		t.Errorf("Add with current dir should confirm success, got: %s", stdout)
 	}
@@ -207,22 +378,39 @@ func testListCommandWithData(t *testing.T, binary string) {
 	if err != nil {
 		t.Errorf("List command failed: %v\nStderr: %s", err, stderr)
 	}
-	
+
 	if !strings.Contains(stdout, "Available commands:") {
 		t.Errorf("List output should show available commands, got: %s", stdout)
 	}
-	
+
 	if !strings.Contains(stdout, "test-cmd") {
 		t.Errorf("List output should contain test-cmd, got: %s", stdout)
 	}
-	
+
 	if !strings.Contains(stdout, "test-cmd-dir") {
 		t.Errorf("List output should contain test-cmd-dir, got: %s", stdout)
 	}
-	
-	// Check that working directory is shown
-	if !strings.Contains(stdout, "(dir:") {
-		t.Errorf("List output should show working directory for some commands, got: %s", stdout)
+
+	// The long listing shows the command line and working directory
+	stdout, _, _ = runCommand(t, binary, "list", "--long")
+	if !strings.Contains(stdout, "command:") || !strings.Contains(stdout, "dir:") {
+		t.Errorf("Long list output should show the command and working directory, got: %s", stdout)
+	}
+	if strings.Contains(stdout, "\033[") {
+		t.Errorf("List output should not be colored when not on a terminal, got: %q", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "list", "-o", "json")
+	var listed struct {
+		Commands []Command `json:"commands"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &listed); err != nil || len(listed.Commands) == 0 || listed.Commands[0].Command == "" {
+		t.Errorf("List -o json should print every command with its fields, got %v: %s", err, stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "--output", "yaml", "info")
+	if !strings.Contains(stdout, "workspace: ") || !strings.Contains(stdout, "commands: ") {
+		t.Errorf("Info --output yaml should print YAML, got: %s", stdout)
 	}
 }
 
@@ -232,121 +420,1408 @@ func testRunCommand(t *testing.T, binary string) {
 	if err != nil {
 		t.Errorf("Run command failed: %v\nStderr: %s", err, stderr)
 	}
-	
+
 	if !strings.Contains(stdout, "Executing: echo hello") {
 		t.Errorf("Run output should show executing command, got: %s", stdout)
 	}
-	
+
 	if !strings.Contains(stdout, "hello") {
 		t.Errorf("Run output should contain command output, got: %s", stdout)
 	}
-}
 
-func testDeleteCommand(t *testing.T, binary string) {
-	// Test deleting a specific command
-	stdout, stderr, err := runCommand(t, binary, "delete", "--name", "test-cmd")
+	// The name can also be given as a positional argument, before or after flags
+	stdout, stderr, err = runCommand(t, binary, "run", "test-cmd", "--force")
 	if err != nil {
-		t.Errorf("Delete command failed: %v\nStderr: %s", err, stderr)
+		t.Errorf("Run with positional name failed: %v\nStderr: %s", err, stderr)
 	}
-	
-	if !strings.Contains(stdout, "Command 'test-cmd' deleted successfully") {
-		t.Errorf("Delete output should confirm success, got: %s", stdout)
+	if !strings.Contains(stdout, "Executing: echo hello") {
+		t.Errorf("Run with positional name should execute the command, got: %s", stdout)
 	}
-	
-	// Verify command is gone - should show error message but exit with code 0
-	stdout, _, err = runCommand(t, binary, "run", "--name", "test-cmd")
-	// The command actually succeeds (exit code 0) but prints error message
+
+	// Arguments after -- are appended to the stored command line
+	stdout, stderr, err = runCommand(t, binary, "run", "test-cmd", "--force", "--", "big", "world")
 	if err != nil {
-		t.Errorf("Run deleted command should print error but exit 0, got error: %v", err)
+		t.Errorf("Run with extra arguments failed: %v\nStderr: %s", err, stderr)
 	}
-	
-	// Check that the error message indicates the command wasn't found
-	if !strings.Contains(stdout, "command 'test-cmd' not found") {
-		t.Errorf("Run deleted command should indicate command not found, got: %s", stdout)
+	if !strings.Contains(stdout, "Executing: echo hello big world") || !strings.Contains(stdout, "hello big world\n") {
+		t.Errorf("Run should append the extra arguments, got: %s", stdout)
 	}
-}
 
-func testDeleteAllCommands(t *testing.T, binary string, tempDir string) {
-	// First add some commands to delete
-	_, _, err := runCommand(t, binary, "add", "--name", "delete-test-1", "--cmd", "echo 1")
-	if err != nil {
-		t.Fatalf("Failed to add test command 1: %v", err)
+	if runtime.GOOS == "windows" {
+		return
 	}
-	_, _, err = runCommand(t, binary, "add", "--name", "delete-test-2", "--cmd", "echo 2")
+
+	// A failing child's exit status becomes afv's own
+	_, _, err = runCommand(t, binary, "add", "--name", "exit-cmd", "--cmd", "sh -c")
 	if err != nil {
-		t.Fatalf("Failed to add test command 2: %v", err)
+		t.Fatalf("Failed to add failing command: %v", err)
 	}
-	
-	// Test delete all with "no" response
-	stdout, stderr, err := runCommandWithInput(t, binary, "n\n", "delete", "--all")
-	if err != nil {
-		t.Errorf("Delete all with 'n' failed: %v\nStderr: %s", err, stderr)
+	defer runCommand(t, binary, "delete", "--name", "exit-cmd")
+	_, _, err = runCommand(t, binary, "run", "exit-cmd", "--", "exit 3")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("Expected afv to exit with the child's status 3, got %v", err)
 	}
-	
-	if !strings.Contains(stdout, "Operation cancelled") {
-		t.Errorf("Delete all with 'n' should be cancelled, got: %s", stdout)
+}
+
+func testRunSequence(t *testing.T, binary string) {
+	if runtime.GOOS == "windows" {
+		return
 	}
-	
-	// Test delete all with "yes" response
-	stdout, stderr, err = runCommandWithInput(t, binary, "y\n", "delete", "--all")
-	if err != nil {
-		t.Errorf("Delete all with 'y' failed: %v\nStderr: %s", err, stderr)
+
+	for name, line := range map[string]string{"seq-a": "echo step-a", "seq-fail": "false", "seq-b": "echo step-b"} {
+		if _, _, err := runCommand(t, binary, "add", "--name", name, "--cmd", line); err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		defer runCommand(t, binary, "delete", "--name", name)
 	}
-	
-	if !strings.Contains(stdout, "Successfully deleted") {
-		t.Errorf("Delete all with 'y' should confirm deletion, got: %s", stdout)
+
+	stdout, _, err := runCommand(t, binary, "run", "seq-a", "seq-b")
+	if err != nil || strings.Index(stdout, "step-a") > strings.Index(stdout, "step-b") || !strings.Contains(stdout, "step-a") {
+		t.Errorf("Expected both commands to run in order, got %v: %s", err, stdout)
 	}
-	
-	// Verify all commands are gone
-	stdout, stderr, err = runCommand(t, binary, "list")
+
+	// The run stops at the first failure by default
+	stdout, _, err = runCommand(t, binary, "run", "seq-a", "seq-fail", "seq-b")
+	if err == nil || strings.Contains(stdout, "step-b") {
+		t.Errorf("Expected the run to stop after seq-fail, got %v: %s", err, stdout)
+	}
+
+	stdout, _, err = runCommand(t, binary, "run", "--keep-going", "seq-fail", "seq-b")
+	if err == nil || !strings.Contains(stdout, "step-b") {
+		t.Errorf("Expected --keep-going to run seq-b and still fail, got %v: %s", err, stdout)
+	}
+
+	_, _, err = runCommand(t, binary, "add", "--name", "hooked", "--cmd", "echo main-step", "--pre-hook", "echo pre-step", "--post-hook", "echo post-step")
 	if err != nil {
-		t.Errorf("List after delete all failed: %v\nStderr: %s", err, stderr)
+		t.Fatalf("Failed to add hooked command: %v", err)
 	}
-	
-	if !strings.Contains(stdout, "No commands found") {
-		t.Errorf("List after delete all should show no commands, got: %s", stdout)
+	defer runCommand(t, binary, "delete", "--name", "hooked")
+	stdout, _, err = runCommand(t, binary, "run", "hooked")
+	pre, main, post := strings.Index(stdout, "\npre-step\n"), strings.Index(stdout, "\nmain-step\n"), strings.Index(stdout, "\npost-step\n")
+	if err != nil || pre < 0 || !(pre < main && main < post) {
+		t.Errorf("Expected the hooks around the command, got %v: %s", err, stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "run", "--no-hooks", "hooked")
+	if strings.Contains(stdout, "pre-step") || strings.Contains(stdout, "post-step") {
+		t.Errorf("Expected --no-hooks to skip the hooks, got: %s", stdout)
+	}
+
+	stdout, _, err = runCommand(t, binary, "run", "--parallel", "seq-a", "seq-b")
+	if err != nil || !strings.Contains(stdout, "seq-a | step-a") || !strings.Contains(stdout, "seq-b | step-b") {
+		t.Errorf("Expected prefixed output of both commands, got %v: %s", err, stdout)
 	}
 }
 
-func testErrorCases(t *testing.T, binary string) {
-	// Test add without required fields - clir prints error but exits with code 0
-	stdout, _, err := runCommand(t, binary, "add")
+func testRunConfirm(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "confirm-cmd", "--cmd", "echo destroyed", "--confirm")
 	if err != nil {
-		t.Errorf("Add without arguments should print error but exit 0, got error: %v", err)
+		t.Fatalf("Failed to add confirm command: %v", err)
 	}
-	
-	// Check error message
-	if !strings.Contains(stdout, "name is required") {
-		t.Errorf("Add without name should indicate name is required, got: %s", stdout)
+	defer runCommand(t, binary, "delete", "--name", "confirm-cmd")
+
+	stdout, _, _ := runCommandWithInput(t, binary, "n\n", "run", "confirm-cmd")
+	if !strings.Contains(stdout, "Are you sure?") || !strings.Contains(stdout, "cancelled") || strings.Contains(stdout, "\ndestroyed") {
+		t.Errorf("Expected the run to be cancelled, got: %s", stdout)
 	}
-	
-	// Test run non-existent command
-	stdout, _, err = runCommand(t, binary, "run", "--name", "non-existent")
+
+	stdout, _, _ = runCommandWithInput(t, binary, "y\n", "run", "confirm-cmd")
+	if !strings.Contains(stdout, "  echo destroyed") || !strings.Contains(stdout, "\ndestroyed") {
+		t.Errorf("Expected the confirmed run to show the command line and execute, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "--force", "confirm-cmd")
+	if strings.Contains(stdout, "Are you sure?") || !strings.Contains(stdout, "\ndestroyed") {
+		t.Errorf("Expected --force to run without asking, got: %s", stdout)
+	}
+}
+
+func testRunScript(t *testing.T, binary string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("script uses sh syntax")
+	}
+	script := "name=world\necho \"hello $name\"\necho \"args: $*\"\n"
+	_, stderr, err := runCommandWithInput(t, binary, script, "add", "--name", "script-cmd", "--script-file", "-")
 	if err != nil {
-		t.Errorf("Run non-existent command should print error but exit 0, got error: %v", err)
+		t.Fatalf("Failed to add script command: %v\n%s", err, stderr)
 	}
-	
-	if !strings.Contains(stdout, "command 'non-existent' not found") {
-		t.Errorf("Run non-existent command should indicate command not found, got: %s", stdout)
+	defer runCommand(t, binary, "delete", "--name", "script-cmd")
+
+	stdout, _, err := runCommand(t, binary, "run", "script-cmd", "--", "a", "b")
+	if err != nil || !strings.Contains(stdout, "hello world") || !strings.Contains(stdout, "args: a b") {
+		t.Errorf("Expected the script to run with its arguments, got: %v\n%s", err, stdout)
 	}
-	
-	// Test delete non-existent command
-	stdout, _, err = runCommand(t, binary, "delete", "--name", "non-existent")
+	// The file the script ran from is removed afterwards
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		if entries, _ := os.ReadDir(filepath.Join(cacheDir, "afv", "scripts")); len(entries) > 0 {
+			t.Errorf("Expected no script files left behind, found %d", len(entries))
+		}
+	}
+
+	stdout, _, _ = runCommand(t, binary, "help", "script-cmd")
+	if !strings.Contains(stdout, "Script:\n  name=world\n") {
+		t.Errorf("Expected the runbook to show the script, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "add", "--name", "script-both", "--cmd", "true", "--script-file", "-")
+	if !strings.Contains(stdout, "use only one of") {
+		t.Errorf("Expected --cmd and --script-file to be rejected together, got: %s", stdout)
+	}
+}
+
+func testRunSteps(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "steps-cmd", "--step", "echo first-step", "--step", "false", "--step", "echo third-step")
 	if err != nil {
-		t.Errorf("Delete non-existent command should print error but exit 0, got error: %v", err)
+		t.Fatalf("Failed to add step command: %v", err)
 	}
-	
-	if !strings.Contains(stdout, "command 'non-existent' not found") {
-		t.Errorf("Delete non-existent command should indicate command not found, got: %s", stdout)
+	defer runCommand(t, binary, "delete", "--name", "steps-cmd")
+
+	stdout, _, err := runCommand(t, binary, "run", "steps-cmd")
+	if err == nil || !strings.Contains(stdout, "[1/3] echo first-step") || !strings.Contains(stdout, "\nfirst-step") || strings.Contains(stdout, "\nthird-step") {
+		t.Errorf("Expected the run to stop at the failing step, got: %v\n%s", err, stdout)
 	}
-	
-	// Test delete without arguments
-	stdout, _, err = runCommand(t, binary, "delete")
+	if !strings.Contains(stdout, "failed   2. false") || !strings.Contains(stdout, "skipped  3. echo third-step") {
+		t.Errorf("Expected the status of every step, got: %s", stdout)
+	}
+
+	stdout, _, err = runCommand(t, binary, "run", "steps-cmd", "--from-step", "3")
+	if err != nil || !strings.Contains(stdout, "\nthird-step") || strings.Contains(stdout, "\nfirst-step") {
+		t.Errorf("Expected --from-step to resume at step 3, got: %v\n%s", err, stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "steps-cmd", "--only-step", "1")
+	if !strings.Contains(stdout, "\nfirst-step") || strings.Contains(stdout, "[2/3]") {
+		t.Errorf("Expected --only-step to run step 1 only, got: %s", stdout)
+	}
+}
+
+func testRunMatrix(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "matrix-cmd", "--cmd", "echo cell-{{os}}-{{arch}}")
 	if err != nil {
-		t.Errorf("Delete without arguments should print error but exit 0, got error: %v", err)
+		t.Fatalf("Failed to add matrix command: %v", err)
 	}
-	
-	if !strings.Contains(stdout, "either --name or --all is required") {
-		t.Errorf("Delete without arguments should indicate name or all is required, got: %s", stdout)
+	defer runCommand(t, binary, "delete", "--name", "matrix-cmd")
+
+	stdout, _, err := runCommand(t, binary, "run", "matrix-cmd", "--matrix", "os=linux,darwin", "--matrix", "arch=amd64,arm64")
+	if err != nil {
+		t.Fatalf("Matrix run failed: %v\n%s", err, stdout)
+	}
+	for _, cell := range []string{"cell-linux-amd64", "cell-linux-arm64", "cell-darwin-amd64", "cell-darwin-arm64"} {
+		if !strings.Contains(stdout, "\n"+cell) {
+			t.Errorf("Expected output of %s, got: %s", cell, stdout)
+		}
+	}
+	if !strings.Contains(stdout, "ok       os=darwin arch=arm64") {
+		t.Errorf("Expected a summary per cell, got: %s", stdout)
+	}
+}
+
+func testImportDryRun(t *testing.T, binary string) {
+	doc := `version: 1
+commands:
+  - name: preview-ok
+    command: echo ok
+  - name: preview-bad
+    description: no command line
+`
+	stdout, _, _ := runCommandWithInput(t, binary, doc, "import", "--dry-run", "-")
+	if !strings.Contains(stdout, "Would import 'preview-ok'.") || !strings.Contains(stdout, "Nothing was changed.") {
+		t.Errorf("Dry run should preview the import, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "Failed to import entry 2 (line 5) 'preview-bad': command is required") {
+		t.Errorf("Dry run should report the failing entry with its line, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list")
+	if strings.Contains(stdout, "preview-ok") {
+		t.Errorf("Dry run should not store anything, got: %s", stdout)
+	}
+}
+
+func testBackupRestore(t *testing.T, binary string) {
+	stdout, _, _ := runCommand(t, binary, "backup", "--keep", "2")
+	if !strings.Contains(stdout, "Backup written to") {
+		t.Fatalf("Backup should report its path, got: %s", stdout)
+	}
+	backup := strings.TrimSpace(strings.TrimPrefix(stdout, "Backup written to"))
+
+	if _, _, err := runCommand(t, binary, "add", "--name", "after-backup", "--cmd", "true"); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	stdout, _, _ = runCommandWithInput(t, binary, "n\n", "restore", filepath.Base(backup))
+	if !strings.Contains(stdout, "Operation cancelled.") {
+		t.Errorf("Restore should ask for confirmation, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "restore", "--yes", filepath.Base(backup))
+	if !strings.Contains(stdout, "Database restored from") || !strings.Contains(stdout, "previous database was backed up") {
+		t.Errorf("Restore should report both backups, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list")
+	if strings.Contains(stdout, "after-backup") {
+		t.Errorf("Restored database should not hold later commands, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "backup", "--list")
+	if strings.Count(stdout, ".db") != 2 {
+		t.Errorf("Expected two backups, got: %s", stdout)
+	}
+}
+
+func testDatabaseOverride(t *testing.T, binary string, tempDir string) {
+	other := filepath.Join(tempDir, "other", "afvikle.db")
+	stdout, _, _ := runCommand(t, binary, "--db", other, "add", "--name", "other-db-cmd", "--cmd", "true")
+	if !strings.Contains(stdout, "added successfully") {
+		t.Fatalf("Add with --db failed: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list", "--db", other)
+	if !strings.Contains(stdout, "other-db-cmd") || strings.Contains(stdout, "test-cmd") {
+		t.Errorf("List with --db should only show the other database, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list")
+	if strings.Contains(stdout, "other-db-cmd") {
+		t.Errorf("The default database should not see commands added with --db, got: %s", stdout)
+	}
+
+	t.Setenv("AFVIKLE_DB", other)
+	stdout, _, _ = runCommand(t, binary, "info")
+	if !strings.Contains(stdout, "Database location: "+other) || !strings.Contains(stdout, "Total commands: 1") {
+		t.Errorf("AFVIKLE_DB should select the database, got: %s", stdout)
+	}
+}
+
+func testProjectFile(t *testing.T, binary string, tempDir string) {
+	project := filepath.Join(tempDir, "project")
+	nested := filepath.Join(project, "src")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+	doc := "commands:\n  - name: project-hello\n    command: echo hello from the project\n"
+	if err := os.WriteFile(filepath.Join(project, ".afvikle.yaml"), []byte(doc), 0644); err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+	runIn := func(args ...string) string {
+		cmd := exec.Command(binary, args...)
+		cmd.Dir = nested
+		out, _ := cmd.Output()
+		return string(out)
+	}
+
+	stdout := runIn("list")
+	if !strings.Contains(stdout, "project-hello") || !strings.Contains(stdout, "[project]") {
+		t.Errorf("List inside the project should show its commands, got: %s", stdout)
+	}
+	stdout = runIn("run", "project-hello")
+	if !strings.Contains(stdout, "hello from the project") {
+		t.Errorf("Expected the project command to run, got: %s", stdout)
+	}
+	stdout = runIn("delete", "--name", "project-hello")
+	if !strings.Contains(stdout, "change it there") {
+		t.Errorf("Deleting a project command should fail, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list")
+	if strings.Contains(stdout, "project-hello") {
+		t.Errorf("Project commands should not show outside the project, got: %s", stdout)
+	}
+}
+
+func testSync(t *testing.T, binary string, tempDir string) {
+	stdout, _, _ := runCommand(t, binary, "sync")
+	if !strings.Contains(stdout, "no repository to sync with") {
+		t.Errorf("Sync without a repository should explain how to set one, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "sync", "--repo", tempDir)
+	if !strings.Contains(stdout, "is not a git repository") {
+		t.Errorf("Sync with a plain directory should fail, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "sync", "--remote", "http://afv.example.com")
+	if !strings.Contains(stdout, "refusing to send the sync token over http") {
+		t.Errorf("Sync should refuse plain http to other hosts, got: %s", stdout)
+	}
+
+	t.Setenv("AFVIKLE_SYNC_TOKEN", "")
+	stdout, _, _ = runCommand(t, binary, "serve", "--addr", "127.0.0.1:0")
+	if !strings.Contains(stdout, "set AFVIKLE_SYNC_TOKEN") {
+		t.Errorf("Serve without a token should refuse to start, got: %s", stdout)
+	}
+}
+
+func testImportFromRunners(t *testing.T, binary string, tempDir string) {
+	dir := filepath.Join(tempDir, "runners")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	justfile := filepath.Join(dir, "justfile")
+	if err := os.WriteFile(justfile, []byte("# Say hello\njust-hello:\n    echo hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write justfile: %v", err)
+	}
+	taskfile := filepath.Join(dir, "Taskfile.yml")
+	if err := os.WriteFile(taskfile, []byte("version: '3'\ntasks:\n  task-hello:\n    desc: Say hello\n    cmds: [echo hello]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Taskfile: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "just-hello")
+	defer runCommand(t, binary, "delete", "--name", "task-hello")
+
+	stdout, _, _ := runCommand(t, binary, "import", "--from-just", justfile, "--dry-run")
+	if !strings.Contains(stdout, "Would import 'just-hello'.") {
+		t.Errorf("Dry run should preview the recipes, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "import", "--from-just", justfile)
+	if !strings.Contains(stdout, "Imported 1 command(s)") {
+		t.Errorf("Failed to import the justfile: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "import", "--from-taskfile", taskfile)
+	if !strings.Contains(stdout, "Imported 1 command(s)") {
+		t.Errorf("Failed to import the Taskfile: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "show", "task-hello")
+	if !strings.Contains(stdout, "task task-hello") || !strings.Contains(stdout, "Say hello") || !strings.Contains(stdout, dir) {
+		t.Errorf("Expected the task with its description and directory, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "import", "--from-just", "--from-taskfile")
+	if !strings.Contains(stdout, "use only one of") {
+		t.Errorf("Expected an error for both flags, got: %s", stdout)
+	}
+}
+
+func testExportAliases(t *testing.T, binary string) {
+	if _, _, err := runCommand(t, binary, "add", "--name", "alias-export", "--cmd", "echo exported"); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "alias-export")
+
+	stdout, _, _ := runCommand(t, binary, "export", "--as-aliases", "--shell", "bash")
+	if !strings.Contains(stdout, "alias alias-export='afv run alias-export --'") {
+		t.Errorf("Expected a bash alias for the command, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "export", "--as-aliases", "--functions", "--shell", "fish", "--name", "alias-export")
+	if !strings.Contains(stdout, "function alias-export\n    afv run alias-export -- $argv\nend") {
+		t.Errorf("Expected a fish function for the command, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "export", "--functions")
+	if !strings.Contains(stdout, "--functions requires --as-aliases") {
+		t.Errorf("Expected --functions to need --as-aliases, got: %s", stdout)
+	}
+}
+
+func testCompletion(t *testing.T, binary string) {
+	if _, _, err := runCommand(t, binary, "add", "--name", "complete-me", "--cmd", "echo completed"); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "complete-me")
+
+	stdout, _, _ := runCommand(t, binary, "__complete", "--", "afv run complete-")
+	if strings.TrimSpace(stdout) != "complete-me" {
+		t.Errorf("Expected the stored command as the only candidate, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "__complete", "--", "afv sh")
+	if !strings.Contains(stdout, "show\n") || !strings.Contains(stdout, "shell-init\n") || strings.Contains(stdout, "shell-eval") {
+		t.Errorf("Expected the visible subcommands, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "__complete", "--", "afv delete --na")
+	if !strings.Contains(stdout, "--name\n") || !strings.Contains(stdout, "--namespace\n") {
+		t.Errorf("Expected the flags of delete, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "completion", "zsh")
+	if !strings.Contains(stdout, "compdef _afv afv") {
+		t.Errorf("Expected the zsh completion script, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "completion", "tcsh")
+	if !strings.Contains(stdout, "unsupported shell 'tcsh'") {
+		t.Errorf("Expected an error for an unsupported shell, got: %s", stdout)
+	}
+}
+
+func testInit(t *testing.T, binary string, tempDir string) {
+	stdout, _, _ := runCommand(t, binary, "init", "zsh")
+	if !strings.Contains(stdout, "bindkey '^X^A' __afv_widget") || !strings.Contains(stdout, "compdef _afv afv") {
+		t.Errorf("Expected the widget and completions in the zsh snippet, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "init", "bash", "--project")
+	if !strings.Contains(stdout, "afv __project-hook bash") {
+		t.Errorf("Expected the project hook with --project, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "pick")
+	if !strings.Contains(stdout, "the picker needs a terminal") {
+		t.Errorf("Expected pick to need a terminal, got: %s", stdout)
+	}
+
+	project := filepath.Join(tempDir, "init-project")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+	doc := "commands:\n  - name: init-hello\n    command: echo hello\n"
+	if err := os.WriteFile(filepath.Join(project, ".afvikle.yaml"), []byte(doc), 0644); err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+	cmd := exec.Command(binary, "__project-hook", "bash")
+	cmd.Dir = project
+	out, _ := cmd.Output()
+	if !strings.Contains(string(out), "init-hello() { afv run init-hello -- \"$@\"; }") {
+		t.Errorf("Expected a function for the project command, got: %s", out)
+	}
+}
+
+func testTUI(t *testing.T, binary string) {
+	stdout, _, _ := runCommand(t, binary, "tui")
+	if !strings.Contains(stdout, "afv tui needs a terminal") {
+		t.Errorf("Expected tui to need a terminal, got: %s", stdout)
+	}
+}
+
+func testShortcut(t *testing.T, binary string) {
+	runCommand(t, binary, "add", "--name", "shortcut-echo", "--desc", "Echo", "--cmd", "echo")
+	defer runCommand(t, binary, "delete", "--name", "shortcut-echo")
+
+	// Arguments after the name go to the command, even afv's own flags
+	stdout, _, err := runCommand(t, binary, "shortcut-echo", "-o", "out", "a")
+	if err != nil || !strings.Contains(stdout, "-o out a") {
+		t.Errorf("Expected afv NAME to run the command with its arguments, got: %s (%v)", stdout, err)
+	}
+	stdout, _, _ = runCommand(t, binary, "--output", "json", "shortcut-echo", "--", "b")
+	if !strings.Contains(stdout, "b") {
+		t.Errorf("Expected global flags before the name to be accepted, got: %s", stdout)
+	}
+
+	// afv's own commands win over stored ones of the same name
+	stdout, _, _ = runCommand(t, binary, "add", "--name", "info", "--desc", "Shadowed", "--cmd", "echo stored info")
+	defer runCommand(t, binary, "delete", "--name", "info")
+	if !strings.Contains(stdout, "run this one with 'afv run info'") {
+		t.Errorf("Expected a warning for a name used by afv, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "info")
+	if !strings.Contains(stdout, "Database location:") || strings.Contains(stdout, "stored info") {
+		t.Errorf("Expected afv info to show the database, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "run", "info")
+	if !strings.Contains(stdout, "stored info") {
+		t.Errorf("Expected afv run info to run the stored command, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "lst")
+	if !strings.Contains(stdout, "Did you mean 'afv list'?") {
+		t.Errorf("Expected a suggestion for a mistyped command, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "shortcut-ecko")
+	if !strings.Contains(stdout, "shortcut-echo") {
+		t.Errorf("Expected a suggestion for a mistyped stored command, got: %s", stdout)
+	}
+
+	// builtinCommands has to follow the commands afv defines
+	stdout, _, _ = runCommand(t, binary, "--help")
+	commands, _ := parseHelp(stdout)
+	for _, name := range commands {
+		if !slices.Contains(builtinCommands, name) {
+			t.Errorf("Command %q is missing from builtinCommands", name)
+		}
+	}
+	for _, name := range builtinCommands {
+		if !slices.Contains(commands, name) && !strings.HasPrefix(name, "__") && name != "shell-eval" {
+			t.Errorf("builtinCommands lists %q, which afv does not define", name)
+		}
+	}
+}
+
+func testVerbose(t *testing.T, binary string, tempDir string) {
+	runCommand(t, binary, "add", "--name", "verbose-echo", "--desc", "Echo", "--cmd", "echo", "--dir", tempDir, "--env", "VERBOSE_SECRET=hidden")
+	defer runCommand(t, binary, "delete", "--name", "verbose-echo")
+
+	stdout, stderr, err := runCommand(t, binary, "--verbose", "run", "verbose-echo", "--", "a")
+	if err != nil || !strings.Contains(stdout, "a") {
+		t.Fatalf("Verbose run failed: %v\n%s", err, stdout)
+	}
+	for _, want := range []string{"msg=database path=", `msg="working directory" dir=` + tempDir + " from=stored", "keys=[VERBOSE_SECRET]", `argv="[echo a]"`} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("Expected %q in the verbose log, got: %s", want, stderr)
+		}
+	}
+	if strings.Contains(stderr, "hidden") || strings.Contains(stderr, "level=DEBUG") {
+		t.Errorf("Expected no values or debug records in the verbose log, got: %s", stderr)
+	}
+
+	_, stderr, _ = runCommand(t, binary, "--debug", "run", "verbose-echo")
+	if !strings.Contains(stderr, `level=DEBUG msg="afv start"`) {
+		t.Errorf("Expected debug records with --debug, got: %s", stderr)
+	}
+	if _, stderr, _ = runCommand(t, binary, "run", "verbose-echo"); stderr != "" {
+		t.Errorf("Expected no log without --verbose, got: %s", stderr)
+	}
+}
+
+// startRun starts afv in the background and waits until the command it runs
+// has started
+func startRun(t *testing.T, binary string, args ...string) *exec.Cmd {
+	return startAfv(t, binary, "Executing:", args...)
+}
+
+// startAfv starts afv in the background and waits until it prints a line
+// containing ready
+func startAfv(t *testing.T, binary, ready string, args ...string) *exec.Cmd {
+	cmd := exec.Command(binary, args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start afv: %v", err)
+	}
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), ready) {
+			go io.Copy(io.Discard, out)
+			return cmd
+		}
+	}
+	cmd.Wait()
+	t.Fatalf("afv %s did not print %q", strings.Join(args, " "), ready)
+	return nil
+}
+
+func testServeConcurrent(t *testing.T, binary string) {
+	t.Setenv("AFVIKLE_SYNC_TOKEN", "secret")
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	server := startAfv(t, binary, "Serving", "serve", "--addr", addr)
+	defer func() {
+		server.Process.Kill()
+		server.Wait()
+	}()
+
+	// The server only holds the database while it answers a request
+	stdout, _, err := runCommand(t, binary, "add", "--name", "served-cmd", "--desc", "Served", "--cmd", "echo served")
+	if err != nil || !strings.Contains(stdout, "added successfully") {
+		t.Fatalf("Expected afv add to work while serving, got: %s (%v)", stdout, err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "served-cmd")
+	stdout, _, err = runCommand(t, binary, "run", "served-cmd")
+	if err != nil || !strings.Contains(stdout, "served") {
+		t.Errorf("Expected afv run to work while serving, got: %s (%v)", stdout, err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/v1/commands", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request to afv serve failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "served-cmd") {
+		t.Errorf("Expected the server to serve the new command, got %s: %s", resp.Status, body)
+	}
+
+	stdout, _, err = runCommand(t, binary, "list")
+	if err != nil || !strings.Contains(stdout, "served-cmd") {
+		t.Errorf("Expected afv list to work after a request, got: %s (%v)", stdout, err)
+	}
+}
+
+func testConcurrentSingleton(t *testing.T, binary string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available on Windows")
+	}
+	runCommand(t, binary, "add", "--name", "conc-single", "--desc", "Singleton", "--cmd", "sleep 2", "--singleton")
+	defer runCommand(t, binary, "delete", "--name", "conc-single")
+
+	first := startRun(t, binary, "run", "conc-single")
+	defer first.Wait()
+
+	// Other afv processes can use the database while the command runs
+	stdout, _, err := runCommand(t, binary, "list")
+	if err != nil || !strings.Contains(stdout, "conc-single") {
+		t.Errorf("Expected afv list to work during a run, got: %s (%v)", stdout, err)
+	}
+	stdout, _, _ = runCommand(t, binary, "run", "conc-single")
+	if !strings.Contains(stdout, "command 'conc-single' is already running since") || !strings.Contains(stdout, fmt.Sprintf("(pid %d)", first.Process.Pid)) {
+		t.Errorf("Expected the second run to be refused, got: %s", stdout)
+	}
+}
+
+func testConcurrentExclusive(t *testing.T, binary string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available on Windows")
+	}
+	runCommand(t, binary, "add", "--name", "conc-excl", "--desc", "Exclusive", "--cmd", "sleep 1")
+	defer runCommand(t, binary, "delete", "--name", "conc-excl")
+
+	first := startRun(t, binary, "run", "conc-excl", "--exclusive")
+	stdout, _, _ := runCommand(t, binary, "run", "conc-excl", "--exclusive")
+	if !strings.Contains(stdout, "command 'conc-excl' is already running since") || !strings.Contains(stdout, "use --wait") {
+		t.Errorf("Expected the second exclusive run to be refused, got: %s", stdout)
+	}
+
+	// Waiting leaves the database to the running instance, which records
+	// its run once it finishes
+	stdout, _, err := runCommand(t, binary, "run", "conc-excl", "--exclusive", "--wait")
+	if err != nil || !strings.Contains(stdout, "Waiting for running instance of 'conc-excl'") || !strings.Contains(stdout, "Executing: sleep 1") {
+		t.Errorf("Expected the second run to wait for the first, got: %s (%v)", stdout, err)
+	}
+	if err := first.Wait(); err != nil {
+		t.Errorf("Expected the first run to succeed, got %v", err)
+	}
+	stdout, _, _ = runCommand(t, binary, "stats", "conc-excl")
+	if !strings.Contains(stdout, "Runs: 2 (2 succeeded, 0 failed)") {
+		t.Errorf("Expected both runs in the history, got: %s", stdout)
+	}
+}
+
+func testConcurrentParallel(t *testing.T, binary string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available on Windows")
+	}
+	runCommand(t, binary, "add", "--name", "conc-par-a", "--desc", "Parallel", "--cmd", "sleep 2", "--singleton")
+	runCommand(t, binary, "add", "--name", "conc-par-b", "--desc", "Parallel", "--cmd", "sleep 2")
+	defer runCommand(t, binary, "delete", "--name", "conc-par-a")
+	defer runCommand(t, binary, "delete", "--name", "conc-par-b")
+
+	batch := startRun(t, binary, "run", "--parallel", "conc-par-a", "conc-par-b")
+
+	// The batch leaves the database to other afv processes while it runs
+	for _, args := range [][]string{{"list"}, {"jobs"}} {
+		if stdout, _, err := runCommand(t, binary, args...); err != nil {
+			t.Errorf("Expected afv %s to work during a parallel run, got: %s (%v)", args[0], stdout, err)
+		}
+	}
+	stdout, _, _ := runCommand(t, binary, "run", "conc-par-a")
+	if !strings.Contains(stdout, "command 'conc-par-a' is already running since") {
+		t.Errorf("Expected a singleton in a parallel run to be refused elsewhere, got: %s", stdout)
+	}
+
+	if err := batch.Wait(); err != nil {
+		t.Errorf("Expected the parallel run to succeed, got %v", err)
+	}
+	stdout, _, _ = runCommand(t, binary, "stats", "conc-par-b")
+	if !strings.Contains(stdout, "Runs: 1 (1 succeeded, 0 failed)") {
+		t.Errorf("Expected the parallel run in the history, got: %s", stdout)
+	}
+}
+
+func testRunTag(t *testing.T, binary string) {
+	doc := `version: 1
+commands:
+  - name: tag-build
+    command: "false"
+    tags: [ci]
+  - name: tag-lint
+    command: echo linting
+    tags: [ci]
+`
+	stdout, _, _ := runCommandWithInput(t, binary, doc, "import", "-")
+	if !strings.Contains(stdout, "Imported 2 command(s)") {
+		t.Fatalf("Failed to import tagged commands: %s", stdout)
+	}
+	defer runCommand(t, binary, "delete", "--name", "tag-lint")
+	defer runCommand(t, binary, "delete", "--name", "tag-build")
+
+	stdout, _, err := runCommand(t, binary, "run", "--tag", "ci", "--keep-going")
+	if err == nil {
+		t.Errorf("Run with a failing tagged command should fail, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "linting") || !strings.Contains(stdout, "Summary:") {
+		t.Errorf("Expected output and a summary, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "tag-lint   ok") || !strings.Contains(stdout, "tag-build  failed       1") {
+		t.Errorf("Expected a row per tagged command, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "--tag", "ci")
+	if !strings.Contains(stdout, "tag-lint   skipped") {
+		t.Errorf("Commands after a failure should be skipped without --keep-going, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "--tag", "ci", "--parallel")
+	if !strings.Contains(stdout, "tag-lint   ok") || !strings.Contains(stdout, "tag-build  failed") {
+		t.Errorf("Expected a summary of the parallel run, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "--tag", "no-such-tag")
+	if !strings.Contains(stdout, "no commands tagged 'no-such-tag'") {
+		t.Errorf("Expected an error for an unknown tag, got: %s", stdout)
+	}
+}
+
+func testRunEdit(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "edit-once", "--cmd", "echo before")
+	if err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "edit-once")
+
+	// The editor is a non-interactive substitution on the temporary file
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i s/before/after/")
+	stdout, _, err := runCommand(t, binary, "run", "edit-once", "--edit")
+	if err != nil {
+		t.Fatalf("Run with --edit failed: %v\n%s", err, stdout)
+	}
+	if !strings.Contains(stdout, "Executing: echo after") || !strings.Contains(stdout, "\nafter\n") {
+		t.Errorf("Expected the edited command to run, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "edit-once")
+	if !strings.Contains(stdout, "\nbefore\n") {
+		t.Errorf("The stored command should be unchanged, got: %s", stdout)
+	}
+
+	t.Setenv("EDITOR", "sed -i d")
+	stdout, _, _ = runCommand(t, binary, "run", "edit-once", "--edit")
+	if !strings.Contains(stdout, "edited command is empty") {
+		t.Errorf("An emptied command should not run, got: %s", stdout)
+	}
+}
+
+func testRunRepeat(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "repeat-ok", "--cmd", "echo repeated", "--cooldown", "1h")
+	if err != nil {
+		t.Fatalf("Failed to add repeat command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "repeat-ok")
+	_, _, err = runCommand(t, binary, "add", "--name", "repeat-fail", "--cmd", "false")
+	if err != nil {
+		t.Fatalf("Failed to add repeat command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "repeat-fail")
+
+	// The cooldown only applies to the first run
+	stdout, _, err := runCommand(t, binary, "run", "repeat-ok", "--repeat", "3", "--interval", "10ms")
+	if err != nil || strings.Count(stdout, "\nrepeated") != 3 || !strings.Contains(stdout, "Repeat: 3 runs, 3 ok, 0 failed") {
+		t.Errorf("Expected three successful runs, got: %v\n%s", err, stdout)
+	}
+
+	stdout, _, err = runCommand(t, binary, "run", "repeat-fail", "--repeat", "3")
+	if err == nil || !strings.Contains(stdout, "Repeat: 3 runs, 0 ok, 3 failed (runs 1, 2, 3)") {
+		t.Errorf("Expected three failed runs, got: %v\n%s", err, stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "repeat-fail", "--until-fail")
+	if !strings.Contains(stdout, "Repeat: 1 run, 0 ok, 1 failed (run 1)") {
+		t.Errorf("Expected --until-fail to stop at the first failure, got: %s", stdout)
+	}
+}
+
+func testRunStdinFile(t *testing.T, binary string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses cat")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "query.sql"), []byte("select stored;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.sql"), []byte("select other;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := runCommand(t, binary, "add", "--name", "stdin-cmd", "--cmd", "cat", "--dir", dir, "--stdin-file", "query.sql")
+	if err != nil {
+		t.Fatalf("Failed to add stdin command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "stdin-cmd")
+
+	stdout, _, err := runCommandWithInput(t, binary, "from terminal\n", "run", "stdin-cmd")
+	if err != nil || !strings.Contains(stdout, "select stored;") || strings.Contains(stdout, "from terminal") {
+		t.Errorf("Expected the stored file as input, got: %v\n%s", err, stdout)
+	}
+
+	stdout, _, err = runCommand(t, binary, "run", "stdin-cmd", "--stdin-file", "other.sql")
+	if err != nil || !strings.Contains(stdout, "select other;") {
+		t.Errorf("Expected --stdin-file to override the stored file, got: %v\n%s", err, stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "stdin-cmd", "--stdin-file", "missing.sql")
+	if !strings.Contains(stdout, "failed to open stdin file") {
+		t.Errorf("Expected an error for a missing file, got: %s", stdout)
+	}
+}
+
+func testRunTiming(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "timed-cmd", "--cmd", "echo timed", "--pre-hook", "echo before")
+	if err != nil {
+		t.Fatalf("Failed to add timed command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "timed-cmd")
+
+	stdout, _, _ := runCommand(t, binary, "list", "--long")
+	if !strings.Contains(stdout, "timed-cmd") || !strings.Contains(stdout, "never run") {
+		t.Errorf("Expected the command to be listed as never run, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "timed-cmd")
+	if !strings.Contains(stdout, "Finished in ") || strings.Contains(stdout, "Timing:") {
+		t.Errorf("Expected the wall time only, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "timed-cmd", "--time")
+	if !strings.Contains(stdout, "Timing:\n  pre hook") || !strings.Contains(stdout, "  command ") || !strings.Contains(stdout, "  total ") {
+		t.Errorf("Expected a breakdown with --time, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "list", "--long")
+	if !strings.Contains(stdout, "last run ") || !strings.Contains(stdout, "exit status 0") {
+		t.Errorf("Expected the last run in the long list, got: %s", stdout)
+	}
+}
+
+func testRunCommandCooldown(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "cooldown-cmd", "--cmd", "echo cooled", "--cooldown", "1h")
+	if err != nil {
+		t.Fatalf("Failed to add cooldown command: %v", err)
+	}
+
+	stdout, _, _ := runCommand(t, binary, "run", "--name", "cooldown-cmd")
+	if !strings.Contains(stdout, "cooled") {
+		t.Errorf("First run should execute the command, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "--name", "cooldown-cmd")
+	if !strings.Contains(stdout, "cooling down") || strings.Contains(stdout, "Executing:") {
+		t.Errorf("Second run should be refused during cooldown, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "--name", "cooldown-cmd", "--force")
+	if !strings.Contains(stdout, "cooled") {
+		t.Errorf("Run with --force should bypass the cooldown, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "delete", "cooldown-cmd")
+	if !strings.Contains(stdout, "Command 'cooldown-cmd' deleted successfully") {
+		t.Errorf("Delete with positional name should confirm success, got: %s", stdout)
+	}
+}
+
+func testServiceCommands(t *testing.T, binary string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("service test uses sleep")
+	}
+
+	_, _, err := runCommand(t, binary, "add", "--name", "sleeper", "--cmd", "sleep 30", "--type", "service")
+	if err != nil {
+		t.Fatalf("Failed to add service: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "sleeper")
+
+	stdout, _, _ := runCommand(t, binary, "start", "sleeper")
+	if !strings.Contains(stdout, "Service 'sleeper' started") {
+		t.Fatalf("Start should report the service as started, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "start", "sleeper")
+	if !strings.Contains(stdout, "already running") {
+		t.Errorf("Starting a running service should fail, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "status", "sleeper")
+	if !strings.Contains(stdout, "running (pid") {
+		t.Errorf("Status should report the service as running, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "stop", "sleeper")
+	if !strings.Contains(stdout, "Service 'sleeper' stopped") {
+		t.Errorf("Stop should report the service as stopped, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "status")
+	if !strings.Contains(stdout, "sleeper") || !strings.Contains(stdout, "stopped") {
+		t.Errorf("Status should report the service as stopped, got: %s", stdout)
+	}
+
+	// Required services are started before the run and stopped with --stop-deps
+	_, _, err = runCommand(t, binary, "add", "--name", "needs-sleeper", "--cmd", "echo dependent", "--requires", "sleeper")
+	if err != nil {
+		t.Fatalf("Failed to add dependent command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "needs-sleeper")
+
+	stdout, _, _ = runCommand(t, binary, "run", "--name", "needs-sleeper", "--stop-deps")
+	if !strings.Contains(stdout, "Starting required service 'sleeper'") {
+		t.Errorf("Run should start the required service, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "dependent") {
+		t.Errorf("Run should execute the command, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, "Service 'sleeper' stopped") {
+		t.Errorf("Run with --stop-deps should stop the started service, got: %s", stdout)
+	}
+}
+
+func testCleanupCommands(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "with-cleanup", "--cmd", "afvikle-no-such-binary", "--cleanup", "echo cleaned-up")
+	if err != nil {
+		t.Fatalf("Failed to add command with cleanup: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "with-cleanup")
+
+	// Cleanup runs even though the command itself fails
+	stdout, _, _ := runCommand(t, binary, "run", "--name", "with-cleanup")
+	if !strings.Contains(stdout, "Running cleanup: echo cleaned-up") || !strings.Contains(stdout, "cleaned-up") {
+		t.Errorf("Run should execute the cleanup after a failure, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "cleanup", "with-cleanup")
+	if !strings.Contains(stdout, "cleaned-up") {
+		t.Errorf("Cleanup command should run the cleanup, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "cleanup", "--name", "test-cmd-dir")
+	if !strings.Contains(stdout, "has no cleanup registered") {
+		t.Errorf("Cleanup without a registered cleanup should fail, got: %s", stdout)
+	}
+}
+
+func testExecCommand(t *testing.T, binary string, tempDir string) {
+	envFile := filepath.Join(tempDir, "exec.env")
+	if err := os.WriteFile(envFile, []byte("AFV_EXEC_TEST=from-env-file\n"), 0644); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+
+	// Flags after -- belong to the child, not to afv
+	stdout, _, err := runCommand(t, binary, "exec", "--dir", tempDir, "--env-file", "exec.env", "--", "go", "env", "-json", "GOOS")
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if !strings.Contains(stdout, "Executing: go env -json GOOS") || !strings.Contains(stdout, `"GOOS"`) {
+		t.Errorf("Exec should run the ad-hoc command, got: %s", stdout)
+	}
+	if !strings.Contains(stdout, fmt.Sprintf("Working directory: %s", tempDir)) {
+		t.Errorf("Exec should use the resolved directory, got: %s", stdout)
+	}
+
+	// The output is captured like that of a stored command
+	logs, _ := filepath.Glob(filepath.Join(tempDir, "data", "*", "logs", "_exec", "run-*.log"))
+	if len(logs) != 1 {
+		t.Fatalf("Expected one exec log, got %v", logs)
+	}
+	if data, err := os.ReadFile(logs[0]); err != nil || !strings.Contains(string(data), `"GOOS"`) {
+		t.Errorf("Expected the output in the exec log, got %q, %v", data, err)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "exec", "--save", "exec-saved", "--", "go", "version")
+	if !strings.Contains(stdout, "Command 'exec-saved' saved") {
+		t.Errorf("Exec with --save should store the command, got: %s", stdout)
+	}
+	defer runCommand(t, binary, "delete", "--name", "exec-saved")
+
+	// A command that cannot be stored is refused before it runs
+	stdout, _, _ = runCommand(t, binary, "exec", "--save", "exec-spaces", "--", "go", "env", "GOOS GOARCH")
+	if !strings.Contains(stdout, "contains whitespace") || strings.Contains(stdout, "Executing:") {
+		t.Errorf("Exec --save with whitespace in an argument should fail without running, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "exec")
+	if !strings.Contains(stdout, "command is required after --") {
+		t.Errorf("Exec without a command should fail, got: %s", stdout)
+	}
+}
+
+func testExportImport(t *testing.T, binary string) {
+	snippet, _, err := runCommand(t, binary, "export", "--name", "test-cmd", "--single", "--format", "json")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if !strings.HasPrefix(snippet, "{") || !strings.Contains(snippet, `"name":"test-cmd"`) {
+		t.Fatalf("Export --single should print a JSON snippet, got: %s", snippet)
+	}
+
+	// Importing an existing name is skipped
+	stdout, _, _ := runCommandWithInput(t, binary, snippet, "import", "-")
+	if !strings.Contains(stdout, "Skipped 'test-cmd'") {
+		t.Errorf("Import of an existing command should be skipped, got: %s", stdout)
+	}
+
+	renamed := strings.Replace(snippet, `"name":"test-cmd"`, `"name":"imported-cmd"`, 1)
+	stdout, _, _ = runCommandWithInput(t, binary, renamed, "import", "-")
+	if !strings.Contains(stdout, "Imported 1 command(s)") {
+		t.Errorf("Import should add the command, got: %s", stdout)
+	}
+	defer runCommand(t, binary, "delete", "--name", "imported-cmd")
+
+	stdout, _, _ = runCommand(t, binary, "export")
+	if !strings.Contains(stdout, "version: 1") || !strings.Contains(stdout, "name: imported-cmd") {
+		t.Errorf("Export should list all commands as YAML, got: %s", stdout)
+	}
+}
+
+func testWorkspaces(t *testing.T, binary string) {
+	stdout, _, _ := runCommand(t, binary, "workspace", "create", "client-a")
+	if !strings.Contains(stdout, "Workspace 'client-a' created") {
+		t.Fatalf("Workspace create failed, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "workspace", "use", "client-a")
+	if !strings.Contains(stdout, "Switched to workspace 'client-a'") {
+		t.Fatalf("Workspace use failed, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "list")
+	if !strings.Contains(stdout, "No commands found") {
+		t.Errorf("New workspace should be empty, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "workspace", "list")
+	if !strings.Contains(stdout, "* client-a") || !strings.Contains(stdout, "  default") {
+		t.Errorf("Workspace list should mark client-a as active, got: %s", stdout)
+	}
+
+	runCommand(t, binary, "workspace", "use", "default")
+	stdout, _, _ = runCommand(t, binary, "list")
+	if !strings.Contains(stdout, "test-cmd") {
+		t.Errorf("Default workspace should still hold its commands, got: %s", stdout)
+	}
+
+	// Profiles are workspaces by another name, selectable per invocation
+	stdout, _, _ = runCommand(t, binary, "profile", "create", "work")
+	if !strings.Contains(stdout, "Profile 'work' created. Switch to it with 'afv profile use work'.") {
+		t.Fatalf("Profile create failed, got: %s", stdout)
+	}
+	runCommand(t, binary, "--profile", "work", "add", "--name", "work-cmd", "--cmd", "true")
+	stdout, _, _ = runCommand(t, binary, "--profile", "work", "list")
+	if !strings.Contains(stdout, "work-cmd") || strings.Contains(stdout, "test-cmd") {
+		t.Errorf("--profile should select the profile for one command, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list")
+	if strings.Contains(stdout, "work-cmd") || !strings.Contains(stdout, "test-cmd") {
+		t.Errorf("--profile should not change the active profile, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "--profile", "missing", "list")
+	if !strings.Contains(stdout, "workspace 'missing' does not exist") {
+		t.Errorf("--profile should reject unknown profiles, got: %s", stdout)
+	}
+}
+
+func testPinCommands(t *testing.T, binary string) {
+	for _, name := range []string{"pin-a", "pin-z"} {
+		if _, _, err := runCommand(t, binary, "add", "--name", name, "--cmd", "echo "+name); err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		defer runCommand(t, binary, "delete", "--name", name)
+	}
+
+	stdout, _, _ := runCommand(t, binary, "pin", "pin-z")
+	if !strings.Contains(stdout, "Command 'pin-z' pinned.") {
+		t.Errorf("Pin should confirm, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list")
+	if !strings.Contains(stdout, "[pinned]") || strings.Index(stdout, "pin-z") > strings.Index(stdout, "pin-a") {
+		t.Errorf("Pinned commands should be listed first and marked, got: %s", stdout)
+	}
+
+	runCommand(t, binary, "unpin", "pin-z")
+	stdout, _, _ = runCommand(t, binary, "list")
+	if strings.Contains(stdout, "[pinned]") || strings.Index(stdout, "pin-z") < strings.Index(stdout, "pin-a") {
+		t.Errorf("Unpinned commands should be back in name order, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "pin", "no-such-cmd")
+	if !strings.Contains(stdout, "not found") {
+		t.Errorf("Pinning a missing command should fail, got: %s", stdout)
+	}
+}
+
+func testAliases(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "alias-target", "--cmd", "echo aliased")
+	if err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	stdout, _, _ := runCommand(t, binary, "alias", "add", "at", "alias-target")
+	if !strings.Contains(stdout, "Alias 'at' now runs 'alias-target'.") {
+		t.Errorf("Alias add should confirm, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "run", "at")
+	if !strings.Contains(stdout, "aliased") {
+		t.Errorf("Running an alias should run its target, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "alias", "list")
+	if !strings.Contains(stdout, "at") || !strings.Contains(stdout, "alias-target") {
+		t.Errorf("Alias list should show the alias, got: %s", stdout)
+	}
+
+	runCommand(t, binary, "delete", "--name", "alias-target")
+	stdout, _, _ = runCommand(t, binary, "alias", "list")
+	if !strings.Contains(stdout, "No aliases found") {
+		t.Errorf("Deleting the target should remove its aliases, got: %s", stdout)
+	}
+}
+
+func testTrash(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "trashed-cmd", "--cmd", "echo trashed")
+	if err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	stdout, _, _ := runCommand(t, binary, "delete", "trashed-cmd")
+	if !strings.Contains(stdout, "afv undelete trashed-cmd") {
+		t.Errorf("Delete should point at undelete, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "trash", "list")
+	if !strings.Contains(stdout, "trashed-cmd") || !strings.Contains(stdout, "deleted ") {
+		t.Errorf("Trash list should show the deleted command, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "undelete", "trashed-cmd")
+	if !strings.Contains(stdout, "Command 'trashed-cmd' restored.") {
+		t.Errorf("Undelete should confirm, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "run", "trashed-cmd")
+	if !strings.Contains(stdout, "trashed") {
+		t.Errorf("The restored command should run, got: %s", stdout)
+	}
+
+	runCommand(t, binary, "delete", "trashed-cmd")
+	stdout, _, _ = runCommandWithInput(t, binary, "y\n", "trash", "empty")
+	if !strings.Contains(stdout, "Permanently deleted") {
+		t.Errorf("Trash empty should confirm, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "undelete", "trashed-cmd")
+	if !strings.Contains(stdout, "not in the trash") {
+		t.Errorf("An emptied trash should not restore anything, got: %s", stdout)
+	}
+}
+
+func testTagCommands(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "tagged-up", "--cmd", "docker compose up", "--tag", "docker,dev")
+	if err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "tagged-up")
+
+	stdout, _, _ := runCommand(t, binary, "list", "--tag", "docker")
+	if !strings.Contains(stdout, "tagged-up") || strings.Contains(stdout, "test-cmd") {
+		t.Errorf("List --tag should only show tagged commands, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "tag", "add", "tagged-up", "ci")
+	if !strings.Contains(stdout, "Tagged 'tagged-up' with ci") {
+		t.Errorf("Tag add should confirm, got: %s", stdout)
+	}
+	runCommand(t, binary, "tag", "remove", "tagged-up", "dev")
+	stdout, _, _ = runCommand(t, binary, "tag", "list")
+	if !strings.Contains(stdout, "ci") || !strings.Contains(stdout, "docker") || strings.Contains(stdout, "dev ") {
+		t.Errorf("Tag list should show ci and docker only, got: %s", stdout)
+	}
+
+	runCommand(t, binary, "update", "tagged-up", "--tag", "compose")
+	stdout, _, _ = runCommand(t, binary, "list", "--tag", "docker")
+	if !strings.Contains(stdout, "No commands tagged 'docker'") {
+		t.Errorf("Update --tag should replace the tags, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list", "--tag", "compose", "-o", "json")
+	if !strings.Contains(stdout, `"name": "tagged-up"`) {
+		t.Errorf("List --tag -o json should include the tagged command, got: %s", stdout)
+	}
+}
+
+func testNamespaces(t *testing.T, binary string) {
+	for _, name := range []string{"proj1:build", "proj1:test", "proj10:build"} {
+		if _, _, err := runCommand(t, binary, "add", "--name", name, "--cmd", "echo "+name); err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+	}
+	defer runCommand(t, binary, "delete", "--name", "proj10:build")
+
+	stdout, _, _ := runCommand(t, binary, "list", "proj1:")
+	if !strings.Contains(stdout, "proj1:build") || !strings.Contains(stdout, "proj1:test") || strings.Contains(stdout, "proj10") || strings.Contains(stdout, "test-cmd") {
+		t.Errorf("List proj1: should only show the namespace, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "run", "proj1:build")
+	if !strings.Contains(stdout, "\nproj1:build\n") {
+		t.Errorf("Namespaced commands should run by their full name, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommandWithInput(t, binary, "y\n", "delete", "--namespace", "proj1")
+	if !strings.Contains(stdout, "delete 2 command(s) in namespace 'proj1'") || !strings.Contains(stdout, "Successfully deleted 2 command(s)") {
+		t.Errorf("Delete --namespace should delete the namespace, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list", "proj1")
+	if !strings.Contains(stdout, "No commands in namespace 'proj1'") {
+		t.Errorf("Namespace should be empty after deleting it, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "add", "--name", "proj1:", "--cmd", "echo")
+	if !strings.Contains(stdout, "namespaces and names must not be empty") {
+		t.Errorf("An empty name in a namespace should be rejected, got: %s", stdout)
+	}
+}
+
+func testShowCommand(t *testing.T, binary string) {
+	_, _, err := runCommand(t, binary, "add", "--name", "show-me", "--desc", "Shown", "--cmd", "echo shown", "--env", "COLOR=blue")
+	if err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "show-me")
+	runCommand(t, binary, "run", "show-me")
+
+	stdout, _, _ := runCommand(t, binary, "show", "show-me")
+	for _, want := range []string{"Command:      echo shown", "Run count:    1", "exit status 0", "  COLOR=blue"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in the details, got: %s", want, stdout)
+		}
+	}
+
+	stdout, _, _ = runCommand(t, binary, "show", "show-me", "--json")
+	var shown struct {
+		Name    string     `json:"name"`
+		LastRun *RunRecord `json:"last_run"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &shown); err != nil || shown.Name != "show-me" || shown.LastRun == nil {
+		t.Errorf("Show --json should print the command and its last run, got %v: %s", err, stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "show", "show-me", "-o", "yaml")
+	if !strings.Contains(stdout, "name: show-me") || !strings.Contains(stdout, "last_run:") {
+		t.Errorf("Show -o yaml should print YAML, got: %s", stdout)
+	}
+}
+
+func testUpdateCommand(t *testing.T, binary string) {
+	dir := t.TempDir()
+	_, _, err := runCommand(t, binary, "add", "--name", "update-me", "--desc", "Original", "--cmd", "echo one", "--dir", dir)
+	if err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	defer runCommand(t, binary, "delete", "--name", "update-me")
+
+	stdout, _, _ := runCommand(t, binary, "update", "update-me", "--cmd", "echo two")
+	if !strings.Contains(stdout, "Command 'update-me' updated successfully") {
+		t.Fatalf("Update should succeed, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "list", "-o", "json")
+	if !strings.Contains(stdout, `"command": "echo two"`) || !strings.Contains(stdout, `"description": "Original"`) || !strings.Contains(stdout, dir) {
+		t.Errorf("Only the command line should change, got: %s", stdout)
+	}
+
+	// An empty --dir removes the working directory
+	runCommand(t, binary, "update", "update-me", "--dir", "", "--desc", "Changed")
+	stdout, _, _ = runCommand(t, binary, "list", "-o", "json")
+	if strings.Contains(stdout, dir) || !strings.Contains(stdout, `"description": "Changed"`) {
+		t.Errorf("Expected the directory removed and the description changed, got: %s", stdout)
+	}
+
+	stdout, _, _ = runCommand(t, binary, "update", "update-me")
+	if !strings.Contains(stdout, "nothing to update") {
+		t.Errorf("Update without flags should fail, got: %s", stdout)
+	}
+	stdout, _, _ = runCommand(t, binary, "update", "no-such-cmd", "--desc", "x")
+	if !strings.Contains(stdout, "not found") {
+		t.Errorf("Update of an unknown command should fail, got: %s", stdout)
+	}
+}
+
+func testDeleteCommand(t *testing.T, binary string) {
+	// Test deleting a specific command
+	stdout, stderr, err := runCommand(t, binary, "delete", "--name", "test-cmd")
+	if err != nil {
+		t.Errorf("Delete command failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Command 'test-cmd' deleted successfully") {
+		t.Errorf("Delete output should confirm success, got: %s", stdout)
+	}
+
+	// Verify command is gone - should show error message and exit with code 1
+	stdout, _, err = runCommand(t, binary, "run", "--name", "test-cmd")
+	if exitCode(err) != 1 {
+		t.Errorf("Run deleted command should exit 1, got error: %v", err)
+	}
+
+	// Check that the error message indicates the command wasn't found
+	if !strings.Contains(stdout, "command 'test-cmd' not found") {
+		t.Errorf("Run deleted command should indicate command not found, got: %s", stdout)
+	}
+}
+
+func testDeleteAllCommands(t *testing.T, binary string, tempDir string) {
+	// First add some commands to delete
+	_, _, err := runCommand(t, binary, "add", "--name", "delete-test-1", "--cmd", "echo 1")
+	if err != nil {
+		t.Fatalf("Failed to add test command 1: %v", err)
+	}
+	_, _, err = runCommand(t, binary, "add", "--name", "delete-test-2", "--cmd", "echo 2")
+	if err != nil {
+		t.Fatalf("Failed to add test command 2: %v", err)
+	}
+
+	// Test delete all with "no" response
+	stdout, stderr, err := runCommandWithInput(t, binary, "n\n", "delete", "--all")
+	if err != nil {
+		t.Errorf("Delete all with 'n' failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Operation cancelled") {
+		t.Errorf("Delete all with 'n' should be cancelled, got: %s", stdout)
+	}
+
+	// Test delete all with "yes" response
+	stdout, stderr, err = runCommandWithInput(t, binary, "y\n", "delete", "--all")
+	if err != nil {
+		t.Errorf("Delete all with 'y' failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "Successfully deleted") {
+		t.Errorf("Delete all with 'y' should confirm deletion, got: %s", stdout)
+	}
+
+	// Verify all commands are gone
+	stdout, stderr, err = runCommand(t, binary, "list")
+	if err != nil {
+		t.Errorf("List after delete all failed: %v\nStderr: %s", err, stderr)
+	}
+
+	if !strings.Contains(stdout, "No commands found") {
+		t.Errorf("List after delete all should show no commands, got: %s", stdout)
+	}
+}
+
+func testErrorCases(t *testing.T, binary string) {
+	// Test add without required fields - afv prints the error and exits with code 1
+	stdout, _, err := runCommand(t, binary, "add")
+	if exitCode(err) != 1 {
+		t.Errorf("Add without arguments should exit 1, got error: %v", err)
+	}
+
+	// Check error message
+	if !strings.Contains(stdout, "name is required") {
+		t.Errorf("Add without name should indicate name is required, got: %s", stdout)
+	}
+
+	// Test run non-existent command
+	stdout, _, err = runCommand(t, binary, "run", "--name", "non-existent")
+	if exitCode(err) != 1 {
+		t.Errorf("Run non-existent command should exit 1, got error: %v", err)
+	}
+
+	if !strings.Contains(stdout, "command 'non-existent' not found") {
+		t.Errorf("Run non-existent command should indicate command not found, got: %s", stdout)
+	}
+
+	// Test delete non-existent command
+	stdout, _, err = runCommand(t, binary, "delete", "--name", "non-existent")
+	if exitCode(err) != 1 {
+		t.Errorf("Delete non-existent command should exit 1, got error: %v", err)
+	}
+
+	if !strings.Contains(stdout, "command 'non-existent' not found") {
+		t.Errorf("Delete non-existent command should indicate command not found, got: %s", stdout)
+	}
+
+	// Test delete without arguments
+	stdout, _, err = runCommand(t, binary, "delete")
+	if exitCode(err) != 1 {
+		t.Errorf("Delete without arguments should exit 1, got error: %v", err)
+	}
+
+	if !strings.Contains(stdout, "either --name or --all is required") {
+		t.Errorf("Delete without arguments should indicate name or all is required, got: %s", stdout)
+	}
+
+	// Errors found before a command runs fail as well
+	for _, args := range [][]string{{"no-such-command"}, {"--log-format", "xml", "list"}, {"list", "--no-such-flag"}} {
+		stdout, _, err = runCommand(t, binary, args...)
+		if exitCode(err) != 1 || !strings.Contains(stdout, "Error:") {
+			t.Errorf("afv %s should print an error and exit 1, got %v: %s", strings.Join(args, " "), err, stdout)
+		}
 	}
 }
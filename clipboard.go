@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardReadCommands returns the clipboard tools to try, in order, for
+// reading the system clipboard on the current platform
+func clipboardReadCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbpaste"}}
+	case "windows":
+		return [][]string{{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw"}}
+	default:
+		var cmds [][]string
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmds = append(cmds, []string{"wl-paste", "--no-newline"})
+		}
+		return append(cmds,
+			[]string{"xclip", "-selection", "clipboard", "-o"},
+			[]string{"xsel", "--clipboard", "--output"},
+		)
+	}
+}
+
+// clipboardWriteCommands returns the clipboard tools to try, in order, for
+// writing stdin to the system clipboard on the current platform
+func clipboardWriteCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"powershell.exe", "-NoProfile", "-Command", "$input | Set-Clipboard"}}
+	default:
+		var cmds [][]string
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmds = append(cmds, []string{"wl-copy"})
+		}
+		return append(cmds,
+			[]string{"xclip", "-selection", "clipboard", "-i"},
+			[]string{"xsel", "--clipboard", "--input"},
+		)
+	}
+}
+
+// runClipboardTool runs the first available tool from candidates with the
+// given stdin and returns its output
+func runClipboardTool(candidates [][]string, stdin string) (string, error) {
+	var tried []string
+	for _, args := range candidates {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			tried = append(tried, args[0])
+			continue
+		}
+
+		var stderr bytes.Buffer
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(stdin)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("clipboard tool %s failed: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return string(out), nil
+	}
+	return "", fmt.Errorf("no clipboard tool found (tried: %s)", strings.Join(tried, ", "))
+}
+
+// readClipboard returns the text content of the system clipboard
+func readClipboard() (string, error) {
+	return runClipboardTool(clipboardReadCommands(), "")
+}
+
+// writeClipboard replaces the content of the system clipboard with text
+func writeClipboard(text string) error {
+	_, err := runClipboardTool(clipboardWriteCommands(), text)
+	return err
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReadClipboard(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("fake clipboard tool is a shell script")
+	}
+
+	binDir, err := os.MkdirTemp("", "afvikle_clipboard_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(binDir)
+
+	t.Setenv("PATH", binDir)
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	if _, err := readClipboard(); err == nil {
+		t.Error("Expected error when no clipboard tool is installed")
+	}
+
+	script := "#!/bin/sh\nprintf 'go test ./...'\n"
+	if err := os.WriteFile(filepath.Join(binDir, "xsel"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake xsel: %v", err)
+	}
+
+	text, err := readClipboard()
+	if err != nil {
+		t.Fatalf("readClipboard failed: %v", err)
+	}
+	if text != "go test ./..." {
+		t.Errorf("Expected clipboard text 'go test ./...', got '%s'", text)
+	}
+}
+
+func TestWriteClipboard(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("fake clipboard tool is a shell script")
+	}
+
+	binDir, err := os.MkdirTemp("", "afvikle_clipboard_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(binDir)
+
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	// The fake xclip stores whatever it receives on stdin
+	target := filepath.Join(binDir, "clipboard.txt")
+	script := "#!/bin/sh\ncat > '" + target + "'\n"
+	if err := os.WriteFile(filepath.Join(binDir, "xclip"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake xclip: %v", err)
+	}
+
+	if err := writeClipboard("afv run build"); err != nil {
+		t.Fatalf("writeClipboard failed: %v", err)
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("Fake clipboard was not written: %v", err)
+	}
+	if string(data) != "afv run build" {
+		t.Errorf("Expected clipboard text 'afv run build', got '%s'", data)
+	}
+}
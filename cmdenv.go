@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// envNamePattern matches portable environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseEnvAssignments parses KEY=VALUE pairs as given with --env
+func parseEnvAssignments(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	env := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid environment variable '%s' (expected KEY=VALUE)", pair)
+		}
+		env[strings.TrimSpace(key)] = value
+	}
+	return env, nil
+}
+
+// validateEnv checks that all variable names are valid
+func validateEnv(env map[string]string) error {
+	for key := range env {
+		if !envNamePattern.MatchString(key) {
+			return fmt.Errorf("invalid environment variable name '%s'", key)
+		}
+	}
+	return nil
+}
+
+// commandEnv returns the variables afv adds to the child's environment for a
+// command running in dir, as KEY=VALUE sorted by key
+func commandEnv(command *Command, dir string) []string {
+	env := maps.Clone(command.Env)
+	if len(command.PathPrepend) > 0 {
+		if env == nil {
+			env = map[string]string{}
+		}
+		env["PATH"] = commandPath(command, dir)
+	}
+
+	keys := slices.Sorted(maps.Keys(env))
+	vars := make([]string, len(keys))
+	for i, key := range keys {
+		vars[i] = key + "=" + env[key]
+	}
+	return vars
+}
+
+// childEnv returns the variables afv adds to the child's environment for a
+// command running in dir: those from its env file, followed by its own
+// variables, which take precedence
+func childEnv(command *Command, dir string) ([]string, error) {
+	var vars []string
+	if command.EnvFile != "" {
+		fileVars, err := LoadEnvFile(command.EnvFile, dir)
+		if err != nil {
+			return nil, err
+		}
+		slog.Debug("environment", "name", command.Name, "env_file", command.EnvFile, "keys", envKeys(fileVars))
+		vars = fileVars
+	}
+	own := commandEnv(command, dir)
+	if len(own) > 0 {
+		slog.Debug("environment", "name", command.Name, "from", "command", "keys", envKeys(own))
+	}
+	vars = append(vars, own...)
+	if len(vars) > 0 {
+		slog.Info("environment", "name", command.Name, "keys", envKeys(vars))
+	}
+	return vars, nil
+}
+
+// pathPrependDirs returns the PathPrepend directories of a command with home
+// shortcuts expanded and relative entries resolved against dir
+func pathPrependDirs(command *Command, dir string) []string {
+	dirs := make([]string, 0, len(command.PathPrepend))
+	for _, entry := range command.PathPrepend {
+		expanded, err := localPaths.expand(entry)
+		if err != nil {
+			expanded = entry
+		}
+		if !filepath.IsAbs(expanded) && dir != "" {
+			expanded = filepath.Join(dir, expanded)
+		}
+		dirs = append(dirs, expanded)
+	}
+	return dirs
+}
+
+// commandPath returns the PATH of a command's child: its PathPrepend
+// directories in front of the PATH it would otherwise inherit
+func commandPath(command *Command, dir string) string {
+	base, ok := command.Env["PATH"]
+	if !ok {
+		base = os.Getenv("PATH")
+	}
+	dirs := pathPrependDirs(command, dir)
+	if base != "" {
+		dirs = append(dirs, base)
+	}
+	return strings.Join(dirs, string(os.PathListSeparator))
+}
+
+// lookPathIn finds an executable in the given directories, so commands can
+// use project-local binaries from PathPrepend
+func lookPathIn(name string, dirs []string) (string, bool) {
+	if strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	for _, dir := range dirs {
+		if path, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// validatePathPrepend trims the PathPrepend entries and drops empty ones
+func validatePathPrepend(cmd *Command) error {
+	var dirs []string
+	for _, dir := range cmd.PathPrepend {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	cmd.PathPrepend = dirs
+	if len(dirs) > 0 && cmd.Wsl != "" {
+		return fmt.Errorf("PATH directories are not supported for WSL commands; set PATH inside the distro instead")
+	}
+	return nil
+}
+
+// exportStatements renders KEY=VALUE pairs as statements that set them in
+// the given shell
+func exportStatements(shell string, vars []string) (string, error) {
+	var b strings.Builder
+	for _, v := range vars {
+		key, value, _ := strings.Cut(v, "=")
+		switch shell {
+		case "bash", "zsh":
+			fmt.Fprintf(&b, "export %s=%s\n", key, shellQuote(shell, value))
+		case "fish":
+			fmt.Fprintf(&b, "set -gx %s %s\n", key, shellQuote(shell, value))
+		default:
+			return "", fmt.Errorf("unsupported shell '%s' (expected bash, zsh or fish)", shell)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvAssignments(t *testing.T) {
+	env, err := parseEnvAssignments([]string{"FOO=bar", "URL=http://x?a=b", "EMPTY="})
+	if err != nil {
+		t.Fatalf("parseEnvAssignments failed: %v", err)
+	}
+	expected := map[string]string{"FOO": "bar", "URL": "http://x?a=b", "EMPTY": ""}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("Expected %v, got %v", expected, env)
+	}
+
+	if _, err := parseEnvAssignments([]string{"NOVALUE"}); err == nil {
+		t.Error("Expected error for an assignment without '='")
+	}
+	if err := validateEnv(map[string]string{"1BAD": "x"}); err == nil {
+		t.Error("Expected error for an invalid variable name")
+	}
+}
+
+func TestCommandEnvAndExports(t *testing.T) {
+	cmd := &Command{Env: map[string]string{"ZED": "last", "ALPHA": "it's"}}
+	vars := commandEnv(cmd, "")
+	if !reflect.DeepEqual(vars, []string{"ALPHA=it's", "ZED=last"}) {
+		t.Errorf("Expected sorted variables, got %v", vars)
+	}
+
+	out, err := exportStatements("bash", vars)
+	if err != nil {
+		t.Fatalf("exportStatements failed: %v", err)
+	}
+	if out != "export ALPHA='it'\\''s'\nexport ZED='last'\n" {
+		t.Errorf("Unexpected bash exports:\n%s", out)
+	}
+
+	out, _ = exportStatements("fish", vars)
+	if out != "set -gx ALPHA 'it\\'s'\nset -gx ZED 'last'\n" {
+		t.Errorf("Unexpected fish exports:\n%s", out)
+	}
+
+	if _, err := exportStatements("cmd", vars); err == nil {
+		t.Error("Expected error for an unsupported shell")
+	}
+}
+
+func TestPathPrepend(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell script")
+	}
+
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "node_modules", ".bin")
+	if err := os.MkdirAll(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "afv-local-tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &Command{Command: "afv-local-tool --check", PathPrepend: []string{"node_modules/.bin", "/opt/tools"}}
+	t.Setenv("PATH", "/usr/bin")
+	vars := commandEnv(cmd, dir)
+	want := "PATH=" + strings.Join([]string{bin, "/opt/tools", "/usr/bin"}, string(os.PathListSeparator))
+	if !reflect.DeepEqual(vars, []string{want}) {
+		t.Errorf("Expected %s, got %v", want, vars)
+	}
+
+	inv, err := ResolveInvocation(cmd, dir)
+	if err != nil {
+		t.Fatalf("ResolveInvocation failed: %v", err)
+	}
+	if inv.Executable != filepath.Join(bin, "afv-local-tool") {
+		t.Errorf("Expected the project-local binary, got %s", inv.Executable)
+	}
+	if !reflect.DeepEqual(inv.Args, []string{"afv-local-tool", "--check"}) {
+		t.Errorf("Unexpected arguments: %q", inv.Args)
+	}
+
+	if err := validatePathPrepend(&Command{PathPrepend: []string{"bin"}, Wsl: "Ubuntu"}); err == nil {
+		t.Error("Expected error for PATH directories on a WSL command")
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// completionScripts holds the per-shell completion scripts. Each passes
+// the command line up to the cursor to the hidden 'afv __complete' command
+// and offers the words it prints, falling back to file names when there
+// are none.
+var completionScripts = map[string]string{
+	"bash": `_afv_complete() {
+  local line="${COMP_LINE:0:COMP_POINT}"
+  local IFS=$'\n'
+  COMPREPLY=($(command afv __complete -- "$line" 2>/dev/null))
+  # bash splits words at colons, so namespaced names are completed from
+  # the last colon on
+  if [[ $COMP_WORDBREAKS == *:* && ${line##*[[:space:]]} == *:* ]]; then
+    local prefix="${line##*[[:space:]]}"
+    prefix="${prefix%"${prefix##*:}"}"
+    COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+  fi
+}
+complete -o default -F _afv_complete afv
+`,
+	"zsh": `#compdef afv
+_afv() {
+  local -a candidates
+  candidates=(${(f)"$(command afv __complete -- "${words[1,CURRENT]}" 2>/dev/null)"})
+  if (( ${#candidates} )); then
+    compadd -- $candidates
+  else
+    _files
+  fi
+}
+compdef _afv afv
+`,
+	"fish": `function __afv_complete
+    set -l candidates (command afv __complete -- (commandline -cp) 2>/dev/null)
+    if test (count $candidates) -gt 0
+        printf '%s\n' $candidates
+    else
+        __fish_complete_path (commandline -ct)
+    end
+end
+complete -c afv -f -a '(__afv_complete)'
+`,
+	"powershell": `Register-ArgumentCompleter -Native -CommandName afv -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $length = $cursorPosition - $commandAst.Extent.StartOffset
+    $line = $commandAst.Extent.Text.PadRight($length).Substring(0, $length)
+    afv __complete -- $line 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`,
+}
+
+// completionScript returns the completion script for the given shell
+func completionScript(shell string) (string, error) {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell '%s' (expected bash, zsh, fish or powershell)", shell)
+	}
+	return script, nil
+}
+
+// completionNameArgs maps commands to the position of their argument that
+// names a stored command, or -1 if every argument does. Stored commands
+// also run as 'afv NAME'.
+var completionNameArgs = map[string]int{
+	"": 0, "run": -1, "which": 0, "env": 0, "cleanup": 0, "help": 0, "show": 0,
+	"update": 0, "edit": 0, "delete": -1, "deprecate": 0, "pin": 0,
+	"unpin": 0, "logs": 0, "stats": 0, "start": 0, "stop": 0, "status": 0,
+	"tag add": 0, "tag remove": 0, "alias add": 1,
+	"schedule add": 0, "schedule delete": 0,
+}
+
+// completionShells maps the commands taking a shell to the shells they
+// support
+var completionShells = map[string][]string{
+	"completion": {"bash", "zsh", "fish", "powershell"},
+	"shell-init": {"bash", "zsh", "fish"},
+}
+
+// completer finds the candidates for the word at the cursor from the help
+// of afv's commands and the names in the database
+type completer struct {
+	db *Database
+	// help returns the help output of the command at path
+	help func(path []string) string
+}
+
+// splitCommandLine splits a command line as typed into words, dropping
+// quotes. A line ending in whitespace ends with an empty word, the one
+// being completed. Only the words of the last command of a list or
+// pipeline are returned.
+func splitCommandLine(line string) []string {
+	var words []string
+	var current strings.Builder
+	var quote rune
+	inWord := false
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+				continue
+			}
+		case c == '"', c == '\'':
+			quote, inWord = c, true
+			continue
+		case unicode.IsSpace(c):
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+			continue
+		}
+		current.WriteRune(c)
+		inWord = true
+	}
+	words = append(words, current.String())
+
+	for i := len(words) - 2; i >= 0; i-- {
+		switch words[i] {
+		case ";", "&&", "||", "|":
+			return words[i+1:]
+		}
+	}
+	return words
+}
+
+// parseHelp reads the subcommands and flags out of the help output of a
+// command. The flags map to whether they take a value.
+func parseHelp(help string) ([]string, map[string]bool) {
+	var commands []string
+	flags := map[string]bool{}
+	section := ""
+	for _, line := range strings.Split(help, "\n") {
+		switch {
+		case line == "Available commands:", line == "Flags:":
+			section = line
+		case section == "Available commands:" && strings.HasPrefix(line, "   "):
+			if fields := strings.Fields(line); len(fields) > 0 {
+				commands = append(commands, fields[0])
+			}
+		case section == "Flags:" && strings.HasPrefix(line, "  -"):
+			// Short flags are followed by their usage on the same line
+			head, _, _ := strings.Cut(line, "\t")
+			if fields := strings.Fields(strings.TrimPrefix(head, "  -")); len(fields) > 0 {
+				flags[fields[0]] = len(fields) > 1
+			}
+		}
+	}
+	return commands, flags
+}
+
+// Complete returns the candidates for the last of words, the command line
+// up to the cursor without the program name
+func (c *completer) Complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	word := words[len(words)-1]
+
+	var path, args []string
+	commands, flags := c.commandHelp(path)
+	valueFlag := ""
+	for _, w := range words[:len(words)-1] {
+		switch {
+		case valueFlag != "":
+			valueFlag = ""
+		case strings.HasPrefix(w, "-") && w != "-":
+			name, _, hasValue := strings.Cut(strings.TrimLeft(w, "-"), "=")
+			if flags[name] && !hasValue {
+				valueFlag = name
+			}
+		case len(args) == 0 && slices.Contains(commands, w):
+			path = append(path, w)
+			commands, flags = c.commandHelp(path)
+		default:
+			args = append(args, w)
+		}
+	}
+
+	var candidates []string
+	switch {
+	case valueFlag != "":
+		candidates = c.flagValues(path, valueFlag)
+	case strings.HasPrefix(word, "-"):
+		for name := range flags {
+			if len(name) > 1 {
+				candidates = append(candidates, "--"+name)
+			}
+		}
+	default:
+		if len(args) == 0 {
+			candidates = append(candidates, commands...)
+			candidates = append(candidates, completionShells[strings.Join(path, " ")]...)
+		}
+		if pos, ok := completionNameArgs[strings.Join(path, " ")]; ok && (pos == -1 || pos == len(args)) {
+			candidates = append(candidates, c.commandNames()...)
+		}
+	}
+
+	candidates = slices.DeleteFunc(candidates, func(s string) bool { return !strings.HasPrefix(s, word) })
+	slices.Sort(candidates)
+	return slices.Compact(candidates)
+}
+
+// commandHelp returns the subcommands and flags of the command at path,
+// along with the global flags
+func (c *completer) commandHelp(path []string) ([]string, map[string]bool) {
+	commands, flags := parseHelp(c.help(path))
+	for _, name := range globalFlags {
+		flags[name] = true
+	}
+	for _, name := range globalBoolFlags {
+		flags[name] = false
+	}
+	return commands, flags
+}
+
+// flagValues returns the candidates for the value of a flag
+func (c *completer) flagValues(path []string, flag string) []string {
+	switch flag {
+	case "name", "use":
+		if _, ok := completionNameArgs[strings.Join(path, " ")]; ok {
+			return c.commandNames()
+		}
+	case "tag":
+		tags, _ := c.db.GetTags()
+		var names []string
+		for tag := range tags {
+			names = append(names, tag)
+		}
+		return names
+	case workspaceFlag, profileFlag:
+		names, _ := ListWorkspaces()
+		return names
+	case "shell":
+		return []string{"bash", "zsh", "fish"}
+	case outputFlag, outputShortFlag:
+		// afv export names the file to write with --output
+		if len(path) == 0 || path[0] != "export" {
+			return []string{outputTable, outputJSON, outputYAML}
+		}
+	}
+	return nil
+}
+
+// commandNames returns the names of the stored and project commands and
+// of the aliases
+func (c *completer) commandNames() []string {
+	var names []string
+	metas, _ := c.db.GetCommandMeta()
+	for _, meta := range metas {
+		names = append(names, meta.Name)
+	}
+	aliases, _ := c.db.GetAliases()
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	return names
+}
+
+// captureStdout returns what fn prints to stdout, used to read the help
+// afv prints for its commands
+func captureStdout(fn func()) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	fn()
+	os.Stdout = stdout
+	w.Close()
+	return <-output, nil
+}
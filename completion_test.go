@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"afv run", []string{"afv", "run"}},
+		{"afv run ", []string{"afv", "run", ""}},
+		{"afv  run 'two words' \"x", []string{"afv", "run", "two words", "x"}},
+		{"cd /tmp && afv sh", []string{"afv", "sh"}},
+		{"", []string{""}},
+	}
+	for _, tt := range tests {
+		if got := splitCommandLine(tt.line); !slices.Equal(got, tt.want) {
+			t.Errorf("splitCommandLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseHelp(t *testing.T) {
+	help := "afv v1.0.0 - Short for afvikle.\n\nafv tag - Manage the tags of commands\nAvailable commands:\n\n" +
+		"   add      Add tags to a command \n   list     List the tags in use \n\nFlags:\n\n" +
+		"  -help\n    \tGet help on the 'afv tag' command.\n  -name string\n    \tCommand name\n  -x\tShort flag\n"
+	commands, flags := parseHelp(help)
+	if !slices.Equal(commands, []string{"add", "list"}) {
+		t.Errorf("Unexpected commands %q", commands)
+	}
+	want := map[string]bool{"help": false, "name": true, "x": false}
+	if len(flags) != len(want) {
+		t.Fatalf("Unexpected flags %v", flags)
+	}
+	for name, value := range want {
+		if flags[name] != value {
+			t.Errorf("Expected flag %s to take a value: %v, got %v", name, value, flags[name])
+		}
+	}
+}
+
+func TestCompleterComplete(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+	for _, cmd := range []Command{
+		{Name: "build", Command: "make", Tags: []string{"ci"}},
+		{Name: "docker:build", Command: "docker build ."},
+		{Name: "deploy", Command: "./deploy.sh"},
+	} {
+		if err := db.InsertCommand(cmd); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+	if err := db.AddAlias("b", "build"); err != nil {
+		t.Fatalf("Failed to add alias: %v", err)
+	}
+
+	helps := map[string]string{
+		"":        "Available commands:\n\n   run   Run \n   tag   Tags \n   list   List \n\nFlags:\n\n  -help\n    \tHelp\n",
+		"run":     "Flags:\n\n  -dir string\n    \tDirectory\n  -force\n    \tForce\n  -name string\n    \tName\n",
+		"tag":     "Available commands:\n\n   add   Add \n\nFlags:\n\n  -help\n    \tHelp\n",
+		"tag add": "Flags:\n\n  -help\n    \tHelp\n",
+		"list":    "Flags:\n\n  -tag string\n    \tTag\n",
+	}
+	c := &completer{db: db, help: func(path []string) string { return helps[strings.Join(path, " ")] }}
+
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{""}, []string{"b", "build", "deploy", "docker:build", "list", "run", "tag"}},
+		{[]string{"de"}, []string{"deploy"}},
+		{[]string{"r"}, []string{"run"}},
+		{[]string{"run", ""}, []string{"b", "build", "deploy", "docker:build"}},
+		{[]string{"run", "d"}, []string{"deploy", "docker:build"}},
+		{[]string{"run", "build", "de"}, []string{"deploy"}},
+		{[]string{"run", "--dir", "/tmp", "--force", "docker:"}, []string{"docker:build"}},
+		{[]string{"run", "--dir", ""}, nil},
+		{[]string{"run", "--name", "b"}, []string{"b", "build"}},
+		{[]string{"run", "--f"}, []string{"--force"}},
+		{[]string{"tag", ""}, []string{"add"}},
+		{[]string{"tag", "add", "bu"}, []string{"build"}},
+		{[]string{"tag", "add", "build", ""}, nil},
+		{[]string{"list", "--tag", ""}, []string{"ci"}},
+		{[]string{"--output", "j"}, []string{"json"}},
+	}
+	for _, tt := range tests {
+		if got := c.Complete(tt.words); !slices.Equal(got, tt.want) {
+			t.Errorf("Complete(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
+
+func TestCompletionScript(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		script, err := completionScript(shell)
+		if err != nil {
+			t.Fatalf("completionScript(%s) failed: %v", shell, err)
+		}
+		if !strings.Contains(script, "afv __complete -- ") {
+			t.Errorf("Expected the %s script to call afv __complete, got:\n%s", shell, script)
+		}
+	}
+	if _, err := completionScript("tcsh"); err == nil {
+		t.Error("Expected an error for an unsupported shell")
+	}
+}
+
+func TestBashCompletionNamespaces(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	// Stand in for afv, offering a namespaced command for any line
+	bin := t.TempDir()
+	stub := "#!/bin/sh\necho docker:build\necho docker:push\n"
+	if err := os.WriteFile(filepath.Join(bin, "afv"), []byte(stub), 0755); err != nil {
+		t.Fatalf("Failed to write afv stub: %v", err)
+	}
+	script := completionScripts["bash"] + `COMP_LINE="afv run docker:p"; COMP_POINT=${#COMP_LINE}
+_afv_complete
+printf '%s\n' "${COMPREPLY[@]}"
+`
+	cmd := exec.Command(bash, "-c", script)
+	cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash failed: %v: %s", err, out)
+	}
+	// The part before the colon is already a word of its own for bash
+	if got := strings.Fields(string(out)); !slices.Equal(got, []string{"build", "push"}) {
+		t.Errorf("Unexpected completions %q", got)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// maxRefDepth limits how deeply {{cmd:NAME}} references may nest
+const maxRefDepth = 10
+
+// commandRefPattern matches a {{cmd:NAME}} reference to another stored command
+var commandRefPattern = regexp.MustCompile(`\{\{\s*cmd:([^{}\s]+)\s*\}\}`)
+
+// commandRefs returns the names referenced with {{cmd:NAME}} in a command line
+func commandRefs(line string) []string {
+	var names []string
+	for _, m := range commandRefPattern.FindAllStringSubmatch(line, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// ExpandCommand returns a copy of command with every {{cmd:NAME}} reference
+// replaced by the command line of that stored command. References are
+// expanded recursively; cycles and overly deep nesting are errors.
+func ExpandCommand(db *Database, command *Command) (*Command, error) {
+	if len(commandRefs(command.Command)) == 0 {
+		return command, nil
+	}
+	line, err := expandCommandRefs(command.Command, []string{command.Name}, db.GetCommand)
+	if err != nil {
+		return nil, err
+	}
+	expanded := *command
+	expanded.Command = line
+	return &expanded, nil
+}
+
+// expandCommandRefs expands the references in line; stack holds the chain of
+// commands being expanded, outermost first
+func expandCommandRefs(line string, stack []string, lookup func(name string) (*Command, error)) (string, error) {
+	var expandErr error
+	expanded := commandRefPattern.ReplaceAllStringFunc(line, func(ref string) string {
+		if expandErr != nil {
+			return ref
+		}
+		name := commandRefPattern.FindStringSubmatch(ref)[1]
+		chain := append(slices.Clone(stack), name)
+		if slices.Contains(stack, name) {
+			expandErr = fmt.Errorf("command reference cycle: %s", strings.Join(chain, " -> "))
+			return ref
+		}
+		if len(stack) > maxRefDepth {
+			expandErr = fmt.Errorf("command references nested more than %d levels deep: %s", maxRefDepth, strings.Join(chain, " -> "))
+			return ref
+		}
+
+		target, err := lookup(name)
+		if err != nil {
+			expandErr = fmt.Errorf("command reference in '%s': %v", stack[len(stack)-1], err)
+			return ref
+		}
+		if target.Script || len(target.Steps) > 0 {
+			expandErr = fmt.Errorf("command reference in '%s': '%s' is a script or has steps and cannot be inlined", stack[len(stack)-1], name)
+			return ref
+		}
+		body, err := expandCommandRefs(target.Command, chain, lookup)
+		if err != nil {
+			expandErr = err
+			return ref
+		}
+		return body
+	})
+	return expanded, expandErr
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExpandCommandRefs(t *testing.T) {
+	stored := map[string]string{
+		"build": "go build ./...",
+		"ci":    "sh -c '{{cmd:build}} && {{ cmd:test }}'",
+		"test":  "go test ./...",
+		"ping":  "echo {{cmd:pong}}",
+		"pong":  "echo {{cmd:ping}}",
+		"ref":   "echo {{cmd:missing}}",
+	}
+	lookup := func(name string) (*Command, error) {
+		line, ok := stored[name]
+		if !ok {
+			return nil, fmt.Errorf("command '%s' not found", name)
+		}
+		return &Command{Name: name, Command: line}, nil
+	}
+
+	got, err := expandCommandRefs(stored["ci"], []string{"ci"}, lookup)
+	if err != nil {
+		t.Fatalf("expandCommandRefs failed: %v", err)
+	}
+	if got != "sh -c 'go build ./... && go test ./...'" {
+		t.Errorf("Unexpected expansion: %s", got)
+	}
+
+	_, err = expandCommandRefs(stored["ping"], []string{"ping"}, lookup)
+	if err == nil || !strings.Contains(err.Error(), "ping -> pong -> ping") {
+		t.Errorf("Expected a cycle error, got %v", err)
+	}
+
+	if _, err := expandCommandRefs(stored["ref"], []string{"ref"}, lookup); err == nil {
+		t.Error("Expected error for a reference to a missing command")
+	}
+
+	// A chain longer than the depth limit
+	for i := 0; i <= maxRefDepth+1; i++ {
+		stored[fmt.Sprintf("level%d", i)] = fmt.Sprintf("echo {{cmd:level%d}}", i+1)
+	}
+	_, err = expandCommandRefs(stored["level0"], []string{"level0"}, lookup)
+	if err == nil || !strings.Contains(err.Error(), "levels deep") {
+		t.Errorf("Expected a depth error, got %v", err)
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer os.RemoveAll(tempDir)
+	defer db.Close()
+
+	if err := db.AddCommand("lint", "", "golangci-lint run", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddCommand("check", "", "make {{cmd:lint}}", ""); err != nil {
+		t.Fatal(err)
+	}
+	command, err := db.GetCommand("check")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expanded, err := ExpandCommand(db, command)
+	if err != nil {
+		t.Fatalf("ExpandCommand failed: %v", err)
+	}
+	if expanded.Command != "make golangci-lint run" {
+		t.Errorf("Unexpected expansion: %s", expanded.Command)
+	}
+	if command.Command != "make {{cmd:lint}}" {
+		t.Error("ExpandCommand must not modify the stored command")
+	}
+
+	if err := db.AddCommand("self", "", "echo {{cmd:self}}", ""); err == nil {
+		t.Error("Expected error for a command referencing itself")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the name of the settings file in the config directory
+const configFileName = "config.yaml"
+
+// Config holds the user settings read from config.yaml
+type Config struct {
+	SMTP    *SMTPConfig   `yaml:"smtp,omitempty"`
+	Notify  NotifyConfig  `yaml:"notify,omitempty"`
+	Logs    LogRetention  `yaml:"logs,omitempty"`
+	Sort    SortConfig    `yaml:"sort,omitempty"`
+	Sync    SyncConfig    `yaml:"sync,omitempty"`
+	Storage StorageConfig `yaml:"storage,omitempty"`
+}
+
+// configFile returns the path of the settings file
+func configFile() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configFileName), nil
+}
+
+// LoadConfig reads config.yaml. A missing file yields an empty config.
+func LoadConfig() (*Config, error) {
+	path, err := configFile()
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &cfg, nil
+}
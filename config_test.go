@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(configDirEnv, dir)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig without a file failed: %v", err)
+	}
+	if cfg.SMTP != nil {
+		t.Errorf("Expected empty config, got %+v", cfg)
+	}
+
+	data := "smtp:\n  host: mail.example.com\n  port: 2525\n  from: afv@example.com\n"
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.SMTP == nil || cfg.SMTP.Host != "mail.example.com" || cfg.SMTP.Port != 2525 {
+		t.Errorf("Unexpected smtp settings %+v", cfg.SMTP)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte("smtp: ["), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Expected error for an invalid config file")
+	}
+}
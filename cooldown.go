@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// cooldownRemaining returns how long the command still has to wait before it
+// may run again. It returns zero if no cooldown is set or it has expired.
+func cooldownRemaining(cmd *Command, now time.Time) (time.Duration, error) {
+	if cmd.Cooldown == "" || cmd.LastRunAt == "" {
+		return 0, nil
+	}
+
+	cooldown, err := time.ParseDuration(cmd.Cooldown)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cooldown '%s': %v", cmd.Cooldown, err)
+	}
+
+	lastRun, err := time.ParseInLocation(timeLayout, cmd.LastRunAt, time.Local)
+	if err != nil {
+		return 0, fmt.Errorf("invalid last run time '%s': %v", cmd.LastRunAt, err)
+	}
+
+	remaining := lastRun.Add(cooldown).Sub(now)
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining.Round(time.Second), nil
+}
+
+// checkCooldown refuses to run a command that is still cooling down
+func checkCooldown(cmd *Command, now time.Time) error {
+	remaining, err := cooldownRemaining(cmd, now)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		return fmt.Errorf("command '%s' ran at %s and is cooling down for another %s (use --force to run anyway)", cmd.Name, cmd.LastRunAt, remaining)
+	}
+	return nil
+}
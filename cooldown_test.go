@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCooldownRemaining(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		cmd      Command
+		expected time.Duration
+	}{
+		{
+			name:     "No cooldown",
+			cmd:      Command{LastRunAt: "2025-06-01 11:59:00"},
+			expected: 0,
+		},
+		{
+			name:     "Never run",
+			cmd:      Command{Cooldown: "10m"},
+			expected: 0,
+		},
+		{
+			name:     "Still cooling down",
+			cmd:      Command{Cooldown: "10m", LastRunAt: "2025-06-01 11:55:00"},
+			expected: 5 * time.Minute,
+		},
+		{
+			name:     "Cooldown expired",
+			cmd:      Command{Cooldown: "10m", LastRunAt: "2025-06-01 11:40:00"},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining, err := cooldownRemaining(&tt.cmd, now)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if remaining != tt.expected {
+				t.Errorf("Expected %s remaining, got %s", tt.expected, remaining)
+			}
+		})
+	}
+}
+
+func TestCheckCooldown(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.Local)
+	cmd := &Command{Name: "deploy", Cooldown: "1h", LastRunAt: "2025-06-01 11:30:00"}
+
+	err := checkCooldown(cmd, now)
+	if err == nil {
+		t.Fatal("Expected cooldown error")
+	}
+	if !strings.Contains(err.Error(), "30m0s") || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("Cooldown error should mention remaining time and --force, got: %v", err)
+	}
+
+	if err := checkCooldown(cmd, now.Add(time.Hour)); err != nil {
+		t.Errorf("Expected no error after cooldown expired, got: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// keyringService is the service name afvikle uses for all OS keyring entries
+const keyringService = "afvikle"
+
+// Credential kinds that can be stored per remote
+const (
+	CredentialSyncToken     = "sync-token"
+	CredentialAPIToken      = "api-token"
+	CredentialSSHPassphrase = "ssh-passphrase"
+)
+
+// credentialKinds lists every supported credential kind
+var credentialKinds = []string{CredentialSyncToken, CredentialAPIToken, CredentialSSHPassphrase}
+
+// validateCredential checks the remote name and credential kind
+func validateCredential(remote, kind string) error {
+	if strings.TrimSpace(remote) == "" {
+		return fmt.Errorf("remote name is required")
+	}
+	for _, k := range credentialKinds {
+		if k == kind {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown credential kind '%s' (expected one of: %s)", kind, strings.Join(credentialKinds, ", "))
+}
+
+// credentialKey builds the keyring account name for a remote and credential kind
+func credentialKey(remote, kind string) string {
+	return strings.TrimSpace(remote) + ":" + kind
+}
+
+// SetCredential stores a secret for the given remote in the OS keyring
+func SetCredential(remote, kind, secret string) error {
+	if err := validateCredential(remote, kind); err != nil {
+		return err
+	}
+	if secret == "" {
+		return fmt.Errorf("secret must not be empty")
+	}
+	if err := keyring.Set(keyringService, credentialKey(remote, kind), secret); err != nil {
+		return fmt.Errorf("failed to store credential in keyring: %v", err)
+	}
+	return nil
+}
+
+// GetCredential retrieves a secret for the given remote from the OS keyring
+func GetCredential(remote, kind string) (string, error) {
+	if err := validateCredential(remote, kind); err != nil {
+		return "", err
+	}
+	secret, err := keyring.Get(keyringService, credentialKey(remote, kind))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", fmt.Errorf("no %s stored for remote '%s' (use 'afv auth login')", kind, remote)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read credential from keyring: %v", err)
+	}
+	return secret, nil
+}
+
+// DeleteCredential removes a secret for the given remote from the OS keyring.
+// It returns true if a secret was removed.
+func DeleteCredential(remote, kind string) (bool, error) {
+	if err := validateCredential(remote, kind); err != nil {
+		return false, err
+	}
+	err := keyring.Delete(keyringService, credentialKey(remote, kind))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to delete credential from keyring: %v", err)
+	}
+	return true, nil
+}
+
+// readSecret prompts for a secret, hiding the input when stdin is a terminal
+func readSecret(prompt string) (string, error) {
+	fmt.Print(prompt)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		data, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %v", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read secret: %v", err)
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestCredentials(t *testing.T) {
+	keyring.MockInit()
+
+	t.Run("Store and retrieve", func(t *testing.T) {
+		if err := SetCredential("home", CredentialSyncToken, "s3cret"); err != nil {
+			t.Fatalf("SetCredential failed: %v", err)
+		}
+
+		secret, err := GetCredential("home", CredentialSyncToken)
+		if err != nil {
+			t.Fatalf("GetCredential failed: %v", err)
+		}
+		if secret != "s3cret" {
+			t.Errorf("Expected secret 's3cret', got '%s'", secret)
+		}
+	})
+
+	t.Run("Kinds are stored separately", func(t *testing.T) {
+		if err := SetCredential("home", CredentialAPIToken, "api"); err != nil {
+			t.Fatalf("SetCredential failed: %v", err)
+		}
+
+		secret, err := GetCredential("home", CredentialSyncToken)
+		if err != nil {
+			t.Fatalf("GetCredential failed: %v", err)
+		}
+		if secret != "s3cret" {
+			t.Errorf("Sync token should be unchanged, got '%s'", secret)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		removed, err := DeleteCredential("home", CredentialSyncToken)
+		if err != nil {
+			t.Fatalf("DeleteCredential failed: %v", err)
+		}
+		if !removed {
+			t.Error("Expected credential to be removed")
+		}
+
+		removed, err = DeleteCredential("home", CredentialSyncToken)
+		if err != nil {
+			t.Fatalf("Second DeleteCredential failed: %v", err)
+		}
+		if removed {
+			t.Error("Deleting a missing credential should report nothing removed")
+		}
+
+		_, err = GetCredential("home", CredentialSyncToken)
+		if err == nil || !strings.Contains(err.Error(), "afv auth login") {
+			t.Errorf("Expected not-found error pointing at 'afv auth login', got: %v", err)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		if err := SetCredential("", CredentialSyncToken, "x"); err == nil {
+			t.Error("Expected error for empty remote")
+		}
+		if err := SetCredential("home", "password", "x"); err == nil {
+			t.Error("Expected error for unknown kind")
+		}
+		if err := SetCredential("home", CredentialSyncToken, ""); err == nil {
+			t.Error("Expected error for empty secret")
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the shorthand schedules accepted in place of five fields
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField is the set of values a cron field matches
+type cronField struct {
+	values map[int]bool
+	any    bool
+}
+
+// CronSpec is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week
+type CronSpec struct {
+	minute, hour, dom, month, dow cronField
+}
+
+// parseCron parses a cron expression such as "0 2 * * *" or "@daily". Fields
+// accept *, numbers, ranges (1-5), steps (*/15, 1-30/2) and lists (1,15).
+// Days of week run from 0 (Sunday) to 6; 7 is Sunday as well.
+func parseCron(expr string) (*CronSpec, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression '%s' (expected 5 fields: minute hour day month weekday)", expr)
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	names := [5]string{"minute", "hour", "day of month", "month", "day of week"}
+	var parsed [5]cronField
+	for i, field := range fields {
+		f, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron %s '%s': %v", names[i], field, err)
+		}
+		parsed[i] = f
+	}
+	if parsed[4].values[7] {
+		parsed[4].values[0] = true
+	}
+	return &CronSpec{minute: parsed[0], hour: parsed[1], dom: parsed[2], month: parsed[3], dow: parsed[4]}, nil
+}
+
+// parseCronField parses one comma-separated cron field within [lo, hi]
+func parseCronField(field string, lo, hi int) (cronField, error) {
+	f := cronField{values: map[int]bool{}, any: field == "*"}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return f, fmt.Errorf("invalid step '%s'", stepPart)
+			}
+			step = n
+		}
+
+		start, end := lo, hi
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = strconv.Atoi(a); err != nil {
+				return f, fmt.Errorf("invalid value '%s'", a)
+			}
+			if end, err = strconv.Atoi(b); err != nil {
+				return f, fmt.Errorf("invalid value '%s'", b)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return f, fmt.Errorf("invalid value '%s'", rangePart)
+			}
+			start = n
+			if !hasStep {
+				end = n
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return f, fmt.Errorf("values must be between %d and %d", lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			f.values[v] = true
+		}
+	}
+	return f, nil
+}
+
+// Matches reports whether the spec fires in the minute of t. As in cron, a
+// restricted day of month and day of week match if either does.
+func (c *CronSpec) Matches(t time.Time) bool {
+	if !c.minute.values[t.Minute()] || !c.hour.values[t.Hour()] || !c.month.values[int(t.Month())] {
+		return false
+	}
+	dom, dow := c.dom.values[t.Day()], c.dow.values[int(t.Weekday())]
+	if c.dom.any || c.dow.any {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first minute after t the spec fires in, or the zero time
+// if it does not fire within five years (e.g. for February 30)
+func (c *CronSpec) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	for limit := next.AddDate(5, 0, 0); next.Before(limit); next = next.Add(time.Minute) {
+		if c.Matches(next) {
+			return next
+		}
+	}
+	return time.Time{}
+}
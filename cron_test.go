@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "0 2 * * *", "*/15 9-17 * * 1-5", "0 0 1,15 * *", "@daily", "30 4 * * 7"} {
+		if _, err := parseCron(expr); err != nil {
+			t.Errorf("Expected '%s' to parse, got %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("Expected error for '%s'", expr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.ParseInLocation(timeLayout, s, time.Local)
+		if err != nil {
+			t.Fatalf("Bad time %s: %v", s, err)
+		}
+		return tm
+	}
+
+	spec, _ := parseCron("*/15 9-17 * * 1-5")
+	// 2026-10-15 is a Thursday
+	if !spec.Matches(at("2026-10-15 09:45:00")) || spec.Matches(at("2026-10-15 09:50:00")) || spec.Matches(at("2026-10-17 10:00:00")) {
+		t.Error("Unexpected matches for a weekday business hours schedule")
+	}
+
+	// Day of month and day of week match if either does
+	spec, _ = parseCron("0 0 1 * 0")
+	if !spec.Matches(at("2026-10-01 00:00:00")) || !spec.Matches(at("2026-10-18 00:00:00")) || spec.Matches(at("2026-10-15 00:00:00")) {
+		t.Error("Expected day of month or day of week to match")
+	}
+
+	spec, _ = parseCron("0 2 * * *")
+	if next := spec.Next(at("2026-10-15 02:00:00")); !next.Equal(at("2026-10-16 02:00:00")) {
+		t.Errorf("Expected the next run tomorrow at 02:00, got %s", next)
+	}
+	spec, _ = parseCron("0 0 30 2 *")
+	if next := spec.Next(at("2026-10-15 02:00:00")); !next.IsZero() {
+		t.Errorf("Expected no next run for February 30, got %s", next)
+	}
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"go.etcd.io/bbolt"
@@ -13,134 +16,584 @@ import (
 
 type Database struct {
 	db *bbolt.DB
+
+	// path is the database file, known while it is released
+	path string
+
+	// shared is set by Share until Reopen: the file is then only open for
+	// the duration of each transaction, which mu keeps to one at a time
+	shared bool
+	mu     sync.Mutex
+
+	// project holds the commands of the project file loaded by LoadProject,
+	// by name, and projectFile its path
+	project     map[string]Command
+	projectFile string
+
+	// remote keeps the commands in the store set in config.yaml, if any
+	remote *remoteCommands
 }
 
 type Command struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Command     string `json:"command"`
-	WorkingDir  string `json:"working_dir"`
-	CreatedAt   string `json:"created_at"`
+	ID          int               `json:"id,omitempty" yaml:"id,omitempty"`
+	Name        string            `json:"name" yaml:"name"`
+	Description string            `json:"description" yaml:"description"`
+	Command     string            `json:"command" yaml:"command"`
+	Steps       []string          `json:"steps,omitempty" yaml:"steps,omitempty"`
+	WorkingDir  string            `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	CreatedAt   string            `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+	UpdatedAt   string            `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+	Cooldown    string            `json:"cooldown,omitempty" yaml:"cooldown,omitempty"`
+	LastRunAt   string            `json:"last_run_at,omitempty" yaml:"last_run_at,omitempty"`
+	RunCount    int               `json:"run_count,omitempty" yaml:"run_count,omitempty"`
+	Singleton   bool              `json:"singleton,omitempty" yaml:"singleton,omitempty"`
+	Confirm     bool              `json:"confirm,omitempty" yaml:"confirm,omitempty"`
+	Script      bool              `json:"script,omitempty" yaml:"script,omitempty"`
+	Type        string            `json:"type,omitempty" yaml:"type,omitempty"`
+	HealthCheck *HealthCheck      `json:"health_check,omitempty" yaml:"health_check,omitempty"`
+	WaitFor     string            `json:"wait_for,omitempty" yaml:"wait_for,omitempty"`
+	WaitAfter   string            `json:"wait_after,omitempty" yaml:"wait_after,omitempty"`
+	Requires    []string          `json:"requires,omitempty" yaml:"requires,omitempty"`
+	Cleanup     string            `json:"cleanup,omitempty" yaml:"cleanup,omitempty"`
+	PreHook     string            `json:"pre_hook,omitempty" yaml:"pre_hook,omitempty"`
+	PostHook    string            `json:"post_hook,omitempty" yaml:"post_hook,omitempty"`
+	Deprecated  *Deprecation      `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Tags        []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Pinned      bool              `json:"pinned,omitempty" yaml:"pinned,omitempty"`
+	Notes       string            `json:"notes,omitempty" yaml:"notes,omitempty"`
+	Examples    []Example         `json:"examples,omitempty" yaml:"examples,omitempty"`
+	Eval        bool              `json:"eval,omitempty" yaml:"eval,omitempty"`
+	Env         map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	Notify      []NotifyRule      `json:"notify,omitempty" yaml:"notify,omitempty"`
+	NotifyEmail []string          `json:"notify_email,omitempty" yaml:"notify_email,omitempty"`
+	Wsl         string            `json:"wsl,omitempty" yaml:"wsl,omitempty"`
+	Container   string            `json:"container,omitempty" yaml:"container,omitempty"`
+	PathPrepend []string          `json:"path_prepend,omitempty" yaml:"path_prepend,omitempty"`
+	Vars        map[string]string `json:"vars,omitempty" yaml:"vars,omitempty"`
+	EnvFile     string            `json:"env_file,omitempty" yaml:"env_file,omitempty"`
+	StdinFile   string            `json:"stdin_file,omitempty" yaml:"stdin_file,omitempty"`
+	RunAs       string            `json:"run_as,omitempty" yaml:"run_as,omitempty"`
+	Timeout     string            `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Nice        int               `json:"nice,omitempty" yaml:"nice,omitempty"`
+	MaxMem      string            `json:"max_mem,omitempty" yaml:"max_mem,omitempty"`
+	CPULimit    int               `json:"cpu_limit,omitempty" yaml:"cpu_limit,omitempty"`
 }
 
-var commandsBucket = []byte("commands")
+// Command types
+const (
+	CommandTypeService = "service"
+)
 
-// NewDatabase creates a new database connection and initializes buckets
+// timeLayout is the format used for timestamps stored in the database
+const timeLayout = "2006-01-02 15:04:05"
+
+var (
+	commandsBucket = []byte("commands")
+	servicesBucket = []byte("services")
+	packsBucket    = []byte("packs")
+	historyBucket  = []byte("history")
+	groupsBucket   = []byte("groups")
+)
+
+// NewDatabase opens the database named by $AFVIKLE_DB, or that of the active
+// workspace, and initializes buckets
 func NewDatabase() (*Database, error) {
-	// Get the directory where the executable is located
-	execPath, err := os.Executable()
+	override, err := databaseOverride()
+	if err != nil {
+		return nil, err
+	}
+	if override != "" {
+		slog.Info("database", "path", override, "from", "$"+databaseEnv)
+		if err := os.MkdirAll(filepath.Dir(override), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %v", err)
+		}
+		return openStoredDatabase(override)
+	}
+
+	workspace, err := activeWorkspace()
+	if err != nil {
+		return nil, err
+	}
+
+	dbPath, err := workspaceDatabasePath(workspace)
+	if err != nil {
+		return nil, err
+	}
+	if workspace == defaultWorkspace {
+		if err := prepareDefaultDatabase(dbPath); err != nil {
+			return nil, err
+		}
+	}
+	slog.Info("database", "path", dbPath, "workspace", workspace)
+	if _, err := os.Stat(filepath.Dir(dbPath)); os.IsNotExist(err) {
+		return nil, fmt.Errorf("workspace '%s' does not exist (create it with 'AFV_WORKSPACE=default afv workspace create %s')", workspace, workspace)
+	}
+
+	return openStoredDatabase(dbPath)
+}
+
+// openStoredDatabase opens the database at dbPath with its commands in the
+// store set in config.yaml, if any
+func openStoredDatabase(dbPath string) (*Database, error) {
+	db, err := openDatabase(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := openStorage(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// prepareDefaultDatabase creates the data directory of the default
+// workspace and moves a database left next to the executable by earlier
+// versions into it
+func prepareDefaultDatabase(dbPath string) error {
+	legacy, err := legacyDatabasePath()
+	if err != nil {
+		return err
+	}
+	moved, err := migrateDatabase(legacy, dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get executable path: %v", err)
+		return fmt.Errorf("failed to move database from %s: %v", legacy, err)
+	}
+	if moved {
+		fmt.Fprintf(os.Stderr, "Migrated the database from %s to %s\n", legacy, dbPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
 	}
-	
-	execDir := filepath.Dir(execPath)
-	dbPath := filepath.Join(execDir, "afvikle.db")
-	
+	return nil
+}
+
+// openDatabase creates or opens the database at dbPath and initializes buckets
+func openDatabase(dbPath string) (*Database, error) {
 	// Create or open the database
+	s := startSpan("db.open", "path", dbPath)
 	db, err := bbolt.Open(dbPath, 0600, &bbolt.Options{Timeout: 1 * time.Second})
+	s.End(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
-	
-	database := &Database{db: db}
-	
+
+	database := &Database{db: db, path: dbPath}
+
 	// Initialize buckets
 	if err := database.initBuckets(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to initialize buckets: %v", err)
 	}
-	
+
 	return database, nil
 }
 
+// open returns the database file for a transaction, opening it for just
+// that transaction while the database is shared. done closes it again.
+func (d *Database) open() (db *bbolt.DB, done func(), err error) {
+	if !d.shared {
+		return d.db, func() {}, nil
+	}
+	d.mu.Lock()
+	db, err = bbolt.Open(d.path, 0600, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		d.mu.Unlock()
+		return nil, nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	return db, func() {
+		db.Close()
+		d.mu.Unlock()
+	}, nil
+}
+
+// update runs fn in a read-write transaction, logged as a span
+func (d *Database) update(op string, fn func(tx *bbolt.Tx) error) error {
+	db, done, err := d.open()
+	if err != nil {
+		return err
+	}
+	defer done()
+	s := startSpan("db."+op, "tx", "update")
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if d.remote != nil {
+			return d.remote.push(tx)
+		}
+		return nil
+	})
+	s.End(err)
+	return err
+}
+
+// view runs fn in a read-only transaction, logged as a span
+func (d *Database) view(op string, fn func(tx *bbolt.Tx) error) error {
+	db, done, err := d.open()
+	if err != nil {
+		return err
+	}
+	defer done()
+	s := startSpan("db."+op, "tx", "view")
+	err = db.View(fn)
+	s.End(err)
+	return err
+}
+
 // initBuckets creates the necessary buckets if they don't exist
 func (d *Database) initBuckets() error {
-	return d.db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(commandsBucket)
-		return err
+	return d.update("initBuckets", func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{commandsBucket, servicesBucket, packsBucket, historyBucket, groupsBucket, jobsBucket, schedulesBucket, aliasesBucket, trashBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(tagsBucket) == nil {
+			if err := rebuildTagIndexTx(tx); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(metaBucket) == nil {
+			return rebuildMetaIndexTx(tx)
+		}
+		return nil
 	})
 }
 
 // AddCommand adds a new command to the database
 func (d *Database) AddCommand(name, description, command, workingDir string) error {
+	return d.InsertCommand(Command{
+		Name:        name,
+		Description: description,
+		Command:     command,
+		WorkingDir:  workingDir,
+	})
+}
+
+// InsertCommand adds a fully populated command to the database
+func (d *Database) InsertCommand(cmd Command) error {
+	if err := normalizeCommand(&cmd); err != nil {
+		return err
+	}
+
+	return d.update("InsertCommand", func(tx *bbolt.Tx) error {
+		return insertCommandTx(tx, cmd)
+	})
+}
+
+// ReplaceCommand stores cmd in place of the existing command with the same
+// name, keeping its creation time and run statistics
+func (d *Database) ReplaceCommand(cmd Command) error {
+	if err := normalizeCommand(&cmd); err != nil {
+		return err
+	}
+	if err := d.checkStored(cmd.Name); err != nil {
+		return err
+	}
+
+	return d.update("ReplaceCommand", func(tx *bbolt.Tx) error {
+		return replaceCommandTx(tx, cmd)
+	})
+}
+
+// insertCommandTx stores a new, already normalized command within tx
+func insertCommandTx(tx *bbolt.Tx, cmd Command) error {
+	b := tx.Bucket(commandsBucket)
+
+	// Check if command already exists
+	if b.Get([]byte(cmd.Name)) != nil {
+		return fmt.Errorf("command '%s' already exists", cmd.Name)
+	}
+	if target := tx.Bucket(aliasesBucket).Get([]byte(cmd.Name)); target != nil {
+		return fmt.Errorf("'%s' is already an alias of '%s'", cmd.Name, target)
+	}
+
+	cmd.CreatedAt = time.Now().Format(timeLayout)
+
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := indexTagsTx(tx, cmd.Name, nil, cmd.Tags); err != nil {
+		return err
+	}
+	if err := indexMetaTx(tx, cmd); err != nil {
+		return err
+	}
+	return b.Put([]byte(cmd.Name), data)
+}
+
+// replaceCommandTx overwrites an existing command within tx, keeping its
+// creation time and run statistics
+func replaceCommandTx(tx *bbolt.Tx, cmd Command) error {
+	b := tx.Bucket(commandsBucket)
+
+	data := b.Get([]byte(cmd.Name))
+	if data == nil {
+		return fmt.Errorf("command '%s' not found", cmd.Name)
+	}
+
+	var existing Command
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return err
+	}
+	cmd.CreatedAt = existing.CreatedAt
+	cmd.UpdatedAt = time.Now().Format(timeLayout)
+	cmd.LastRunAt = existing.LastRunAt
+	cmd.RunCount = existing.RunCount
+
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := indexTagsTx(tx, cmd.Name, existing.Tags, cmd.Tags); err != nil {
+		return err
+	}
+	if err := indexMetaTx(tx, cmd); err != nil {
+		return err
+	}
+	return b.Put([]byte(cmd.Name), data)
+}
+
+// normalizeCommand validates the required fields, trims whitespace and
+// applies defaults before a command is stored
+func normalizeCommand(cmd *Command) error {
 	// Validate required fields
-	if name == "" {
+	if cmd.Name == "" {
 		return fmt.Errorf("command name is required")
 	}
-	if command == "" {
+	if cmd.Command == "" && len(cmd.Steps) == 0 {
 		return fmt.Errorf("command is required")
 	}
-	
+
 	// Trim whitespace
-	name = strings.TrimSpace(name)
-	command = strings.TrimSpace(command)
-	description = strings.TrimSpace(description)
-	workingDir = strings.TrimSpace(workingDir)
-	
+	cmd.Name = strings.TrimSpace(cmd.Name)
+	cmd.Command = strings.TrimSpace(cmd.Command)
+	cmd.Description = strings.TrimSpace(cmd.Description)
+	cmd.WorkingDir = strings.TrimSpace(cmd.WorkingDir)
+	if err := validateCommandName(cmd.Name); err != nil {
+		return err
+	}
+
 	// Set default description if empty
-	if description == "" {
-		description = "No description provided"
+	if cmd.Description == "" {
+		cmd.Description = "No description provided"
 	}
-	
+
 	// Validate working directory if provided
-	if workingDir != "" {
-		if _, err := os.Stat(workingDir); os.IsNotExist(err) {
-			return fmt.Errorf("working directory '%s' does not exist", workingDir)
+	if cmd.WorkingDir != "" {
+		if _, err := os.Stat(cmd.WorkingDir); os.IsNotExist(err) {
+			return fmt.Errorf("working directory '%s' does not exist", cmd.WorkingDir)
+		}
+	}
+
+	return validateCommandOptions(cmd)
+}
+
+// validateCommandOptions validates and normalizes the optional command fields
+func validateCommandOptions(cmd *Command) error {
+	cmd.Type = strings.TrimSpace(cmd.Type)
+	if cmd.Type != "" && cmd.Type != CommandTypeService {
+		return fmt.Errorf("unknown command type '%s' (expected '%s')", cmd.Type, CommandTypeService)
+	}
+
+	if cmd.HealthCheck != nil {
+		if err := cmd.HealthCheck.validate(); err != nil {
+			return err
+		}
+	}
+
+	cmd.Cleanup = strings.TrimSpace(cmd.Cleanup)
+	cmd.PreHook = strings.TrimSpace(cmd.PreHook)
+	cmd.PostHook = strings.TrimSpace(cmd.PostHook)
+
+	cmd.Wsl = strings.TrimSpace(cmd.Wsl)
+	if err := validateWsl(cmd.Wsl); err != nil {
+		return err
+	}
+	cmd.Container = strings.TrimSpace(cmd.Container)
+	if err := validateContainer(cmd); err != nil {
+		return err
+	}
+	if err := validateScript(cmd); err != nil {
+		return err
+	}
+	if err := validateSteps(cmd); err != nil {
+		return err
+	}
+	if err := validateLimits(cmd); err != nil {
+		return err
+	}
+	if err := validateRunAs(cmd); err != nil {
+		return err
+	}
+	if err := validatePathPrepend(cmd); err != nil {
+		return err
+	}
+
+	if err := validateEnv(cmd.Env); err != nil {
+		return err
+	}
+	cmd.EnvFile = strings.TrimSpace(cmd.EnvFile)
+	if err := validateVars(cmd.Vars); err != nil {
+		return err
+	}
+
+	if err := validateNotifyRules(cmd.Notify); err != nil {
+		return err
+	}
+	recipients, err := validateEmailRecipients(cmd.NotifyEmail)
+	if err != nil {
+		return err
+	}
+	cmd.NotifyEmail = recipients
+
+	cmd.Notes = strings.TrimSpace(cmd.Notes)
+	cmd.Examples = normalizeExamples(cmd.Examples)
+
+	tags, err := normalizeTags(cmd.Tags)
+	if err != nil {
+		return err
+	}
+	cmd.Tags = tags
+
+	if cmd.Deprecated != nil {
+		if err := cmd.Deprecated.validate(cmd.Name); err != nil {
+			return err
+		}
+	}
+
+	if slices.Contains(commandRefs(cmd.Command), cmd.Name) {
+		return fmt.Errorf("command '%s' cannot reference itself", cmd.Name)
+	}
+
+	for _, req := range cmd.Requires {
+		if req == cmd.Name {
+			return fmt.Errorf("command '%s' cannot require itself", cmd.Name)
+		}
+	}
+
+	for _, spec := range []*string{&cmd.WaitFor, &cmd.WaitAfter} {
+		*spec = strings.TrimSpace(*spec)
+		if *spec == "" {
+			continue
+		}
+		if _, _, err := parseWaitFor(*spec); err != nil {
+			return err
+		}
+	}
+
+	cmd.Timeout = strings.TrimSpace(cmd.Timeout)
+	if _, err := parseTimeout(cmd.Timeout); err != nil {
+		return err
+	}
+
+	cmd.Cooldown = strings.TrimSpace(cmd.Cooldown)
+	if cmd.Cooldown != "" {
+		cooldown, err := time.ParseDuration(cmd.Cooldown)
+		if err != nil {
+			return fmt.Errorf("invalid cooldown '%s': %v", cmd.Cooldown, err)
+		}
+		if cooldown < 0 {
+			return fmt.Errorf("cooldown must not be negative")
 		}
 	}
-	
-	return d.db.Update(func(tx *bbolt.Tx) error {
+	return nil
+}
+
+// ModifyCommand loads a command, applies fn to it and stores the result
+func (d *Database) ModifyCommand(name string, fn func(cmd *Command) error) error {
+	return d.modifyCommand("ModifyCommand", name, true, fn)
+}
+
+// modifyCommand applies fn to a stored command in a transaction named op;
+// touch records the change as the command's last update
+func (d *Database) modifyCommand(op, name string, touch bool, fn func(cmd *Command) error) error {
+	if err := d.checkStored(name); err != nil {
+		return err
+	}
+	return d.update(op, func(tx *bbolt.Tx) error {
 		b := tx.Bucket(commandsBucket)
-		
-		// Check if command already exists
-		if b.Get([]byte(name)) != nil {
-			return fmt.Errorf("command '%s' already exists", name)
-		}
-		
-		cmd := Command{
-			Name:        name,
-			Description: description,
-			Command:     command,
-			WorkingDir:  workingDir,
-			CreatedAt:   time.Now().Format("2006-01-02 15:04:05"),
-		}
-		
+
+		data := b.Get([]byte(name))
+		if data == nil {
+			return fmt.Errorf("command '%s' not found", name)
+		}
+
+		var cmd Command
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			return err
+		}
+
+		oldTags := slices.Clone(cmd.Tags)
+		if err := fn(&cmd); err != nil {
+			return err
+		}
+		if touch {
+			cmd.UpdatedAt = time.Now().Format(timeLayout)
+		}
+
 		data, err := json.Marshal(cmd)
 		if err != nil {
 			return err
 		}
-		
+
+		if err := indexTagsTx(tx, name, oldTags, cmd.Tags); err != nil {
+			return err
+		}
+		if err := indexMetaTx(tx, cmd); err != nil {
+			return err
+		}
 		return b.Put([]byte(name), data)
 	})
 }
 
-// GetCommand retrieves a command by name
+// RecordRun stores the start time of a run and increments the run counter
+func (d *Database) RecordRun(name string, startedAt time.Time) error {
+	// Commands of the project file are not stored, so there is nothing to record
+	if d.isProjectCommand(name) {
+		return nil
+	}
+	return d.modifyCommand("RecordRun", name, false, func(cmd *Command) error {
+		cmd.LastRunAt = startedAt.Format(timeLayout)
+		cmd.RunCount++
+		return nil
+	})
+}
+
+// GetCommand retrieves a command by name, from the project file if it
+// declares one of that name
 func (d *Database) GetCommand(name string) (*Command, error) {
+	if cmd, ok := d.project[name]; ok {
+		return &cmd, nil
+	}
 	var cmd Command
-	err := d.db.View(func(tx *bbolt.Tx) error {
+	err := d.view("GetCommand", func(tx *bbolt.Tx) error {
 		b := tx.Bucket(commandsBucket)
 		data := b.Get([]byte(name))
 		if data == nil {
 			return fmt.Errorf("command '%s' not found", name)
 		}
-		
+
 		return json.Unmarshal(data, &cmd)
 	})
-	
+
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return &cmd, nil
 }
 
 // GetAllCommands retrieves all commands from the database
 func (d *Database) GetAllCommands() ([]Command, error) {
 	var commands []Command
-	
-	err := d.db.View(func(tx *bbolt.Tx) error {
+
+	err := d.view("GetAllCommands", func(tx *bbolt.Tx) error {
 		b := tx.Bucket(commandsBucket)
-		
+
 		c := b.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			var cmd Command
@@ -149,13 +602,31 @@ func (d *Database) GetAllCommands() ([]Command, error) {
 			}
 			commands = append(commands, cmd)
 		}
-		
+
 		return nil
 	})
-	
+
 	return commands, err
 }
 
+// ForEachCommand calls fn for every command in name order, reading them
+// with a cursor instead of loading all commands at once
+func (d *Database) ForEachCommand(fn func(cmd Command) error) error {
+	return d.view("ForEachCommand", func(tx *bbolt.Tx) error {
+		c := tx.Bucket(commandsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var cmd Command
+			if err := json.Unmarshal(v, &cmd); err != nil {
+				return err
+			}
+			if err := fn(cmd); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 // UpdateCommand updates an existing command
 func (d *Database) UpdateCommand(name, description, command, workingDir string) error {
 	// Validate required fields
@@ -165,64 +636,64 @@ func (d *Database) UpdateCommand(name, description, command, workingDir string)
 	if command == "" {
 		return fmt.Errorf("command is required")
 	}
-	
+
 	// Trim whitespace
 	name = strings.TrimSpace(name)
 	command = strings.TrimSpace(command)
 	description = strings.TrimSpace(description)
 	workingDir = strings.TrimSpace(workingDir)
-	
+	if err := d.checkStored(name); err != nil {
+		return err
+	}
+
 	// Set default description if empty
 	if description == "" {
 		description = "No description provided"
 	}
-	
+
 	// Validate working directory if provided
 	if workingDir != "" {
 		if _, err := os.Stat(workingDir); os.IsNotExist(err) {
 			return fmt.Errorf("working directory '%s' does not exist", workingDir)
 		}
 	}
-	
-	return d.db.Update(func(tx *bbolt.Tx) error {
+
+	return d.update("UpdateCommand", func(tx *bbolt.Tx) error {
 		b := tx.Bucket(commandsBucket)
-		
+
 		// Check if command exists
 		data := b.Get([]byte(name))
 		if data == nil {
 			return fmt.Errorf("command '%s' not found", name)
 		}
-		
+
 		var cmd Command
 		if err := json.Unmarshal(data, &cmd); err != nil {
 			return err
 		}
-		
+
 		// Update fields
 		cmd.Description = description
 		cmd.Command = command
 		cmd.WorkingDir = workingDir
-		
+		cmd.UpdatedAt = time.Now().Format(timeLayout)
+
 		data, err := json.Marshal(cmd)
 		if err != nil {
 			return err
 		}
-		
+
+		if err := indexMetaTx(tx, cmd); err != nil {
+			return err
+		}
 		return b.Put([]byte(name), data)
 	})
 }
 
 // DeleteCommand removes a command from the database
 func (d *Database) DeleteCommand(name string) error {
-	return d.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(commandsBucket)
-		
-		// Check if command exists
-		if b.Get([]byte(name)) == nil {
-			return fmt.Errorf("command '%s' not found", name)
-		}
-		
-		return b.Delete([]byte(name))
+	return d.update("DeleteCommand", func(tx *bbolt.Tx) error {
+		return deleteCommandTx(tx, name)
 	})
 }
 
@@ -231,13 +702,66 @@ func (d *Database) Close() error {
 	return d.db.Close()
 }
 
-// GetDatabasePath returns the path to the database file
+// Release closes the database file so other afv processes can use it while a
+// long-running command is idle. Reopen opens it again.
+func (d *Database) Release() error {
+	return d.db.Close()
+}
+
+// Share closes the database file like Release, but transactions can still
+// run until Reopen: each opens the file for its own duration. Runs sharing
+// the process, such as those of a parallel batch, use it so other afv
+// processes get at the database between their transactions.
+func (d *Database) Share() error {
+	if err := d.db.Close(); err != nil {
+		return err
+	}
+	d.shared = true
+	return nil
+}
+
+// Shared reports whether the database is shared after Share
+func (d *Database) Shared() bool {
+	return d.shared
+}
+
+// Reopen opens the database file again after Release or Share
+func (d *Database) Reopen() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, err := bbolt.Open(d.path, 0600, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	d.db = db
+	d.shared = false
+	return nil
+}
+
+// Path returns the database file
+func (d *Database) Path() string {
+	return d.path
+}
+
+// DataDir returns the directory holding the database and runtime state such as locks
+func (d *Database) DataDir() string {
+	return filepath.Dir(d.path)
+}
+
+// LockDir returns the directory holding per-command lock files
+func (d *Database) LockDir() string {
+	return filepath.Join(d.DataDir(), "locks")
+}
+
+// GetDatabasePath returns the path to the database file in use: the override
+// from $AFVIKLE_DB or --db, or that of the active workspace
 func (d *Database) GetDatabasePath() (string, error) {
-	execPath, err := os.Executable()
+	if override, err := databaseOverride(); override != "" || err != nil {
+		return override, err
+	}
+	workspace, err := activeWorkspace()
 	if err != nil {
-		return "", fmt.Errorf("failed to get executable path: %v", err)
+		return "", err
 	}
-	
-	execDir := filepath.Dir(execPath)
-	return filepath.Join(execDir, "afvikle.db"), nil
+	return workspaceDatabasePath(workspace)
 }
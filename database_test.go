@@ -19,22 +19,22 @@ func createTempDB(t *testing.T) (*Database, string) {
 
 	// Create database directly in temp directory
 	dbPath := filepath.Join(tempDir, "test.db")
-	
+
 	db, err := bbolt.Open(dbPath, 0600, &bbolt.Options{Timeout: 1 * time.Second})
 	if err != nil {
 		os.RemoveAll(tempDir)
 		t.Fatalf("Failed to create database: %v", err)
 	}
-	
-	database := &Database{db: db}
-	
+
+	database := &Database{db: db, path: dbPath}
+
 	// Initialize buckets
 	if err := database.initBuckets(); err != nil {
 		db.Close()
 		os.RemoveAll(tempDir)
 		t.Fatalf("Failed to initialize buckets: %v", err)
 	}
-	
+
 	return database, tempDir
 }
 
@@ -127,7 +127,7 @@ func TestAddCommand(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			err := db.AddCommand(tt.cmdName, tt.description, tt.command, tt.workingDir)
-			
+
 			if tt.expectError {
 				if err == nil {
 					t.Errorf("Expected error but got none")
@@ -178,7 +178,7 @@ func TestGetCommand(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cmd, err := db.GetCommand(tt.cmdName)
-			
+
 			if tt.expectError {
 				if err == nil {
 					t.Errorf("Expected error but got none")
@@ -329,7 +329,7 @@ func TestUpdateCommand(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			err := db.UpdateCommand(tt.cmdName, tt.description, tt.command, tt.workingDir)
-			
+
 			if tt.expectError {
 				if err == nil {
 					t.Errorf("Expected error but got none")
@@ -340,7 +340,7 @@ func TestUpdateCommand(t *testing.T) {
 				if err != nil {
 					t.Errorf("Unexpected error: %v", err)
 				}
-				
+
 				// Verify the update
 				cmd, err := db.GetCommand(tt.cmdName)
 				if err != nil {
@@ -396,7 +396,7 @@ func TestDeleteCommand(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			err := db.DeleteCommand(tt.cmdName)
-			
+
 			if tt.expectError {
 				if err == nil {
 					t.Errorf("Expected error but got none")
@@ -407,7 +407,7 @@ func TestDeleteCommand(t *testing.T) {
 				if err != nil {
 					t.Errorf("Unexpected error: %v", err)
 				}
-				
+
 				// Verify deletion
 				_, err := db.GetCommand(tt.cmdName)
 				if err == nil {
@@ -500,3 +500,89 @@ func TestGetDatabasePath(t *testing.T) {
 		t.Errorf("Database path should end with 'afvikle.db', got: %s", path)
 	}
 }
+
+func TestRecordRun(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	err := db.InsertCommand(Command{Name: "deploy", Command: "echo deploy", Cooldown: "10m"})
+	if err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	startedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.Local)
+	for i := 0; i < 2; i++ {
+		if err := db.RecordRun("deploy", startedAt); err != nil {
+			t.Fatalf("RecordRun failed: %v", err)
+		}
+	}
+
+	cmd, err := db.GetCommand("deploy")
+	if err != nil {
+		t.Fatalf("Failed to get command: %v", err)
+	}
+
+	if cmd.Cooldown != "10m" {
+		t.Errorf("Expected cooldown '10m', got '%s'", cmd.Cooldown)
+	}
+	if cmd.LastRunAt != "2025-01-02 03:04:05" {
+		t.Errorf("Expected last run '2025-01-02 03:04:05', got '%s'", cmd.LastRunAt)
+	}
+	if cmd.RunCount != 2 {
+		t.Errorf("Expected run count 2, got %d", cmd.RunCount)
+	}
+
+	if err := db.RecordRun("missing", startedAt); err == nil {
+		t.Error("Expected error recording run of missing command")
+	}
+}
+
+func TestInsertCommandInvalidCooldown(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.InsertCommand(Command{Name: "bad", Command: "echo", Cooldown: "soon"}); err == nil {
+		t.Error("Expected error for unparsable cooldown")
+	}
+	if err := db.InsertCommand(Command{Name: "neg", Command: "echo", Cooldown: "-5m"}); err == nil {
+		t.Error("Expected error for negative cooldown")
+	}
+}
+
+func TestShare(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.Share(); err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+	// Transactions still work while another process can open the file
+	// between them
+	if err := db.AddCommand("build", "", "make", ""); err != nil {
+		t.Fatalf("AddCommand on a shared database failed: %v", err)
+	}
+	other, err := bbolt.Open(db.Path(), 0600, &bbolt.Options{Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Expected the shared database to be free between transactions: %v", err)
+	}
+	other.Close()
+	if _, err := db.GetCommand("build"); err != nil {
+		t.Errorf("GetCommand on a shared database failed: %v", err)
+	}
+
+	if err := db.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if _, err := bbolt.Open(db.Path(), 0600, &bbolt.Options{Timeout: 100 * time.Millisecond}); err == nil {
+		t.Error("Expected the reopened database to be held again")
+	}
+}
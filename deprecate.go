@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Deprecation marks a command as superseded, optionally pointing at the
+// command that replaces it. Strict deprecations refuse to run.
+type Deprecation struct {
+	Use    string `json:"use,omitempty" yaml:"use,omitempty"`
+	Strict bool   `json:"strict,omitempty" yaml:"strict,omitempty"`
+}
+
+// validate normalizes the deprecation of the named command
+func (d *Deprecation) validate(name string) error {
+	d.Use = strings.TrimSpace(d.Use)
+	if d.Use == name {
+		return fmt.Errorf("command '%s' cannot be replaced by itself", name)
+	}
+	return nil
+}
+
+// deprecationMessage describes a deprecated command and its replacement
+func deprecationMessage(cmd *Command) string {
+	msg := fmt.Sprintf("command '%s' is deprecated", cmd.Name)
+	if cmd.Deprecated.Use != "" {
+		msg += fmt.Sprintf(", use '%s' instead", cmd.Deprecated.Use)
+	}
+	return msg
+}
+
+// checkDeprecation warns about running a deprecated command, or refuses if
+// the deprecation is strict
+func checkDeprecation(cmd *Command) error {
+	if cmd.Deprecated == nil {
+		return nil
+	}
+	if cmd.Deprecated.Strict {
+		return fmt.Errorf("%s", deprecationMessage(cmd))
+	}
+	warn("%s", deprecationMessage(cmd))
+	return nil
+}
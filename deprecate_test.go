@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCheckDeprecation(t *testing.T) {
+	cmd := &Command{Name: "old"}
+	if err := checkDeprecation(cmd); err != nil {
+		t.Errorf("Command without deprecation should run, got %v", err)
+	}
+
+	cmd.Deprecated = &Deprecation{Use: "new"}
+	if err := checkDeprecation(cmd); err != nil {
+		t.Errorf("Non-strict deprecation should only warn, got %v", err)
+	}
+
+	cmd.Deprecated.Strict = true
+	err := checkDeprecation(cmd)
+	if err == nil {
+		t.Fatal("Strict deprecation should refuse to run")
+	}
+	if err.Error() != "command 'old' is deprecated, use 'new' instead" {
+		t.Errorf("Unexpected message: %v", err)
+	}
+}
+
+func TestInsertCommandDeprecatedBySelf(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	err := db.InsertCommand(Command{Name: "old", Command: "echo old", Deprecated: &Deprecation{Use: "old"}})
+	if err == nil {
+		t.Error("Expected error for a command replaced by itself")
+	}
+}
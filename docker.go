@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// containerWorkDir is where the working directory is mounted in a container
+const containerWorkDir = "/work"
+
+// validateContainer checks the image of a container command
+func validateContainer(cmd *Command) error {
+	if cmd.Container == "" {
+		return nil
+	}
+	if strings.ContainsAny(cmd.Container, " \t\n") {
+		return fmt.Errorf("invalid container image '%s'", cmd.Container)
+	}
+	if cmd.Wsl != "" {
+		return fmt.Errorf("a command cannot run both in WSL and in a container")
+	}
+	return nil
+}
+
+// newContainerCmd wraps a command line in docker run so it runs in the
+// command's image with dir mounted at /work. The line is run by sh, with
+// extra arguments appended to the docker arguments passed on as "$@". env is
+// forwarded by name so values do not show up in the process list.
+func newContainerCmd(command *Command, dir string, env []string) (*exec.Cmd, error) {
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %v", err)
+		}
+		dir = wd
+	}
+
+	args := []string{"run", "--rm", "-i", "-v", dir + ":" + containerWorkDir, "-w", containerWorkDir}
+	if command.MaxMem != "" {
+		args = append(args, "--memory", command.MaxMem)
+	}
+	if command.CPULimit > 0 {
+		args = append(args, "--cpus", strconv.Itoa(command.CPULimit))
+	}
+	for _, v := range env {
+		key, _, _ := strings.Cut(v, "=")
+		args = append(args, "-e", key)
+	}
+	args = append(args, command.Container, "sh", "-c", command.Command+` "$@"`, "sh")
+
+	cmd := exec.Command("docker", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd, nil
+}
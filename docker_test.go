@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestNewExecCmdContainer(t *testing.T) {
+	command := &Command{
+		Command:   "go test ./...",
+		Container: "golang:1.24",
+		Env:       map[string]string{"GOFLAGS": "-count=1"},
+	}
+
+	cmd, _, err := newExecCmd(command, "/src/afv")
+	if err != nil {
+		t.Fatalf("newExecCmd failed: %v", err)
+	}
+	expected := []string{"docker", "run", "--rm", "-i", "-v", "/src/afv:/work", "-w", "/work",
+		"-e", "GOFLAGS", "golang:1.24", "sh", "-c", `go test ./... "$@"`, "sh"}
+	if !reflect.DeepEqual(cmd.Args, expected) {
+		t.Errorf("Expected %v, got %v", expected, cmd.Args)
+	}
+	if cmd.Dir != "/src/afv" {
+		t.Errorf("Expected docker to run in the mounted directory, got %q", cmd.Dir)
+	}
+	if !slices.Contains(cmd.Env, "GOFLAGS=-count=1") {
+		t.Errorf("Expected GOFLAGS to be set for docker to forward, got %v", cmd.Env)
+	}
+}
+
+func TestValidateContainer(t *testing.T) {
+	if err := validateContainer(&Command{Container: "ghcr.io/acme/tools:1.2"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := validateContainer(&Command{Container: "golang 1.24"}); err == nil {
+		t.Error("Expected error for image with whitespace")
+	}
+	if err := validateContainer(&Command{Container: "golang", Wsl: "Ubuntu"}); err == nil {
+		t.Error("Expected error for container combined with WSL")
+	}
+}
+
+func TestNewExecCmdContainerLimits(t *testing.T) {
+	command := &Command{Command: "make", Container: "alpine", MaxMem: "512M", CPULimit: 2, Nice: 5}
+	cmd, _, err := newExecCmd(command, "/src")
+	if err != nil {
+		t.Fatalf("newExecCmd failed: %v", err)
+	}
+	expected := []string{"docker", "run", "--rm", "-i", "-v", "/src:/work", "-w", "/work",
+		"--memory", "512M", "--cpus", "2", "alpine", "sh", "-c", `make "$@"`, "sh"}
+	if !reflect.DeepEqual(cmd.Args, expected) {
+		t.Errorf("Expected %v, got %v", expected, cmd.Args)
+	}
+	if limits := command.Limits(); limits != (ResourceLimits{Nice: 5}) {
+		t.Errorf("Expected only the priority to apply to docker itself, got %+v", limits)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// editorCommand returns the user's editor from $VISUAL or $EDITOR, falling
+// back to a platform default
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// openEditor opens path in the user's editor and waits for it to exit
+func openEditor(path string) error {
+	args := append(editorCommand(), path)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor '%s' failed: %v", args[0], err)
+	}
+	return nil
+}
+
+// editText opens text in the user's editor in a temporary file named after
+// pattern, as for os.CreateTemp, and returns what was saved
+func editText(text, pattern string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(text)
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to write temporary file: %v", err)
+	}
+
+	if err := openEditor(f.Name()); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited file: %v", err)
+	}
+	return string(data), nil
+}
+
+// editForRun opens the command line of command in the user's editor and
+// returns a copy running the edited version; the stored command is left
+// as it is. Step commands are edited one step per line.
+func editForRun(command *Command) (*Command, error) {
+	text := command.Command
+	if len(command.Steps) > 0 {
+		text = strings.Join(command.Steps, "\n")
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	edited, err := editText(text, "afv-edit-*.sh")
+	if err != nil {
+		return nil, err
+	}
+
+	copied := *command
+	switch {
+	case len(command.Steps) > 0:
+		copied.Steps = nil
+		for _, line := range strings.Split(edited, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				copied.Steps = append(copied.Steps, line)
+			}
+		}
+		if len(copied.Steps) == 0 {
+			return nil, fmt.Errorf("edited command has no steps, not running it")
+		}
+	case command.Script:
+		copied.Command = edited
+	default:
+		copied.Command = strings.TrimSpace(edited)
+	}
+	if len(copied.Steps) == 0 && strings.TrimSpace(copied.Command) == "" {
+		return nil, fmt.Errorf("edited command is empty, not running it")
+	}
+	return &copied, nil
+}
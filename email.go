@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpPasswordEnv overrides the SMTP password from the config file
+const smtpPasswordEnv = "AFV_SMTP_PASSWORD"
+
+// defaultSMTPPort is the mail submission port used when none is configured
+const defaultSMTPPort = 587
+
+// SMTPConfig holds the mail server settings of the email notifier. The
+// connection is upgraded with STARTTLS when the server supports it.
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	From     string `yaml:"from"`
+}
+
+// validate checks that the settings are complete
+func (c *SMTPConfig) validate() error {
+	if strings.TrimSpace(c.Host) == "" {
+		return fmt.Errorf("smtp host is required")
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid smtp port %d", c.Port)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("invalid smtp from address '%s'", c.From)
+	}
+	return nil
+}
+
+// address returns the host:port of the mail server
+func (c *SMTPConfig) address() string {
+	port := c.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	return net.JoinHostPort(c.Host, strconv.Itoa(port))
+}
+
+// auth returns the PLAIN authentication for the server, or nil when no
+// username is configured
+func (c *SMTPConfig) auth() smtp.Auth {
+	if c.Username == "" {
+		return nil
+	}
+	password := c.Password
+	if env := os.Getenv(smtpPasswordEnv); env != "" {
+		password = env
+	}
+	return smtp.PlainAuth("", c.Username, password, c.Host)
+}
+
+// validateEmailRecipients normalizes and checks notification recipients
+func validateEmailRecipients(recipients []string) ([]string, error) {
+	var valid []string
+	for _, r := range recipients {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email recipient '%s'", r)
+		}
+		valid = append(valid, addr.Address)
+	}
+	return valid, nil
+}
+
+// emailMessage builds a plain text mail
+func emailMessage(from string, to []string, subject, body string, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// runEmailBody describes a finished run in a notification mail
+func runEmailBody(record RunRecord) string {
+	host, _ := os.Hostname()
+	status := "succeeded"
+	if !record.Success {
+		status = fmt.Sprintf("failed with exit status %d", record.ExitCode)
+	}
+	return fmt.Sprintf("Command: %s\nRun:     %s\nDir:     %s\nHost:    %s\nStarted: %s\nResult:  %s after %s\n",
+		record.Name, record.Command, record.Dir, host,
+		record.StartedAt.Format(timeLayout), status, formatDuration(record.Duration))
+}
+
+// sendEmailNotification mails a notification about a finished run to the
+// given recipients
+func sendEmailNotification(cfg *SMTPConfig, to []string, record RunRecord) error {
+	if cfg == nil {
+		return fmt.Errorf("email notification needs an smtp section in %s", configFileName)
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	from, _ := mail.ParseAddress(cfg.From)
+	subject, _ := notificationText(record)
+	msg := emailMessage(cfg.From, to, subject, runEmailBody(record), time.Now())
+	if err := smtp.SendMail(cfg.address(), cfg.auth(), from.Address, to, msg); err != nil {
+		return fmt.Errorf("failed to send notification email: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts a single mail without authentication and sends the
+// received DATA on the returned channel
+func fakeSMTPServer(t *testing.T) (int, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ready")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				lines, _ := tp.ReadDotLines()
+				received <- strings.Join(lines, "\n")
+				tp.PrintfLine("250 ok")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, received
+}
+
+func TestValidateEmailRecipients(t *testing.T) {
+	recipients, err := validateEmailRecipients([]string{" ops@example.com ", "", "Dev <dev@example.com>"})
+	if err != nil {
+		t.Fatalf("validateEmailRecipients failed: %v", err)
+	}
+	if strings.Join(recipients, ",") != "ops@example.com,dev@example.com" {
+		t.Errorf("Unexpected recipients %v", recipients)
+	}
+	if _, err := validateEmailRecipients([]string{"not an address"}); err == nil {
+		t.Error("Expected error for an invalid recipient")
+	}
+}
+
+func TestSMTPConfigValidate(t *testing.T) {
+	if err := (&SMTPConfig{From: "afv@example.com"}).validate(); err == nil {
+		t.Error("Expected error without a host")
+	}
+	if err := (&SMTPConfig{Host: "mail", From: "nope"}).validate(); err == nil {
+		t.Error("Expected error for an invalid from address")
+	}
+	cfg := &SMTPConfig{Host: "mail", From: "afv@example.com"}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if cfg.address() != "mail:587" {
+		t.Errorf("Expected default submission port, got %s", cfg.address())
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	msg := string(emailMessage("afv@example.com", []string{"a@example.com", "b@example.com"},
+		"afv: backup failed", "line one\nline two", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: afv: backup failed\r\n",
+		"\r\n\r\nline one\r\nline two\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected message to contain %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestSendEmailNotification(t *testing.T) {
+	if err := sendEmailNotification(nil, []string{"ops@example.com"}, RunRecord{}); err == nil {
+		t.Error("Expected error without smtp settings")
+	}
+
+	port, received := fakeSMTPServer(t)
+	cfg := &SMTPConfig{Host: "127.0.0.1", Port: port, From: "afv <afv@example.com>"}
+	record := RunRecord{Name: "backup", Command: "restic backup", ExitCode: 3, Duration: 7 * time.Minute, StartedAt: time.Now()}
+	if err := sendEmailNotification(cfg, []string{"ops@example.com"}, record); err != nil {
+		t.Fatalf("sendEmailNotification failed: %v", err)
+	}
+
+	select {
+	case data := <-received:
+		if !strings.Contains(data, "Subject: afv: backup failed") || !strings.Contains(data, "exit status 3") {
+			t.Errorf("Unexpected mail:\n%s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Mail was not received")
+	}
+}
+
+func TestNotifyRunUsesEmail(t *testing.T) {
+	t.Setenv(configDirEnv, t.TempDir())
+
+	// Recipients without smtp settings fail instead of falling back to the desktop
+	cmd := &Command{Name: "backup", Notify: []NotifyRule{{On: NotifyFailure}}, NotifyEmail: []string{"ops@example.com"}}
+	err := NotifyRun(cmd, RunRecord{Name: "backup"}, false)
+	if err == nil || !strings.Contains(err.Error(), "smtp") {
+		t.Errorf("Expected missing smtp settings error, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadEnvFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "afvikle_env_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content := `# comment
+PLAIN=value
+export EXPORTED=yes
+SPACED = padded  
+INLINE=value # trailing comment
+SINGLE='literal $HOME \n'
+DOUBLE="line1\nline2 \"quoted\""
+EMPTY=
+`
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+
+	vars, err := LoadEnvFile(".env", dir)
+	if err != nil {
+		t.Fatalf("LoadEnvFile failed: %v", err)
+	}
+
+	expected := []string{
+		"PLAIN=value",
+		"EXPORTED=yes",
+		"SPACED=padded",
+		"INLINE=value",
+		`SINGLE=literal $HOME \n`,
+		"DOUBLE=line1\nline2 \"quoted\"",
+		"EMPTY=",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("Unexpected variables:\n got: %q\nwant: %q", vars, expected)
+	}
+}
+
+func TestLoadEnvFileErrors(t *testing.T) {
+	dir, err := os.MkdirTemp("", "afvikle_env_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := LoadEnvFile("missing.env", dir); err == nil {
+		t.Error("Expected error for a missing env file")
+	}
+
+	tests := map[string]string{
+		"no equals":    "JUSTAKEY\n",
+		"space in key": "MY KEY=value\n",
+		"unterminated": "KEY=\"open\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "bad.env")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write env file: %v", err)
+			}
+			if _, err := LoadEnvFile(path, ""); err == nil {
+				t.Errorf("Expected error for %s", name)
+			}
+		})
+	}
+}
+
+func TestChildEnvWithEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("API_URL=http://localhost\nMODE=file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &Command{Command: "true", EnvFile: ".env", Env: map[string]string{"MODE": "stored"}}
+	vars, err := childEnv(cmd, dir)
+	if err != nil {
+		t.Fatalf("childEnv failed: %v", err)
+	}
+	// The command's own variables come last so they win over the file
+	if !reflect.DeepEqual(vars, []string{"API_URL=http://localhost", "MODE=file", "MODE=stored"}) {
+		t.Errorf("Unexpected variables: %v", vars)
+	}
+
+	cmd.EnvFile = "missing.env"
+	if _, _, err := newExecCmd(cmd, dir); err == nil {
+		t.Error("Expected error for a missing env file")
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// exportVersion is the current version of the export document format
+const exportVersion = 1
+
+// ExportDocument is the portable representation of a set of stored commands
+type ExportDocument struct {
+	Version  int       `json:"version" yaml:"version"`
+	Pack     *PackInfo `json:"pack,omitempty" yaml:"pack,omitempty"`
+	Commands []Command `json:"commands" yaml:"commands"`
+}
+
+// exportCommand strips the runtime state that should not travel with a command
+func exportCommand(cmd Command) Command {
+	cmd.ID = 0
+	cmd.CreatedAt = ""
+	cmd.UpdatedAt = ""
+	cmd.LastRunAt = ""
+	cmd.RunCount = 0
+	return cmd
+}
+
+// encodeExport writes commands as an export document in json or yaml. With
+// single set, the only command is written as a compact bare snippet instead.
+func encodeExport(w io.Writer, commands []Command, format string, single bool) error {
+	if single {
+		if len(commands) != 1 {
+			return fmt.Errorf("a single snippet needs exactly one command, got %d", len(commands))
+		}
+		return encodeSnippet(w, exportCommand(commands[0]), format)
+	}
+
+	ew, err := newExportWriter(w, format)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range commands {
+		if err := ew.Write(cmd); err != nil {
+			return err
+		}
+	}
+	return ew.Close()
+}
+
+// exportWriter streams an export document one command at a time, so
+// exporting a large database does not hold every command in memory. The
+// output is the same as encoding the whole document at once.
+type exportWriter struct {
+	w      io.Writer
+	format string
+	count  int
+}
+
+// newExportWriter starts an export document in json or yaml
+func newExportWriter(w io.Writer, format string) (*exportWriter, error) {
+	var header string
+	switch format {
+	case "json":
+		header = fmt.Sprintf("{\n  \"version\": %d,\n  \"commands\": [", exportVersion)
+	case "yaml":
+		header = fmt.Sprintf("version: %d\ncommands:", exportVersion)
+	default:
+		return nil, fmt.Errorf("unknown format '%s' (expected json or yaml)", format)
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return nil, err
+	}
+	return &exportWriter{w: w, format: format}, nil
+}
+
+// Write appends a command to the document, stripped of its runtime state
+func (e *exportWriter) Write(cmd Command) error {
+	cmd = exportCommand(cmd)
+
+	var item []byte
+	switch e.format {
+	case "json":
+		data, err := json.MarshalIndent(cmd, "    ", "  ")
+		if err != nil {
+			return err
+		}
+		sep := ",\n    "
+		if e.count == 0 {
+			sep = "\n    "
+		}
+		item = append([]byte(sep), data...)
+	case "yaml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode([]Command{cmd}); err != nil {
+			return err
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		if e.count == 0 {
+			item = []byte("\n")
+		}
+		// Indent the sequence item below the commands key
+		for _, line := range bytes.SplitAfter(buf.Bytes(), []byte("\n")) {
+			if len(line) > 0 {
+				item = append(append(item, "  "...), line...)
+			}
+		}
+	}
+
+	if _, err := e.w.Write(item); err != nil {
+		return err
+	}
+	e.count++
+	return nil
+}
+
+// Close ends the document
+func (e *exportWriter) Close() error {
+	var footer string
+	switch {
+	case e.format == "json" && e.count == 0:
+		footer = "]\n}\n"
+	case e.format == "json":
+		footer = "\n  ]\n}\n"
+	case e.count == 0:
+		footer = " []\n"
+	}
+	_, err := io.WriteString(e.w, footer)
+	return err
+}
+
+// streamExport writes every stored command to w as an export document and
+// returns how many were written
+func streamExport(db *Database, w io.Writer, format string) (int, error) {
+	bw := bufio.NewWriter(w)
+	ew, err := newExportWriter(bw, format)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.ForEachCommand(ew.Write); err != nil {
+		return ew.count, fmt.Errorf("failed to export commands: %v", err)
+	}
+	if err := ew.Close(); err != nil {
+		return ew.count, err
+	}
+	return ew.count, bw.Flush()
+}
+
+// exportToFile streams every stored command into a new file at path
+func exportToFile(db *Database, path, format string) (int, error) {
+	if format != "json" && format != "yaml" {
+		return 0, fmt.Errorf("unknown format '%s' (expected json or yaml)", format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write export: %v", err)
+	}
+	count, err := streamExport(db, f, format)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write export: %v", closeErr)
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return count, err
+}
+
+// encodeSnippet writes a single command on one line, suitable for pasting in chat
+func encodeSnippet(w io.Writer, cmd Command, format string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(cmd)
+	case "yaml":
+		var node yaml.Node
+		if err := node.Encode(cmd); err != nil {
+			return err
+		}
+		node.Style = yaml.FlowStyle
+		data, err := yaml.Marshal(&node)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		return fmt.Errorf("unknown format '%s' (expected json or yaml)", format)
+	}
+}
+
+// decodeExport parses an export document or a single command snippet and
+// returns its commands
+func decodeExport(data []byte) ([]Command, error) {
+	doc, err := decodeExportDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Commands, nil
+}
+
+// decodeExportDocument parses an export document or a single command
+// snippet. JSON is accepted as well since it is valid YAML.
+func decodeExportDocument(data []byte) (*ExportDocument, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("import document is empty")
+	}
+
+	var probe map[string]interface{}
+	if err := yaml.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse import document: %v", err)
+	}
+
+	if _, ok := probe["commands"]; ok {
+		var doc ExportDocument
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse import document: %v", err)
+		}
+		if doc.Version > exportVersion {
+			return nil, fmt.Errorf("import document version %d is newer than supported version %d", doc.Version, exportVersion)
+		}
+		return &doc, nil
+	}
+
+	var cmd Command
+	if err := yaml.Unmarshal(data, &cmd); err != nil {
+		return nil, fmt.Errorf("failed to parse command snippet: %v", err)
+	}
+	if cmd.Name == "" {
+		return nil, fmt.Errorf("import document has neither a 'commands' list nor a command 'name'")
+	}
+	return &ExportDocument{Version: exportVersion, Commands: []Command{cmd}}, nil
+}
+
+// commandLines returns the line of each command entry in an import
+// document, or nil if the document cannot be parsed
+func commandLines(data []byte) []int {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "commands" {
+			continue
+		}
+		var lines []int
+		for _, entry := range root.Content[i+1].Content {
+			lines = append(lines, entry.Line)
+		}
+		return lines
+	}
+	// A bare command snippet is a single entry
+	return []int{root.Line}
+}
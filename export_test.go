@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	commands := []Command{
+		{
+			Name:        "build",
+			Description: "Build it",
+			Command:     "go build",
+			WorkingDir:  "/src",
+			CreatedAt:   "2025-01-01 10:00:00",
+			RunCount:    4,
+			LastRunAt:   "2025-01-02 10:00:00",
+			Requires:    []string{"db"},
+			HealthCheck: &HealthCheck{Check: "http://localhost:8080", Retries: 2},
+		},
+		{Name: "test", Description: "Test it", Command: "go test ./..."},
+	}
+
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeExport(&buf, commands, format, false); err != nil {
+				t.Fatalf("encodeExport failed: %v", err)
+			}
+			if strings.Contains(buf.String(), "run_count") || strings.Contains(buf.String(), "created_at") {
+				t.Errorf("Export should not contain runtime state, got: %s", buf.String())
+			}
+
+			decoded, err := decodeExport(buf.Bytes())
+			if err != nil {
+				t.Fatalf("decodeExport failed: %v", err)
+			}
+
+			expected := []Command{exportCommand(commands[0]), exportCommand(commands[1])}
+			if !reflect.DeepEqual(decoded, expected) {
+				t.Errorf("Round trip mismatch:\n got: %+v\nwant: %+v", decoded, expected)
+			}
+		})
+	}
+}
+
+func TestExportSingleSnippet(t *testing.T) {
+	cmd := Command{Name: "deploy", Description: "Ship it", Command: "make deploy"}
+
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeExport(&buf, []Command{cmd}, format, true); err != nil {
+				t.Fatalf("encodeExport failed: %v", err)
+			}
+			if lines := strings.Count(strings.TrimSpace(buf.String()), "\n"); lines != 0 {
+				t.Errorf("Snippet should be a single line, got: %s", buf.String())
+			}
+
+			decoded, err := decodeExport(buf.Bytes())
+			if err != nil {
+				t.Fatalf("decodeExport failed: %v", err)
+			}
+			if len(decoded) != 1 || !reflect.DeepEqual(decoded[0], cmd) {
+				t.Errorf("Snippet round trip mismatch: %+v", decoded)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := encodeExport(&buf, []Command{cmd, cmd}, "json", true); err == nil {
+		t.Error("Single snippet with two commands should fail")
+	}
+}
+
+func TestDecodeExportErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":        "  \n",
+		"not yaml":     "{{{",
+		"no name":      "command: echo hi\n",
+		"future":       "version: 99\ncommands: []\n",
+		"bad commands": "commands: 5\n",
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeExport([]byte(doc)); err == nil {
+				t.Errorf("Expected error for %s document", name)
+			}
+		})
+	}
+}
+
+func TestExportWriterMatchesDocumentEncoding(t *testing.T) {
+	commands := []Command{
+		{Name: "build", Description: "Build <it>", Command: "go build", Env: map[string]string{"CGO_ENABLED": "0"}},
+		{Name: "multi", Description: "Two lines", Command: "echo one\necho two", Tags: []string{"a", "b"}},
+	}
+	doc := ExportDocument{Version: exportVersion, Commands: commands}
+
+	var want bytes.Buffer
+	enc := json.NewEncoder(&want)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := encodeExport(&got, commands, "json", false); err != nil {
+		t.Fatalf("encodeExport failed: %v", err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("JSON stream differs:\n got: %s\nwant: %s", got.String(), want.String())
+	}
+
+	want.Reset()
+	yenc := yaml.NewEncoder(&want)
+	yenc.SetIndent(2)
+	if err := yenc.Encode(doc); err != nil {
+		t.Fatal(err)
+	}
+	yenc.Close()
+	got.Reset()
+	if err := encodeExport(&got, commands, "yaml", false); err != nil {
+		t.Fatalf("encodeExport failed: %v", err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("YAML stream differs:\n got: %s\nwant: %s", got.String(), want.String())
+	}
+
+	for _, format := range []string{"json", "yaml"} {
+		got.Reset()
+		if err := encodeExport(&got, nil, format, false); err != nil {
+			t.Fatalf("encodeExport of nothing failed: %v", err)
+		}
+		decoded, err := decodeExport(got.Bytes())
+		if err != nil || len(decoded) != 0 {
+			t.Errorf("Expected empty %s document, got %v, %v:\n%s", format, decoded, err, got.String())
+		}
+	}
+}
+
+func TestStreamExport(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, name := range []string{"b", "a", "c"} {
+		if err := db.AddCommand(name, "", "echo "+name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	count, err := streamExport(db, &buf, "yaml")
+	if err != nil || count != 3 {
+		t.Fatalf("streamExport = %d, %v", count, err)
+	}
+	decoded, err := decodeExport(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decodeExport failed: %v", err)
+	}
+	var names []string
+	for _, cmd := range decoded {
+		names = append(names, cmd.Name)
+		if cmd.CreatedAt != "" {
+			t.Errorf("Expected runtime state to be stripped, got %+v", cmd)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Errorf("Expected commands in name order, got %v", names)
+	}
+
+	path := filepath.Join(tempDir, "export.json")
+	if _, err := exportToFile(db, path, "xml"); err == nil {
+		t.Error("Expected error for an unknown format")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected no file for a rejected format")
+	}
+	if count, err := exportToFile(db, path, "json"); err != nil || count != 3 {
+		t.Fatalf("exportToFile = %d, %v", count, err)
+	}
+	data, _ := os.ReadFile(path)
+	if decoded, err := decodeExport(data); err != nil || len(decoded) != 3 {
+		t.Errorf("Expected 3 commands in the file, got %d, %v", len(decoded), err)
+	}
+}
+
+func TestCommandLines(t *testing.T) {
+	doc := "version: 1\ncommands:\n  - name: a\n    command: x\n\n  - name: b\n    command: y\n"
+	if lines := commandLines([]byte(doc)); !reflect.DeepEqual(lines, []int{3, 6}) {
+		t.Errorf("Expected lines 3 and 6, got %v", lines)
+	}
+	if lines := commandLines([]byte("name: a\ncommand: x\n")); !reflect.DeepEqual(lines, []int{1}) {
+		t.Errorf("Expected a snippet on line 1, got %v", lines)
+	}
+	if lines := commandLines([]byte("[")); lines != nil {
+		t.Errorf("Expected no lines for an invalid document, got %v", lines)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+)
+
+// runForeground runs cmd while afv waits for it. Signals asking afv to stop
+// are forwarded to the child instead, so afv exits with the child's status
+// rather than leaving it orphaned. Once timeout expires the child is sent
+// SIGTERM and killed if it has not exited after grace; a timeout of zero
+// runs the command without a limit. limits are applied to the child as it
+// starts.
+func runForeground(cmd *exec.Cmd, limits ResourceLimits, timeout, grace time.Duration) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := startLimited(cmd, limits); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		case sig := <-signals:
+			if err := forwardSignal(cmd.Process, sig); err != nil {
+				warn("failed to forward %v to the command: %v", sig, err)
+			}
+		case <-expired:
+			fmt.Fprintf(os.Stderr, "Command timed out after %s, stopping it...\n", timeout)
+			if err := interruptProcess(cmd.Process); err != nil {
+				_ = cmd.Process.Kill()
+			}
+			select {
+			case <-done:
+			case <-time.After(grace):
+				fmt.Fprintf(os.Stderr, "Command did not exit within %s, killing it.\n", grace)
+				_ = cmd.Process.Kill()
+				<-done
+			}
+			return &TimeoutError{Timeout: timeout}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunForegroundForwardsSignals(t *testing.T) {
+	cmd := exec.Command("sh", "-c", `trap "exit 7" TERM; sleep 5 & wait`)
+	done := make(chan error, 1)
+	go func() {
+		done <- runForeground(cmd, ResourceLimits{}, 0, 0)
+	}()
+
+	// Give the shell time to install its trap
+	time.Sleep(300 * time.Millisecond)
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("Failed to signal the test process: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if code := exitCode(err); code != 7 {
+			t.Errorf("Expected the child to handle SIGTERM and exit with 7, got %d (%v)", code, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The child did not receive the forwarded signal")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Global flags selecting the output format of list, show and info
+const (
+	outputFlag      = "output"
+	outputShortFlag = "o"
+)
+
+// Output formats of list, show and info
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// extractOutputFormat removes --output and -o from args and returns the
+// format they select, table by default. afv export keeps its own --output,
+// which names the file to write.
+func extractOutputFormat(args []string) ([]string, string, error) {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			if arg == "export" {
+				return args, outputTable, nil
+			}
+			break
+		}
+	}
+	rest, values, err := extractGlobalFlags(args, outputFlag, outputShortFlag)
+	if err != nil {
+		return nil, "", err
+	}
+	format := firstNonEmpty(values[outputFlag], values[outputShortFlag], outputTable)
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return rest, format, nil
+	default:
+		return nil, "", fmt.Errorf("unknown output format '%s' (expected table, json or yaml)", format)
+	}
+}
+
+// writeData writes v to w as indented JSON or as YAML
+func writeData(w io.Writer, format string, v any) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("format '%s' is not a data format", format)
+	}
+}
+
+// infoData is what 'afv info' writes as JSON or YAML
+type infoData struct {
+	Workspace string `json:"workspace" yaml:"workspace"`
+	Database  string `json:"database" yaml:"database"`
+	Commands  int    `json:"commands" yaml:"commands"`
+	Project   string `json:"project,omitempty" yaml:"project,omitempty"`
+}
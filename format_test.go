@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+func TestExtractOutputFormat(t *testing.T) {
+	for _, tc := range []struct {
+		args   []string
+		rest   []string
+		format string
+	}{
+		{[]string{"list"}, []string{"list"}, outputTable},
+		{[]string{"list", "-o", "json"}, []string{"list"}, outputJSON},
+		{[]string{"--output=yaml", "info"}, []string{"info"}, outputYAML},
+		{[]string{"export", "--output", "cmds.yaml"}, []string{"export", "--output", "cmds.yaml"}, outputTable},
+	} {
+		rest, format, err := extractOutputFormat(tc.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.args, err)
+			continue
+		}
+		if !slices.Equal(rest, tc.rest) || format != tc.format {
+			t.Errorf("%v: got %v and %s, want %v and %s", tc.args, rest, format, tc.rest, tc.format)
+		}
+	}
+
+	if _, _, err := extractOutputFormat([]string{"list", "-o", "xml"}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestWriteData(t *testing.T) {
+	data := infoData{Workspace: "default", Database: "/tmp/afv.db", Commands: 2}
+
+	var out bytes.Buffer
+	if err := writeData(&out, outputJSON, data); err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"workspace\": \"default\",\n  \"database\": \"/tmp/afv.db\",\n  \"commands\": 2\n}\n"
+	if out.String() != want {
+		t.Errorf("Unexpected JSON %q", out.String())
+	}
+
+	out.Reset()
+	if err := writeData(&out, outputYAML, data); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "workspace: default\ndatabase: /tmp/afv.db\ncommands: 2\n" {
+		t.Errorf("Unexpected YAML %q", out.String())
+	}
+}
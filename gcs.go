@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gcsEndpoint is the Google Cloud Storage API, replaced by the emulator
+// named in STORAGE_EMULATOR_HOST
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// gcsStore is an object in a Google Cloud Storage bucket. Revisions are
+// object generations, so writes are conditional on the generation read.
+type gcsStore struct {
+	endpoint string
+	bucket   string
+	object   string
+	token    string
+}
+
+// openGCSStore returns the store of object in bucket. The access token is
+// taken from GOOGLE_OAUTH_ACCESS_TOKEN or else from gcloud.
+func openGCSStore(bucket, object string) (*gcsStore, error) {
+	if object == "" {
+		return nil, fmt.Errorf("gs://%s needs an object name, e.g. gs://%s/afvikle.json", bucket, bucket)
+	}
+	s := &gcsStore{endpoint: gcsEndpoint, bucket: bucket, object: object, token: os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")}
+	if emulator := os.Getenv("STORAGE_EMULATOR_HOST"); emulator != "" {
+		s.endpoint = strings.TrimSuffix(emulator, "/")
+		if !strings.Contains(s.endpoint, "://") {
+			s.endpoint = "http://" + s.endpoint
+		}
+		return s, nil
+	}
+	if s.token == "" {
+		out, err := exec.Command("gcloud", "auth", "print-access-token").Output()
+		if err != nil {
+			return nil, fmt.Errorf("set GOOGLE_OAUTH_ACCESS_TOKEN or log in with gcloud to sync with gs://%s: %v", bucket, err)
+		}
+		s.token = strings.TrimSpace(string(out))
+	}
+	return s, nil
+}
+
+// authorize adds the access token to req, if there is one
+func (s *gcsStore) authorize(req *http.Request) {
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+}
+
+func (s *gcsStore) Read() ([]byte, string, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(s.object))
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	s.authorize(req)
+	resp, data, err := storeRequest(req)
+	if err != nil {
+		return nil, "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return data, resp.Header.Get("X-Goog-Generation"), nil
+	case http.StatusNotFound:
+		return nil, "", nil
+	}
+	return nil, "", storeError(resp, data)
+}
+
+func (s *gcsStore) Write(data []byte, revision string) (string, error) {
+	// Generation 0 only matches an object that does not exist yet
+	generation := revision
+	if generation == "" {
+		generation = "0"
+	}
+	query := url.Values{"uploadType": {"media"}, "name": {s.object}, "ifGenerationMatch": {generation}}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", s.endpoint, url.PathEscape(s.bucket), query.Encode())
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.authorize(req)
+	resp, body, err := storeRequest(req)
+	if err != nil {
+		return "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// The response is the metadata of the new object generation
+		var object struct {
+			Generation string `json:"generation"`
+		}
+		if err := json.Unmarshal(body, &object); err != nil {
+			return "", fmt.Errorf("%s: invalid upload response: %v", resp.Request.URL.Host, err)
+		}
+		return object.Generation, nil
+	case http.StatusPreconditionFailed:
+		return "", errStaleRevision
+	}
+	return "", storeError(resp, body)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Group execution modes
+const (
+	GroupSequential = "sequential"
+	GroupParallel   = "parallel"
+)
+
+// Group is a named list of stored commands run as one unit
+type Group struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Steps       []string `json:"steps" yaml:"steps"`
+	Mode        string   `json:"mode" yaml:"mode"`
+	CreatedAt   string   `json:"created_at" yaml:"created_at"`
+}
+
+// normalizeGroup trims the fields of a group, defaults its mode and checks
+// that it is valid
+func normalizeGroup(group *Group) error {
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" {
+		return fmt.Errorf("group name is required")
+	}
+	var steps []string
+	for _, step := range group.Steps {
+		if step = strings.TrimSpace(step); step != "" {
+			steps = append(steps, step)
+		}
+	}
+	if len(steps) == 0 {
+		return fmt.Errorf("group '%s' needs at least one step", group.Name)
+	}
+	group.Steps = steps
+
+	switch group.Mode = strings.TrimSpace(group.Mode); group.Mode {
+	case "":
+		group.Mode = GroupSequential
+	case GroupSequential, GroupParallel:
+	default:
+		return fmt.Errorf("unknown group mode '%s' (expected sequential or parallel)", group.Mode)
+	}
+	return nil
+}
+
+// AddGroup stores a new group whose steps must all be stored commands
+func (d *Database) AddGroup(group Group) error {
+	if err := normalizeGroup(&group); err != nil {
+		return err
+	}
+	group.CreatedAt = time.Now().Format(timeLayout)
+
+	return d.update("AddGroup", func(tx *bbolt.Tx) error {
+		b := tx.Bucket(groupsBucket)
+		if b.Get([]byte(group.Name)) != nil {
+			return fmt.Errorf("group '%s' already exists", group.Name)
+		}
+		for _, step := range group.Steps {
+			if tx.Bucket(commandsBucket).Get([]byte(step)) == nil {
+				return fmt.Errorf("command '%s' not found", step)
+			}
+		}
+		data, err := json.Marshal(group)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(group.Name), data)
+	})
+}
+
+// GetGroup returns a group by name
+func (d *Database) GetGroup(name string) (*Group, error) {
+	var group Group
+	err := d.view("GetGroup", func(tx *bbolt.Tx) error {
+		data := tx.Bucket(groupsBucket).Get([]byte(name))
+		if data == nil {
+			return fmt.Errorf("group '%s' not found", name)
+		}
+		return json.Unmarshal(data, &group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GetAllGroups returns all groups in name order
+func (d *Database) GetAllGroups() ([]Group, error) {
+	var groups []Group
+	err := d.view("GetAllGroups", func(tx *bbolt.Tx) error {
+		return tx.Bucket(groupsBucket).ForEach(func(k, v []byte) error {
+			var group Group
+			if err := json.Unmarshal(v, &group); err != nil {
+				return err
+			}
+			groups = append(groups, group)
+			return nil
+		})
+	})
+	return groups, err
+}
+
+// DeleteGroup removes a group; its commands are kept
+func (d *Database) DeleteGroup(name string) error {
+	return d.update("DeleteGroup", func(tx *bbolt.Tx) error {
+		b := tx.Bucket(groupsBucket)
+		if b.Get([]byte(name)) == nil {
+			return fmt.Errorf("group '%s' not found", name)
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
+// RunGroup runs the steps of a group with opts in the group's mode
+func RunGroup(db *Database, group *Group, opts RunOptions, keepGoing bool) error {
+	steps := make([]RunStep, len(group.Steps))
+	for i, name := range group.Steps {
+		steps[i] = RunStep{Name: name, Opts: opts}
+	}
+	if group.Mode == GroupParallel {
+		return RunParallel(db, steps)
+	}
+	return RunSequence(db, steps, keepGoing)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, name := range []string{"db", "api"} {
+		if err := db.AddCommand(name, "", "true", ""); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+
+	if err := db.AddGroup(Group{Name: "dev", Steps: []string{"db", " api ", ""}}); err != nil {
+		t.Fatalf("AddGroup failed: %v", err)
+	}
+	group, err := db.GetGroup("dev")
+	if err != nil {
+		t.Fatalf("GetGroup failed: %v", err)
+	}
+	if group.Mode != GroupSequential || !reflect.DeepEqual(group.Steps, []string{"db", "api"}) {
+		t.Errorf("Expected a sequential group of db and api, got %+v", group)
+	}
+
+	if err := db.AddGroup(Group{Name: "dev", Steps: []string{"db"}}); err == nil {
+		t.Error("Expected error for a duplicate group")
+	}
+	if err := db.AddGroup(Group{Name: "web", Steps: []string{"missing"}}); err == nil {
+		t.Error("Expected error for a step that is not a stored command")
+	}
+	if err := db.AddGroup(Group{Name: "web", Steps: []string{"db"}, Mode: "random"}); err == nil {
+		t.Error("Expected error for an unknown mode")
+	}
+	if err := db.AddGroup(Group{Name: "empty"}); err == nil {
+		t.Error("Expected error for a group without steps")
+	}
+
+	if err := db.DeleteGroup("dev"); err != nil {
+		t.Fatalf("DeleteGroup failed: %v", err)
+	}
+	groups, err := db.GetAllGroups()
+	if err != nil || len(groups) != 0 {
+		t.Errorf("Expected no groups after deleting, got %+v, %v", groups, err)
+	}
+	if _, err := db.GetCommand("db"); err != nil {
+		t.Errorf("Deleting a group must keep its commands: %v", err)
+	}
+}
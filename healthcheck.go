@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Health check defaults used when a field is not set
+const (
+	defaultHealthRetries  = 3
+	defaultHealthInterval = 2 * time.Second
+	defaultHealthTimeout  = 5 * time.Second
+)
+
+// HealthCheck describes how to verify that a command did what it should.
+// Check is either an http(s) URL that must answer with a non-error status or
+// a command line that must exit with status 0.
+type HealthCheck struct {
+	Check    string `json:"check" yaml:"check"`
+	Retries  int    `json:"retries,omitempty" yaml:"retries,omitempty"`
+	Interval string `json:"interval,omitempty" yaml:"interval,omitempty"`
+	Timeout  string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// isHTTP reports whether the check is an HTTP probe
+func (h *HealthCheck) isHTTP() bool {
+	return strings.HasPrefix(h.Check, "http://") || strings.HasPrefix(h.Check, "https://")
+}
+
+// settings returns the retry count, interval and per-attempt timeout with defaults applied
+func (h *HealthCheck) settings() (int, time.Duration, time.Duration, error) {
+	retries := h.Retries
+	if retries == 0 {
+		retries = defaultHealthRetries
+	}
+	if retries < 0 {
+		return 0, 0, 0, fmt.Errorf("health check retries must not be negative")
+	}
+
+	interval := defaultHealthInterval
+	if h.Interval != "" {
+		d, err := time.ParseDuration(h.Interval)
+		if err != nil || d < 0 {
+			return 0, 0, 0, fmt.Errorf("invalid health check interval '%s'", h.Interval)
+		}
+		interval = d
+	}
+
+	timeout := defaultHealthTimeout
+	if h.Timeout != "" {
+		d, err := time.ParseDuration(h.Timeout)
+		if err != nil || d <= 0 {
+			return 0, 0, 0, fmt.Errorf("invalid health check timeout '%s'", h.Timeout)
+		}
+		timeout = d
+	}
+
+	return retries, interval, timeout, nil
+}
+
+// validate normalizes the health check and verifies its settings
+func (h *HealthCheck) validate() error {
+	h.Check = strings.TrimSpace(h.Check)
+	if h.Check == "" {
+		return fmt.Errorf("health check must not be empty")
+	}
+	_, _, _, err := h.settings()
+	return err
+}
+
+// RunHealthCheck evaluates the health check until it passes or all attempts
+// are used up. Command checks run in dir.
+func RunHealthCheck(h *HealthCheck, dir string) error {
+	retries, interval, timeout, err := h.settings()
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= retries; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		lastErr = checkHealthOnce(ctx, h, dir)
+		cancel()
+
+		if lastErr == nil {
+			fmt.Printf("Health check passed: %s\n", h.Check)
+			return nil
+		}
+
+		fmt.Printf("Health check attempt %d/%d failed: %v\n", attempt, retries, lastErr)
+		if attempt < retries {
+			time.Sleep(interval)
+		}
+	}
+
+	return fmt.Errorf("health check '%s' failed after %d attempt(s): %v", h.Check, retries, lastErr)
+}
+
+// checkHealthOnce performs a single health check attempt
+func checkHealthOnce(ctx context.Context, h *HealthCheck, dir string) error {
+	if h.isHTTP() {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.Check, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	}
+
+	parts := strings.Fields(h.Check)
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return fmt.Errorf("%v: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunHealthCheckHTTP(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt to exercise retries
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	h := &HealthCheck{Check: server.URL, Retries: 3, Interval: "10ms"}
+	if err := RunHealthCheck(h, ""); err != nil {
+		t.Fatalf("Health check should pass on retry: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 attempts, got %d", calls)
+	}
+}
+
+func TestRunHealthCheckHTTPFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	h := &HealthCheck{Check: server.URL, Retries: 2, Interval: "10ms"}
+	err := RunHealthCheck(h, "")
+	if err == nil {
+		t.Fatal("Health check should fail")
+	}
+	if !strings.Contains(err.Error(), "after 2 attempt(s)") {
+		t.Errorf("Error should report the number of attempts, got: %v", err)
+	}
+}
+
+func TestRunHealthCheckCommand(t *testing.T) {
+	dir, err := os.MkdirTemp("", "afvikle_health_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := RunHealthCheck(&HealthCheck{Check: "go version", Retries: 1}, dir); err != nil {
+		t.Errorf("Command health check should pass: %v", err)
+	}
+
+	err = RunHealthCheck(&HealthCheck{Check: "afvikle-no-such-binary", Retries: 1}, dir)
+	if err == nil {
+		t.Error("Command health check with a missing binary should fail")
+	}
+}
+
+func TestHealthCheckValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		check HealthCheck
+		valid bool
+	}{
+		{"Defaults", HealthCheck{Check: "http://localhost"}, true},
+		{"Empty check", HealthCheck{Check: "  "}, false},
+		{"Negative retries", HealthCheck{Check: "true", Retries: -1}, false},
+		{"Bad interval", HealthCheck{Check: "true", Interval: "often"}, false},
+		{"Zero timeout", HealthCheck{Check: "true", Timeout: "0s"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check.validate()
+			if tt.valid && err != nil {
+				t.Errorf("Expected valid, got: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// RunRecord is one entry of the run history
+type RunRecord struct {
+	ID        uint64        `json:"id" yaml:"id"`
+	Name      string        `json:"name,omitempty" yaml:"name,omitempty"`
+	Command   string        `json:"command" yaml:"command"`
+	Dir       string        `json:"dir,omitempty" yaml:"dir,omitempty"`
+	StartedAt time.Time     `json:"started_at" yaml:"started_at"`
+	Duration  time.Duration `json:"duration_ns" yaml:"duration"`
+	ExitCode  int           `json:"exit_code" yaml:"exit_code"`
+	Success   bool          `json:"success" yaml:"success"`
+	// Elapsed is the wall time of the whole run including hooks, readiness
+	// probes and cleanup, where Duration covers the command only. JSON holds
+	// both in nanoseconds, YAML as durations such as 1.5s.
+	Elapsed time.Duration `json:"elapsed_ns,omitempty" yaml:"elapsed,omitempty"`
+	// Args are the extra arguments of a stored command run, or the whole
+	// command of an ad-hoc run; Vars, EnvFile and StdinFile are the
+	// placeholder values, env file and stdin file overrides used. Together
+	// they allow rerunning the run.
+	Args      []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Vars      map[string]string `json:"vars,omitempty" yaml:"vars,omitempty"`
+	EnvFile   string            `json:"env_file,omitempty" yaml:"env_file,omitempty"`
+	StdinFile string            `json:"stdin_file,omitempty" yaml:"stdin_file,omitempty"`
+	// LogFile holds the captured output of the run
+	LogFile string `json:"log_file,omitempty" yaml:"log_file,omitempty"`
+}
+
+// exitCode returns the exit status of a finished process, 0 for success,
+// 128+N if it was killed by signal N, 124 if it timed out and -1 if it could
+// not be started
+func exitCode(err error) int {
+	if code, ok := childExitCode(err); ok {
+		return code
+	}
+	if err == nil {
+		return 0
+	}
+	return -1
+}
+
+// childExitCode returns the exit status of a child process that ran but
+// failed, reporting false if err does not come from such a process
+func childExitCode(err error) (int, bool) {
+	if isTimeout(err) {
+		return timeoutExitCode, true
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+	if code, ok := signalExitCode(exitErr.ProcessState); ok {
+		return code, true
+	}
+	return exitErr.ExitCode(), true
+}
+
+// AddRunRecord appends a record to the run history. Records are keyed by a
+// sequence number so iteration yields them in the order they were added.
+func (d *Database) AddRunRecord(record RunRecord) error {
+	return d.update("AddRunRecord", func(tx *bbolt.Tx) error {
+		b := tx.Bucket(historyBucket)
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, id)
+		return b.Put(key, data)
+	})
+}
+
+// GetRunHistory returns the run history of a command, or of all runs if name
+// is empty, oldest first
+func (d *Database) GetRunHistory(name string) ([]RunRecord, error) {
+	var records []RunRecord
+	err := d.view("GetRunHistory", func(tx *bbolt.Tx) error {
+		return tx.Bucket(historyBucket).ForEach(func(k, v []byte) error {
+			var record RunRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if name == "" || record.Name == name {
+				records = append(records, record)
+			}
+			return nil
+		})
+	})
+	return records, err
+}
+
+// lastRunByName returns the most recent run of every command in records
+func lastRunByName(records []RunRecord) map[string]RunRecord {
+	last := make(map[string]RunRecord)
+	for _, record := range records {
+		if record.Name != "" {
+			last[record.Name] = record
+		}
+	}
+	return last
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRunHistory(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	start := time.Now()
+	for i, name := range []string{"build", "test", "build"} {
+		record := RunRecord{Name: name, Command: "make", StartedAt: start.Add(time.Duration(i) * time.Minute), Duration: time.Duration(i+1) * time.Second, Success: true}
+		if err := db.AddRunRecord(record); err != nil {
+			t.Fatalf("AddRunRecord failed: %v", err)
+		}
+	}
+
+	records, err := db.GetRunHistory("build")
+	if err != nil {
+		t.Fatalf("GetRunHistory failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 build runs, got %d", len(records))
+	}
+	if records[0].Duration != time.Second || records[1].Duration != 3*time.Second {
+		t.Errorf("Expected runs oldest first with precise durations, got %+v", records)
+	}
+	if records[0].ID >= records[1].ID {
+		t.Errorf("Expected increasing record IDs, got %d and %d", records[0].ID, records[1].ID)
+	}
+
+	all, _ := db.GetRunHistory("")
+	if len(all) != 3 {
+		t.Errorf("Expected 3 runs in total, got %d", len(all))
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if exitCode(nil) != 0 {
+		t.Error("Expected exit code 0 for success")
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if code := exitCode(exec.Command("sh", "-c", "exit 3").Run()); code != 3 {
+		t.Errorf("Expected exit code 3, got %d", code)
+	}
+	if code := exitCode(exec.Command("afv-no-such-binary").Run()); code != -1 {
+		t.Errorf("Expected -1 for a command that could not start, got %d", code)
+	}
+	if code := exitCode(exec.Command("sh", "-c", "kill -TERM $$").Run()); code != 143 {
+		t.Errorf("Expected 143 for a command killed by SIGTERM, got %d", code)
+	}
+	if _, ok := childExitCode(fmt.Errorf("run failed: %w", exec.Command("sh", "-c", "exit 4").Run())); !ok {
+		t.Error("Expected the exit status of a wrapped error")
+	}
+	if _, ok := childExitCode(fmt.Errorf("command not found")); ok {
+		t.Error("Errors from afv itself are not child exit statuses")
+	}
+}
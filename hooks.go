@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// runHook executes a pre or post hook of a command in dir with the
+// command's environment
+func runHook(command *Command, kind, line, dir string, stdout, stderr io.Writer) error {
+	fmt.Fprintf(stdout, "Running %s hook: %s\n", kind, line)
+	hook := *command
+	hook.Command = line
+	hook.Script = false
+	hook.Steps = nil
+	cmd, _, err := newExecCmd(&hook, dir)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	s := startSpan("exec.hook", "name", command.Name, "hook", kind, "command", line, "dir", dir)
+	logExec(cmd)
+	err = cmd.Run()
+	s.End(err)
+	if err != nil {
+		return fmt.Errorf("%s hook for '%s' failed: %v", kind, command.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// promptConflict returns a resolver that asks on in/out what to do with each
+// name collision: keep the local command, take the incoming one, import it
+// under a new name or show the differences first
+func promptConflict(in io.Reader, out io.Writer) ConflictResolver {
+	reader := bufio.NewReader(in)
+	readLine := func(prompt string) (string, error) {
+		fmt.Fprint(out, prompt)
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("import aborted: %v", err)
+		}
+		return strings.TrimSpace(line), nil
+	}
+
+	return func(local Command, incoming *Command) (string, error) {
+		fmt.Fprintf(out, "Command '%s' already exists.\n", incoming.Name)
+		fmt.Fprintf(out, "  local:    %s\n", local.Command)
+		fmt.Fprintf(out, "  incoming: %s\n", incoming.Command)
+		for {
+			answer, err := readLine("[k]eep local, [t]ake incoming, [r]ename incoming, show [d]iff, [q]uit: ")
+			if err != nil {
+				return "", err
+			}
+
+			switch strings.ToLower(answer) {
+			case "k", "keep":
+				return ConflictSkip, nil
+			case "t", "take":
+				return ConflictOverwrite, nil
+			case "r", "rename":
+				name, err := readLine(fmt.Sprintf("New name (empty for '%s-N'): ", incoming.Name))
+				if err != nil {
+					return "", err
+				}
+				if name != "" {
+					incoming.Name = name
+				}
+				return ConflictRename, nil
+			case "d", "diff":
+				changes := diffCommandFields(local, *incoming)
+				if len(changes) == 0 {
+					fmt.Fprintln(out, "  The commands are identical.")
+				}
+				for _, change := range changes {
+					fmt.Fprintf(out, "  %s\n", change)
+				}
+			case "q", "quit":
+				return "", fmt.Errorf("import aborted")
+			default:
+				fmt.Fprintf(out, "Unknown answer '%s'.\n", answer)
+			}
+		}
+	}
+}
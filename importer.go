@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Conflict strategies for commands whose name already exists
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictRename    = "rename"
+)
+
+// validateConflictStrategy checks that a conflict strategy is known
+func validateConflictStrategy(strategy string) error {
+	switch strategy {
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+		return nil
+	}
+	return fmt.Errorf("unknown conflict strategy '%s' (expected skip, overwrite or rename)", strategy)
+}
+
+// ImportResult summarizes what an import did, or would do in a dry run,
+// with each command
+type ImportResult struct {
+	Added   []string
+	Updated []string
+	Renamed map[string]string
+	Skipped []string
+	Failed  []ImportFailure
+	// Lines holds the line of each entry in the imported document, if known,
+	// so failures can point at it
+	Lines  []int
+	DryRun bool
+}
+
+// ImportFailure is a command that could not be imported
+type ImportFailure struct {
+	// Entry is the position of the command in the document, counting from 1
+	Entry int
+	Name  string
+	Err   error
+}
+
+// fail records that the command at index i of the document failed
+func (r *ImportResult) fail(i int, name string, err error) {
+	r.Failed = append(r.Failed, ImportFailure{Entry: i + 1, Name: name, Err: err})
+}
+
+// importPlan holds the writes of an import; replacedAt is the document
+// index of each replaced command
+type importPlan struct {
+	added      []Command
+	replaced   []Command
+	replacedAt []int
+}
+
+// ConflictResolver decides what to do with an incoming command whose name
+// is already taken by local, returning one of the conflict strategies. For
+// ConflictRename it may set incoming.Name to the new name; otherwise the
+// lowest free numeric suffix is used.
+type ConflictResolver func(local Command, incoming *Command) (string, error)
+
+// fixedConflict resolves every collision with the same strategy
+func fixedConflict(strategy string) ConflictResolver {
+	return func(local Command, incoming *Command) (string, error) {
+		return strategy, nil
+	}
+}
+
+// ImportCommands stores commands, resolving name collisions with strategy.
+// Commands for which owned returns true are always overwritten, which lets a
+// pack update its own commands regardless of the strategy. New commands are
+// written with AddCommands, so large imports take few transactions.
+func ImportCommands(db *Database, commands []Command, strategy string, owned func(name string) bool) (*ImportResult, error) {
+	if err := validateConflictStrategy(strategy); err != nil {
+		return nil, err
+	}
+	return ImportCommandsWith(db, commands, fixedConflict(strategy), owned)
+}
+
+// ImportCommandsWith stores commands like ImportCommands, asking resolve what
+// to do with each name collision. An error from resolve aborts the import
+// before anything is written.
+func ImportCommandsWith(db *Database, commands []Command, resolve ConflictResolver, owned func(name string) bool) (*ImportResult, error) {
+	result, plan, err := planImport(db, commands, resolve, owned)
+	if err != nil {
+		return nil, err
+	}
+
+	var progress func(done, total int)
+	if len(plan.added) > batchSize {
+		progress = terminalProgress("Importing")
+	}
+	if err := db.AddCommands(plan.added, progress); err != nil {
+		return nil, err
+	}
+	for i, cmd := range plan.replaced {
+		if err := db.ReplaceCommand(cmd); err != nil {
+			result.fail(plan.replacedAt[i], cmd.Name, err)
+			result.Updated = slices.DeleteFunc(result.Updated, func(name string) bool { return name == cmd.Name })
+		}
+	}
+	return result, nil
+}
+
+// PreviewImport reports what ImportCommandsWith would do without writing
+// anything
+func PreviewImport(db *Database, commands []Command, resolve ConflictResolver, owned func(name string) bool) (*ImportResult, error) {
+	result, _, err := planImport(db, commands, resolve, owned)
+	if err != nil {
+		return nil, err
+	}
+	result.DryRun = true
+	return result, nil
+}
+
+// planImport validates commands and resolves their name collisions,
+// returning the result of the import and the writes it takes
+func planImport(db *Database, commands []Command, resolve ConflictResolver, owned func(name string) bool) (*ImportResult, importPlan, error) {
+	var plan importPlan
+	stored, err := db.GetAllCommands()
+	if err != nil {
+		return nil, plan, fmt.Errorf("failed to get commands: %v", err)
+	}
+	aliases, err := db.GetAliases()
+	if err != nil {
+		return nil, plan, fmt.Errorf("failed to get aliases: %v", err)
+	}
+	taken := make(map[string]bool, len(stored)+len(commands))
+	existing := make(map[string]Command, len(stored))
+	for _, cmd := range stored {
+		taken[cmd.Name] = true
+		existing[cmd.Name] = exportCommand(cmd)
+	}
+
+	result := &ImportResult{Renamed: map[string]string{}}
+	var added []Command
+	pending := map[string]int{}
+	for i, cmd := range commands {
+		cmd = exportCommand(cmd)
+		if err := normalizeCommand(&cmd); err != nil {
+			result.fail(i, cmd.Name, err)
+			continue
+		}
+		if target, ok := aliases[cmd.Name]; ok {
+			result.fail(i, cmd.Name, fmt.Errorf("'%s' is already an alias of '%s'", cmd.Name, target))
+			continue
+		}
+
+		if !taken[cmd.Name] {
+			taken[cmd.Name] = true
+			pending[cmd.Name] = len(added)
+			added = append(added, cmd)
+			result.Added = append(result.Added, cmd.Name)
+			continue
+		}
+
+		original := cmd.Name
+		effective := ConflictOverwrite
+		if owned == nil || !owned(cmd.Name) {
+			local, ok := existing[original]
+			if i, isPending := pending[original]; isPending {
+				local, ok = added[i], true
+			}
+			if !ok {
+				local = Command{Name: original}
+			}
+			effective, err = resolve(local, &cmd)
+			if err != nil {
+				return nil, plan, err
+			}
+			if err := validateConflictStrategy(effective); err != nil {
+				return nil, plan, err
+			}
+		}
+
+		switch effective {
+		case ConflictSkip:
+			result.Skipped = append(result.Skipped, original)
+		case ConflictOverwrite:
+			cmd.Name = original
+			// A command added earlier in the same import is replaced before it is written
+			if i, ok := pending[cmd.Name]; ok {
+				added[i] = cmd
+			} else {
+				plan.replaced = append(plan.replaced, cmd)
+				plan.replacedAt = append(plan.replacedAt, i)
+			}
+			result.Updated = append(result.Updated, cmd.Name)
+		case ConflictRename:
+			if cmd.Name == original || taken[cmd.Name] {
+				cmd.Name = availableName(taken, original)
+			}
+			taken[cmd.Name] = true
+			pending[cmd.Name] = len(added)
+			added = append(added, cmd)
+			result.Renamed[original] = cmd.Name
+		}
+	}
+
+	plan.added = added
+	return result, plan, nil
+}
+
+// availableName returns name with the lowest numeric suffix that is not taken
+func availableName(taken map[string]bool, name string) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// Print writes a per-command report followed by a summary line. Failures
+// name the position of the command in the document, and its line if known.
+func (r *ImportResult) Print() {
+	verb := func(done, planned string) string {
+		if r.DryRun {
+			return "Would " + planned
+		}
+		return done
+	}
+	for _, name := range r.Added {
+		fmt.Printf("%s '%s'.\n", verb("Imported", "import"), name)
+	}
+	for _, name := range r.Updated {
+		fmt.Printf("%s '%s'.\n", verb("Updated", "update"), name)
+	}
+	for _, original := range slices.Sorted(maps.Keys(r.Renamed)) {
+		fmt.Printf("%s '%s' as '%s'.\n", verb("Imported", "import"), original, r.Renamed[original])
+	}
+	for _, name := range r.Skipped {
+		fmt.Printf("%s '%s': a command with this name already exists.\n", verb("Skipped", "skip"), name)
+	}
+	for _, f := range r.Failed {
+		where := fmt.Sprintf("entry %d", f.Entry)
+		if f.Entry <= len(r.Lines) {
+			where += fmt.Sprintf(" (line %d)", r.Lines[f.Entry-1])
+		}
+		if f.Name != "" {
+			where += fmt.Sprintf(" '%s'", f.Name)
+		}
+		fmt.Printf("Failed to import %s: %v\n", where, f.Err)
+	}
+	if r.DryRun {
+		fmt.Printf("Dry run: would import %d command(s), update %d, rename %d, skip %d, fail %d. Nothing was changed.\n",
+			len(r.Added), len(r.Updated), len(r.Renamed), len(r.Skipped), len(r.Failed))
+		return
+	}
+	fmt.Printf("Imported %d command(s), updated %d, renamed %d, skipped %d, failed %d.\n",
+		len(r.Added), len(r.Updated), len(r.Renamed), len(r.Skipped), len(r.Failed))
+}
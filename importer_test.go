@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportCommandsConflictStrategies(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("build", "Old build", "make", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	incoming := []Command{{Name: "build", Description: "New build", Command: "go build"}}
+
+	result, err := ImportCommands(db, incoming, ConflictSkip, nil)
+	if err != nil {
+		t.Fatalf("ImportCommands failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Skipped, []string{"build"}) {
+		t.Errorf("Expected 'build' to be skipped, got %+v", result)
+	}
+
+	result, err = ImportCommands(db, incoming, ConflictRename, nil)
+	if err != nil {
+		t.Fatalf("ImportCommands failed: %v", err)
+	}
+	if result.Renamed["build"] != "build-2" {
+		t.Errorf("Expected 'build' to be renamed to 'build-2', got %+v", result.Renamed)
+	}
+
+	result, err = ImportCommands(db, incoming, ConflictOverwrite, nil)
+	if err != nil {
+		t.Fatalf("ImportCommands failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Updated, []string{"build"}) {
+		t.Errorf("Expected 'build' to be updated, got %+v", result)
+	}
+	cmd, err := db.GetCommand("build")
+	if err != nil {
+		t.Fatalf("GetCommand failed: %v", err)
+	}
+	if cmd.Command != "go build" || cmd.CreatedAt == "" {
+		t.Errorf("Overwrite should replace the command and keep its creation time, got %+v", cmd)
+	}
+
+	if _, err := ImportCommands(db, incoming, "merge", nil); err == nil {
+		t.Error("Expected error for unknown conflict strategy")
+	}
+}
+
+func TestImportCommandsOwnedOverwrite(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("lint", "Lint", "golint", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	owned := func(name string) bool { return name == "lint" }
+	result, err := ImportCommands(db, []Command{{Name: "lint", Command: "go vet ./..."}}, ConflictSkip, owned)
+	if err != nil {
+		t.Fatalf("ImportCommands failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Updated, []string{"lint"}) {
+		t.Errorf("Owned commands should be overwritten regardless of strategy, got %+v", result)
+	}
+}
+
+func TestImportCommandsPromptConflict(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, name := range []string{"build", "test", "lint"} {
+		if err := db.AddCommand(name, "", "make "+name, ""); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+	incoming := []Command{
+		{Name: "build", Command: "go build"},
+		{Name: "test", Command: "go test"},
+		{Name: "lint", Command: "go vet"},
+	}
+
+	// Diff then keep build, take test, rename lint to vet
+	var out bytes.Buffer
+	answers := strings.NewReader("d\nk\nt\nr\nvet\n")
+	result, err := ImportCommandsWith(db, incoming, promptConflict(answers, &out), nil)
+	if err != nil {
+		t.Fatalf("ImportCommandsWith failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Skipped, []string{"build"}) || !reflect.DeepEqual(result.Updated, []string{"test"}) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if result.Renamed["lint"] != "vet" {
+		t.Errorf("Expected 'lint' to be imported as 'vet', got %+v", result.Renamed)
+	}
+	if !strings.Contains(out.String(), `~ command: "make build" -> "go build"`) {
+		t.Errorf("Expected the diff in the prompt output:\n%s", out.String())
+	}
+
+	// Running out of answers aborts without writing anything
+	_, err = ImportCommandsWith(db, []Command{{Name: "build", Command: "go build"}, {Name: "new", Command: "true"}}, promptConflict(strings.NewReader(""), &out), nil)
+	if err == nil {
+		t.Fatal("Expected the import to be aborted")
+	}
+	if _, err := db.GetCommand("new"); err == nil {
+		t.Error("An aborted import must not store any command")
+	}
+}
+
+func TestPreviewImport(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("build", "", "make", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	incoming := []Command{
+		{Name: "build", Command: "go build"},
+		{Name: "", Command: "true"},
+		{Name: "test", Command: "go test"},
+	}
+
+	result, err := PreviewImport(db, incoming, fixedConflict(ConflictOverwrite), nil)
+	if err != nil {
+		t.Fatalf("PreviewImport failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Added, []string{"test"}) || !reflect.DeepEqual(result.Updated, []string{"build"}) {
+		t.Errorf("Unexpected preview: %+v", result)
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Entry != 2 {
+		t.Errorf("Expected the nameless entry to fail as entry 2, got %+v", result.Failed)
+	}
+	if cmd, err := db.GetCommand("build"); err != nil || cmd.Command != "make" {
+		t.Errorf("A preview should not change stored commands, got %+v, %v", cmd, err)
+	}
+	if _, err := db.GetCommand("test"); err == nil {
+		t.Error("A preview should not add commands")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxImportSize bounds the size of documents fetched from a URL
+const maxImportSize = 10 << 20
+
+// importHTTPClient is used to fetch remote import documents
+var importHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// isImportURL reports whether an import source is a URL rather than a file
+func isImportURL(src string) bool {
+	return strings.HasPrefix(src, "https://") || strings.HasPrefix(src, "http://")
+}
+
+// gistRawURL rewrites a gist page URL to the URL serving its raw content.
+// Other URLs are returned unchanged.
+func gistRawURL(u *url.URL) *url.URL {
+	if u.Host != "gist.github.com" || strings.Contains(u.Path, "/raw") {
+		return u
+	}
+	raw := *u
+	raw.Path = strings.TrimSuffix(u.Path, "/") + "/raw"
+	return &raw
+}
+
+// fetchImportURL downloads an import document over HTTPS
+func fetchImportURL(src string) ([]byte, error) {
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL '%s': %v", src, err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("refusing to import over %s, use an https URL", u.Scheme)
+	}
+	u = gistRawURL(u)
+
+	resp, err := importHTTPClient.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", u, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", u, err)
+	}
+	if len(data) > maxImportSize {
+		return nil, fmt.Errorf("import document at %s exceeds %d bytes", u, maxImportSize)
+	}
+	return data, nil
+}
+
+// readImportSource reads an import document from a file, stdin ("-") or URL
+func readImportSource(src string) ([]byte, error) {
+	switch {
+	case src == "-":
+		return io.ReadAll(os.Stdin)
+	case isImportURL(src):
+		return fetchImportURL(src)
+	default:
+		return os.ReadFile(src)
+	}
+}
+
+// checksum returns the hex-encoded SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyChecksum compares data against an expected SHA-256, if one is given
+func verifyChecksum(data []byte, expected string) error {
+	expected = strings.ToLower(strings.TrimSpace(expected))
+	if expected == "" {
+		return nil
+	}
+	if actual := checksum(data); actual != expected {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expected, actual)
+	}
+	return nil
+}
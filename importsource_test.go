@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGistRawURL(t *testing.T) {
+	tests := map[string]string{
+		"https://gist.github.com/alice/abc123":      "https://gist.github.com/alice/abc123/raw",
+		"https://gist.github.com/alice/abc123/":     "https://gist.github.com/alice/abc123/raw",
+		"https://gist.github.com/alice/abc123/raw":  "https://gist.github.com/alice/abc123/raw",
+		"https://example.com/commands.yaml":         "https://example.com/commands.yaml",
+		"https://gist.github.com/alice/abc123/raw/": "https://gist.github.com/alice/abc123/raw/",
+	}
+	for input, expected := range tests {
+		u, err := url.Parse(input)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", input, err)
+		}
+		if got := gistRawURL(u).String(); got != expected {
+			t.Errorf("gistRawURL(%s) = %s, expected %s", input, got, expected)
+		}
+	}
+}
+
+func TestFetchImportURL(t *testing.T) {
+	doc := "version: 1\ncommands:\n  - name: remote\n    command: echo remote\n"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/commands.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	defer server.Close()
+
+	original := importHTTPClient
+	importHTTPClient = server.Client()
+	defer func() { importHTTPClient = original }()
+
+	data, err := readImportSource(server.URL + "/commands.yaml")
+	if err != nil {
+		t.Fatalf("readImportSource failed: %v", err)
+	}
+	if string(data) != doc {
+		t.Errorf("Unexpected document: %s", data)
+	}
+
+	if _, err := fetchImportURL(server.URL + "/missing.yaml"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected 404 error, got: %v", err)
+	}
+
+	if _, err := fetchImportURL(strings.Replace(server.URL, "https://", "http://", 1)); err == nil {
+		t.Error("Plain http URLs should be refused")
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("hello")
+	sum := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	if checksum(data) != sum {
+		t.Errorf("Unexpected checksum %s", checksum(data))
+	}
+	if err := verifyChecksum(data, ""); err != nil {
+		t.Errorf("Empty expected checksum should be accepted: %v", err)
+	}
+	if err := verifyChecksum(data, strings.ToUpper(sum)); err != nil {
+		t.Errorf("Checksum comparison should ignore case: %v", err)
+	}
+	if err := verifyChecksum(data, "deadbeef"); err == nil {
+		t.Error("Expected checksum mismatch")
+	}
+}
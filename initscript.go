@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Environment variables the project hook keeps its state in: the project
+// file whose commands are loaded and the functions defined for them
+const (
+	projectEnv      = "AFV_PROJECT"
+	projectFuncsEnv = "AFV_PROJECT_FUNCS"
+)
+
+// pickerWidgets holds the per-shell widgets that open the picker on
+// Ctrl-X Ctrl-A and put 'afv run NAME' on the command line for the chosen
+// command, like Ctrl-R does for the history
+var pickerWidgets = map[string]string{
+	"bash": `__afv_widget() {
+  local name
+  name="$(command afv pick </dev/tty)" || return
+  READLINE_LINE="afv run $name"
+  READLINE_POINT=${#READLINE_LINE}
+}
+if [[ $- == *i* ]]; then
+  bind -x '"\C-x\C-a": __afv_widget'
+fi
+`,
+	"zsh": `__afv_widget() {
+  local name
+  name="$(command afv pick </dev/tty)"
+  if [ -n "$name" ]; then
+    BUFFER="afv run $name"
+    CURSOR=${#BUFFER}
+  fi
+  zle reset-prompt
+}
+zle -N __afv_widget
+bindkey '^X^A' __afv_widget
+`,
+	"fish": `function __afv_widget
+    set -l name (command afv pick </dev/tty)
+    and commandline --replace "afv run $name"
+    commandline -f repaint
+end
+bind \cx\ca __afv_widget
+`,
+}
+
+// projectHooks holds the per-shell hooks that load the commands of the
+// project file as shell functions whenever the directory changes
+var projectHooks = map[string]string{
+	"bash": `__afv_project_hook() {
+  if [ "$PWD" != "$__afv_project_pwd" ]; then
+    __afv_project_pwd="$PWD"
+    eval "$(command afv __project-hook bash)"
+  fi
+}
+PROMPT_COMMAND="__afv_project_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+`,
+	"zsh": `__afv_project_hook() {
+  eval "$(command afv __project-hook zsh)"
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd __afv_project_hook
+__afv_project_hook
+`,
+	"fish": `function __afv_project_hook --on-variable PWD
+    command afv __project-hook fish | source
+end
+__afv_project_hook
+`,
+}
+
+// initScript returns the snippet 'afv init' prints for the shell rc: the
+// shell wrapper, completions and the picker widget, and with project set
+// the hook loading the commands of project files
+func initScript(shell string, project bool) (string, error) {
+	wrapper, ok := shellInitScripts[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell '%s' (expected bash, zsh or fish)", shell)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by 'afv init " + shell + "'\n")
+	b.WriteString(wrapper)
+	if shell == "zsh" {
+		// compdef is only defined once the completion system is loaded
+		b.WriteString("(( $+functions[compdef] )) || { autoload -Uz compinit && compinit }\n")
+	}
+	b.WriteString(completionScripts[shell])
+	b.WriteString(pickerWidgets[shell])
+	if project {
+		b.WriteString(projectHooks[shell])
+	}
+	return b.String(), nil
+}
+
+// projectHookScript returns the script the project hook evaluates in the
+// shell. Unless file is the project file loaded already, it removes the
+// functions defined for the previous one and defines a function running
+// each of commands through afv run. Names that are taken by another
+// command of the shell are left alone.
+func projectHookScript(shell, file string, commands []Command, loaded string, defined []string) string {
+	if file == loaded {
+		return ""
+	}
+
+	var b strings.Builder
+	fish := shell == "fish"
+	for _, name := range defined {
+		if !shellNamePattern.MatchString(name) {
+			continue
+		}
+		if fish {
+			fmt.Fprintf(&b, "functions -e %s\n", name)
+		} else {
+			fmt.Fprintf(&b, "unset -f %s\n", name)
+		}
+	}
+	if file == "" {
+		if fish {
+			fmt.Fprintf(&b, "set -e %s %s\n", projectEnv, projectFuncsEnv)
+		} else {
+			fmt.Fprintf(&b, "unset %s %s\n", projectEnv, projectFuncsEnv)
+		}
+		return b.String()
+	}
+
+	if fish {
+		fmt.Fprintf(&b, "set -gx %s %s\nset -gx %s\n", projectEnv, shellQuote(shell, file), projectFuncsEnv)
+	} else {
+		fmt.Fprintf(&b, "export %s=%s %s=\n", projectEnv, shellQuote(shell, file), projectFuncsEnv)
+	}
+	for _, cmd := range commands {
+		name := cmd.Name
+		if !shellNamePattern.MatchString(name) {
+			continue
+		}
+		if fish {
+			fmt.Fprintf(&b, "if not type -q %s\n    function %s\n        afv run %s -- $argv\n    end\n    set -a %s %s\nend\n",
+				name, name, name, projectFuncsEnv, name)
+		} else {
+			fmt.Fprintf(&b, "if ! type %s >/dev/null 2>&1; then\n  %s() { afv run %s -- \"$@\"; }\n  %s=\"$%s %s\"\nfi\n",
+				name, name, name, projectFuncsEnv, projectFuncsEnv, name)
+		}
+	}
+	fmt.Fprintf(&b, "echo %s >&2\n", shellQuote(shell, fmt.Sprintf("afv: loaded %d command(s) from %s", len(commands), file)))
+	return b.String()
+}
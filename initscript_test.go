@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestInitScript(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		script, err := initScript(shell, false)
+		if err != nil {
+			t.Fatalf("initScript(%s) failed: %v", shell, err)
+		}
+		for _, part := range []string{"shell-eval " + shell, "afv __complete", "command afv pick"} {
+			if !strings.Contains(script, part) {
+				t.Errorf("Expected the %s snippet to contain %q, got:\n%s", shell, part, script)
+			}
+		}
+		if strings.Contains(script, "__project-hook") {
+			t.Errorf("Expected no project hook for %s without project, got:\n%s", shell, script)
+		}
+		if script, _ := initScript(shell, true); !strings.Contains(script, "afv __project-hook "+shell) {
+			t.Errorf("Expected the project hook for %s, got:\n%s", shell, script)
+		}
+	}
+	if _, err := initScript("powershell", false); err == nil {
+		t.Error("Expected an error for an unsupported shell")
+	}
+}
+
+func TestProjectHookScript(t *testing.T) {
+	commands := []Command{{Name: "hello"}, {Name: "test"}, {Name: "docker:build"}}
+	if script := projectHookScript("bash", "/p/.afvikle.yaml", commands, "/p/.afvikle.yaml", []string{"hello"}); script != "" {
+		t.Errorf("Expected nothing to do for the loaded project file, got:\n%s", script)
+	}
+
+	script := projectHookScript("fish", "", nil, "/p/.afvikle.yaml", []string{"hello"})
+	if script != "functions -e hello\nset -e AFV_PROJECT AFV_PROJECT_FUNCS\n" {
+		t.Errorf("Unexpected script leaving the project:\n%s", script)
+	}
+
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	// Load the project as the hook would, after one defining 'old'
+	script = "old() { :; }\n" + projectHookScript("bash", "/p/.afvikle.yaml", commands, "/q/.afvikle.yaml", []string{"old"}) +
+		"afv() { echo \"$*\"; }\nhello a b\ntype -t old || echo old removed\ntype -t test\necho \"$AFV_PROJECT|$AFV_PROJECT_FUNCS\"\n"
+	out, err := exec.Command(bash, "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash failed: %v: %s", err, out)
+	}
+	want := "afv: loaded 3 command(s) from /p/.afvikle.yaml\nrun hello -- a b\nold removed\nbuiltin\n/p/.afvikle.yaml| hello\n"
+	if string(out) != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out, want)
+	}
+}
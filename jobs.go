@@ -0,0 +1,347 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// jobsBucket holds the background jobs started with run --detach, keyed by
+// their zero-padded id
+var jobsBucket = []byte("jobs")
+
+// jobCommand is the hidden afv command that runs a job in its own process
+const jobCommand = "__job"
+
+// Job is a stored command running detached in the background
+type Job struct {
+	ID        uint64 `json:"id"`
+	Name      string `json:"name"`
+	Command   string `json:"command"`
+	Dir       string `json:"dir,omitempty"`
+	PID       int    `json:"pid"`
+	StartedAt string `json:"started_at"`
+	LogFile   string `json:"log_file"`
+	// Options are what the job's process runs the command with
+	Options RunOptions `json:"options"`
+	// FinishedAt, ExitCode and Error record how the job ended
+	FinishedAt string `json:"finished_at,omitempty"`
+	ExitCode   int    `json:"exit_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Running reports whether the job's process is still alive
+func (j Job) Running() bool {
+	return j.FinishedAt == "" && processAlive(j.PID)
+}
+
+// Status describes the state of a job for afv jobs
+func (j Job) Status() string {
+	switch {
+	case j.FinishedAt != "" && j.Error == "":
+		return "succeeded"
+	case j.FinishedAt != "" && j.ExitCode > 0:
+		return fmt.Sprintf("failed (exit %d)", j.ExitCode)
+	case j.FinishedAt != "":
+		return "failed"
+	case j.Running():
+		return fmt.Sprintf("running (pid %d)", j.PID)
+	}
+	// Killed before it could record its end
+	return "exited"
+}
+
+// jobKey returns the bucket key of a job id, padded so keys sort by id
+func jobKey(id uint64) []byte {
+	return []byte(fmt.Sprintf("%010d", id))
+}
+
+// JobDir returns the directory holding the output of background jobs
+func (d *Database) JobDir() string {
+	return filepath.Join(d.DataDir(), "jobs")
+}
+
+// nextJobID reserves the id of a new job
+func (d *Database) nextJobID() (uint64, error) {
+	var id uint64
+	err := d.update("nextJobID", func(tx *bbolt.Tx) error {
+		var err error
+		id, err = tx.Bucket(jobsBucket).NextSequence()
+		return err
+	})
+	return id, err
+}
+
+// SaveJob stores a background job
+func (d *Database) SaveJob(job Job) error {
+	return d.update("SaveJob", func(tx *bbolt.Tx) error {
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(jobsBucket).Put(jobKey(job.ID), data)
+	})
+}
+
+// GetAllJobs returns all recorded jobs, oldest first
+func (d *Database) GetAllJobs() ([]Job, error) {
+	var jobs []Job
+	err := d.view("GetAllJobs", func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(k, v []byte) error {
+			var job Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// FindJob returns a job by id, or the most recent job of a command by name
+func (d *Database) FindJob(ref string) (*Job, error) {
+	jobs, err := d.GetAllJobs()
+	if err != nil {
+		return nil, err
+	}
+	id, idErr := strconv.ParseUint(ref, 10, 64)
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if (idErr == nil && jobs[i].ID == id) || jobs[i].Name == ref {
+			return &jobs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("job '%s' not found (see 'afv jobs')", ref)
+}
+
+// FinishJob records how a job ended, err being the outcome of its run
+func (d *Database) FinishJob(id uint64, err error) error {
+	return d.update("FinishJob", func(tx *bbolt.Tx) error {
+		b := tx.Bucket(jobsBucket)
+		data := b.Get(jobKey(id))
+		if data == nil {
+			return fmt.Errorf("job %d not found", id)
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return err
+		}
+		job.FinishedAt = time.Now().Format(timeLayout)
+		job.ExitCode = exitCode(err)
+		if err != nil {
+			job.Error = err.Error()
+		}
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		return b.Put(jobKey(id), data)
+	})
+}
+
+// DeleteJob forgets a job
+func (d *Database) DeleteJob(id uint64) error {
+	return d.update("DeleteJob", func(tx *bbolt.Tx) error {
+		return tx.Bucket(jobsBucket).Delete(jobKey(id))
+	})
+}
+
+// StartJob starts a stored command detached from the terminal. The job gets
+// an afv process of its own, which runs the command like afv run, with its
+// guards, hooks, history and notifications, writes the output to a log file
+// in the job directory and records how the job ended.
+func StartJob(db *Database, name string, opts RunOptions) (*Job, error) {
+	command, err := db.GetCommand(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get command: %v", err)
+	}
+	if err := checkDeprecation(command); err != nil {
+		return nil, err
+	}
+	if command.Eval {
+		return nil, fmt.Errorf("command '%s' must be evaluated by your shell and cannot run in the background", command.Name)
+	}
+
+	// The job's process checks these again, but failing here shows why
+	// the job cannot run rather than hiding it in the job log
+	if !opts.Force {
+		if err := checkCooldown(command, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if (command.Singleton || opts.Exclusive) && !opts.Wait {
+		lock, err := AcquireCommandLock(db.LockDir(), command.Name, false)
+		if err != nil {
+			return nil, err
+		}
+		lock.Release()
+	}
+
+	dir, err := resolveRunDirectory(command, opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	prepared, err := prepareCommand(db, command, opts, terminalPrompter())
+	if err != nil {
+		return nil, err
+	}
+	line := commandLine(prepared.Display, opts.Args)
+	if err := confirmRun(command, line, opts); err != nil {
+		return nil, err
+	}
+	// Nobody can answer the job's process, so pass on what was asked here
+	opts.Dir, opts.Vars, opts.Remember, opts.Confirmed = dir, prepared.Vars, false, true
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the afv executable: %v", err)
+	}
+	id, err := db.nextJobID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record job: %v", err)
+	}
+	if err := os.MkdirAll(db.JobDir(), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %v", err)
+	}
+	logPath := filepath.Join(db.JobDir(), fmt.Sprintf("%d-%s.log", id, safeFileName(command.Name)))
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job log: %v", err)
+	}
+	defer logFile.Close()
+
+	job := Job{
+		ID:        id,
+		Name:      command.Name,
+		Command:   line,
+		Dir:       dir,
+		StartedAt: time.Now().Format(timeLayout),
+		LogFile:   logPath,
+		Options:   opts,
+	}
+	if err := db.SaveJob(job); err != nil {
+		return nil, fmt.Errorf("failed to record job: %v", err)
+	}
+
+	// The job's process uses this database and, for project commands,
+	// the project file of the current directory
+	cmd := exec.Command(exe, jobCommand, strconv.FormatUint(id, 10))
+	cmd.Env = append(os.Environ(), databaseEnv+"="+db.Path())
+	if cwd, err := os.Getwd(); err == nil {
+		cmd.Dir = cwd
+	}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+
+	s := startSpan("exec.detach", "name", command.Name, "command", line, "dir", dir)
+	err = cmd.Start()
+	s.End(err)
+	if err != nil {
+		_ = db.DeleteJob(id)
+		return nil, fmt.Errorf("failed to start '%s': %v", command.Name, err)
+	}
+	job.PID = cmd.Process.Pid
+	if err := db.SaveJob(job); err != nil {
+		return nil, fmt.Errorf("failed to record job: %v", err)
+	}
+
+	// The job records its own end; this only reaps it in long-lived
+	// processes such as the scheduler
+	go func() { _ = cmd.Wait() }()
+	return &job, nil
+}
+
+// RunJob runs a job started by StartJob in the job's own process and
+// records how it ended
+func RunJob(db *Database, ref string) error {
+	if _, err := strconv.ParseUint(ref, 10, 64); err != nil {
+		return fmt.Errorf("invalid job id '%s'", ref)
+	}
+	job, err := db.FindJob(ref)
+	if err != nil {
+		return err
+	}
+	runErr := RunStored(db, job.Name, job.Options)
+	if err := db.FinishJob(job.ID, runErr); err != nil {
+		warn("failed to record the end of job %d: %v", job.ID, err)
+	}
+	return runErr
+}
+
+// KillJob stops a running job, escalating to a forced kill if it does not
+// exit within serviceStopTimeout. It returns false if the job had already
+// exited.
+func KillJob(job *Job) (bool, error) {
+	if !job.Running() {
+		return false, nil
+	}
+	if err := terminateProcess(job.PID); err != nil {
+		return false, fmt.Errorf("failed to stop job %d: %v", job.ID, err)
+	}
+	deadline := time.Now().Add(serviceStopTimeout)
+	for job.Running() && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if job.Running() {
+		if err := killProcess(job.PID); err != nil {
+			return false, fmt.Errorf("failed to kill job %d: %v", job.ID, err)
+		}
+	}
+	return true, nil
+}
+
+// ClearJobs forgets jobs that have exited and removes their logs
+func ClearJobs(db *Database) ([]Job, error) {
+	jobs, err := db.GetAllJobs()
+	if err != nil {
+		return nil, err
+	}
+	var cleared []Job
+	for _, job := range jobs {
+		if job.Running() {
+			continue
+		}
+		if err := db.DeleteJob(job.ID); err != nil {
+			return cleared, err
+		}
+		if err := os.Remove(job.LogFile); err != nil && !os.IsNotExist(err) {
+			warn("failed to remove %s: %v", job.LogFile, err)
+		}
+		cleared = append(cleared, job)
+	}
+	return cleared, nil
+}
+
+// followInterval is how often a followed job log is checked for new output
+const followInterval = 500 * time.Millisecond
+
+// PrintJobLog writes the output of a job to w. With follow it keeps writing
+// new output until the job exits.
+func PrintJobLog(job *Job, w io.Writer, follow bool) error {
+	f, err := os.Open(job.LogFile)
+	if err != nil {
+		return fmt.Errorf("failed to open job log: %v", err)
+	}
+	defer f.Close()
+
+	for {
+		// Check before copying so output written just before the job
+		// exited is not missed
+		running := follow && job.Running()
+		if _, err := io.Copy(w, f); err != nil {
+			return err
+		}
+		if !running {
+			return nil
+		}
+		time.Sleep(followInterval)
+	}
+}
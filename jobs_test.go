@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runAsAfvEnv makes the test binary run as afv, so tests can start afv's
+// own processes, such as the one running a job
+const runAsAfvEnv = "AFV_TEST_RUN_AS_AFV"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(runAsAfvEnv) != "" || (len(os.Args) > 1 && os.Args[1] == limitCommand) {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// waitForJob waits until the job's process has exited
+func waitForJob(t *testing.T, job *Job) {
+	deadline := time.Now().Add(10 * time.Second)
+	for processAlive(job.PID) && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if processAlive(job.PID) {
+		t.Fatalf("Job %d is still running", job.ID)
+	}
+}
+
+func TestFindAndClearJobs(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, name := range []string{"server", "worker", "server"} {
+		id, err := db.nextJobID()
+		if err != nil {
+			t.Fatalf("nextJobID failed: %v", err)
+		}
+		if err := db.SaveJob(Job{ID: id, Name: name, LogFile: tempDir + "/missing.log"}); err != nil {
+			t.Fatalf("SaveJob failed: %v", err)
+		}
+	}
+
+	job, err := db.FindJob("2")
+	if err != nil || job.Name != "worker" {
+		t.Errorf("Expected job 2 to be worker, got %+v, %v", job, err)
+	}
+	job, err = db.FindJob("server")
+	if err != nil || job.ID != 3 {
+		t.Errorf("Expected the most recent server job, got %+v, %v", job, err)
+	}
+	if _, err := db.FindJob("missing"); err == nil {
+		t.Error("Expected error for an unknown job")
+	}
+
+	cleared, err := ClearJobs(db)
+	if err != nil || len(cleared) != 3 {
+		t.Fatalf("Expected all exited jobs to be cleared, got %d, %v", len(cleared), err)
+	}
+	if jobs, _ := db.GetAllJobs(); len(jobs) != 0 {
+		t.Errorf("Expected no jobs after clearing, got %+v", jobs)
+	}
+}
+
+func TestStartAndKillJob(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv(runAsAfvEnv, "1")
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("server", "", "sh -c", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	job, err := StartJob(db, "server", RunOptions{Args: []string{"echo listening; sleep 30"}})
+	if err != nil {
+		t.Fatalf("StartJob failed: %v", err)
+	}
+	// The job's process needs the database
+	if err := db.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "listening") && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		out.Reset()
+		if err := PrintJobLog(job, &out, false); err != nil {
+			t.Fatalf("PrintJobLog failed: %v", err)
+		}
+	}
+	if !strings.Contains(out.String(), "listening") {
+		t.Errorf("Expected the job's output in its log, got %q", out.String())
+	}
+
+	stopped, err := KillJob(job)
+	if err != nil || !stopped {
+		t.Fatalf("Expected the job to be stopped, got %v, %v", stopped, err)
+	}
+	waitForJob(t, job)
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	job, _ = db.FindJob("server")
+	if job.Running() || job.Status() != "failed (exit 143)" {
+		t.Errorf("Expected the job to record being stopped, got %q", job.Status())
+	}
+}
+
+func TestJobRunsLikeRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	t.Setenv(runAsAfvEnv, "1")
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, cmd := range []Command{
+		{Name: "single", Command: "sh -c", Singleton: true},
+		{Name: "hooked", Command: "sh -c", PostHook: "echo post hook", Cooldown: "1h", Confirm: true, Timeout: "100ms"},
+	} {
+		if err := db.InsertCommand(cmd); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+
+	single, err := StartJob(db, "single", RunOptions{Args: []string{"sleep 1"}})
+	if err != nil {
+		t.Fatalf("StartJob failed: %v", err)
+	}
+	if err := db.Release(); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "Executing:") && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		out.Reset()
+		PrintJobLog(single, &out, false)
+	}
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StartJob(db, "single", RunOptions{}); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Expected a second job of a singleton command to be refused, got %v", err)
+	}
+
+	hooked, err := StartJob(db, "hooked", RunOptions{Args: []string{"echo run; exec sleep 5"}, Force: true})
+	if err != nil {
+		t.Fatalf("StartJob failed: %v", err)
+	}
+	if err := db.Release(); err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, single)
+	waitForJob(t, hooked)
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	if job, _ := db.FindJob("single"); job.Status() != "succeeded" {
+		t.Errorf("Expected the singleton job to succeed, got %q (%s)", job.Status(), job.Error)
+	}
+	job, _ := db.FindJob("hooked")
+	if job.Status() != "failed (exit 124)" {
+		t.Errorf("Expected the stored timeout to stop the job, got %q (%s)", job.Status(), job.Error)
+	}
+	out.Reset()
+	PrintJobLog(job, &out, false)
+	if !strings.Contains(out.String(), "run") || !strings.Contains(out.String(), "timed out") {
+		t.Errorf("Unexpected job log %q", out.String())
+	}
+	records, err := db.GetRunHistory("")
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected both jobs in the run history, got %+v, %v", records, err)
+	}
+	if _, err := StartJob(db, "hooked", RunOptions{}); err == nil || !strings.Contains(err.Error(), "cooling down") {
+		t.Errorf("Expected the cooldown to apply to jobs, got %v", err)
+	}
+}
+
+func TestUnattendedRunsNotify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses false")
+	}
+	t.Setenv(runAsAfvEnv, "1")
+
+	for _, scheduled := range []bool{false, true} {
+		port, received := fakeSMTPServer(t)
+		configDir := t.TempDir()
+		t.Setenv(configDirEnv, configDir)
+		config := fmt.Sprintf("smtp:\n  host: 127.0.0.1\n  port: %d\n  from: afv@example.com\n", port)
+		if err := os.WriteFile(filepath.Join(configDir, configFileName), []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		db, tempDir := createTempDB(t)
+		cmd := Command{Name: "backup", Command: "false", Notify: []NotifyRule{{On: NotifyFailure}}, NotifyEmail: []string{"ops@example.com"}}
+		if err := db.InsertCommand(cmd); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+		if scheduled {
+			if err := db.AddSchedule(Schedule{Name: "backup", Cron: "@hourly"}); err != nil {
+				t.Fatalf("AddSchedule failed: %v", err)
+			}
+			db.Release()
+			runDueSchedules(db, time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local))
+		} else {
+			if _, err := StartJob(db, "backup", RunOptions{}); err != nil {
+				t.Fatalf("StartJob failed: %v", err)
+			}
+			db.Release()
+		}
+
+		select {
+		case data := <-received:
+			if !strings.Contains(data, "Subject: afv: backup failed") {
+				t.Errorf("Unexpected mail:\n%s", data)
+			}
+		case <-time.After(10 * time.Second):
+			t.Errorf("No mail for the failed run (scheduled %v)", scheduled)
+		}
+		db.Reopen()
+		db.Close()
+		os.RemoveAll(tempDir)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ResourceLimits restrict the process of a command so heavyweight commands
+// do not starve the machine
+type ResourceLimits struct {
+	// Nice is the scheduling priority, from -20 (highest) to 19 (lowest)
+	Nice int
+	// MaxMem is the most memory the process may use, in bytes
+	MaxMem uint64
+	// CPUs is the number of CPUs the process may use
+	CPUs int
+}
+
+// IsZero reports whether no limit is set
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// memoryUnits are the suffixes accepted by parseMemory
+var memoryUnits = map[string]uint64{
+	"":  1,
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// parseMemory parses a memory size such as 512M or 2G, with binary units
+func parseMemory(value string) (uint64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	digits := strings.TrimRight(s, "KMGT")
+	unit, ok := memoryUnits[s[len(digits):]]
+	n, err := strconv.ParseUint(digits, 10, 64)
+	if !ok || err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid memory size '%s' (expected e.g. 512M or 2G)", value)
+	}
+	return n * unit, nil
+}
+
+// validateLimits checks the resource limits of a command
+func validateLimits(cmd *Command) error {
+	if cmd.Nice < -20 || cmd.Nice > 19 {
+		return fmt.Errorf("nice must be between -20 and 19")
+	}
+	cmd.MaxMem = strings.TrimSpace(cmd.MaxMem)
+	if cmd.MaxMem != "" {
+		if _, err := parseMemory(cmd.MaxMem); err != nil {
+			return err
+		}
+	}
+	if cmd.CPULimit < 0 {
+		return fmt.Errorf("cpu limit must be positive")
+	}
+	return nil
+}
+
+// Limits returns the resource limits of a validated command's process.
+// Containers get their memory and CPU limits from docker instead.
+func (c *Command) Limits() ResourceLimits {
+	limits := ResourceLimits{Nice: c.Nice}
+	if c.Container != "" {
+		return limits
+	}
+	limits.CPUs = c.CPULimit
+	if c.MaxMem != "" {
+		limits.MaxMem, _ = parseMemory(c.MaxMem)
+	}
+	return limits
+}
+
+// limitCommand is the hidden command that applies resource limits to its
+// own process before it executes the limited command, see startWithLimits
+const limitCommand = "__limit"
+
+// startLimited starts cmd with limits applied to its process
+func startLimited(cmd *exec.Cmd, limits ResourceLimits) error {
+	logExec(cmd)
+	if limits.IsZero() {
+		return cmd.Start()
+	}
+	return startWithLimits(cmd, limits)
+}
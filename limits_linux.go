@@ -0,0 +1,37 @@
+package main
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// setLimits sets the priority, address space limit and CPU affinity of the
+// calling thread's process, for the command it executes. The CPUs are the
+// first ones afv itself may run on.
+func setLimits(limits ResourceLimits) error {
+	if limits.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, limits.Nice); err != nil {
+			return err
+		}
+	}
+	if limits.MaxMem > 0 {
+		rlimit := unix.Rlimit{Cur: limits.MaxMem, Max: limits.MaxMem}
+		if err := unix.Setrlimit(unix.RLIMIT_AS, &rlimit); err != nil {
+			return err
+		}
+	}
+	if limits.CPUs > 0 {
+		var available, set unix.CPUSet
+		if err := unix.SchedGetaffinity(0, &available); err != nil {
+			return err
+		}
+		for cpu := 0; set.Count() < limits.CPUs && set.Count() < available.Count(); cpu++ {
+			if available.IsSet(cpu) {
+				set.Set(cpu)
+			}
+		}
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRunForegroundLimits(t *testing.T) {
+	// The limits are in place before the command runs, not just soon after
+	cmd := exec.Command("sh", "-c", "ulimit -v; nproc; cat /proc/$$/stat")
+	var out strings.Builder
+	cmd.Stdout = &out
+	limits := ResourceLimits{Nice: 7, MaxMem: 256 << 20, CPUs: 1}
+	if err := runForeground(cmd, limits, 0, 0); err != nil {
+		t.Fatalf("runForeground failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Unexpected output %q", out.String())
+	}
+	if lines[0] != "262144" {
+		t.Errorf("Expected a 256M address space limit, got %s KB", lines[0])
+	}
+	if lines[1] != "1" {
+		t.Errorf("Expected 1 CPU, got %s", lines[1])
+	}
+	// The nice value is field 19 of /proc/PID/stat
+	if fields := strings.Fields(lines[2][strings.LastIndex(lines[2], ")")+1:]); len(fields) < 17 || fields[16] != "7" {
+		t.Errorf("Expected nice 7, got stat %q", lines[2])
+	}
+}
+
+func TestStartLimitedFailure(t *testing.T) {
+	cmd := exec.Command("/nonexistent/afv-test")
+	err := startLimited(cmd, ResourceLimits{Nice: 5})
+	if err == nil || !strings.Contains(err.Error(), "failed to run /nonexistent/afv-test") {
+		t.Errorf("Expected the exec failure to be reported, got %v", err)
+	}
+}
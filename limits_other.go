@@ -0,0 +1,17 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// setLimits sets the priority of the process, for the command it executes.
+// Memory and CPU limits need Linux or Windows.
+func setLimits(limits ResourceLimits) error {
+	if limits.MaxMem > 0 || limits.CPUs > 0 {
+		return fmt.Errorf("memory and CPU limits are not supported on this platform")
+	}
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, limits.Nice)
+}
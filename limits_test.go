@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseMemory(t *testing.T) {
+	tests := map[string]uint64{
+		"1024":  1024,
+		"512M":  512 << 20,
+		"512mb": 512 << 20,
+		"2G":    2 << 30,
+		"2GiB":  2 << 30,
+		"64k":   64 << 10,
+	}
+	for input, expected := range tests {
+		got, err := parseMemory(input)
+		if err != nil || got != expected {
+			t.Errorf("parseMemory(%q) = %d, %v; expected %d", input, got, err, expected)
+		}
+	}
+	for _, invalid := range []string{"", "M", "0", "-1G", "2X", "1.5G"} {
+		if _, err := parseMemory(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestValidateLimits(t *testing.T) {
+	cmd := &Command{Nice: 10, MaxMem: " 1G ", CPULimit: 2}
+	if err := validateLimits(cmd); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if limits := cmd.Limits(); limits != (ResourceLimits{Nice: 10, MaxMem: 1 << 30, CPUs: 2}) {
+		t.Errorf("Unexpected limits %+v", limits)
+	}
+
+	for _, invalid := range []*Command{{Nice: 20}, {Nice: -21}, {MaxMem: "lots"}, {CPULimit: -1}} {
+		if err := validateLimits(invalid); err == nil {
+			t.Errorf("Expected error for %+v", invalid)
+		}
+	}
+}
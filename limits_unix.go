@@ -0,0 +1,98 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// startWithLimits starts cmd through afv's limit command, which applies the
+// limits to its own process and then executes cmd in its place, so they
+// are in effect from the command's first instruction. A process whose
+// limits cannot be applied never runs the command.
+func startWithLimits(cmd *exec.Cmd, limits ResourceLimits) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the afv executable: %v", err)
+	}
+
+	// The limit command reports failures on this pipe, which is closed
+	// without a word once it executes the command
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	fd := 2 + len(cmd.ExtraFiles)
+	cmd.Args = append([]string{exe, limitCommand,
+		strconv.Itoa(limits.Nice), strconv.FormatUint(limits.MaxMem, 10), strconv.Itoa(limits.CPUs),
+		strconv.Itoa(fd), cmd.Path}, cmd.Args...)
+	cmd.Path = exe
+
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return err
+	}
+	msg, _ := io.ReadAll(r)
+	if len(msg) > 0 {
+		_ = cmd.Wait()
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+// RunLimitCommand is the limit command: it applies the limits in args to
+// the process and executes the command in args. It only returns if that
+// fails, after reporting why to afv.
+func RunLimitCommand(args []string) {
+	// Priority and CPU affinity are per thread on Linux, so set them on the
+	// thread that executes the command
+	runtime.LockOSThread()
+
+	status := os.Stderr
+	err := func() error {
+		if len(args) < 6 {
+			return fmt.Errorf("usage: afv %s NICE MAX_MEM CPUS FD PATH ARGV...", limitCommand)
+		}
+		fd, err := strconv.Atoi(args[3])
+		if err != nil {
+			return err
+		}
+		status = os.NewFile(uintptr(fd), "status")
+		syscall.CloseOnExec(fd)
+
+		var limits ResourceLimits
+		limits.Nice, err = strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		if limits.MaxMem, err = strconv.ParseUint(args[1], 10, 64); err != nil {
+			return err
+		}
+		if limits.CPUs, err = strconv.Atoi(args[2]); err != nil {
+			return err
+		}
+		if err := setLimits(limits); err != nil {
+			return fmt.Errorf("failed to apply resource limits: %v", err)
+		}
+		path := args[4]
+		if err := syscall.Exec(path, args[5:], os.Environ()); err != nil {
+			return fmt.Errorf("failed to run %s: %v", path, err)
+		}
+		return nil
+	}()
+	fmt.Fprint(status, strings.TrimSpace(err.Error()))
+	os.Exit(127)
+}
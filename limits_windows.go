@@ -0,0 +1,108 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// jobCPURateControl mirrors JOBOBJECT_CPU_RATE_CONTROL_INFORMATION
+type jobCPURateControl struct {
+	ControlFlags uint32
+	CPURate      uint32
+}
+
+// Flags of jobCPURateControl
+const (
+	jobCPURateControlEnable  = 0x1
+	jobCPURateControlHardCap = 0x4
+)
+
+// priorityClass maps a nice value to the closest Windows priority class
+func priorityClass(nice int) uint32 {
+	switch {
+	case nice <= -10:
+		return windows.HIGH_PRIORITY_CLASS
+	case nice < 0:
+		return windows.ABOVE_NORMAL_PRIORITY_CLASS
+	case nice == 0:
+		return windows.NORMAL_PRIORITY_CLASS
+	case nice < 10:
+		return windows.BELOW_NORMAL_PRIORITY_CLASS
+	default:
+		return windows.IDLE_PRIORITY_CLASS
+	}
+}
+
+// startWithLimits starts cmd and puts its process in a job object right
+// after it starts, as Windows cannot apply limits before. A process whose
+// limits cannot be applied is killed.
+func startWithLimits(cmd *exec.Cmd, limits ResourceLimits) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := applyLimits(cmd.Process, limits); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("failed to apply resource limits: %v", err)
+	}
+	return nil
+}
+
+// RunLimitCommand is not used on Windows, where limits are applied by
+// startWithLimits
+func RunLimitCommand(args []string) {
+	fmt.Fprintf(os.Stderr, "Error: afv %s is not supported on Windows\n", limitCommand)
+	os.Exit(1)
+}
+
+// applyLimits puts a started process in a job object that limits its
+// priority, memory and CPU rate. The CPU limit is a hard cap on the share
+// of all CPUs. The job lives on after its handle is closed as long as the
+// process runs.
+func applyLimits(p *os.Process, limits ResourceLimits) error {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(job)
+
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	if limits.Nice != 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PRIORITY_CLASS
+		info.BasicLimitInformation.PriorityClass = priorityClass(limits.Nice)
+	}
+	if limits.MaxMem > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PROCESS_MEMORY
+		info.ProcessMemoryLimit = uintptr(limits.MaxMem)
+	}
+	if info.BasicLimitInformation.LimitFlags != 0 {
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+			uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+			return err
+		}
+	}
+	if limits.CPUs > 0 && limits.CPUs < runtime.NumCPU() {
+		rate := jobCPURateControl{
+			ControlFlags: jobCPURateControlEnable | jobCPURateControlHardCap,
+			CPURate:      uint32(limits.CPUs * 10000 / runtime.NumCPU()),
+		}
+		if _, err := windows.SetInformationJobObject(job, windows.JobObjectCpuRateControlInformation,
+			uintptr(unsafe.Pointer(&rate)), uint32(unsafe.Sizeof(rate))); err != nil {
+			return err
+		}
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(p.Pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(process)
+	return windows.AssignProcessToJobObject(job, process)
+}
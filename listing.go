@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ANSI colors of 'afv list'
+const (
+	colorName = "36"
+	colorWarn = "33"
+	colorFail = "31"
+	colorDim  = "2"
+)
+
+// minFitWidth is the narrowest a truncated column gets, however narrow the
+// terminal is
+const minFitWidth = 12
+
+// tableCell is one cell of a table, colored when Color is set and the table
+// is colored
+type tableCell struct {
+	Text  string
+	Color string
+}
+
+// table writes rows of cells in aligned columns. When width is set, the fit
+// column is truncated so the rows do not wrap.
+type table struct {
+	rows  [][]tableCell
+	color bool
+	width int
+	fit   int
+}
+
+func (t *table) add(cells ...tableCell) {
+	t.rows = append(t.rows, cells)
+}
+
+// write writes the rows to w, each starting with indent
+func (t *table) write(w io.Writer, indent string) {
+	widths := t.layout(indent)
+	for _, row := range t.rows {
+		t.writeRow(w, indent, row, widths)
+	}
+}
+
+// layout returns the width of each column for rows starting with indent
+func (t *table) layout(indent string) []int {
+	var widths []int
+	for _, row := range t.rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell.Text))
+		}
+	}
+	if t.width > 0 && t.fit < len(widths) {
+		used := len(indent) + 2*(len(widths)-1)
+		for i, width := range widths {
+			if i != t.fit {
+				used += width
+			}
+		}
+		widths[t.fit] = min(widths[t.fit], max(minFitWidth, t.width-used))
+	}
+	return widths
+}
+
+// writeRow writes one row with the column widths from layout
+func (t *table) writeRow(w io.Writer, indent string, row []tableCell, widths []int) {
+	// Empty trailing cells would only add trailing spaces
+	for len(row) > 0 && row[len(row)-1].Text == "" {
+		row = row[:len(row)-1]
+	}
+	var b strings.Builder
+	b.WriteString(indent)
+	pos := len(indent)
+	for i, cell := range row {
+		// A last cell can use the rest of the line even if its column
+		// had to be narrowed for other rows
+		limit := widths[i]
+		if i == len(row)-1 && t.width > 0 {
+			limit = max(limit, t.width-pos)
+		}
+		text := truncate(cell.Text, limit)
+		padding := max(widths[i]-utf8.RuneCountInString(text), 0)
+		pos += widths[i] + 2
+		if t.color && cell.Color != "" {
+			text = fmt.Sprintf("\033[%sm%s\033[0m", cell.Color, text)
+		}
+		b.WriteString(text)
+		if i < len(row)-1 {
+			b.WriteString(strings.Repeat(" ", padding+2))
+		}
+	}
+	fmt.Fprintln(w, b.String())
+}
+
+// truncate shortens text to at most n characters, ending it with an
+// ellipsis when something was cut
+func truncate(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:max(n-1, 0)]) + "…"
+}
+
+// summaryLine returns the command line of a command for a listing, on one
+// line
+func summaryLine(cmd *Command) string {
+	switch {
+	case len(cmd.Steps) > 0:
+		return strings.Join(cmd.Steps, " && ")
+	case cmd.Script:
+		return fmt.Sprintf("script, %d lines", strings.Count(strings.TrimRight(cmd.Command, "\n"), "\n")+1)
+	}
+	line, _, multiline := strings.Cut(cmd.Command, "\n")
+	if multiline {
+		line += " …"
+	}
+	return line
+}
+
+// listView is what 'afv list' shows
+type listView struct {
+	Commands []CommandMeta
+	Groups   []Group
+	// Details holds the full commands and LastRuns their most recent runs;
+	// both are only filled for --long
+	Details  map[string]*Command
+	LastRuns map[string]RunRecord
+	Long     bool
+}
+
+// writeList writes the command list, colored when color is set and fitted
+// to width columns when width is not 0
+func writeList(w io.Writer, view listView, color bool, width int) {
+	if len(view.Commands) > 0 {
+		fmt.Fprintln(w, "Available commands:")
+	}
+	commands := &table{color: color, width: width, fit: 1}
+	for _, cmd := range view.Commands {
+		row := []tableCell{{Text: cmd.Name, Color: colorName}, {Text: cmd.Description}}
+		var markers []string
+		marker := tableCell{Color: colorDim}
+		if cmd.Pinned {
+			markers = append(markers, "[pinned]")
+		}
+		if cmd.Project {
+			markers = append(markers, "[project]")
+		}
+		if cmd.Deprecated != nil {
+			if cmd.Deprecated.Use != "" {
+				markers = append(markers, fmt.Sprintf("[deprecated, use %s]", cmd.Deprecated.Use))
+			} else {
+				markers = append(markers, "[deprecated]")
+			}
+			marker.Color = colorWarn
+		}
+		if len(markers) > 0 {
+			marker.Text = strings.Join(markers, " ")
+			row = append(row, marker)
+		}
+		commands.add(row...)
+	}
+	// With --long each command is followed by its details, still aligned
+	// with the other commands
+	widths := commands.layout("  ")
+	for i, row := range commands.rows {
+		commands.writeRow(w, "  ", row, widths)
+		if view.Long {
+			name := view.Commands[i].Name
+			writeDetails(w, view.Details[name], view.LastRuns[name], color, width)
+		}
+	}
+
+	if len(view.Groups) > 0 {
+		fmt.Fprintln(w, "Groups:")
+		groups := &table{color: color, width: width, fit: 1}
+		for _, group := range view.Groups {
+			steps := fmt.Sprintf("[%s: %s]", group.Mode, strings.Join(group.Steps, ", "))
+			groups.add(tableCell{Text: group.Name, Color: colorName}, tableCell{Text: group.Description}, tableCell{Text: steps, Color: colorDim})
+		}
+		groups.write(w, "  ")
+	}
+}
+
+// writeDetails writes the detail lines of a command for --long
+func writeDetails(w io.Writer, cmd *Command, last RunRecord, color bool, width int) {
+	details := &table{color: color, width: width, fit: 1}
+	if cmd != nil {
+		details.add(tableCell{Text: "command:", Color: colorDim}, tableCell{Text: summaryLine(cmd)})
+		if cmd.WorkingDir != "" {
+			details.add(tableCell{Text: "dir:", Color: colorDim}, tableCell{Text: cmd.WorkingDir})
+		}
+		if len(cmd.Tags) > 0 {
+			details.add(tableCell{Text: "tags:", Color: colorDim}, tableCell{Text: strings.Join(cmd.Tags, ", ")})
+		}
+	}
+	details.write(w, "      ")
+
+	// The last run is a sentence of its own rather than a labeled value
+	lastRun := &table{color: color, width: width}
+	lastRun.add(lastRunCell(last))
+	lastRun.write(w, "      ")
+}
+
+// lastRunCell describes the most recent run of a command, red if it failed
+func lastRunCell(record RunRecord) tableCell {
+	if record.ID == 0 {
+		return tableCell{Text: "never run", Color: colorDim}
+	}
+	elapsed := record.Elapsed
+	if elapsed == 0 {
+		elapsed = record.Duration
+	}
+	cell := tableCell{Text: fmt.Sprintf("last run %s, took %s, exit status %d", record.StartedAt.Format(timeLayout), formatDuration(elapsed), record.ExitCode)}
+	if !record.Success {
+		cell.Color = colorFail
+	}
+	return cell
+}
+
+// listData is what 'afv list' writes as JSON or YAML
+type listData struct {
+	Commands []Command `json:"commands" yaml:"commands"`
+	Groups   []Group   `json:"groups" yaml:"groups"`
+}
+
+// ListOptions select what 'afv list' shows and how
+type ListOptions struct {
+	// Long adds the details and last run of each command
+	Long bool
+	// Format is table, json or yaml
+	Format string
+	// Tag and Namespace only list the commands carrying the tag or lying in
+	// the namespace; groups are then left out
+	Tag, Namespace string
+}
+
+// ListCommands prints the stored commands and groups as a table, or with
+// every field as JSON or YAML. Commands are filtered by tag through the tag
+// index, and pinned commands come first.
+func ListCommands(db *Database, opts ListOptions) error {
+	commands, err := db.GetCommandMeta()
+	if err != nil {
+		return fmt.Errorf("failed to get commands: %v", err)
+	}
+	var groups []Group
+	if opts.Tag == "" && opts.Namespace == "" {
+		if groups, err = db.GetAllGroups(); err != nil {
+			return fmt.Errorf("failed to get groups: %v", err)
+		}
+	}
+	keep := func(name string) bool {
+		return opts.Namespace == "" || inNamespace(name, opts.Namespace)
+	}
+	if opts.Tag != "" {
+		tagged, err := db.GetCommandNamesByTag(opts.Tag)
+		if err != nil {
+			return fmt.Errorf("failed to get commands: %v", err)
+		}
+		inNamespace := keep
+		keep = func(name string) bool {
+			_, found := slices.BinarySearch(tagged, name)
+			return found && inNamespace(name)
+		}
+	}
+	// Commands of the project file are not in the tag index
+	keepProject := func(cmd Command) bool {
+		return (opts.Tag == "" || slices.Contains(cmd.Tags, opts.Tag)) &&
+			(opts.Namespace == "" || inNamespace(cmd.Name, opts.Namespace))
+	}
+	project := slices.DeleteFunc(db.ProjectCommands(), func(cmd Command) bool { return !keepProject(cmd) })
+	commands = slices.DeleteFunc(commands, func(cmd CommandMeta) bool {
+		if cmd.Project {
+			return !slices.ContainsFunc(project, func(p Command) bool { return p.Name == cmd.Name })
+		}
+		return !keep(cmd.Name)
+	})
+
+	if len(commands) == 0 && len(groups) == 0 && opts.Format == outputTable {
+		switch {
+		case opts.Tag != "":
+			fmt.Printf("No commands tagged '%s'.\n", opts.Tag)
+			return nil
+		case opts.Namespace != "":
+			fmt.Printf("No commands in namespace '%s'.\n", opts.Namespace)
+			return nil
+		}
+		fmt.Println("No commands found. Use 'afv add' to add commands.")
+		return nil
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	if err := sortByName(commands, cfg.Sort, func(cmd CommandMeta) string { return cmd.Name }); err != nil {
+		return err
+	}
+	pinnedFirst(commands, func(cmd CommandMeta) bool { return cmd.Pinned })
+	if err := sortByName(groups, cfg.Sort, func(group Group) string { return group.Name }); err != nil {
+		return err
+	}
+
+	if opts.Format != outputTable {
+		all, err := db.GetCommandsByTag(opts.Tag)
+		if err != nil {
+			return fmt.Errorf("failed to get commands: %v", err)
+		}
+		all = slices.DeleteFunc(all, func(cmd Command) bool { return !keep(cmd.Name) || db.isProjectCommand(cmd.Name) })
+		all = mergeProject(all, project)
+		if err := sortByName(all, cfg.Sort, func(cmd Command) string { return cmd.Name }); err != nil {
+			return err
+		}
+		pinnedFirst(all, func(cmd Command) bool { return cmd.Pinned })
+		data := listData{Commands: all, Groups: groups}
+		if data.Commands == nil {
+			data.Commands = []Command{}
+		}
+		if data.Groups == nil {
+			data.Groups = []Group{}
+		}
+		return writeData(os.Stdout, opts.Format, data)
+	}
+
+	view := listView{Commands: commands, Groups: groups, Long: opts.Long}
+	if opts.Long {
+		all, err := db.GetCommandsByTag(opts.Tag)
+		if err != nil {
+			return fmt.Errorf("failed to get commands: %v", err)
+		}
+		all = mergeProject(all, project)
+		view.Details = make(map[string]*Command, len(all))
+		for i := range all {
+			view.Details[all[i].Name] = &all[i]
+		}
+		records, err := db.GetRunHistory("")
+		if err != nil {
+			return fmt.Errorf("failed to read run history: %v", err)
+		}
+		view.LastRuns = lastRunByName(records)
+	}
+
+	width := 0
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			width = w
+		}
+	}
+	writeList(os.Stdout, view, useColor(os.Stdout), width)
+	return nil
+}
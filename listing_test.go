@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"deploy", 10, "deploy"},
+		{"deploy", 6, "deploy"},
+		{"deploy to prod", 8, "deploy …"},
+		{"æøå og mere", 4, "æøå…"},
+	} {
+		if got := truncate(tc.text, tc.n); got != tc.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tc.text, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestWriteList(t *testing.T) {
+	view := listView{
+		Commands: []CommandMeta{
+			{Name: "build", Description: "Build the project"},
+			{Name: "old-deploy", Description: "Deploy the old way", Deprecated: &Deprecation{Use: "deploy"}},
+		},
+		Groups: []Group{{Name: "dev", Mode: "parallel", Steps: []string{"api", "web"}}},
+	}
+
+	var out bytes.Buffer
+	writeList(&out, view, false, 0)
+	want := `Available commands:
+  build       Build the project
+  old-deploy  Deploy the old way  [deprecated, use deploy]
+Groups:
+  dev    [parallel: api, web]
+`
+	if out.String() != want {
+		t.Errorf("Unexpected list:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	writeList(&out, view, false, 40)
+	if !strings.Contains(out.String(), "  build       Build the project\n") || !strings.Contains(out.String(), "  old-deploy  Deploy the …  [deprecated, use deploy]\n") {
+		t.Errorf("Expected descriptions truncated to the width, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	writeList(&out, view, true, 0)
+	if !strings.Contains(out.String(), "\033[36mbuild\033[0m       Build") {
+		t.Errorf("Expected colored names padded outside the color, got %q", out.String())
+	}
+}
+
+func TestWriteListLong(t *testing.T) {
+	started := time.Date(2026, 3, 1, 9, 30, 0, 0, time.Local)
+	view := listView{
+		Commands: []CommandMeta{{Name: "build"}, {Name: "test"}},
+		Details: map[string]*Command{
+			"build": {Name: "build", Command: "go build ./...", WorkingDir: "/src", Tags: []string{"ci", "go"}},
+			"test":  {Name: "test", Steps: []string{"go vet ./...", "go test ./..."}},
+		},
+		LastRuns: map[string]RunRecord{
+			"build": {ID: 1, StartedAt: started, Duration: 1500 * time.Millisecond, ExitCode: 2},
+		},
+		Long: true,
+	}
+
+	var out bytes.Buffer
+	writeList(&out, view, false, 0)
+	want := `Available commands:
+  build
+      command:  go build ./...
+      dir:      /src
+      tags:     ci, go
+      last run 2026-03-01 09:30:00, took 1.5s, exit status 2
+  test
+      command:  go vet ./... && go test ./...
+      never run
+`
+	if out.String() != want {
+		t.Errorf("Unexpected long list:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
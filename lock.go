@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errLocked is returned by tryLockFile when another process holds the lock
+var errLocked = errors.New("lock is held by another process")
+
+// CommandLock is an exclusive, process-wide lock on a stored command
+type CommandLock struct {
+	file *os.File
+}
+
+// safeFileName converts a command name into a string usable as a file name
+func safeFileName(name string) string {
+	var b strings.Builder
+	for _, r := range []byte(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			b.WriteByte(r)
+		default:
+			fmt.Fprintf(&b, "_%02x", r)
+		}
+	}
+	return b.String()
+}
+
+// AcquireCommandLock takes the lock for the named command in lockDir. If the
+// lock is held elsewhere it either waits for it or fails with a message
+// describing the current holder.
+func AcquireCommandLock(lockDir, name string, wait bool) (*CommandLock, error) {
+	if err := os.MkdirAll(lockDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %v", err)
+	}
+
+	path := filepath.Join(lockDir, safeFileName(name)+".lock")
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+
+	err = tryLockFile(file)
+	if errors.Is(err, errLocked) {
+		holder := describeLockHolder(file)
+		if !wait {
+			file.Close()
+			return nil, fmt.Errorf("command '%s' is already running%s (use --wait to wait for it)", name, holder)
+		}
+		fmt.Printf("Waiting for running instance of '%s'%s to finish...\n", name, holder)
+		err = lockFile(file)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock command '%s': %v", name, err)
+	}
+
+	// Record the holder so other invocations can report who is running
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(fmt.Sprintf("%d\n%s\n", os.Getpid(), time.Now().Format(timeLayout))), 0)
+	}
+
+	return &CommandLock{file: file}, nil
+}
+
+// describeLockHolder reads the pid and start time written by the lock holder
+func describeLockHolder(file *os.File) string {
+	data := make([]byte, 128)
+	n, _ := file.ReadAt(data, 0)
+	fields := strings.Fields(string(data[:n]))
+	if len(fields) < 3 {
+		return ""
+	}
+	if _, err := strconv.Atoi(fields[0]); err != nil {
+		return ""
+	}
+	since, err := time.ParseInLocation(timeLayout, fields[1]+" "+fields[2], time.Local)
+	if err != nil {
+		return fmt.Sprintf(" (pid %s)", fields[0])
+	}
+	return fmt.Sprintf(" since %s (pid %s)", formatSince(since, time.Now()), fields[0])
+}
+
+// formatSince formats the start of a lock holder as HH:MM, with the date if
+// it did not start today
+func formatSince(since, now time.Time) string {
+	if since.Format("2006-01-02") == now.Format("2006-01-02") {
+		return since.Format("15:04")
+	}
+	return since.Format("2006-01-02 15:04")
+}
+
+// Release unlocks and closes the lock file
+func (l *CommandLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	_ = l.file.Truncate(0)
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
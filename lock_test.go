@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSafeFileName(t *testing.T) {
+	tests := map[string]string{
+		"build":      "build",
+		"proj:build": "proj_3abuild",
+		"a/b":        "a_2fb",
+		"x_y":        "x_5fy",
+	}
+	for input, expected := range tests {
+		if got := safeFileName(input); got != expected {
+			t.Errorf("safeFileName(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestAcquireCommandLock(t *testing.T) {
+	lockDir, err := os.MkdirTemp("", "afvikle_lock_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(lockDir)
+
+	lock, err := AcquireCommandLock(lockDir, "deploy", false)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	_, err = AcquireCommandLock(lockDir, "deploy", false)
+	if err == nil {
+		t.Fatal("Second acquire should fail while the lock is held")
+	}
+	if !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Error should say the command is already running, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("pid %d", os.Getpid())) {
+		t.Errorf("Error should name the holder pid, got: %v", err)
+	}
+
+	// Other commands are not affected
+	other, err := AcquireCommandLock(lockDir, "build", false)
+	if err != nil {
+		t.Fatalf("Lock for a different command should succeed: %v", err)
+	}
+	other.Release()
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+
+	lock, err = AcquireCommandLock(lockDir, "deploy", false)
+	if err != nil {
+		t.Fatalf("Lock should be available after release: %v", err)
+	}
+	lock.Release()
+}
+
+func TestLockHolderMessage(t *testing.T) {
+	lockDir := t.TempDir()
+	lock, err := AcquireCommandLock(lockDir, "deploy", false)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer lock.Release()
+
+	_, err = AcquireCommandLock(lockDir, "deploy", false)
+	if err == nil || !strings.Contains(err.Error(), "already running since ") || !strings.Contains(err.Error(), fmt.Sprintf(" (pid %d)", os.Getpid())) {
+		t.Errorf("Expected the holder's start time and pid, got: %v", err)
+	}
+
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	if got := formatSince(now.Add(-time.Hour), now); got != "08:00" {
+		t.Errorf("Expected the time only for today, got %q", got)
+	}
+	if got := formatSince(now.Add(-12*time.Hour), now); got != "2026-03-01 21:00" {
+		t.Errorf("Expected the date for an earlier day, got %q", got)
+	}
+}
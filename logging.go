@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Global flags that configure the internal log. They are taken out of the
+// arguments before clir parses them, so they work with every subcommand.
+const (
+	logFileFlag   = "log-file"
+	logFormatFlag = "log-format"
+	verboseFlag   = "verbose"
+	debugFlag     = "debug"
+)
+
+// logOptions holds the values of the global log flags. Verbose shows what
+// afv resolves and executes on stderr; Debug adds every step on the way.
+type logOptions struct {
+	File    string
+	Format  string
+	Verbose bool
+	Debug   bool
+}
+
+// globalFlags lists the flags taken out of the arguments before clir parses
+// them, all of which take a value
+var globalFlags = []string{logFileFlag, logFormatFlag, pprofFlag, traceFlag, outputFlag, outputShortFlag, databaseFlag, workspaceFlag, profileFlag}
+
+// globalBoolFlags lists the global flags that take no value
+var globalBoolFlags = []string{verboseFlag, debugFlag}
+
+// extractGlobalFlags removes the named flags (also in the -flag and
+// flag=value forms) from args and returns their values
+func extractGlobalFlags(args []string, names ...string) ([]string, map[string]string, error) {
+	values := map[string]string{}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || !slices.Contains(names, name) {
+			rest = append(rest, args[i])
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("flag --%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		values[name] = value
+	}
+	return rest, values, nil
+}
+
+// extractGlobalBoolFlags removes the named flags without a value (also in
+// the -flag and flag=bool forms) from args and returns which were set
+func extractGlobalBoolFlags(args []string, names ...string) ([]string, map[string]bool, error) {
+	values := map[string]bool{}
+	var rest []string
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || !slices.Contains(names, name) {
+			rest = append(rest, arg)
+			continue
+		}
+		set := true
+		if hasValue {
+			var err error
+			if set, err = strconv.ParseBool(value); err != nil {
+				return nil, nil, fmt.Errorf("invalid value '%s' for flag --%s", value, name)
+			}
+		}
+		values[name] = set
+	}
+	return rest, values, nil
+}
+
+// extractLogOptions removes --log-file, --log-format, --verbose and --debug
+// from args
+func extractLogOptions(args []string) ([]string, logOptions, error) {
+	rest, values, err := extractGlobalFlags(args, logFileFlag, logFormatFlag)
+	if err != nil {
+		return nil, logOptions{}, err
+	}
+	rest, levels, err := extractGlobalBoolFlags(rest, verboseFlag, debugFlag)
+	if err != nil {
+		return nil, logOptions{}, err
+	}
+	return rest, logOptions{
+		File:    values[logFileFlag],
+		Format:  values[logFormatFlag],
+		Verbose: levels[verboseFlag],
+		Debug:   levels[debugFlag],
+	}, nil
+}
+
+// level returns the lowest level logged: everything for a log file or
+// --debug, and only the resolved paths, environments and commands along
+// with warnings and errors for --verbose
+func (opts logOptions) level() slog.Level {
+	if opts.Verbose && !opts.Debug {
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
+
+// newLogHandler creates the slog handler writing records of at least level
+// to w in the given format
+func newLogHandler(w io.Writer, format string, level slog.Level) (slog.Handler, error) {
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "", "text":
+		return slog.NewTextHandler(w, handlerOpts), nil
+	case "json":
+		return slog.NewJSONHandler(w, handlerOpts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format '%s' (expected json or text)", format)
+	}
+}
+
+// setupLogging installs the default slog logger. Without a log file the
+// internal log is discarded, unless --verbose or --debug write it to stderr
+// like "-" does. The returned function closes the log file.
+func setupLogging(opts logOptions) (func(), error) {
+	if opts.File == "" && (opts.Verbose || opts.Debug) {
+		opts.File = "-"
+	}
+	if opts.File == "" {
+		if _, err := newLogHandler(io.Discard, opts.Format, opts.level()); err != nil {
+			return nil, err
+		}
+		slog.SetDefault(slog.New(slog.DiscardHandler))
+		return func() {}, nil
+	}
+
+	var w io.Writer = os.Stderr
+	closeLog := func() {}
+	if opts.File != "-" {
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %v", err)
+		}
+		w = f
+		closeLog = func() { f.Close() }
+	}
+
+	handler, err := newLogHandler(w, opts.Format, opts.level())
+	if err != nil {
+		closeLog()
+		return nil, err
+	}
+	slog.SetDefault(slog.New(handler))
+	return closeLog, nil
+}
+
+// span measures one operation in the internal log
+type span struct {
+	op    string
+	attrs []any
+	start time.Time
+}
+
+// startSpan logs the start of an operation
+func startSpan(op string, attrs ...any) span {
+	slog.Debug(op+" start", attrs...)
+	return span{op: op, attrs: attrs, start: time.Now()}
+}
+
+// End logs the outcome and duration of the operation
+func (s span) End(err error, attrs ...any) {
+	attrs = append(append(s.attrs, attrs...), "duration", time.Since(s.start))
+	if err != nil {
+		slog.Error(s.op+" failed", append(attrs, "error", err)...)
+		return
+	}
+	slog.Debug(s.op+" done", attrs...)
+}
+
+// logExec records the exact program, arguments and directory of a child
+// process about to start
+func logExec(cmd *exec.Cmd) {
+	slog.Info("exec", "path", cmd.Path, "argv", cmd.Args, "dir", cmd.Dir)
+}
+
+// envKeys returns the names of KEY=VALUE entries. Values are left out of the
+// log since they often hold secrets.
+func envKeys(vars []string) []string {
+	keys := make([]string, len(vars))
+	for i, v := range vars {
+		keys[i], _, _ = strings.Cut(v, "=")
+	}
+	return keys
+}
+
+// warn prints a warning for the user and records it in the internal log
+func warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	slog.Warn(msg)
+	fmt.Printf("Warning: %s\n", msg)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractLogOptions(t *testing.T) {
+	args, opts, err := extractLogOptions([]string{"--log-file", "afv.log", "run", "--name", "build", "-log-format=json"})
+	if err != nil {
+		t.Fatalf("extractLogOptions failed: %v", err)
+	}
+	if !reflect.DeepEqual(args, []string{"run", "--name", "build"}) {
+		t.Errorf("Expected log flags to be removed, got %v", args)
+	}
+	if opts.File != "afv.log" || opts.Format != "json" {
+		t.Errorf("Unexpected options %+v", opts)
+	}
+
+	if _, _, err := extractLogOptions([]string{"list", "--log-file"}); err == nil {
+		t.Error("Expected error for a flag without a value")
+	}
+
+	args, opts, err = extractLogOptions([]string{"--verbose", "list", "-debug=false"})
+	if err != nil {
+		t.Fatalf("extractLogOptions failed: %v", err)
+	}
+	if !reflect.DeepEqual(args, []string{"list"}) || !opts.Verbose || opts.Debug {
+		t.Errorf("Unexpected verbose options %v, %+v", args, opts)
+	}
+	if _, _, err := extractLogOptions([]string{"--debug=loud"}); err == nil {
+		t.Error("Expected error for an invalid boolean")
+	}
+}
+
+func TestSetupLoggingVerbose(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	stderr := os.Stderr
+	defer func() { os.Stderr = stderr }()
+
+	for _, tt := range []struct {
+		opts  logOptions
+		debug bool
+	}{
+		{logOptions{Verbose: true}, false},
+		{logOptions{Debug: true}, true},
+		{logOptions{Verbose: true, Debug: true}, true},
+	} {
+		f, err := os.CreateTemp(t.TempDir(), "stderr")
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Stderr = f
+		closeLog, err := setupLogging(tt.opts)
+		if err != nil {
+			t.Fatalf("setupLogging failed: %v", err)
+		}
+		slog.Debug("resolved directory")
+		logExec(exec.Command("echo", "a b"))
+		closeLog()
+		f.Close()
+
+		data, _ := os.ReadFile(f.Name())
+		if !strings.Contains(string(data), `argv="[echo a b]"`) {
+			t.Errorf("Expected the argv on stderr for %+v, got:\n%s", tt.opts, data)
+		}
+		if got := strings.Contains(string(data), "resolved directory"); got != tt.debug {
+			t.Errorf("Expected debug records %v for %+v, got:\n%s", tt.debug, tt.opts, data)
+		}
+	}
+}
+
+func TestSetupLoggingWritesSpans(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	if _, err := setupLogging(logOptions{Format: "xml"}); err == nil {
+		t.Error("Expected error for an unknown format")
+	}
+
+	path := filepath.Join(t.TempDir(), "afv.log")
+	closeLog, err := setupLogging(logOptions{File: path, Format: "json"})
+	if err != nil {
+		t.Fatalf("setupLogging failed: %v", err)
+	}
+	startSpan("db.Test", "tx", "view").End(nil)
+	startSpan("exec.run", "name", "build").End(errors.New("exit status 1"), "exit_code", 1)
+	closeLog()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 log lines, got %d:\n%s", len(lines), data)
+	}
+
+	var failed map[string]any
+	if err := json.Unmarshal([]byte(lines[3]), &failed); err != nil {
+		t.Fatalf("Expected JSON log line: %v", err)
+	}
+	if failed["msg"] != "exec.run failed" || failed["level"] != "ERROR" || failed["exit_code"] != float64(1) {
+		t.Errorf("Unexpected span end %v", failed)
+	}
+	if _, ok := failed["duration"]; !ok {
+		t.Error("Expected span duration")
+	}
+}
+
+func TestDatabaseOperationsAreLogged(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	path := filepath.Join(t.TempDir(), "afv.log")
+	closeLog, err := setupLogging(logOptions{File: path})
+	if err != nil {
+		t.Fatalf("setupLogging failed: %v", err)
+	}
+	defer closeLog()
+
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+	db.GetCommand("missing")
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `msg="db.GetCommand failed"`) {
+		t.Errorf("Expected failed db span in log:\n%s", data)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log retention defaults used when config.yaml does not set them
+const (
+	defaultLogMaxSize  = "10MB"
+	defaultLogMaxFiles = 5
+	defaultLogMaxAge   = "30d"
+)
+
+// serviceLogName is the file a service writes its output to inside its log
+// directory
+const serviceLogName = "service.log"
+
+// LogRetention limits the log files kept per command. MaxSize is the size at
+// which a log is rotated, MaxFiles the number of files kept per command
+// (including the current one) and MaxAge the age after which files are
+// removed. Sizes accept B, KB, MB and GB; ages accept Go durations and days
+// such as 30d.
+type LogRetention struct {
+	MaxSize  string `yaml:"max_size,omitempty"`
+	MaxFiles int    `yaml:"max_files,omitempty"`
+	MaxAge   string `yaml:"max_age,omitempty"`
+}
+
+// logLimits are the parsed retention settings
+type logLimits struct {
+	maxSize  int64
+	maxFiles int
+	maxAge   time.Duration
+}
+
+// limits parses the retention settings, applying defaults for unset fields
+func (r LogRetention) limits() (logLimits, error) {
+	size, err := parseByteSize(firstNonEmpty(r.MaxSize, defaultLogMaxSize))
+	if err != nil {
+		return logLimits{}, err
+	}
+
+	files := r.MaxFiles
+	if files == 0 {
+		files = defaultLogMaxFiles
+	}
+	if files < 1 {
+		return logLimits{}, fmt.Errorf("log max files must be at least 1")
+	}
+
+	age, err := parseAge(firstNonEmpty(r.MaxAge, defaultLogMaxAge))
+	if err != nil {
+		return logLimits{}, err
+	}
+	return logLimits{maxSize: size, maxFiles: files, maxAge: age}, nil
+}
+
+// parseByteSize parses sizes like 512KB or 10MB (1KB = 1024 bytes)
+func parseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.factor
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size '%s' (expected e.g. 10MB)", s)
+	}
+	return n * multiplier, nil
+}
+
+// parseAge parses a Go duration or a number of days such as 30d
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age '%s' (expected e.g. 30d or 12h)", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age '%s' (expected e.g. 30d or 12h)", s)
+	}
+	return d, nil
+}
+
+// LogDir returns the directory holding the per-command log directories
+func (d *Database) LogDir() string {
+	return filepath.Join(d.DataDir(), "logs")
+}
+
+// AdhocLogDir returns the log directory of afv exec runs. safeFileName only
+// writes an underscore before two hex digits, so no command shares it.
+func (d *Database) AdhocLogDir() string {
+	return filepath.Join(d.LogDir(), "_exec")
+}
+
+// CommandLogDir returns the log directory of a command
+func (d *Database) CommandLogDir(name string) string {
+	return filepath.Join(d.LogDir(), safeFileName(name))
+}
+
+// rotatedLogPath returns the path of the n-th rotated generation of a log
+func rotatedLogPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// rotateLog moves path to path.1 once it reaches the size limit, shifting
+// older generations and dropping those beyond the file limit. A log that is
+// still being written is copied and truncated instead of renamed, so the
+// writer keeps appending to the same file.
+func rotateLog(path string, limits logLimits, inUse bool) (bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Size() < limits.maxSize {
+		return false, nil
+	}
+
+	keep := limits.maxFiles - 1
+	for n := keep; n >= 1; n-- {
+		src := rotatedLogPath(path, n)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if n == keep {
+			if err := os.Remove(src); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if err := os.Rename(src, rotatedLogPath(path, n+1)); err != nil {
+			return false, err
+		}
+	}
+
+	if keep == 0 {
+		return true, os.Truncate(path, 0)
+	}
+	if !inUse {
+		return true, os.Rename(path, rotatedLogPath(path, 1))
+	}
+	if err := copyLogFile(path, rotatedLogPath(path, 1)); err != nil {
+		return false, err
+	}
+	return true, os.Truncate(path, 0)
+}
+
+// rotateServiceLog rotates the log of a service that is about to start,
+// using the retention limits from config.yaml
+func rotateServiceLog(path string) error {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	limits, err := cfg.Logs.limits()
+	if err != nil {
+		return err
+	}
+	if _, err := rotateLog(path, limits, false); err != nil {
+		return fmt.Errorf("failed to rotate service log: %v", err)
+	}
+	return nil
+}
+
+// copyLogFile copies the content of src to a new file dst
+func copyLogFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// PruneResult lists what a log prune changed
+type PruneResult struct {
+	Rotated []string
+	Removed []string
+	Freed   int64
+}
+
+// logFile is a file in a command's log directory
+type logFile struct {
+	path string
+	info os.FileInfo
+}
+
+// pruneLogDir enforces the retention limits on one command's log
+// directory. active is the log a running process still writes to; it is
+// rotated in place but never removed.
+func pruneLogDir(dir, active string, limits logLimits, now time.Time, result *PruneResult) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".log") {
+			path := filepath.Join(dir, entry.Name())
+			rotated, err := rotateLog(path, limits, path == active)
+			if err != nil {
+				return fmt.Errorf("failed to rotate %s: %v", path, err)
+			}
+			if rotated {
+				result.Rotated = append(result.Rotated, path)
+			}
+		}
+	}
+
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var files []logFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, logFile{path: filepath.Join(dir, entry.Name()), info: info})
+	}
+
+	// Newest first, so the files beyond the limit are the oldest ones
+	slices.SortFunc(files, func(a, b logFile) int {
+		return b.info.ModTime().Compare(a.info.ModTime())
+	})
+	kept := 0
+	for _, f := range files {
+		if f.path == active {
+			kept++
+			continue
+		}
+		if kept < limits.maxFiles && now.Sub(f.info.ModTime()) <= limits.maxAge {
+			kept++
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			return fmt.Errorf("failed to remove %s: %v", f.path, err)
+		}
+		result.Removed = append(result.Removed, f.path)
+		result.Freed += f.info.Size()
+	}
+
+	if kept == 0 {
+		os.Remove(dir)
+	}
+	return nil
+}
+
+// PruneLogs rotates oversized logs and removes old log files in every
+// command's log directory
+func PruneLogs(db *Database, retention LogRetention, now time.Time) (*PruneResult, error) {
+	limits, err := retention.limits()
+	if err != nil {
+		return nil, err
+	}
+
+	// Services that are still running keep writing to their current log
+	active := map[string]string{}
+	states, err := db.GetAllServiceStates()
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		if processAlive(state.PID) {
+			active[filepath.Dir(state.LogFile)] = state.LogFile
+		}
+	}
+
+	result := &PruneResult{}
+	entries, err := os.ReadDir(db.LogDir())
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log directory: %v", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(db.LogDir(), entry.Name())
+		if err := pruneLogDir(dir, active[dir], limits, now, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Print writes a summary of the prune
+func (r *PruneResult) Print() {
+	for _, path := range r.Rotated {
+		fmt.Printf("Rotated %s\n", path)
+	}
+	for _, path := range r.Removed {
+		fmt.Printf("Removed %s\n", path)
+	}
+	if len(r.Rotated) == 0 && len(r.Removed) == 0 {
+		fmt.Println("Logs are within the retention limits.")
+		return
+	}
+	fmt.Printf("%d rotated, %d removed, %s freed.\n", len(r.Rotated), len(r.Removed), formatByteSize(r.Freed))
+}
+
+// formatByteSize renders a byte count with a binary unit
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
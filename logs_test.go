@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseByteSizeAndAge(t *testing.T) {
+	sizes := map[string]int64{"100": 100, "512B": 512, "4kb": 4096, "10MB": 10 << 20, "1 GB": 1 << 30}
+	for input, expected := range sizes {
+		got, err := parseByteSize(input)
+		if err != nil || got != expected {
+			t.Errorf("parseByteSize(%q) = %d, %v; expected %d", input, got, err, expected)
+		}
+	}
+	for _, input := range []string{"", "MB", "-1MB", "ten"} {
+		if _, err := parseByteSize(input); err == nil {
+			t.Errorf("Expected error for size %q", input)
+		}
+	}
+
+	if d, err := parseAge("30d"); err != nil || d != 30*24*time.Hour {
+		t.Errorf("parseAge(30d) = %v, %v", d, err)
+	}
+	if d, err := parseAge("12h"); err != nil || d != 12*time.Hour {
+		t.Errorf("parseAge(12h) = %v, %v", d, err)
+	}
+	if _, err := parseAge("0d"); err == nil {
+		t.Error("Expected error for a zero age")
+	}
+}
+
+func writeLog(t *testing.T, path string, size int, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateLog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, serviceLogName)
+	limits := logLimits{maxSize: 10, maxFiles: 3, maxAge: time.Hour}
+
+	writeLog(t, path, 5, time.Now())
+	if rotated, err := rotateLog(path, limits, false); err != nil || rotated {
+		t.Fatalf("Expected small log to stay, got %v, %v", rotated, err)
+	}
+
+	// Three rotations keep only two generations next to the current log
+	for i := 0; i < 3; i++ {
+		writeLog(t, path, 20+i, time.Now())
+		if rotated, err := rotateLog(path, limits, false); err != nil || !rotated {
+			t.Fatalf("Expected rotation %d, got %v, %v", i, rotated, err)
+		}
+	}
+	if info, err := os.Stat(rotatedLogPath(path, 1)); err != nil || info.Size() != 22 {
+		t.Errorf("Expected newest generation in .1, got %v, %v", info, err)
+	}
+	if info, err := os.Stat(rotatedLogPath(path, 2)); err != nil || info.Size() != 21 {
+		t.Errorf("Expected older generation in .2, got %v, %v", info, err)
+	}
+	if _, err := os.Stat(rotatedLogPath(path, 3)); !os.IsNotExist(err) {
+		t.Error("Expected generations beyond max files to be dropped")
+	}
+
+	// A log in use is copied and truncated so the writer keeps its file
+	writeLog(t, path, 30, time.Now())
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := rotateLog(path, limits, true); err != nil {
+		t.Fatalf("rotateLog in use failed: %v", err)
+	}
+	f.WriteString("after")
+	if data, _ := os.ReadFile(path); string(data) != "after" {
+		t.Errorf("Expected writer to continue in the truncated log, got %q", data)
+	}
+	if info, err := os.Stat(rotatedLogPath(path, 1)); err != nil || info.Size() != 30 {
+		t.Errorf("Expected copied content in .1, got %v, %v", info, err)
+	}
+}
+
+func TestPruneLogs(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	now := time.Now()
+	buildDir := db.CommandLogDir("build")
+	oldDir := db.CommandLogDir("old")
+	for _, dir := range []string{buildDir, oldDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i <= 4; i++ {
+		writeLog(t, filepath.Join(buildDir, "run"+string(rune('0'+i))+".log"), 10, now.Add(-time.Duration(i)*time.Hour))
+	}
+	writeLog(t, filepath.Join(oldDir, serviceLogName), 10, now.Add(-48*time.Hour))
+
+	result, err := PruneLogs(db, LogRetention{MaxSize: "1MB", MaxFiles: 2, MaxAge: "1d"}, now)
+	if err != nil {
+		t.Fatalf("PruneLogs failed: %v", err)
+	}
+	if len(result.Removed) != 3 || result.Freed != 30 {
+		t.Errorf("Expected 3 removed files and 30 bytes freed, got %+v", result)
+	}
+	for _, name := range []string{"run1.log", "run2.log"} {
+		if _, err := os.Stat(filepath.Join(buildDir, name)); err != nil {
+			t.Errorf("Expected newest log %s to be kept: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(oldDir); !os.IsNotExist(err) {
+		t.Error("Expected empty log directory to be removed")
+	}
+
+	if _, err := PruneLogs(db, LogRetention{MaxAge: "soon"}, now); err == nil {
+		t.Error("Expected error for an invalid max age")
+	}
+}
+
+func TestPruneLogsKeepsRunningServiceLog(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	dir := db.CommandLogDir("web")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(dir, serviceLogName)
+	writeLog(t, logPath, 10, time.Now().Add(-90*24*time.Hour))
+	if err := db.SaveServiceState(ServiceState{Name: "web", PID: os.Getpid(), LogFile: logPath}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := PruneLogs(db, LogRetention{MaxAge: "1d"}, time.Now()); err != nil {
+		t.Fatalf("PruneLogs failed: %v", err)
+	}
+	if _, err := os.Stat(logPath); err != nil {
+		t.Errorf("Expected log of a running service to be kept: %v", err)
+	}
+}
@@ -1,104 +1,329 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
 	"os"
-	"os/exec"
-	"os/user"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/leaanthony/clir"
+	"golang.org/x/term"
 )
 
-// resolveDirectory resolves special directory shortcuts like "." and "~"
+// resolveDirectory resolves special directory shortcuts like ".", "~" and,
+// on Windows, ~\ and %USERPROFILE%
 func resolveDirectory(dir string) (string, error) {
 	if dir == "" {
 		return "", nil
 	}
-	
+
 	dir = strings.TrimSpace(dir)
-	
-	switch dir {
-	case ".":
+
+	if dir == "." {
 		// Current directory
 		cwd, err := os.Getwd()
 		if err != nil {
 			return "", fmt.Errorf("failed to get current directory: %v", err)
 		}
 		return cwd, nil
-	case "~":
-		// Home directory
-		usr, err := user.Current()
-		if err != nil {
-			return "", fmt.Errorf("failed to get user home directory: %v", err)
+	}
+
+	// Expand home and environment shortcuts
+	expanded, err := localPaths.expand(dir)
+	if err != nil {
+		return "", err
+	}
+
+	// Convert to absolute if relative; this also resolves drive-relative
+	// paths on Windows and cleans mixed separators
+	absPath, err := filepath.Abs(expanded)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %v", err)
+	}
+	slog.Debug("resolved directory", "dir", dir, "path", absPath)
+	return absPath, nil
+}
+
+// commandName returns the value of the --name flag, falling back to the first
+// positional argument
+func commandName(cmd *clir.Command, flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if args := cmd.OtherArgs(); len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
+
+// givenFlags returns the names of the flags present in args, so an action
+// can tell a flag set to an empty value from one that was left out
+func givenFlags(args []string) map[string]bool {
+	given := map[string]bool{}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			given[name] = true
 		}
-		return usr.HomeDir, nil
-	default:
-		// Handle paths starting with ~/ (home directory expansion)
-		if strings.HasPrefix(dir, "~/") {
-			usr, err := user.Current()
-			if err != nil {
-				return "", fmt.Errorf("failed to get user home directory: %v", err)
-			}
-			return filepath.Join(usr.HomeDir, dir[2:]), nil
+	}
+	return given
+}
+
+// firstNonEmpty returns the first of its arguments that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
 		}
-		// Regular path - convert to absolute if relative
-		absPath, err := filepath.Abs(dir)
-		if err != nil {
-			return "", fmt.Errorf("failed to resolve path: %v", err)
+	}
+	return ""
+}
+
+// splitList splits a comma-separated flag value into trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// confirm asks a yes/no question on stdin and reports whether the answer was yes
+func confirm(prompt string) bool {
+	fmt.Printf("%s (y/N): ", prompt)
+	var response string
+	_, _ = fmt.Scanln(&response) // Ignore error - user input handling
+
+	response = strings.ToLower(strings.TrimSpace(response))
+	return response == "y" || response == "yes"
+}
+
+// splitPassthrough separates afv's own arguments from those after the first
+// "--", which are passed to the child process untouched
+func splitPassthrough(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:]
 		}
-		return absPath, nil
 	}
+	return args, nil
 }
 
 func main() {
+	// Commands with resource limits are started through afv, which applies
+	// them before it executes the command
+	if len(os.Args) > 1 && os.Args[1] == limitCommand {
+		RunLimitCommand(os.Args[2:])
+	}
+
+	// Exit with a failure status once the deferred cleanup has run, so
+	// scripts can tell when afv failed
+	status := 0
+	defer func() {
+		if status != 0 {
+			os.Exit(status)
+		}
+	}()
+
+	// Arguments after "--" bypass clir's flag parsing
+	cliArgs, passthroughArgs := splitPassthrough(os.Args[1:])
+	// afv NAME args... runs a stored command; its arguments are passed on
+	// before afv looks for its own flags in them
+	cliArgs, shortcut, shortcutArgs := splitShortcut(cliArgs)
+	if shortcut != "" {
+		passthroughArgs = slices.Concat(shortcutArgs, passthroughArgs)
+	}
+	cliArgs, logOpts, err := extractLogOptions(cliArgs)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		status = 1
+		return
+	}
+	closeLog, err := setupLogging(logOpts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		status = 1
+		return
+	}
+	defer closeLog()
+
+	cliArgs, profileOpts, err := extractProfileOptions(cliArgs)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		status = 1
+		return
+	}
+	stopProfiling, err := startProfiling(profileOpts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		status = 1
+		return
+	}
+	defer stopProfiling()
+	cliArgs, outputFormat, err := extractOutputFormat(cliArgs)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		status = 1
+		return
+	}
+	cliArgs, err = extractDatabaseOption(cliArgs)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		status = 1
+		return
+	}
+	cliArgs, err = extractWorkspaceOption(cliArgs)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		status = 1
+		return
+	}
+	slog.Debug("afv start", "args", cliArgs, "passthrough", passthroughArgs)
+
 	cli := clir.NewCli("afv", "Short for afvikle. CLI to speed up the process of running multiple scripts without creating another script. Run from anywhere.", "v1.0.0")
 
 	// Initialize database
 	db, err := NewDatabase()
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		slog.Error("failed to initialize database", "error", err)
+		fmt.Fprintf(os.Stderr, "Failed to initialize database: %v\n", err)
+		stopProfiling()
+		closeLog()
+		os.Exit(1)
 	}
 	defer db.Close()
 
-	// List command - show all stored commands
-	cli.NewSubCommand("list", "Returns a list of commands runnable with afvikle").
-		Action(func() error {
-			commands, err := db.GetAllCommands()
-			if err != nil {
-				return fmt.Errorf("failed to get commands: %v", err)
-			}
-
-			if len(commands) == 0 {
-				fmt.Println("No commands found. Use 'afv add' to add commands.")
-				return nil
-			}
+	// Commands shared through the project file of the working directory
+	if cwd, err := os.Getwd(); err == nil {
+		if err := db.LoadProject(cwd); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring the project file: %v\n", err)
+		}
+	}
 
-			fmt.Println("Available commands:")
-			for _, cmd := range commands {
-				fmt.Printf("  %-15s %s", cmd.Name, cmd.Description)
-				if cmd.WorkingDir != "" {
-					fmt.Printf(" (dir: %s)", cmd.WorkingDir)
-				}
-				fmt.Println()
-			}
-			return nil
-		})
+	// List command - show all stored commands
+	listCmd := cli.NewSubCommand("list", "Returns a list of commands runnable with afvikle")
+	var listLong bool
+	var listTag string
+	listCmd.StringFlag("tag", "Only list commands with this tag (optional)", &listTag)
+	listCmd.BoolFlag("long", "Also show the command line, working directory, tags and last run of each command", &listLong)
+	listCmd.Action(func() error {
+		opts := ListOptions{Long: listLong, Format: outputFormat, Tag: listTag}
+		if args := listCmd.OtherArgs(); len(args) > 0 {
+			opts.Namespace = normalizeNamespace(args[0])
+		}
+		return ListCommands(db, opts)
+	})
 
 	// Add command - store a new command
 	addCmd := cli.NewSubCommand("add", "Add a new command to the database")
-	var addName, addDesc, addCommand, addWorkingDir string
+	var addName, addDesc, addCommand, addWorkingDir, addCooldown, addType string
+	var addSingleton, addFromClipboard bool
+	var addHealthCheck, addHealthInterval, addHealthTimeout string
+	var addHealthRetries int
+	var addWaitFor, addWaitAfter, addRequires, addCleanup string
 	addCmd.StringFlag("name", "Command name", &addName)
 	addCmd.StringFlag("desc", "Command description", &addDesc)
 	addCmd.StringFlag("cmd", "Command to execute", &addCommand)
+	addCmd.BoolFlag("from-clipboard", "Read the command to execute from the system clipboard", &addFromClipboard)
+	var addScriptFile string
+	var addEdit bool
+	addCmd.StringFlag("script-file", "Store the contents of this script file instead of a command line, - for stdin", &addScriptFile)
+	addCmd.BoolFlag("edit", "Write the script to store in $EDITOR", &addEdit)
+	var addSteps []string
+	addCmd.StringsFlag("step", "Command line of a step, repeatable; steps run in order and stop at the first failure", &addSteps)
 	addCmd.StringFlag("dir", "Working directory for the command (optional)", &addWorkingDir)
+	addCmd.StringFlag("cooldown", "Minimum time between runs, e.g. 10m (optional)", &addCooldown)
+	addCmd.BoolFlag("singleton", "Prevent the command from running more than once at a time", &addSingleton)
+	var addConfirm bool
+	addCmd.BoolFlag("confirm", "Ask for confirmation before every run", &addConfirm)
+	addCmd.StringFlag("type", "Command type: leave empty for a regular command or 'service' for start/stop/status (optional)", &addType)
+	addCmd.StringFlag("health-check", "Command or http(s) URL that must succeed after running (optional)", &addHealthCheck)
+	addCmd.IntFlag("health-retries", "Number of health check attempts (default 3)", &addHealthRetries)
+	addCmd.StringFlag("health-interval", "Delay between health check attempts (default 2s)", &addHealthInterval)
+	addCmd.StringFlag("health-timeout", "Timeout per health check attempt (default 5s)", &addHealthTimeout)
+	addCmd.StringFlag("wait-for", "Wait for host:port[,timeout] to accept connections before running (optional)", &addWaitFor)
+	addCmd.StringFlag("wait-after", "Wait for host:port[,timeout] to accept connections after running or starting (optional)", &addWaitAfter)
+	addCmd.StringFlag("requires", "Comma-separated service commands that must be running first (optional)", &addRequires)
+	addCmd.StringFlag("cleanup", "Command run after every run, even if it failed (optional)", &addCleanup)
+	var addPreHook, addPostHook string
+	addCmd.StringFlag("pre-hook", "Command run before every run; the run is aborted if it fails (optional)", &addPreHook)
+	addCmd.StringFlag("post-hook", "Command run after every successful run (optional)", &addPostHook)
+	var addNotes string
+	var addEval bool
+	var addEnv []string
+	addCmd.StringsFlag("env", "Environment variable KEY=VALUE for the command, repeatable (optional)", &addEnv)
+	addCmd.StringFlag("notes", "Runbook notes shown by 'afv help NAME' (optional)", &addNotes)
+	var addTags []string
+	addCmd.StringsFlag("tag", "Tag for the command, repeatable or comma-separated (optional)", &addTags)
+	addCmd.BoolFlag("eval", "Evaluate the command in the calling shell, e.g. for cd or export (needs 'afv shell-init')", &addEval)
+	var addNotifyOn, addNotifyAfter, addNotifyEmail string
+	addCmd.StringFlag("notify-on", "Send a desktop notification on: always, failure or success (optional)", &addNotifyOn)
+	addCmd.StringFlag("notify-after", "Only notify if the run took at least this long, e.g. 5m (optional)", &addNotifyAfter)
+	addCmd.StringFlag("notify-email", "Comma-separated recipients notified by email instead of the desktop (optional)", &addNotifyEmail)
+	var addWsl string
+	addCmd.StringFlag("wsl", "Run the command inside this WSL distro from Windows (optional)", &addWsl)
+	var addContainer string
+	addCmd.StringFlag("container", "Run the command in a Docker container of this image with the working directory mounted (optional)", &addContainer)
+	var addPath, addVars []string
+	var addEnvFile, addTimeout string
+	addCmd.StringFlag("timeout", "Stop the command if it runs longer than this, e.g. 30s (optional)", &addTimeout)
+	var addNice, addCPULimit int
+	var addMaxMem string
+	addCmd.IntFlag("nice", "Scheduling priority from -20 (highest) to 19 (lowest) (optional)", &addNice)
+	addCmd.StringFlag("max-mem", "Most memory the command may use, e.g. 512M or 2G (optional)", &addMaxMem)
+	addCmd.IntFlag("cpu-limit", "Number of CPUs the command may use (optional)", &addCPULimit)
+	addCmd.StringFlag("env-file", "Dotenv file loaded before running, relative to the working directory (optional)", &addEnvFile)
+	var addStdinFile, addRunAs string
+	addCmd.StringFlag("run-as", "User the command runs as; afv needs root to switch users (optional, not on Windows)", &addRunAs)
+	addCmd.StringFlag("stdin-file", "File fed to the command's standard input, relative to the working directory (optional)", &addStdinFile)
+	addCmd.StringsFlag("var", "Default name=value for a {{name}} placeholder, repeatable (optional)", &addVars)
+	addCmd.StringsFlag("path", "Directory prepended to PATH for the command, relative to its working directory, repeatable (optional)", &addPath)
 	addCmd.Action(func() error {
 		if addName == "" {
 			return fmt.Errorf("name is required")
 		}
-		if addCommand == "" {
+		sources := 0
+		for _, given := range []bool{addCommand != "", addFromClipboard, addScriptFile != "", addEdit, len(addSteps) > 0} {
+			if given {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return fmt.Errorf("use only one of --cmd, --from-clipboard, --script-file, --edit and --step")
+		}
+		script := addScriptFile != "" || addEdit
+		if addFromClipboard {
+			clip, err := readClipboard()
+			if err != nil {
+				return err
+			}
+			addCommand = strings.TrimSpace(clip)
+			if addCommand == "" {
+				return fmt.Errorf("clipboard is empty")
+			}
+			fmt.Printf("Command from clipboard: %s\n", addCommand)
+		}
+		if addScriptFile != "" {
+			body, err := readScript(addScriptFile)
+			if err != nil {
+				return err
+			}
+			addCommand = body
+		} else if addEdit {
+			body, err := editScript()
+			if err != nil {
+				return err
+			}
+			addCommand = body
+		}
+		if addCommand == "" && len(addSteps) == 0 {
 			return fmt.Errorf("cmd is required")
 		}
 
@@ -106,18 +331,78 @@ func main() {
 			addDesc = "No description provided"
 		}
 
-		// Handle special directory shortcuts
-		resolvedDir, err := resolveDirectory(addWorkingDir)
+		env, err := parseEnvAssignments(addEnv)
 		if err != nil {
-			return fmt.Errorf("failed to resolve directory: %v", err)
+			return err
+		}
+		vars, err := parseVarAssignments(addVars)
+		if err != nil {
+			return err
+		}
+
+		// Handle special directory shortcuts; directories inside a WSL
+		// distro are kept for the distro to resolve
+		resolvedDir := strings.TrimSpace(addWorkingDir)
+		if addWsl == "" || !isLinuxPath(resolvedDir) {
+			resolvedDir, err = resolveDirectory(addWorkingDir)
+			if err != nil {
+				return fmt.Errorf("failed to resolve directory: %v", err)
+			}
+		}
+
+		newCmd := Command{
+			Name:        addName,
+			Description: addDesc,
+			Command:     addCommand,
+			Steps:       addSteps,
+			WorkingDir:  resolvedDir,
+			Cooldown:    addCooldown,
+			Singleton:   addSingleton,
+			Confirm:     addConfirm,
+			Script:      script,
+			Type:        addType,
+			WaitFor:     addWaitFor,
+			WaitAfter:   addWaitAfter,
+			Requires:    splitList(addRequires),
+			Cleanup:     addCleanup,
+			PreHook:     addPreHook,
+			PostHook:    addPostHook,
+			Notes:       addNotes,
+			Tags:        splitTags(addTags),
+			Eval:        addEval,
+			Env:         env,
+			NotifyEmail: splitList(addNotifyEmail),
+			Wsl:         addWsl,
+			Container:   addContainer,
+			PathPrepend: addPath,
+			Vars:        vars,
+			EnvFile:     addEnvFile,
+			StdinFile:   addStdinFile,
+			RunAs:       addRunAs,
+			Timeout:     addTimeout,
+			Nice:        addNice,
+			MaxMem:      addMaxMem,
+			CPULimit:    addCPULimit,
+		}
+		if addNotifyOn != "" || addNotifyAfter != "" {
+			newCmd.Notify = []NotifyRule{{On: addNotifyOn, MinDuration: addNotifyAfter}}
+		}
+		if addHealthCheck != "" {
+			newCmd.HealthCheck = &HealthCheck{
+				Check:    addHealthCheck,
+				Retries:  addHealthRetries,
+				Interval: addHealthInterval,
+				Timeout:  addHealthTimeout,
+			}
 		}
 
-		err = db.AddCommand(addName, addDesc, addCommand, resolvedDir)
+		err = db.InsertCommand(newCmd)
 		if err != nil {
 			return fmt.Errorf("failed to add command: %v", err)
 		}
 
 		fmt.Printf("Command '%s' added successfully.\n", addName)
+		warnBuiltinName(addName)
 		if resolvedDir != "" {
 			fmt.Printf("Working directory: %s\n", resolvedDir)
 		}
@@ -128,132 +413,1976 @@ func main() {
 	runCmd := cli.NewSubCommand("run", "Run a stored command")
 	var runName string
 	var workingDir string
+	var runForce, runWait, runStopDeps bool
+	var runWaitFor, runWaitAfter, runThen string
 	runCmd.StringFlag("name", "Command name to run", &runName)
 	runCmd.StringFlag("dir", "Working directory to run the command in (optional)", &workingDir)
+	runCmd.BoolFlag("force", "Run even if the command is still cooling down, without asking for confirmation", &runForce)
+	runCmd.BoolFlag("wait", "Wait for a running instance of a singleton or --exclusive command instead of failing", &runWait)
+	var runExclusive bool
+	runCmd.BoolFlag("exclusive", "Fail if another invocation of the command is running, as if it was a singleton", &runExclusive)
+	runCmd.StringFlag("wait-for", "Wait for host:port[,timeout] before running, overriding the stored value", &runWaitFor)
+	runCmd.StringFlag("wait-after", "Wait for host:port[,timeout] after running, overriding the stored value", &runWaitAfter)
+	runCmd.BoolFlag("stop-deps", "Stop required services that this run started once it finishes", &runStopDeps)
+	var runVars []string
+	var runRemember bool
+	var runEnvFile, runTimeout, runContainer string
+	runCmd.StringFlag("container", "Run in a Docker container of this image, overriding the stored one (optional)", &runContainer)
+	runCmd.StringFlag("timeout", "Stop the command if it runs longer than this, overriding the stored value (optional)", &runTimeout)
+	runCmd.StringFlag("env-file", "Dotenv file to load instead of the stored one, relative to the working directory (optional)", &runEnvFile)
+	var runStdinFile string
+	runCmd.StringFlag("stdin-file", "File fed to standard input instead of the stored one or the terminal, relative to the working directory (optional)", &runStdinFile)
+	runCmd.StringsFlag("var", "Value name=value for a {{name}} placeholder, repeatable (optional)", &runVars)
+	runCmd.BoolFlag("remember", "Store the placeholder values of this run as the command's defaults", &runRemember)
+	runCmd.StringFlag("then", "Comma-separated commands to run one after another once the named ones succeed (optional)", &runThen)
+	var runKeepGoing, runParallel, runNoHooks, runDetach, runFuzzy, runNoLog bool
+	runCmd.BoolFlag("no-log", "Do not capture the output, so interactive commands keep the terminal", &runNoLog)
+	var runMatrix []string
+	runCmd.StringsFlag("matrix", "Run once per value of NAME=v1,v2,...; repeat for every combination of several variables", &runMatrix)
+	var runNotify bool
+	var runTime bool
+	runCmd.BoolFlag("time", "Show how long each phase of the run took, such as hooks and steps", &runTime)
+	runCmd.BoolFlag("notify", "Send a notification when the run finishes, even if the command's rules would not", &runNotify)
+	var runRepeat int
+	var runInterval string
+	var runUntilFail bool
+	runCmd.IntFlag("repeat", "Run the command this many times, reporting aggregate results", &runRepeat)
+	runCmd.StringFlag("interval", "Time to wait between repeated runs, e.g. 30s (optional)", &runInterval)
+	runCmd.BoolFlag("until-fail", "Stop repeating at the first failed run; repeats indefinitely without --repeat", &runUntilFail)
+	var runFromStep, runOnlyStep int
+	runCmd.IntFlag("from-step", "Resume a step command at this step, counting from 1", &runFromStep)
+	runCmd.IntFlag("only-step", "Run only this step of a step command, counting from 1", &runOnlyStep)
+	runCmd.BoolFlag("fuzzy", "Run the closest matching command when a name is not found", &runFuzzy)
+	runCmd.BoolFlag("detach", "Start the commands in the background as jobs (see afv jobs)", &runDetach)
+	runCmd.BoolFlag("no-hooks", "Skip the pre and post hooks of the commands", &runNoHooks)
+	var runEdit bool
+	runCmd.BoolFlag("edit", "Edit the command line in $EDITOR and run the edited version once without storing it", &runEdit)
+	runCmd.BoolFlag("keep-going", "Run every named command even if one fails, reporting all failures at the end", &runKeepGoing)
+	runCmd.BoolFlag("parallel", "Run the named commands at the same time with their output prefixed by name", &runParallel)
+	var runTag string
+	runCmd.StringFlag("tag", "Run every command with this tag and print a summary of the results", &runTag)
 	runCmd.Action(func() error {
-		if runName == "" {
-			return fmt.Errorf("name is required")
+		names := runCmd.OtherArgs()
+		if runName != "" {
+			names = append([]string{runName}, names...)
 		}
-
-		command, err := db.GetCommand(runName)
-		if err != nil {
-			return fmt.Errorf("failed to get command: %v", err)
+		if runTag != "" {
+			tagged, err := db.GetCommandsByTag(runTag)
+			if err != nil {
+				return err
+			}
+			tagged = slices.DeleteFunc(mergeProject(tagged, db.ProjectCommands()), func(cmd Command) bool {
+				return !slices.Contains(cmd.Tags, runTag)
+			})
+			if len(tagged) == 0 {
+				return fmt.Errorf("no commands tagged '%s'", runTag)
+			}
+			for _, command := range tagged {
+				if !slices.Contains(names, command.Name) {
+					names = append(names, command.Name)
+				}
+			}
 		}
-
-		// Determine working directory with resolution
-		var cmdDir string
-		if workingDir != "" {
-			// Use specified working directory (resolve shortcuts)
-			resolvedDir, err := resolveDirectory(workingDir)
+		if len(names) == 0 {
+			if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+				return fmt.Errorf("name is required")
+			}
+			picked, err := PickCommand(db)
 			if err != nil {
-				return fmt.Errorf("failed to resolve working directory: %v", err)
+				return err
 			}
-			cmdDir = resolvedDir
-		} else if command.WorkingDir != "" {
-			// Use stored working directory
-			cmdDir = command.WorkingDir
-		} else {
-			// Use current directory
-			cmdDir, _ = os.Getwd()
+			names = []string{picked}
 		}
-
-		fmt.Printf("Executing: %s\n", command.Command)
-		if cmdDir != "" {
-			fmt.Printf("Working directory: %s\n", cmdDir)
+		if len(names) > 1 && len(passthroughArgs) > 0 {
+			return fmt.Errorf("arguments after -- can only be given when running a single command")
 		}
 
-		// Parse and execute the command
-		parts := strings.Fields(command.Command)
-		if len(parts) == 0 {
-			return fmt.Errorf("empty command")
+		vars, err := parseVarAssignments(runVars)
+		if err != nil {
+			return err
 		}
-
-		cmd := exec.Command(parts[0], parts[1:]...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
-		
-		// Set working directory if specified
-		if cmdDir != "" {
-			cmd.Dir = cmdDir
+		axes, err := parseMatrix(runMatrix)
+		if err != nil {
+			return err
+		}
+		if runEdit && (len(names) > 1 || runParallel || runDetach || runRepeat != 0 || runUntilFail || len(axes) > 0) {
+			return fmt.Errorf("--edit runs a single command once and cannot be combined with --parallel, --detach, --repeat or --matrix")
 		}
 
-		return cmd.Run()
-	})
+		opts := RunOptions{
+			Dir:       workingDir,
+			Force:     runForce,
+			Wait:      runWait,
+			Exclusive: runExclusive,
+			WaitFor:   runWaitFor,
+			WaitAfter: runWaitAfter,
+			StopDeps:  runStopDeps,
+			Args:      passthroughArgs,
+			EnvFile:   runEnvFile,
+			StdinFile: runStdinFile,
+			Timeout:   runTimeout,
+			Container: runContainer,
+			FromStep:  runFromStep,
+			OnlyStep:  runOnlyStep,
+			Time:      runTime,
+			Notify:    runNotify,
+			Edit:      runEdit,
+			NoHooks:   runNoHooks,
+			NoLog:     runNoLog,
+			Vars:      vars,
+			Remember:  runRemember,
+		}
+		var steps []RunStep
+		for _, name := range names {
+			resolved, err := resolveCommandName(db, name, runFuzzy)
+			if err != nil {
+				return err
+			}
+			steps = append(steps, RunStep{Name: resolved, Opts: opts})
+		}
 
-	// Delete command - remove a stored command
-	deleteCmd := cli.NewSubCommand("delete", "Delete a stored command")
-	var deleteName string
-	var deleteAll bool
-	deleteCmd.StringFlag("name", "Command name to delete", &deleteName)
-	deleteCmd.BoolFlag("all", "Delete all commands", &deleteAll)
-	deleteCmd.Action(func() error {
-		if deleteAll {
-			// Delete all commands
-			commands, err := db.GetAllCommands()
+		// Chained commands share the run options except the readiness
+		// overrides, extra arguments, env and stdin files, step selection,
+		// remembering placeholder values and editing the command line
+		opts.WaitFor, opts.WaitAfter, opts.Args, opts.EnvFile, opts.StdinFile, opts.Remember = "", "", nil, "", "", false
+		opts.FromStep, opts.OnlyStep, opts.Edit = 0, 0, false
+		var then []RunStep
+		for _, next := range splitList(runThen) {
+			target, err := db.GetAliasTarget(next)
 			if err != nil {
-				return fmt.Errorf("failed to get commands: %v", err)
+				return fmt.Errorf("failed to get aliases: %v", err)
 			}
+			then = append(then, RunStep{Name: firstNonEmpty(target, next), Opts: opts})
+		}
 
-			if len(commands) == 0 {
-				fmt.Println("No commands to delete.")
-				return nil
+		if runRepeat != 0 || runUntilFail || runInterval != "" {
+			if len(steps) > 1 || len(then) > 0 || runParallel || runDetach || len(axes) > 0 {
+				return fmt.Errorf("--repeat runs a single command and cannot be combined with --then, --parallel, --detach or --matrix")
+			}
+			repeat := RepeatOptions{Count: runRepeat, UntilFail: runUntilFail}
+			if runInterval != "" {
+				if repeat.Interval, err = time.ParseDuration(runInterval); err != nil || repeat.Interval < 0 {
+					return fmt.Errorf("invalid interval '%s'", runInterval)
+				}
 			}
+			return RunRepeat(db, steps[0].Name, steps[0].Opts, repeat)
+		}
 
-			fmt.Printf("This will delete %d command(s). Are you sure? (y/N): ", len(commands))
-			var response string
-			_, _ = fmt.Scanln(&response) // Ignore error - user input handling
-			
-			if strings.ToLower(strings.TrimSpace(response)) != "y" && strings.ToLower(strings.TrimSpace(response)) != "yes" {
-				fmt.Println("Operation cancelled.")
-				return nil
+		if len(axes) > 0 {
+			if len(steps) > 1 || len(then) > 0 || runParallel || runDetach {
+				return fmt.Errorf("--matrix runs a single command and cannot be combined with --then, --parallel or --detach")
 			}
+			return RunMatrix(db, steps[0].Name, steps[0].Opts, axes)
+		}
 
-			// Delete all commands
-			for _, cmd := range commands {
-				err := db.DeleteCommand(cmd.Name)
+		if runDetach {
+			if len(then) > 0 || runParallel {
+				return fmt.Errorf("--detach cannot be combined with --then or --parallel")
+			}
+			for _, step := range steps {
+				job, err := StartJob(db, step.Name, step.Opts)
 				if err != nil {
-					return fmt.Errorf("failed to delete command '%s': %v", cmd.Name, err)
+					return err
 				}
+				fmt.Printf("Job %d started: %s (pid %d).\n", job.ID, job.Name, job.PID)
+				fmt.Printf("Log file: %s\n", job.LogFile)
 			}
+			return nil
+		}
 
-			fmt.Printf("Successfully deleted %d command(s).\n", len(commands))
+		if runTag != "" {
+			if len(then) > 0 {
+				return fmt.Errorf("--tag cannot be combined with --then")
+			}
+			return RunBatch(db, steps, runParallel, runKeepGoing)
+		}
+		if runParallel {
+			if err := RunParallel(db, steps); err != nil {
+				return err
+			}
+			return RunSequence(db, then, runKeepGoing)
+		}
+		return RunSequence(db, append(steps, then...), runKeepGoing)
+	})
+
+	// Rerun command - run a recent run again
+	rerunCmd := cli.NewSubCommand("rerun", "Run the last (or an earlier) run again")
+	rerunNth := 1
+	var rerunList bool
+	rerunCmd.IntFlag("nth", "Which run to repeat, counting back from the last one (default 1)", &rerunNth)
+	rerunCmd.BoolFlag("list", "Show the recent runs with their --nth number instead of running", &rerunList)
+	rerunCmd.Action(func() error {
+		if !rerunList {
+			return Rerun(db, rerunNth)
+		}
+		records, err := db.GetRunHistory("")
+		if err != nil {
+			return fmt.Errorf("failed to read run history: %v", err)
+		}
+		for nth := 1; nth <= min(len(records), 10); nth++ {
+			fmt.Printf("  %2d  %s\n", nth, describeRun(records[len(records)-nth]))
+		}
+		return nil
+	})
+
+	// Help command - render the runbook page of a stored command
+	helpCmd := cli.NewSubCommand("help", "Show usage help, or the runbook page of a stored command")
+	helpCmd.Action(func() error {
+		args := helpCmd.OtherArgs()
+		if len(args) == 0 {
+			cli.PrintHelp()
 			return nil
 		}
 
-		if deleteName == "" {
-			return fmt.Errorf("either --name or --all is required")
+		command, err := db.GetCommand(args[0])
+		if err != nil {
+			return err
+		}
+		PrintCommandHelp(command)
+		return nil
+	})
+
+	// Which command - show what run would execute without running it
+	whichCmd := cli.NewSubCommand("which", "Show the resolved invocation of a stored command without running it")
+	var whichName, whichDir string
+	whichCmd.StringFlag("name", "Command name", &whichName)
+	whichCmd.StringFlag("dir", "Working directory override, as for run (optional)", &whichDir)
+	whichCmd.Action(func() error {
+		name := commandName(whichCmd, whichName)
+		if name == "" {
+			return fmt.Errorf("name is required")
 		}
 
-		err := db.DeleteCommand(deleteName)
+		command, err := db.GetCommand(name)
 		if err != nil {
-			return fmt.Errorf("failed to delete command: %v", err)
+			return fmt.Errorf("failed to get command: %v", err)
+		}
+		dir, err := resolveRunDirectory(command, whichDir)
+		if err != nil {
+			return err
 		}
 
-		fmt.Printf("Command '%s' deleted successfully.\n", deleteName)
+		command, err = ExpandCommand(db, command)
+		if err != nil {
+			return err
+		}
+		inv, err := ResolveInvocation(command, dir)
+		if err != nil {
+			return err
+		}
+		inv.Args = append(inv.Args, passthroughArgs...)
+		inv.Print()
 		return nil
 	})
 
-	// Info command - show database information
-	cli.NewSubCommand("info", "Show database information").
-		Action(func() error {
-			dbPath, err := db.GetDatabasePath()
-			if err != nil {
-				return fmt.Errorf("failed to get database path: %v", err)
+	// Env command - print the environment afv would add as export statements
+	envCmd := cli.NewSubCommand("env", "Print the environment of a stored command as export statements")
+	var envName string
+	envShell := "bash"
+	envCmd.StringFlag("name", "Command name", &envName)
+	envCmd.StringFlag("shell", "Shell syntax: bash, zsh or fish", &envShell)
+	envCmd.Action(func() error {
+		name := commandName(envCmd, envName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+
+		command, err := db.GetCommand(name)
+		if err != nil {
+			return fmt.Errorf("failed to get command: %v", err)
+		}
+
+		dir, err := resolveRunDirectory(command, "")
+		if err != nil {
+			return err
+		}
+		vars, err := childEnv(command, dir)
+		if err != nil {
+			return err
+		}
+		statements, err := exportStatements(envShell, vars)
+		if err != nil {
+			return err
+		}
+		fmt.Print(statements)
+		return nil
+	})
+
+	// Cleanup command - run a command's cleanup manually
+	cleanupCmd := cli.NewSubCommand("cleanup", "Run the cleanup registered for a stored command")
+	var cleanupName, cleanupDir string
+	cleanupCmd.StringFlag("name", "Command name whose cleanup to run", &cleanupName)
+	cleanupCmd.StringFlag("dir", "Working directory to run the cleanup in (optional)", &cleanupDir)
+	cleanupCmd.Action(func() error {
+		name := commandName(cleanupCmd, cleanupName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+
+		command, err := db.GetCommand(name)
+		if err != nil {
+			return fmt.Errorf("failed to get command: %v", err)
+		}
+
+		dir, err := resolveRunDirectory(command, cleanupDir)
+		if err != nil {
+			return err
+		}
+		return RunCleanup(command, dir)
+	})
+
+	// Exec command - run an ad-hoc command with afv's directory and env handling
+	execCmd := cli.NewSubCommand("exec", "Run an ad-hoc command given after -- without storing it")
+	var execDir, execEnvFile, execSave string
+	var execNoLog bool
+	execCmd.StringFlag("dir", "Working directory to run the command in (optional)", &execDir)
+	execCmd.StringFlag("env-file", "Dotenv file to load, relative to the working directory (optional)", &execEnvFile)
+	execCmd.StringFlag("save", "Store the command under this name if it succeeds (optional)", &execSave)
+	execCmd.BoolFlag("no-log", "Do not capture the output, so interactive commands keep the terminal", &execNoLog)
+	execCmd.Action(func() error {
+		if len(passthroughArgs) == 0 {
+			return fmt.Errorf("command is required after --, e.g. afv exec -- go test ./...")
+		}
+
+		// Stored commands are split on whitespace, so arguments containing
+		// whitespace would not survive the round trip; refuse before running
+		if execSave != "" {
+			for _, arg := range passthroughArgs {
+				if strings.ContainsAny(arg, " \t\n") {
+					return fmt.Errorf("cannot save command: argument %q contains whitespace", arg)
+				}
 			}
+		}
 
-			commands, err := db.GetAllCommands()
-			if err != nil {
-				return fmt.Errorf("failed to get commands: %v", err)
+		command := &Command{Name: execSave, Command: strings.Join(passthroughArgs, " ")}
+		cmdDir, err := resolveRunDirectory(command, execDir)
+		if err != nil {
+			return err
+		}
+
+		err = RunAdhoc(db, passthroughArgs, cmdDir, execEnvFile, execNoLog)
+		if err != nil {
+			if execSave != "" {
+				fmt.Printf("Command failed, not saving it as '%s'.\n", execSave)
 			}
+			return err
+		}
 
-			fmt.Printf("Database location: %s\n", dbPath)
-			fmt.Printf("Total commands: %d\n", len(commands))
+		if execSave == "" {
 			return nil
+		}
+		if execDir != "" {
+			command.WorkingDir = cmdDir
+		}
+		if err := db.InsertCommand(*command); err != nil {
+			return fmt.Errorf("failed to save command: %v", err)
+		}
+		fmt.Printf("Command '%s' saved.\n", execSave)
+		if execEnvFile != "" {
+			fmt.Println("Note: the env file is not stored with the command.")
+		}
+		return nil
+	})
+
+	// Save-last command - store the previous shell command
+	saveLastCmd := cli.NewSubCommand("save-last", "Store the last command run in your shell")
+	var saveLastName, saveLastDesc, saveLastDir, saveLastHookShell string
+	saveLastDir = "."
+	saveLastCmd.StringFlag("name", "Command name", &saveLastName)
+	saveLastCmd.StringFlag("desc", "Command description", &saveLastDesc)
+	saveLastCmd.StringFlag("dir", "Working directory for the command (default: current directory)", &saveLastDir)
+	saveLastCmd.StringFlag("hook", "Print the shell hook for bash, zsh or fish instead of saving", &saveLastHookShell)
+	saveLastCmd.Action(func() error {
+		if saveLastHookShell != "" {
+			hook, err := saveLastHook(saveLastHookShell)
+			if err != nil {
+				return err
+			}
+			fmt.Print(hook)
+			return nil
+		}
+
+		if saveLastName == "" {
+			return fmt.Errorf("name is required")
+		}
+
+		last, err := lastShellCommand()
+		if err != nil {
+			return err
+		}
+
+		resolvedDir, err := resolveDirectory(saveLastDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve directory: %v", err)
+		}
+
+		err = db.InsertCommand(Command{
+			Name:        saveLastName,
+			Description: saveLastDesc,
+			Command:     last,
+			WorkingDir:  resolvedDir,
 		})
+		if err != nil {
+			return fmt.Errorf("failed to add command: %v", err)
+		}
 
-	// Starte the CLI
-	if err := cli.Run(); err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Printf("Command '%s' added successfully: %s\n", saveLastName, last)
+		warnBuiltinName(saveLastName)
+		if resolvedDir != "" {
+			fmt.Printf("Working directory: %s\n", resolvedDir)
+		}
+		return nil
+	})
+
+	// Shell init command - print the wrapper function for --eval commands
+	shellInitCmd := cli.NewSubCommand("shell-init", "Print the shell wrapper that runs --eval commands in your shell (bash, zsh or fish)")
+	shellInitCmd.Action(func() error {
+		args := shellInitCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("shell is required (bash, zsh or fish)")
+		}
+		script, err := shellInit(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
+		return nil
+	})
+
+	// Shell eval command - used by the shell wrapper, prints the script to
+	// evaluate for a run of an --eval command
+	shellEvalCmd := cli.NewSubCommand("shell-eval", "Print the script the shell wrapper evaluates")
+	shellEvalCmd.Hidden()
+	shellEvalCmd.Action(func() error {
+		args := shellEvalCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("shell is required")
+		}
+		if script := ShellEvalScript(db, args[0], passthroughArgs); script != "" {
+			fmt.Println(script)
+		}
+		return nil
+	})
+
+	// Completion command - print the completion script for a shell
+	completionCmd := cli.NewSubCommand("completion", "Print the tab completion script for your shell (bash, zsh, fish or powershell)")
+	completionCmd.Action(func() error {
+		args := completionCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("shell is required (bash, zsh, fish or powershell)")
+		}
+		script, err := completionScript(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
+		return nil
+	})
+
+	// Complete command - used by the completion scripts, prints the
+	// candidates for the command line given after --
+	completeCmd := cli.NewSubCommand("__complete", "Print the completion candidates for a command line")
+	completeCmd.Hidden()
+	completeCmd.Action(func() error {
+		words := splitCommandLine(strings.Join(passthroughArgs, " "))
+		c := &completer{db: db, help: func(path []string) string {
+			help, _ := captureStdout(func() { _ = cli.Run(append(path, "--help")...) })
+			return help
+		}}
+		for _, candidate := range c.Complete(words[1:]) {
+			fmt.Println(candidate)
+		}
+		return nil
+	})
+
+	// Init command - print the snippet wiring afv into the shell rc
+	initCmd := cli.NewSubCommand("init", "Print the snippet to eval in your shell rc: wrapper, completions and picker widget (bash, zsh or fish)")
+	var initProject bool
+	initCmd.BoolFlag("project", "Also load the commands of project files as shell functions when changing directories", &initProject)
+	initCmd.Action(func() error {
+		args := initCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("shell is required (bash, zsh or fish)")
+		}
+		script, err := initScript(args[0], initProject)
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
+		return nil
+	})
+
+	// Project hook command - used by the project hook of afv init, prints
+	// the script loading the commands of the project file
+	projectHookCmd := cli.NewSubCommand("__project-hook", "Print the script loading the commands of the project file")
+	projectHookCmd.Hidden()
+	projectHookCmd.Action(func() error {
+		args := projectHookCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("shell is required")
+		}
+		defined := strings.Fields(os.Getenv(projectFuncsEnv))
+		fmt.Print(projectHookScript(args[0], db.ProjectFile(), db.ProjectCommands(), os.Getenv(projectEnv), defined))
+		return nil
+	})
+
+	// TUI command - full-screen manager for browsing and running commands
+	cli.NewSubCommand("tui", "Browse, search, add, edit, tag and run commands in a full-screen manager").
+		Action(func() error {
+			return RunTUI(db)
+		})
+
+	// Pick command - choose a command with the picker and print its name
+	pickCmd := cli.NewSubCommand("pick", "Choose a command with the fuzzy picker and print its name")
+	pickCmd.Action(func() error {
+		if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+			return fmt.Errorf("the picker needs a terminal")
+		}
+		// The picker draws on stderr, so the name can be captured
+		name, err := pickCommand(db, os.Stdin, os.Stderr)
+		if err != nil {
+			return err
+		}
+		fmt.Println(name)
+		return nil
+	})
+
+	// Show command - print every field of a command
+	showCmd := cli.NewSubCommand("show", "Show every field of a command and its last run")
+	var showName string
+	var showJSON bool
+	showCmd.StringFlag("name", "Command name to show", &showName)
+	showCmd.BoolFlag("json", "Print the command as JSON, like --output json", &showJSON)
+	showCmd.Action(func() error {
+		name := commandName(showCmd, showName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		format := outputFormat
+		if showJSON {
+			format = outputJSON
+		}
+		return ShowCommand(db, name, format)
+	})
+
+	// Update command - change the command line, description or directory
+	updateCmd := cli.NewSubCommand("update", "Change the command line, description or working directory of a command")
+	var updateName, updateCommand, updateDesc, updateDir string
+	updateCmd.StringFlag("name", "Command name to update", &updateName)
+	updateCmd.StringFlag("cmd", "New command to execute (optional)", &updateCommand)
+	updateCmd.StringFlag("desc", "New description (optional)", &updateDesc)
+	updateCmd.StringFlag("dir", "New working directory, empty to remove it (optional)", &updateDir)
+	var updateTags []string
+	updateCmd.StringsFlag("tag", "Replace the tags, repeatable or comma-separated; empty to remove them (optional)", &updateTags)
+	updateCmd.Action(func() error {
+		name := commandName(updateCmd, updateName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		given := givenFlags(cliArgs)
+		if !given["cmd"] && !given["desc"] && !given["dir"] && !given["tag"] {
+			return fmt.Errorf("nothing to update; give --cmd, --desc, --dir or --tag")
+		}
+
+		existing, err := db.GetCommand(name)
+		if err != nil {
+			return err
+		}
+		if len(existing.Steps) > 0 {
+			return fmt.Errorf("command '%s' consists of steps; change it with afv edit", name)
+		}
+		if existing.Script && given["cmd"] {
+			return fmt.Errorf("command '%s' is a script; change it with afv edit", name)
+		}
+
+		// Only the given flags change, the other fields keep their values
+		command, description, dir := existing.Command, existing.Description, existing.WorkingDir
+		if given["cmd"] {
+			command = updateCommand
+		}
+		if given["desc"] {
+			description = updateDesc
+		}
+		if given["dir"] {
+			dir = strings.TrimSpace(updateDir)
+			if existing.Wsl == "" || !isLinuxPath(dir) {
+				if dir, err = resolveDirectory(updateDir); err != nil {
+					return fmt.Errorf("failed to resolve directory: %v", err)
+				}
+			}
+		}
+		if given["cmd"] || given["desc"] || given["dir"] {
+			if err := db.UpdateCommand(name, description, command, dir); err != nil {
+				return err
+			}
+		}
+		if given["tag"] {
+			if err := TagCommand(db, name, splitTags(updateTags), existing.Tags); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Command '%s' updated successfully.\n", name)
+		return nil
+	})
+
+	// Edit command - change a command in $EDITOR
+	editCmd := cli.NewSubCommand("edit", "Edit every field of a command in $EDITOR")
+	var editName string
+	editCmd.StringFlag("name", "Command name to edit", &editName)
+	editCmd.Action(func() error {
+		name := commandName(editCmd, editName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		return EditCommand(db, name)
+	})
+
+	// Delete command - remove a stored command
+	deleteCmd := cli.NewSubCommand("delete", "Delete a stored command")
+	var deleteName, deleteNamespace string
+	var deleteAll bool
+	deleteCmd.StringFlag("name", "Command name to delete", &deleteName)
+	deleteCmd.BoolFlag("all", "Delete all commands", &deleteAll)
+	deleteCmd.StringFlag("namespace", "Delete every command in this namespace, e.g. proj1", &deleteNamespace)
+	deleteCmd.Action(func() error {
+		if deleteAll || deleteNamespace != "" {
+			// Delete all commands, or those of a namespace
+			commands, err := db.GetCommandMeta()
+			if err != nil {
+				return fmt.Errorf("failed to get commands: %v", err)
+			}
+			// Commands of the project file are deleted by editing it
+			commands = slices.DeleteFunc(commands, func(cmd CommandMeta) bool { return cmd.Project })
+			scope := ""
+			if namespace := normalizeNamespace(deleteNamespace); !deleteAll {
+				commands = slices.DeleteFunc(commands, func(cmd CommandMeta) bool {
+					return !inNamespace(cmd.Name, namespace)
+				})
+				scope = fmt.Sprintf(" in namespace '%s'", namespace)
+			}
+
+			if len(commands) == 0 {
+				fmt.Printf("No commands%s to delete.\n", scope)
+				return nil
+			}
+
+			if !confirm(fmt.Sprintf("This will delete %d command(s)%s. Are you sure?", len(commands), scope)) {
+				fmt.Println("Operation cancelled.")
+				return nil
+			}
+
+			for _, cmd := range commands {
+				err := db.TrashCommand(cmd.Name)
+				if err != nil {
+					return fmt.Errorf("failed to delete command '%s': %v", cmd.Name, err)
+				}
+			}
+
+			fmt.Printf("Successfully deleted %d command(s).\n", len(commands))
+			fmt.Println("They are in the trash; restore them with 'afv undelete NAME'.")
+			return nil
+		}
+
+		name := commandName(deleteCmd, deleteName)
+		if name == "" {
+			return fmt.Errorf("either --name or --all is required")
+		}
+
+		err := db.TrashCommand(name)
+		if err != nil {
+			return fmt.Errorf("failed to delete command: %v", err)
+		}
+
+		fmt.Printf("Command '%s' deleted successfully.\n", name)
+		fmt.Printf("Restore it with 'afv undelete %s'.\n", name)
+		return nil
+	})
+
+	// Undelete command - restore a deleted command from the trash
+	undeleteCmd := cli.NewSubCommand("undelete", "Restore a deleted command from the trash")
+	var undeleteName string
+	undeleteCmd.StringFlag("name", "Command name to restore", &undeleteName)
+	undeleteCmd.Action(func() error {
+		name := commandName(undeleteCmd, undeleteName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		entry, err := db.UndeleteCommand(name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Command '%s' restored.\n", name)
+		if len(entry.Aliases) > 0 {
+			fmt.Printf("Aliases: %s\n", strings.Join(entry.Aliases, ", "))
+		}
+		return nil
+	})
+
+	trashCmd := cli.NewSubCommand("trash", "Show or empty the deleted commands")
+
+	trashCmd.NewSubCommand("list", "List the deleted commands with when they were deleted").
+		Action(func() error {
+			entries, err := db.GetTrash()
+			if err != nil {
+				return fmt.Errorf("failed to read the trash: %v", err)
+			}
+			if len(entries) == 0 {
+				fmt.Println("The trash is empty.")
+				return nil
+			}
+			for _, entry := range entries {
+				fmt.Printf("  %-20s deleted %s\n", entry.Command.Name, entry.DeletedAt)
+			}
+			return nil
+		})
+
+	trashCmd.NewSubCommand("empty", "Delete the commands in the trash for good").
+		Action(func() error {
+			entries, err := db.GetTrash()
+			if err != nil {
+				return fmt.Errorf("failed to read the trash: %v", err)
+			}
+			if len(entries) == 0 {
+				fmt.Println("The trash is empty.")
+				return nil
+			}
+			if !confirm(fmt.Sprintf("This will permanently delete %d command(s). Are you sure?", len(entries))) {
+				fmt.Println("Operation cancelled.")
+				return nil
+			}
+			n, err := db.EmptyTrash()
+			if err != nil {
+				return fmt.Errorf("failed to empty the trash: %v", err)
+			}
+			fmt.Printf("Permanently deleted %d command(s).\n", n)
+			return nil
+		})
+
+	// Deprecate command - point users of a command at its replacement
+	deprecateCmd := cli.NewSubCommand("deprecate", "Mark a command as deprecated in favour of another")
+	var deprecateName, deprecateUse string
+	var deprecateStrict, deprecateUndo bool
+	deprecateCmd.StringFlag("name", "Command name to deprecate", &deprecateName)
+	deprecateCmd.StringFlag("use", "Command that replaces it (optional)", &deprecateUse)
+	deprecateCmd.BoolFlag("strict", "Refuse to run the command instead of only warning", &deprecateStrict)
+	deprecateCmd.BoolFlag("undo", "Remove the deprecation", &deprecateUndo)
+	deprecateCmd.Action(func() error {
+		name := commandName(deprecateCmd, deprecateName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+
+		if deprecateUndo {
+			err := db.ModifyCommand(name, func(cmd *Command) error {
+				cmd.Deprecated = nil
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Command '%s' is no longer deprecated.\n", name)
+			return nil
+		}
+
+		if deprecateUse != "" {
+			if _, err := db.GetCommand(deprecateUse); err != nil {
+				return fmt.Errorf("replacement: %v", err)
+			}
+		}
+
+		err := db.ModifyCommand(name, func(cmd *Command) error {
+			cmd.Deprecated = &Deprecation{Use: deprecateUse, Strict: deprecateStrict}
+			return cmd.Deprecated.validate(cmd.Name)
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Command '%s' is now deprecated.\n", name)
+		if deprecateUse != "" {
+			fmt.Printf("Replacement: %s\n", deprecateUse)
+		}
+		return nil
+	})
+
+	// Pin and unpin commands - keep favourites at the top of list and picker
+	pinCmd := cli.NewSubCommand("pin", "Pin a command to the top of the list and picker")
+	var pinName string
+	pinCmd.StringFlag("name", "Command name to pin", &pinName)
+	pinCmd.Action(func() error {
+		name := commandName(pinCmd, pinName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		if err := PinCommand(db, name, true); err != nil {
+			return err
+		}
+		fmt.Printf("Command '%s' pinned.\n", name)
+		return nil
+	})
+
+	unpinCmd := cli.NewSubCommand("unpin", "Unpin a pinned command")
+	var unpinName string
+	unpinCmd.StringFlag("name", "Command name to unpin", &unpinName)
+	unpinCmd.Action(func() error {
+		name := commandName(unpinCmd, unpinName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		if err := PinCommand(db, name, false); err != nil {
+			return err
+		}
+		fmt.Printf("Command '%s' unpinned.\n", name)
+		return nil
+	})
+
+	// Bulk edit command - edit many commands at once in $EDITOR
+	bulkEditCmd := cli.NewSubCommand("bulk-edit", "Edit stored commands in your editor and apply all changes at once")
+	var bulkEditTag string
+	bulkEditCmd.StringFlag("tag", "Only edit commands with this tag (optional)", &bulkEditTag)
+	bulkEditCmd.Action(func() error {
+		return BulkEdit(db, bulkEditTag)
+	})
+
+	// Export command - write stored commands as a portable document
+	exportCmd := cli.NewSubCommand("export", "Export stored commands as JSON or YAML")
+	var exportName, exportFormat, exportOutput string
+	var exportSingle, exportClipboard, exportAsAliases, exportFunctions bool
+	exportShell := defaultShell()
+	exportFormat = "yaml"
+	exportCmd.StringFlag("name", "Only export this command (default: all commands)", &exportName)
+	exportCmd.StringFlag("format", "Output format: json or yaml", &exportFormat)
+	exportCmd.StringFlag("output", "Write to this file instead of stdout", &exportOutput)
+	exportCmd.BoolFlag("single", "Write the command given by --name as a compact one-line snippet", &exportSingle)
+	exportCmd.BoolFlag("clipboard", "Copy the export to the system clipboard instead of printing it", &exportClipboard)
+	exportCmd.BoolFlag("as-aliases", "Write shell aliases running each command through afv run", &exportAsAliases)
+	exportCmd.BoolFlag("functions", "With --as-aliases, write shell functions instead of aliases", &exportFunctions)
+	exportCmd.StringFlag("shell", "Shell to write aliases for: bash, zsh or fish (default: your login shell)", &exportShell)
+	exportCmd.Action(func() error {
+		if exportSingle && exportName == "" {
+			return fmt.Errorf("--single requires --name")
+		}
+		if exportFunctions && !exportAsAliases {
+			return fmt.Errorf("--functions requires --as-aliases")
+		}
+
+		// A single command, aliases or the clipboard need the whole
+		// document in memory
+		if exportName != "" || exportClipboard || exportAsAliases {
+			var commands []Command
+			if exportName != "" {
+				command, err := db.GetCommand(exportName)
+				if err != nil {
+					return fmt.Errorf("failed to get command: %v", err)
+				}
+				commands = []Command{*command}
+			} else {
+				all, err := db.GetAllCommands()
+				if err != nil {
+					return fmt.Errorf("failed to get commands: %v", err)
+				}
+				commands = all
+			}
+
+			var buf bytes.Buffer
+			if exportAsAliases {
+				var names []string
+				for _, cmd := range commands {
+					names = append(names, cmd.Name)
+				}
+				// Aliases of afv commands get a shell alias of their own
+				if exportName == "" {
+					aliases, err := db.GetAliases()
+					if err != nil {
+						return fmt.Errorf("failed to get aliases: %v", err)
+					}
+					names = slices.AppendSeq(names, maps.Keys(aliases))
+				}
+				if err := writeShellAliases(&buf, exportShell, names, exportFunctions); err != nil {
+					return err
+				}
+			} else if err := encodeExport(&buf, commands, exportFormat, exportSingle); err != nil {
+				return err
+			}
+
+			switch {
+			case exportClipboard:
+				if err := writeClipboard(buf.String()); err != nil {
+					return err
+				}
+				fmt.Printf("Copied %d command(s) to the clipboard.\n", len(commands))
+			case exportOutput != "":
+				if err := os.WriteFile(exportOutput, buf.Bytes(), 0644); err != nil {
+					return fmt.Errorf("failed to write export: %v", err)
+				}
+				fmt.Printf("Exported %d command(s) to %s.\n", len(commands), exportOutput)
+			default:
+				fmt.Print(buf.String())
+			}
+			return nil
+		}
+
+		// Stream all commands straight from the database to the output
+		if exportOutput == "" {
+			_, err := streamExport(db, os.Stdout, exportFormat)
+			return err
+		}
+		count, err := exportToFile(db, exportOutput, exportFormat)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d command(s) to %s.\n", count, exportOutput)
+		return nil
+	})
+
+	// Import command - add commands from an export document
+	importCmd := cli.NewSubCommand("import", "Import commands from a file, an https URL or gist, or stdin (-)")
+	var importSHA256, importConflict string
+	var importYes, importDryRun, importFromJust, importFromTaskfile bool
+	importCmd.StringFlag("sha256", "Expected SHA-256 checksum of the document (optional)", &importSHA256)
+	importCmd.StringFlag("on-conflict", "What to do with existing commands: skip, overwrite or rename (default: ask, or skip without a terminal)", &importConflict)
+	importCmd.BoolFlag("yes", "Apply a downloaded document without asking for confirmation", &importYes)
+	importCmd.BoolFlag("dry-run", "Show what the import would do without changing anything", &importDryRun)
+	importCmd.BoolFlag("from-just", "Import the recipes of a justfile (default: the one in the current directory)", &importFromJust)
+	importCmd.BoolFlag("from-taskfile", "Import the tasks of a go-task Taskfile (default: the one in the current directory)", &importFromTaskfile)
+	importCmd.Action(func() error {
+		args := importCmd.OtherArgs()
+		if importConflict != "" {
+			if err := validateConflictStrategy(importConflict); err != nil {
+				return err
+			}
+		}
+
+		var source string
+		var commands []Command
+		var lines []int
+		switch {
+		case importFromJust && importFromTaskfile:
+			return fmt.Errorf("use only one of --from-just and --from-taskfile")
+		case importFromJust || importFromTaskfile:
+			// Recipes and tasks go through the same conflict handling as
+			// export documents
+			if len(args) > 0 {
+				source = args[0]
+			}
+			var err error
+			if commands, lines, err = loadRunnerFile(source, importFromTaskfile); err != nil {
+				return err
+			}
+		default:
+			if len(args) == 0 {
+				return fmt.Errorf("source is required (a file, an https URL or - for stdin)")
+			}
+			source = args[0]
+
+			data, err := readImportSource(source)
+			if err != nil {
+				return fmt.Errorf("failed to read import document: %v", err)
+			}
+			if err := verifyChecksum(data, importSHA256); err != nil {
+				return err
+			}
+
+			commands, err = decodeExport(data)
+			if err != nil {
+				return err
+			}
+			lines = commandLines(data)
+
+			// Show what a downloaded document contains before applying it
+			if isImportURL(source) {
+				fmt.Printf("Source: %s\n", source)
+				fmt.Printf("SHA-256: %s\n", checksum(data))
+				fmt.Printf("Commands (%d):\n", len(commands))
+				for _, cmd := range commands {
+					fmt.Printf("  %-15s %s\n", cmd.Name, cmd.Command)
+				}
+				if !importYes && !importDryRun && !confirm("Import these commands?") {
+					fmt.Println("Operation cancelled.")
+					return nil
+				}
+			}
+		}
+
+		// Ask about each collision when nobody chose a strategy, unless the
+		// document itself came from stdin or this is only a preview
+		resolve := fixedConflict(firstNonEmpty(importConflict, ConflictSkip))
+		if importConflict == "" && !importDryRun && source != "-" && term.IsTerminal(int(os.Stdin.Fd())) {
+			resolve = promptConflict(os.Stdin, os.Stdout)
+		}
+
+		importFn := ImportCommandsWith
+		if importDryRun {
+			importFn = PreviewImport
+		}
+		result, err := importFn(db, commands, resolve, nil)
+		if err != nil {
+			return err
+		}
+		result.Lines = lines
+		result.Print()
+		return nil
+	})
+
+	// Apply command - reconcile the database with a declarative file
+	applyCmd := cli.NewSubCommand("apply", "Create and update commands to match a declarative file")
+	var applyPrune, applyWatch bool
+	applyCmd.BoolFlag("prune", "Delete stored commands that the file does not declare", &applyPrune)
+	applyCmd.BoolFlag("watch", "Keep running and re-apply whenever the file changes", &applyWatch)
+	applyCmd.Action(func() error {
+		args := applyCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("source is required (a file, an https URL or - for stdin)")
+		}
+		if applyWatch {
+			return WatchApply(db, args[0], applyPrune)
+		}
+		return Apply(db, args[0], applyPrune)
+	})
+
+	// Plan command - preview what apply would change
+	planCmd := cli.NewSubCommand("plan", "Show what apply would change without modifying anything")
+	var planPrune bool
+	planCmd.BoolFlag("prune", "Include deletions of stored commands that the file does not declare", &planPrune)
+	planCmd.Action(func() error {
+		args := planCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("source is required (a file, an https URL or - for stdin)")
+		}
+
+		cs, err := PlanApply(db, args[0], planPrune)
+		if err != nil {
+			return err
+		}
+		if cs.Empty() {
+			fmt.Println("No changes. The database matches the file.")
+			return nil
+		}
+		cs.PrintDiff()
+		return nil
+	})
+
+	// Sync command - mirror the database through a git repository
+	syncCmd := cli.NewSubCommand("sync", "Merge the database with a YAML file in a git repository, then commit and push it")
+	var syncRepo, syncFile, syncRemote, syncPrefer string
+	syncCmd.StringFlag("repo", "Git repository to sync with (default: sync.repo in config.yaml)", &syncRepo)
+	syncCmd.StringFlag("file", "File within the repository (default: sync.file in config.yaml or afvikle.yaml)", &syncFile)
+	syncCmd.StringFlag("remote", "URL of a server run with 'afv serve' to sync with instead of a repository", &syncRemote)
+	syncCmd.StringFlag("prefer", "Side that wins commands changed on both sides: local or remote", &syncPrefer)
+	syncCmd.Action(func() error {
+		cfg, err := LoadConfig()
+		if err != nil {
+			return err
+		}
+		if syncRemote == "" && syncRepo == "" {
+			syncRemote = cfg.Sync.Remote
+		}
+		if syncRemote != "" {
+			return SyncRemote(db, syncRemote, syncPrefer)
+		}
+		if syncRepo == "" {
+			syncRepo = cfg.Sync.Repo
+		}
+		if syncRepo == "" {
+			return fmt.Errorf("no repository to sync with (set sync.repo or sync.remote in config.yaml, or pass --repo or --remote)")
+		}
+		repo, err := resolveDirectory(syncRepo)
+		if err != nil {
+			return err
+		}
+		if syncFile == "" {
+			syncFile = cfg.Sync.File
+		}
+		if syncFile == "" {
+			syncFile = defaultSyncFile
+		}
+		return Sync(db, repo, syncFile, syncPrefer)
+	})
+
+	// Serve command - expose the database to sync clients over HTTP
+	serveCmd := cli.NewSubCommand("serve", "Serve the database to 'afv sync --remote' clients over HTTP")
+	serveAddr := ":8787"
+	var serveCert, serveKey string
+	serveCmd.StringFlag("addr", "Address to listen on", &serveAddr)
+	serveCmd.StringFlag("cert", "TLS certificate file, to serve HTTPS", &serveCert)
+	serveCmd.StringFlag("key", "TLS key file, to serve HTTPS", &serveKey)
+	serveCmd.Action(func() error {
+		return Serve(db, serveAddr, serveCert, serveKey)
+	})
+
+	// Tag command - manage the tags of commands
+	tagCmd := cli.NewSubCommand("tag", "Manage the tags of commands")
+
+	tagAddCmd := tagCmd.NewSubCommand("add", "Add tags to a command: afv tag add NAME TAG...")
+	tagAddCmd.Action(func() error {
+		args := tagAddCmd.OtherArgs()
+		if len(args) < 2 {
+			return fmt.Errorf("a command name and at least one tag are required")
+		}
+		if err := TagCommand(db, args[0], splitTags(args[1:]), nil); err != nil {
+			return err
+		}
+		fmt.Printf("Tagged '%s' with %s.\n", args[0], strings.Join(splitTags(args[1:]), ", "))
+		return nil
+	})
+
+	tagRemoveCmd := tagCmd.NewSubCommand("remove", "Remove tags from a command: afv tag remove NAME TAG...")
+	tagRemoveCmd.Action(func() error {
+		args := tagRemoveCmd.OtherArgs()
+		if len(args) < 2 {
+			return fmt.Errorf("a command name and at least one tag are required")
+		}
+		if err := TagCommand(db, args[0], nil, splitTags(args[1:])); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s from '%s'.\n", strings.Join(splitTags(args[1:]), ", "), args[0])
+		return nil
+	})
+
+	tagCmd.NewSubCommand("list", "List the tags in use with their number of commands").
+		Action(func() error {
+			tags, err := db.GetTags()
+			if err != nil {
+				return fmt.Errorf("failed to get tags: %v", err)
+			}
+			if len(tags) == 0 {
+				fmt.Println("No tags found. Use 'afv tag add' to tag a command.")
+				return nil
+			}
+			names := slices.Sorted(maps.Keys(tags))
+			for _, tag := range names {
+				fmt.Printf("  %-15s %d command(s)\n", tag, tags[tag])
+			}
+			return nil
+		})
+
+	aliasCmd := cli.NewSubCommand("alias", "Manage other names for stored commands")
+
+	aliasAddCmd := aliasCmd.NewSubCommand("add", "Add an alias for a command: afv alias add ALIAS NAME")
+	aliasAddCmd.Action(func() error {
+		args := aliasAddCmd.OtherArgs()
+		if len(args) != 2 {
+			return fmt.Errorf("an alias and a command name are required")
+		}
+		if err := db.AddAlias(args[0], args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Alias '%s' now runs '%s'.\n", strings.TrimSpace(args[0]), args[1])
+		return nil
+	})
+
+	aliasRemoveCmd := aliasCmd.NewSubCommand("remove", "Remove an alias: afv alias remove ALIAS")
+	aliasRemoveCmd.Action(func() error {
+		args := aliasRemoveCmd.OtherArgs()
+		if len(args) != 1 {
+			return fmt.Errorf("an alias is required")
+		}
+		if err := db.RemoveAlias(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Alias '%s' removed.\n", args[0])
+		return nil
+	})
+
+	aliasCmd.NewSubCommand("list", "List the aliases with the commands they run").
+		Action(func() error {
+			aliases, err := db.GetAliases()
+			if err != nil {
+				return fmt.Errorf("failed to get aliases: %v", err)
+			}
+			if len(aliases) == 0 {
+				fmt.Println("No aliases found. Use 'afv alias add' to add one.")
+				return nil
+			}
+			for _, alias := range slices.Sorted(maps.Keys(aliases)) {
+				fmt.Printf("  %-15s %s\n", alias, aliases[alias])
+			}
+			return nil
+		})
+
+	// Group command - run several stored commands as one unit
+	groupCmd := cli.NewSubCommand("group", "Manage groups of commands run as one unit")
+
+	groupAddCmd := groupCmd.NewSubCommand("add", "Add a group of stored commands")
+	var groupAddName, groupAddDesc, groupAddSteps, groupAddMode string
+	groupAddCmd.StringFlag("name", "Group name", &groupAddName)
+	groupAddCmd.StringFlag("desc", "Group description (optional)", &groupAddDesc)
+	groupAddCmd.StringFlag("steps", "Comma-separated commands of the group", &groupAddSteps)
+	groupAddCmd.StringFlag("mode", "How the steps run: sequential (default) or parallel", &groupAddMode)
+	groupAddCmd.Action(func() error {
+		group := Group{
+			Name:        commandName(groupAddCmd, groupAddName),
+			Description: groupAddDesc,
+			Steps:       splitList(groupAddSteps),
+			Mode:        groupAddMode,
+		}
+		if err := db.AddGroup(group); err != nil {
+			return err
+		}
+		fmt.Printf("Group '%s' added successfully.\n", strings.TrimSpace(group.Name))
+		return nil
+	})
+
+	groupRunCmd := groupCmd.NewSubCommand("run", "Run the commands of a group")
+	var groupRunName, groupRunDir string
+	var groupRunKeepGoing bool
+	groupRunCmd.StringFlag("name", "Group name", &groupRunName)
+	groupRunCmd.StringFlag("dir", "Working directory to run the commands in (optional)", &groupRunDir)
+	groupRunCmd.BoolFlag("keep-going", "Run every step of a sequential group even if one fails", &groupRunKeepGoing)
+	groupRunCmd.Action(func() error {
+		name := commandName(groupRunCmd, groupRunName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		group, err := db.GetGroup(name)
+		if err != nil {
+			return err
+		}
+		return RunGroup(db, group, RunOptions{Dir: groupRunDir}, groupRunKeepGoing)
+	})
+
+	groupCmd.NewSubCommand("list", "List groups").
+		Action(func() error {
+			groups, err := db.GetAllGroups()
+			if err != nil {
+				return fmt.Errorf("failed to get groups: %v", err)
+			}
+			if len(groups) == 0 {
+				fmt.Println("No groups found. Use 'afv group add' to add one.")
+				return nil
+			}
+			for _, group := range groups {
+				fmt.Printf("  %-15s %-10s %s\n", group.Name, group.Mode, strings.Join(group.Steps, ", "))
+			}
+			return nil
+		})
+
+	groupDeleteCmd := groupCmd.NewSubCommand("delete", "Delete a group, keeping its commands")
+	var groupDeleteName string
+	groupDeleteCmd.StringFlag("name", "Group name", &groupDeleteName)
+	groupDeleteCmd.Action(func() error {
+		name := commandName(groupDeleteCmd, groupDeleteName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		if err := db.DeleteGroup(name); err != nil {
+			return err
+		}
+		fmt.Printf("Group '%s' deleted successfully.\n", name)
+		return nil
+	})
+
+	// Pack command - install and update shared command packs
+	packCmd := cli.NewSubCommand("pack", "Install and update command packs")
+
+	packInstallCmd := packCmd.NewSubCommand("install", "Install a pack from a file or an https URL")
+	packInstallConflict := ConflictSkip
+	var packInstallForce bool
+	packInstallCmd.StringFlag("on-conflict", "What to do with existing commands: skip, overwrite or rename", &packInstallConflict)
+	packInstallCmd.BoolFlag("force", "Allow replacing an installed pack with an older version", &packInstallForce)
+	packInstallCmd.Action(func() error {
+		args := packInstallCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("source is required (a file or an https URL)")
+		}
+
+		pack, result, err := InstallPack(db, args[0], packInstallConflict, packInstallForce)
+		if err != nil {
+			return err
+		}
+		result.Print()
+		fmt.Printf("Installed pack '%s' version %s.\n", pack.Name, pack.Version)
+		return nil
+	})
+
+	packCmd.NewSubCommand("list", "List installed packs").
+		Action(func() error {
+			packs, err := db.GetAllPacks()
+			if err != nil {
+				return fmt.Errorf("failed to get packs: %v", err)
+			}
+			if len(packs) == 0 {
+				fmt.Println("No packs installed. Use 'afv pack install' to install one.")
+				return nil
+			}
+
+			for _, pack := range packs {
+				fmt.Printf("  %-15s %-10s %d command(s)  %s\n", pack.Name, pack.Version, len(pack.Commands), pack.Source)
+			}
+			return nil
+		})
+
+	packCmd.NewSubCommand("outdated", "Check installed packs for newer versions").
+		Action(func() error {
+			packs, err := db.GetAllPacks()
+			if err != nil {
+				return fmt.Errorf("failed to get packs: %v", err)
+			}
+			if len(packs) == 0 {
+				fmt.Println("No packs installed.")
+				return nil
+			}
+
+			outdated := 0
+			for _, pack := range packs {
+				update, err := CheckPackUpdate(pack)
+				if err != nil {
+					fmt.Printf("  %-15s %-10s check failed: %v\n", pack.Name, pack.Version, err)
+					continue
+				}
+				switch {
+				case update.Outdated():
+					outdated++
+					fmt.Printf("  %-15s %-10s -> %s\n", pack.Name, pack.Version, update.Available.Pack.Version)
+				case update.Downgrade():
+					fmt.Printf("  %-15s %-10s source has older version %s\n", pack.Name, pack.Version, update.Available.Pack.Version)
+				}
+			}
+			if outdated == 0 {
+				fmt.Println("All packs are up to date.")
+			}
+			return nil
+		})
+
+	packUpgradeCmd := packCmd.NewSubCommand("upgrade", "Upgrade installed packs to the version at their source")
+	var packUpgradeName string
+	var packUpgradeForce bool
+	packUpgradeConflict := ConflictSkip
+	packUpgradeCmd.StringFlag("name", "Pack to upgrade (default: all packs)", &packUpgradeName)
+	packUpgradeCmd.StringFlag("on-conflict", "What to do with existing commands not owned by the pack: skip, overwrite or rename", &packUpgradeConflict)
+	packUpgradeCmd.BoolFlag("force", "Apply the version at the source even if it is older than the installed one", &packUpgradeForce)
+	packUpgradeCmd.Action(func() error {
+		if err := validateConflictStrategy(packUpgradeConflict); err != nil {
+			return err
+		}
+
+		var packs []PackRecord
+		if name := commandName(packUpgradeCmd, packUpgradeName); name != "" {
+			pack, err := db.GetPack(name)
+			if err != nil {
+				return err
+			}
+			packs = append(packs, *pack)
+		} else {
+			all, err := db.GetAllPacks()
+			if err != nil {
+				return fmt.Errorf("failed to get packs: %v", err)
+			}
+			packs = all
+		}
+
+		upgraded := 0
+		for _, pack := range packs {
+			update, err := CheckPackUpdate(pack)
+			if err != nil {
+				fmt.Printf("Failed to check pack '%s': %v\n", pack.Name, err)
+				continue
+			}
+			if update.Change == 0 {
+				continue
+			}
+			if update.Downgrade() && !packUpgradeForce {
+				fmt.Printf("Skipping pack '%s': source has older version %s than installed %s (use --force to downgrade).\n",
+					pack.Name, update.Available.Pack.Version, pack.Version)
+				continue
+			}
+
+			if changes := update.Changes(); len(changes) > 0 {
+				fmt.Printf("Changes in pack '%s':\n", pack.Name)
+				for _, entry := range changes {
+					fmt.Printf("  %s\n", entry.Version)
+					for _, change := range entry.Changes {
+						fmt.Printf("    - %s\n", change)
+					}
+				}
+			}
+
+			result, err := UpgradePack(db, update, packUpgradeConflict, packUpgradeForce)
+			if err != nil {
+				fmt.Printf("Failed to upgrade pack '%s': %v\n", pack.Name, err)
+				continue
+			}
+			result.Print()
+			verb := "Upgraded"
+			if update.Downgrade() {
+				verb = "Downgraded"
+			}
+			fmt.Printf("%s pack '%s' from %s to %s.\n", verb, pack.Name, pack.Version, update.Available.Pack.Version)
+			upgraded++
+		}
+		if upgraded == 0 {
+			fmt.Println("All packs are up to date.")
+		}
+		return nil
+	})
+
+	// Workspace commands, also available as 'afv profile'
+	for _, noun := range []string{"workspace", "profile"} {
+		title := strings.ToUpper(noun[:1]) + noun[1:]
+		description := "Manage independent command databases"
+		if noun == "profile" {
+			description = "Manage profiles, another name for workspaces"
+		}
+		workspaceCmd := cli.NewSubCommand(noun, description)
+
+		workspaceCreateCmd := workspaceCmd.NewSubCommand("create", "Create a new "+noun)
+		workspaceCreateCmd.Action(func() error {
+			name := commandName(workspaceCreateCmd, "")
+			if name == "" {
+				return fmt.Errorf("name is required")
+			}
+			if err := CreateWorkspace(name); err != nil {
+				return err
+			}
+			fmt.Printf("%s '%s' created. Switch to it with 'afv %s use %s'.\n", title, name, noun, name)
+			return nil
+		})
+
+		workspaceCmd.NewSubCommand("list", "List "+noun+"s").
+			Action(func() error {
+				names, err := ListWorkspaces()
+				if err != nil {
+					return err
+				}
+				active, err := activeWorkspace()
+				if err != nil {
+					return err
+				}
+				for _, name := range names {
+					marker := " "
+					if name == active {
+						marker = "*"
+					}
+					fmt.Printf("%s %s\n", marker, name)
+				}
+				return nil
+			})
+
+		workspaceUseCmd := workspaceCmd.NewSubCommand("use", "Switch the active "+noun)
+		workspaceUseCmd.Action(func() error {
+			name := commandName(workspaceUseCmd, "")
+			if name == "" {
+				return fmt.Errorf("name is required")
+			}
+			if err := UseWorkspace(name); err != nil {
+				return err
+			}
+			fmt.Printf("Switched to %s '%s'.\n", noun, name)
+			if env := os.Getenv(workspaceEnv); env != "" && env != name {
+				fmt.Printf("Note: %s=%s overrides the active %s in this shell.\n", workspaceEnv, env, noun)
+			}
+			return nil
+		})
+	}
+
+	// Stats command - run counts and duration percentiles from the history
+	statsCmd := cli.NewSubCommand("stats", "Show run statistics with duration percentiles and trends")
+	var statsName string
+	statsCmd.StringFlag("name", "Command name (default: overview of all commands)", &statsName)
+	statsCmd.Action(func() error {
+		name := commandName(statsCmd, statsName)
+		records, err := db.GetRunHistory(name)
+		if err != nil {
+			return fmt.Errorf("failed to get run history: %v", err)
+		}
+
+		if name == "" {
+			PrintStatsOverview(records)
+			return nil
+		}
+		PrintCommandStats(name, records)
+		return nil
+	})
+
+	// Logs command - manage captured command output
+	logsCmd := cli.NewSubCommand("logs", "Show the output of the last run of a command or of a background job")
+	var logsFollow, logsList bool
+	logsCmd.BoolFlag("follow", "Keep printing new output until the job exits", &logsFollow)
+	logsCmd.BoolFlag("list", "List the captured runs of the command instead", &logsList)
+	logsCmd.Action(func() error {
+		args := logsCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("command name or job id is required")
+		}
+		if !logsList {
+			return ShowLogs(db, args[0], os.Stdout, logsFollow)
+		}
+
+		runs, err := RunLogs(db, args[0])
+		if err != nil {
+			return err
+		}
+		if len(runs) == 0 {
+			fmt.Printf("No output recorded for '%s'.\n", args[0])
+			return nil
+		}
+		for i := len(runs) - 1; i >= 0; i-- {
+			fmt.Printf("  %s\n      %s\n", describeRun(runs[i]), runs[i].LogFile)
+		}
+		return nil
+	})
+	logsPruneCmd := logsCmd.NewSubCommand("prune", "Rotate oversized logs and remove old ones")
+	var pruneMaxSize, pruneMaxAge string
+	var pruneMaxFiles int
+	logsPruneCmd.StringFlag("max-size", "Rotate logs at this size, e.g. 10MB (default from config.yaml)", &pruneMaxSize)
+	logsPruneCmd.IntFlag("max-files", "Log files to keep per command (default from config.yaml)", &pruneMaxFiles)
+	logsPruneCmd.StringFlag("max-age", "Remove logs older than this, e.g. 30d (default from config.yaml)", &pruneMaxAge)
+	logsPruneCmd.Action(func() error {
+		cfg, err := LoadConfig()
+		if err != nil {
+			return err
+		}
+		retention := LogRetention{
+			MaxSize:  firstNonEmpty(pruneMaxSize, cfg.Logs.MaxSize),
+			MaxFiles: cfg.Logs.MaxFiles,
+			MaxAge:   firstNonEmpty(pruneMaxAge, cfg.Logs.MaxAge),
+		}
+		if pruneMaxFiles != 0 {
+			retention.MaxFiles = pruneMaxFiles
+		}
+
+		result, err := PruneLogs(db, retention, time.Now())
+		if err != nil {
+			return err
+		}
+		result.Print()
+		return nil
+	})
+
+	// Jobs command - list commands running in the background
+	jobsCmd := cli.NewSubCommand("jobs", "List background jobs started with run --detach")
+	var jobsClear bool
+	jobsCmd.BoolFlag("clear", "Forget jobs that have exited and remove their logs", &jobsClear)
+	jobsCmd.Action(func() error {
+		if jobsClear {
+			cleared, err := ClearJobs(db)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Cleared %d finished job(s).\n", len(cleared))
+			return nil
+		}
+
+		jobs, err := db.GetAllJobs()
+		if err != nil {
+			return fmt.Errorf("failed to get jobs: %v", err)
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No jobs found. Use 'afv run --detach' to start one.")
+			return nil
+		}
+		for _, job := range jobs {
+			fmt.Printf("  %-4d %-15s %-20s since %s\n", job.ID, job.Name, job.Status(), job.StartedAt)
+		}
+		return nil
+	})
+
+	// Job command - the process of a background job, started by run
+	// --detach and the scheduler
+	jobCmd := cli.NewSubCommand(jobCommand, "Run a background job")
+	jobCmd.Hidden()
+	jobCmd.Action(func() error {
+		args := jobCmd.OtherArgs()
+		if len(args) != 1 {
+			return fmt.Errorf("job id is required")
+		}
+		return RunJob(db, args[0])
+	})
+
+	// Schedule command - run stored commands on cron expressions
+	scheduleCmd := cli.NewSubCommand("schedule", "Run stored commands on a cron schedule")
+
+	scheduleAddCmd := scheduleCmd.NewSubCommand("add", "Schedule a stored command")
+	var scheduleName, scheduleCron, scheduleDir string
+	scheduleAddCmd.StringFlag("name", "Command name", &scheduleName)
+	scheduleAddCmd.StringFlag("cron", "Cron expression, e.g. \"0 2 * * *\" or @daily", &scheduleCron)
+	scheduleAddCmd.StringFlag("dir", "Working directory override for the scheduled runs (optional)", &scheduleDir)
+	scheduleAddCmd.Action(func() error {
+		name := commandName(scheduleAddCmd, scheduleName)
+		if name == "" || scheduleCron == "" {
+			return fmt.Errorf("name and --cron are required")
+		}
+		if err := db.AddSchedule(Schedule{Name: name, Cron: scheduleCron, Dir: scheduleDir}); err != nil {
+			return err
+		}
+		fmt.Printf("Command '%s' scheduled: %s\n", name, scheduleCron)
+		if pid, _ := SchedulerPID(db); pid == 0 {
+			fmt.Println("The scheduler is not running; start it with 'afv scheduler start'.")
+		}
+		return nil
+	})
+
+	scheduleCmd.NewSubCommand("list", "List schedules with their next run").
+		Action(func() error {
+			schedules, err := db.GetAllSchedules()
+			if err != nil {
+				return fmt.Errorf("failed to get schedules: %v", err)
+			}
+			if len(schedules) == 0 {
+				fmt.Println("No schedules found. Use 'afv schedule add' to add one.")
+				return nil
+			}
+			for _, schedule := range schedules {
+				next := "never"
+				if spec, err := parseCron(schedule.Cron); err == nil {
+					if t := spec.Next(time.Now()); !t.IsZero() {
+						next = t.Format(timeLayout)
+					}
+				}
+				fmt.Printf("  %-15s %-20s next: %s\n", schedule.Name, schedule.Cron, next)
+			}
+			return nil
+		})
+
+	scheduleDeleteCmd := scheduleCmd.NewSubCommand("delete", "Remove the schedule of a command")
+	var scheduleDeleteName string
+	scheduleDeleteCmd.StringFlag("name", "Command name", &scheduleDeleteName)
+	scheduleDeleteCmd.Action(func() error {
+		name := commandName(scheduleDeleteCmd, scheduleDeleteName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+		if err := db.DeleteSchedule(name); err != nil {
+			return err
+		}
+		fmt.Printf("Schedule of '%s' deleted.\n", name)
+		return nil
+	})
+
+	// Scheduler command - manage the daemon running scheduled commands
+	schedulerCmd := cli.NewSubCommand("scheduler", "Start, stop and inspect the scheduler daemon")
+	schedulerCmd.NewSubCommand("start", "Start the scheduler daemon in the background").
+		Action(func() error {
+			pid, err := StartScheduler(db)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Scheduler started (pid %d).\n", pid)
+			fmt.Printf("Log file: %s\n", db.schedulerLogFile())
+			return nil
+		})
+	schedulerCmd.NewSubCommand("stop", "Stop the scheduler daemon").
+		Action(func() error {
+			stopped, err := StopScheduler(db)
+			if err != nil {
+				return err
+			}
+			if !stopped {
+				fmt.Println("Scheduler is not running.")
+				return nil
+			}
+			fmt.Println("Scheduler stopped.")
+			return nil
+		})
+	schedulerCmd.NewSubCommand("status", "Show whether the scheduler daemon is running").
+		Action(func() error {
+			pid, err := SchedulerPID(db)
+			if err != nil {
+				return err
+			}
+			if pid == 0 {
+				fmt.Println("Scheduler is not running.")
+				return nil
+			}
+			fmt.Printf("Scheduler is running (pid %d).\n", pid)
+			fmt.Printf("Log file: %s\n", db.schedulerLogFile())
+			return nil
+		})
+	schedulerRunCmd := schedulerCmd.NewSubCommand("run", "Run the scheduler in the foreground")
+	schedulerRunCmd.Hidden()
+	schedulerRunCmd.Action(func() error {
+		return RunScheduler(db)
+	})
+
+	// Kill command - stop a background job
+	killCmd := cli.NewSubCommand("kill", "Stop a background job")
+	killCmd.Action(func() error {
+		args := killCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("job is required (an id or command name from 'afv jobs')")
+		}
+		job, err := db.FindJob(args[0])
+		if err != nil {
+			return err
+		}
+		stopped, err := KillJob(job)
+		if err != nil {
+			return err
+		}
+		if !stopped {
+			fmt.Printf("Job %d (%s) is not running.\n", job.ID, job.Name)
+			return nil
+		}
+		fmt.Printf("Job %d (%s) stopped.\n", job.ID, job.Name)
+		return nil
+	})
+
+	// Prompt command - short status for embedding in a shell prompt
+	promptCmd := cli.NewSubCommand("prompt", "Print a short status line for your shell prompt")
+	var promptFormat string
+	promptCmd.StringFlag("format", "Output format with {workspace}, {pinned}, {jobs} and {running} placeholders (optional)", &promptFormat)
+	promptCmd.Action(func() error {
+		fmt.Println(collectPromptStatus(db).Render(promptFormat))
+		return nil
+	})
+
+	// Info command - show database information
+	cli.NewSubCommand("info", "Show database information").
+		Action(func() error {
+			dbPath, err := db.GetDatabasePath()
+			if err != nil {
+				return fmt.Errorf("failed to get database path: %v", err)
+			}
+
+			commands, err := db.GetAllCommands()
+			if err != nil {
+				return fmt.Errorf("failed to get commands: %v", err)
+			}
+
+			workspace, err := activeWorkspace()
+			if err != nil {
+				return err
+			}
+
+			if outputFormat != outputTable {
+				return writeData(os.Stdout, outputFormat, infoData{Workspace: workspace, Database: dbPath, Commands: len(commands), Project: db.ProjectFile()})
+			}
+			fmt.Printf("Workspace: %s\n", workspace)
+			fmt.Printf("Database location: %s\n", dbPath)
+			fmt.Printf("Total commands: %d\n", len(commands))
+			if db.ProjectFile() != "" {
+				fmt.Printf("Project file: %s (%d commands)\n", db.ProjectFile(), len(db.ProjectCommands()))
+			}
+			return nil
+		})
+
+	// Backup and restore commands - copies of the database file
+	backupCmd := cli.NewSubCommand("backup", "Write a timestamped copy of the database to the backups directory")
+	var backupKeep int
+	var backupList bool
+	backupCmd.IntFlag("keep", "Only keep this many of the most recent backups (default: keep all)", &backupKeep)
+	backupCmd.BoolFlag("list", "List the backups instead of writing one", &backupList)
+	backupCmd.Action(func() error {
+		if backupList {
+			backups, err := db.Backups()
+			if err != nil {
+				return fmt.Errorf("failed to list backups: %v", err)
+			}
+			if len(backups) == 0 {
+				fmt.Println("No backups found. Use 'afv backup' to write one.")
+				return nil
+			}
+			for _, path := range backups {
+				fmt.Printf("  %s\n", filepath.Base(path))
+			}
+			return nil
+		}
+		if backupKeep < 0 {
+			return fmt.Errorf("--keep must not be negative")
+		}
+		path, err := db.Backup(backupKeep, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Backup written to %s\n", path)
+		return nil
+	})
+
+	restoreCmd := cli.NewSubCommand("restore", "Replace the database with a backup")
+	var restoreYes bool
+	restoreCmd.BoolFlag("yes", "Restore without asking for confirmation", &restoreYes)
+	restoreCmd.Action(func() error {
+		args := restoreCmd.OtherArgs()
+		if len(args) == 0 {
+			return fmt.Errorf("backup is required (a path or a name from afv backup --list)")
+		}
+		path, err := db.resolveBackup(args[0])
+		if err != nil {
+			return err
+		}
+		if !restoreYes && !confirm(fmt.Sprintf("This will replace every command with those in %s. Are you sure?", filepath.Base(path))) {
+			fmt.Println("Operation cancelled.")
+			return nil
+		}
+		previous, err := db.Restore(path, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Database restored from %s\n", path)
+		fmt.Printf("The previous database was backed up to %s\n", previous)
+		return nil
+	})
+
+	// Start command - launch a service in the background
+	startCmd := cli.NewSubCommand("start", "Start a service command in the background")
+	var startName, startDir string
+	startCmd.StringFlag("name", "Service name to start", &startName)
+	startCmd.StringFlag("dir", "Working directory to start the service in (optional)", &startDir)
+	startCmd.Action(func() error {
+		name := commandName(startCmd, startName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+
+		command, err := getService(db, name)
+		if err != nil {
+			return err
+		}
+		if err := checkDeprecation(command); err != nil {
+			return err
+		}
+
+		_, err = StartServiceAndWait(db, name, startDir)
+		return err
+	})
+
+	// Stop command - terminate a running service
+	stopCmd := cli.NewSubCommand("stop", "Stop a running service command")
+	var stopName string
+	stopCmd.StringFlag("name", "Service name to stop", &stopName)
+	stopCmd.Action(func() error {
+		name := commandName(stopCmd, stopName)
+		if name == "" {
+			return fmt.Errorf("name is required")
+		}
+
+		if _, err := getService(db, name); err != nil {
+			return err
+		}
+
+		stopped, err := StopService(db, name)
+		if err != nil {
+			return err
+		}
+		if !stopped {
+			fmt.Printf("Service '%s' is not running.\n", name)
+			return nil
+		}
+
+		fmt.Printf("Service '%s' stopped.\n", name)
+		return nil
+	})
+
+	// Status command - report whether services are running
+	statusCmd := cli.NewSubCommand("status", "Show the status of service commands")
+	var statusName string
+	statusCmd.StringFlag("name", "Service name (default: all services)", &statusName)
+	statusCmd.Action(func() error {
+		var services []Command
+		if name := commandName(statusCmd, statusName); name != "" {
+			command, err := getService(db, name)
+			if err != nil {
+				return err
+			}
+			services = append(services, *command)
+		} else {
+			commands, err := db.GetAllCommands()
+			if err != nil {
+				return fmt.Errorf("failed to get commands: %v", err)
+			}
+			for _, cmd := range commands {
+				if cmd.Type == CommandTypeService {
+					services = append(services, cmd)
+				}
+			}
+		}
+
+		if len(services) == 0 {
+			fmt.Println("No services found. Use 'afv add --type service' to add one.")
+			return nil
+		}
+
+		for _, svc := range services {
+			state, err := runningService(db, svc.Name)
+			if err != nil {
+				return err
+			}
+			if state == nil {
+				fmt.Printf("  %-15s stopped\n", svc.Name)
+				continue
+			}
+			fmt.Printf("  %-15s running (pid %d, since %s)\n", svc.Name, state.PID, state.StartedAt)
+		}
+		return nil
+	})
+
+	// Auth command - manage credentials for remotes in the OS keyring
+	authCmd := cli.NewSubCommand("auth", "Manage credentials for sync and remote mode")
+
+	loginCmd := authCmd.NewSubCommand("login", "Store a credential for a remote in the OS keyring")
+	var loginRemote, loginKind string
+	loginKind = CredentialSyncToken
+	loginCmd.StringFlag("remote", "Remote name", &loginRemote)
+	loginCmd.StringFlag("kind", "Credential kind: sync-token, api-token or ssh-passphrase", &loginKind)
+	loginCmd.Action(func() error {
+		if err := validateCredential(loginRemote, loginKind); err != nil {
+			return err
+		}
+
+		secret, err := readSecret(fmt.Sprintf("Enter %s for '%s': ", loginKind, loginRemote))
+		if err != nil {
+			return err
+		}
+
+		if err := SetCredential(loginRemote, loginKind, secret); err != nil {
+			return err
+		}
+
+		fmt.Printf("Stored %s for remote '%s'.\n", loginKind, loginRemote)
+		return nil
+	})
+
+	logoutCmd := authCmd.NewSubCommand("logout", "Remove credentials for a remote from the OS keyring")
+	var logoutRemote, logoutKind string
+	logoutCmd.StringFlag("remote", "Remote name", &logoutRemote)
+	logoutCmd.StringFlag("kind", "Credential kind to remove (default: all kinds)", &logoutKind)
+	logoutCmd.Action(func() error {
+		kinds := credentialKinds
+		if logoutKind != "" {
+			kinds = []string{logoutKind}
+		}
+
+		removed := 0
+		for _, kind := range kinds {
+			ok, err := DeleteCredential(logoutRemote, kind)
+			if err != nil {
+				return err
+			}
+			if ok {
+				removed++
+			}
+		}
+
+		if removed == 0 {
+			fmt.Printf("No credentials stored for remote '%s'.\n", logoutRemote)
+			return nil
+		}
+
+		fmt.Printf("Removed %d credential(s) for remote '%s'.\n", removed, logoutRemote)
+		return nil
+	})
+
+	if shortcut != "" {
+		if _, err := resolveCommandName(db, shortcut, false); err != nil {
+			if matches := suggestNames(shortcut, builtinCommands); len(matches) > 0 {
+				err = fmt.Errorf("unknown command '%s'. Did you mean 'afv %s'?", shortcut, matches[0].Name)
+			}
+			fmt.Printf("Error: %v\n", err)
+			status = 1
+			return
+		}
+		cliArgs = append(cliArgs, "run", shortcut)
+	}
+
+	// Starte the CLI
+	if err := cli.Run(cliArgs...); err != nil {
+		slog.Error("command failed", "args", cliArgs, "error", err)
+		fmt.Printf("Error: %v\n", err)
+
+		// Exit with the status of a failed child so scripts can act on it
+		status = 1
+		if code, ok := childExitCode(err); ok {
+			status = code
+		}
 	}
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// matrixAxis is one variable of a matrix run with the values it takes
+type matrixAxis struct {
+	Name   string
+	Values []string
+}
+
+// parseMatrix parses --matrix NAME=v1,v2,... specifications
+func parseMatrix(specs []string) ([]matrixAxis, error) {
+	var axes []matrixAxis
+	for _, spec := range specs {
+		name, values, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid matrix '%s' (expected NAME=value1,value2)", spec)
+		}
+		if slices.ContainsFunc(axes, func(axis matrixAxis) bool { return axis.Name == name }) {
+			return nil, fmt.Errorf("matrix variable %s is given more than once", name)
+		}
+		axis := matrixAxis{Name: name, Values: splitList(values)}
+		if len(axis.Values) == 0 {
+			return nil, fmt.Errorf("matrix variable %s has no values", name)
+		}
+		axes = append(axes, axis)
+	}
+	return axes, nil
+}
+
+// matrixCells returns every combination of the axis values, with the first
+// axis changing slowest
+func matrixCells(axes []matrixAxis) []map[string]string {
+	cells := []map[string]string{{}}
+	for _, axis := range axes {
+		var next []map[string]string
+		for _, cell := range cells {
+			for _, value := range axis.Values {
+				combined := maps.Clone(cell)
+				combined[axis.Name] = value
+				next = append(next, combined)
+			}
+		}
+		cells = next
+	}
+	return cells
+}
+
+// cellLabel describes a matrix cell as NAME=value pairs in axis order
+func cellLabel(axes []matrixAxis, cell map[string]string) string {
+	parts := make([]string, len(axes))
+	for i, axis := range axes {
+		parts[i] = axis.Name + "=" + cell[axis.Name]
+	}
+	return strings.Join(parts, " ")
+}
+
+// RunMatrix runs a stored command once per matrix cell, with the cell's
+// values set as environment variables and filling {{NAME}} placeholders.
+// Every cell runs even if an earlier one failed; the outcome of each is
+// summarized at the end.
+func RunMatrix(db *Database, name string, opts RunOptions, axes []matrixAxis) error {
+	cells := matrixCells(axes)
+	type cellResult struct {
+		label    string
+		err      error
+		duration time.Duration
+	}
+	results := make([]cellResult, len(cells))
+
+	var failed []string
+	var firstErr error
+	for i, cell := range cells {
+		label := cellLabel(axes, cell)
+		fmt.Printf("=== [%d/%d] %s\n", i+1, len(cells), label)
+
+		cellOpts := opts
+		cellOpts.Vars = maps.Clone(opts.Vars)
+		if cellOpts.Vars == nil {
+			cellOpts.Vars = map[string]string{}
+		}
+		maps.Copy(cellOpts.Vars, cell)
+		cellOpts.Env = cell
+		// Matrix values are not the command's defaults
+		cellOpts.Remember = false
+
+		start := time.Now()
+		err := RunStored(db, name, cellOpts)
+		results[i] = cellResult{label: label, err: err, duration: time.Since(start)}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error running %s: %v\n", label, err)
+			failed = append(failed, label)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	fmt.Println("Matrix:")
+	for _, result := range results {
+		status := "ok"
+		if result.err != nil {
+			status = "failed"
+		}
+		fmt.Printf("  %-8s %s (%s)\n", status, result.label, result.duration.Round(time.Millisecond))
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%d of %d matrix runs failed (%s): %w", len(failed), len(cells), strings.Join(failed, ", "), firstErr)
+	}
+	return nil
+}
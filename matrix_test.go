@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMatrix(t *testing.T) {
+	axes, err := parseMatrix([]string{"GOOS=linux,darwin", "GOARCH = amd64, arm64"})
+	if err != nil {
+		t.Fatalf("parseMatrix failed: %v", err)
+	}
+	expected := []matrixAxis{
+		{Name: "GOOS", Values: []string{"linux", "darwin"}},
+		{Name: "GOARCH", Values: []string{"amd64", "arm64"}},
+	}
+	if !reflect.DeepEqual(axes, expected) {
+		t.Errorf("Expected %v, got %v", expected, axes)
+	}
+
+	for _, invalid := range [][]string{{"GOOS"}, {"GOOS="}, {"1X=a"}, {"A=a", "A=b"}} {
+		if _, err := parseMatrix(invalid); err == nil {
+			t.Errorf("Expected error for %v", invalid)
+		}
+	}
+}
+
+func TestMatrixCells(t *testing.T) {
+	axes := []matrixAxis{
+		{Name: "GOOS", Values: []string{"linux", "darwin"}},
+		{Name: "GOARCH", Values: []string{"amd64", "arm64"}},
+	}
+	var labels []string
+	for _, cell := range matrixCells(axes) {
+		labels = append(labels, cellLabel(axes, cell))
+	}
+	expected := []string{
+		"GOOS=linux GOARCH=amd64",
+		"GOOS=linux GOARCH=arm64",
+		"GOOS=darwin GOARCH=amd64",
+		"GOOS=darwin GOARCH=arm64",
+	}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected %v, got %v", expected, labels)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"go.etcd.io/bbolt"
+)
+
+// metaBucket caches the fields needed to render command listings, keyed by
+// command name, so listing thousands of commands does not decode every full
+// record with its env, notes and examples
+var metaBucket = []byte("meta")
+
+// CommandMeta is the summary of a command kept in the metadata index
+type CommandMeta struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	WorkingDir  string       `json:"working_dir,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	LastRunAt   string       `json:"last_run_at,omitempty"`
+	Deprecated  *Deprecation `json:"deprecated,omitempty"`
+	Pinned      bool         `json:"pinned,omitempty"`
+	// Project is set for commands of the project file, which are not indexed
+	Project bool `json:"-"`
+}
+
+// commandMeta returns the index entry of a command
+func commandMeta(cmd Command) CommandMeta {
+	return CommandMeta{
+		Name:        cmd.Name,
+		Description: cmd.Description,
+		WorkingDir:  cmd.WorkingDir,
+		Tags:        cmd.Tags,
+		LastRunAt:   cmd.LastRunAt,
+		Deprecated:  cmd.Deprecated,
+		Pinned:      cmd.Pinned,
+	}
+}
+
+// indexMetaTx stores the index entry of a command within tx
+func indexMetaTx(tx *bbolt.Tx, cmd Command) error {
+	data, err := json.Marshal(commandMeta(cmd))
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(metaBucket).Put([]byte(cmd.Name), data)
+}
+
+// rebuildMetaIndexTx recreates the metadata index from the stored commands,
+// used for databases created before the index existed
+func rebuildMetaIndexTx(tx *bbolt.Tx) error {
+	if tx.Bucket(metaBucket) != nil {
+		if err := tx.DeleteBucket(metaBucket); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.CreateBucket(metaBucket); err != nil {
+		return err
+	}
+	return tx.Bucket(commandsBucket).ForEach(func(k, v []byte) error {
+		var cmd Command
+		if err := json.Unmarshal(v, &cmd); err != nil {
+			return err
+		}
+		return indexMetaTx(tx, cmd)
+	})
+}
+
+// GetCommandMeta returns the index entries of all commands in name order,
+// with the commands of the project file in place of stored ones of the same
+// name
+func (d *Database) GetCommandMeta() ([]CommandMeta, error) {
+	var metas []CommandMeta
+	err := d.view("GetCommandMeta", func(tx *bbolt.Tx) error {
+		return tx.Bucket(metaBucket).ForEach(func(k, v []byte) error {
+			if d.isProjectCommand(string(k)) {
+				return nil
+			}
+			var meta CommandMeta
+			if err := json.Unmarshal(v, &meta); err != nil {
+				return err
+			}
+			metas = append(metas, meta)
+			return nil
+		})
+	})
+	if err != nil || len(d.project) == 0 {
+		return metas, err
+	}
+	for _, cmd := range d.ProjectCommands() {
+		meta := commandMeta(cmd)
+		meta.Project = true
+		metas = append(metas, meta)
+	}
+	slices.SortFunc(metas, func(a, b CommandMeta) int { return strings.Compare(a.Name, b.Name) })
+	return metas, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestMetaIndexFollowsWrites(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, cmd := range []Command{
+		{Name: "build", Description: "Build it", Command: "go build", Tags: []string{"go"}, Notes: "Long notes"},
+		{Name: "test", Command: "go test ./...", Env: map[string]string{"CGO_ENABLED": "0"}},
+	} {
+		if err := db.InsertCommand(cmd); err != nil {
+			t.Fatalf("InsertCommand failed: %v", err)
+		}
+	}
+
+	metas, err := db.GetCommandMeta()
+	if err != nil {
+		t.Fatalf("GetCommandMeta failed: %v", err)
+	}
+	want := []CommandMeta{
+		{Name: "build", Description: "Build it", Tags: []string{"go"}},
+		{Name: "test", Description: "No description provided"},
+	}
+	if !reflect.DeepEqual(metas, want) {
+		t.Errorf("Expected %+v, got %+v", want, metas)
+	}
+
+	// Runs, updates and deletions keep the index current
+	startedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	if err := db.RecordRun("build", startedAt); err != nil {
+		t.Fatalf("RecordRun failed: %v", err)
+	}
+	if err := db.UpdateCommand("test", "Run tests", "go test ./...", ""); err != nil {
+		t.Fatalf("UpdateCommand failed: %v", err)
+	}
+	if err := db.ReplaceCommand(Command{Name: "build", Command: "go build", Deprecated: &Deprecation{}}); err != nil {
+		t.Fatalf("ReplaceCommand failed: %v", err)
+	}
+	metas, _ = db.GetCommandMeta()
+	if metas[0].LastRunAt != startedAt.Format(timeLayout) || metas[0].Deprecated == nil {
+		t.Errorf("Expected run time and deprecation in the index, got %+v", metas[0])
+	}
+	if metas[1].Description != "Run tests" {
+		t.Errorf("Expected the updated description, got %+v", metas[1])
+	}
+
+	if err := db.DeleteCommand("test"); err != nil {
+		t.Fatalf("DeleteCommand failed: %v", err)
+	}
+	if metas, _ := db.GetCommandMeta(); len(metas) != 1 || metas[0].Name != "build" {
+		t.Errorf("Expected only build after delete, got %+v", metas)
+	}
+}
+
+func TestMetaIndexRebuiltForOldDatabases(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("build", "Build it", "go build", ""); err != nil {
+		t.Fatalf("AddCommand failed: %v", err)
+	}
+	err := db.db.Update(func(tx *bbolt.Tx) error {
+		return tx.DeleteBucket(metaBucket)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.initBuckets(); err != nil {
+		t.Fatalf("initBuckets failed: %v", err)
+	}
+	metas, err := db.GetCommandMeta()
+	if err != nil {
+		t.Fatalf("GetCommandMeta failed: %v", err)
+	}
+	if len(metas) != 1 || metas[0].Description != "Build it" {
+		t.Errorf("Expected the index to be rebuilt, got %+v", metas)
+	}
+}
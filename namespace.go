@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// namespaceSeparator separates the namespaces of a hierarchical command
+// name, as in proj1:build
+const namespaceSeparator = ":"
+
+// validateCommandName checks that no namespace or name of a hierarchical
+// command name is empty
+func validateCommandName(name string) error {
+	for _, part := range strings.Split(name, namespaceSeparator) {
+		if strings.TrimSpace(part) == "" {
+			return fmt.Errorf("invalid command name '%s': namespaces and names must not be empty", name)
+		}
+	}
+	return nil
+}
+
+// normalizeNamespace strips a trailing separator, so proj1 and proj1: name
+// the same namespace
+func normalizeNamespace(namespace string) string {
+	return strings.TrimSuffix(strings.TrimSpace(namespace), namespaceSeparator)
+}
+
+// inNamespace reports whether name lies in namespace or in a namespace
+// nested in it
+func inNamespace(name, namespace string) bool {
+	return strings.HasPrefix(name, namespace+namespaceSeparator)
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestValidateCommandName(t *testing.T) {
+	for _, name := range []string{"build", "proj1:build", "proj1:api:test"} {
+		if err := validateCommandName(name); err != nil {
+			t.Errorf("Unexpected error for %q: %v", name, err)
+		}
+	}
+	for _, name := range []string{":build", "proj1:", "proj1::build", "proj1: :build"} {
+		if err := validateCommandName(name); err == nil {
+			t.Errorf("Expected an error for %q", name)
+		}
+	}
+}
+
+func TestInNamespace(t *testing.T) {
+	namespace := normalizeNamespace("proj1:")
+	if namespace != "proj1" {
+		t.Fatalf("Expected the trailing separator to be stripped, got %q", namespace)
+	}
+	for name, want := range map[string]bool{
+		"proj1:build":    true,
+		"proj1:api:test": true,
+		"proj1":          false,
+		"proj10:build":   false,
+		"build":          false,
+	} {
+		if got := inNamespace(name, namespace); got != want {
+			t.Errorf("inNamespace(%q, %q) = %v, want %v", name, namespace, got, want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Outcomes a notification rule can match
+const (
+	NotifyAlways  = "always"
+	NotifyFailure = "failure"
+	NotifySuccess = "success"
+)
+
+// NotifyRule decides whether a finished run sends a notification. A run
+// matches when its outcome matches On (default always) and it took at least
+// MinDuration. A command notifies if any of its rules match.
+type NotifyRule struct {
+	On          string `json:"on,omitempty" yaml:"on,omitempty"`
+	MinDuration string `json:"min_duration,omitempty" yaml:"min_duration,omitempty"`
+}
+
+// validate normalizes the rule and checks its fields
+func (r *NotifyRule) validate() error {
+	r.On = strings.ToLower(strings.TrimSpace(r.On))
+	switch r.On {
+	case "", NotifyAlways, NotifyFailure, NotifySuccess:
+	default:
+		return fmt.Errorf("unknown notify condition '%s' (expected %s, %s or %s)", r.On, NotifyAlways, NotifyFailure, NotifySuccess)
+	}
+
+	r.MinDuration = strings.TrimSpace(r.MinDuration)
+	if r.MinDuration != "" {
+		d, err := time.ParseDuration(r.MinDuration)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid notify duration '%s'", r.MinDuration)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule fires for a finished run
+func (r NotifyRule) matches(record RunRecord) bool {
+	switch r.On {
+	case NotifyFailure:
+		if record.Success {
+			return false
+		}
+	case NotifySuccess:
+		if !record.Success {
+			return false
+		}
+	}
+	if r.MinDuration != "" {
+		d, err := time.ParseDuration(r.MinDuration)
+		if err != nil || record.Duration < d {
+			return false
+		}
+	}
+	return true
+}
+
+// validateNotifyRules validates all rules of a command
+func validateNotifyRules(rules []NotifyRule) error {
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shouldNotify reports whether any of the rules fires for a finished run
+func shouldNotify(rules []NotifyRule, record RunRecord) bool {
+	for _, rule := range rules {
+		if rule.matches(record) {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationText returns the title and message describing a finished run
+func notificationText(record RunRecord) (string, string) {
+	if record.Success {
+		return fmt.Sprintf("afv: %s succeeded", record.Name),
+			fmt.Sprintf("Finished in %s", formatDuration(record.Duration))
+	}
+	return fmt.Sprintf("afv: %s failed", record.Name),
+		fmt.Sprintf("Exit status %d after %s", record.ExitCode, formatDuration(record.Duration))
+}
+
+// desktopNotifyCommands returns the notification tools to try, in order, on
+// the current platform
+func desktopNotifyCommands(title, message string) [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, title)
+		return [][]string{{"osascript", "-e", script}}
+	case "windows":
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms; `+
+			`$n = New-Object System.Windows.Forms.NotifyIcon; `+
+			`$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; `+
+			`$n.ShowBalloonTip(5000, '%s', '%s', 'Info'); Start-Sleep -Seconds 5; $n.Dispose()`,
+			strings.ReplaceAll(title, "'", "''"), strings.ReplaceAll(message, "'", "''"))
+		return [][]string{{"powershell.exe", "-NoProfile", "-Command", script}}
+	default:
+		return [][]string{{"notify-send", "--app-name=afv", title, message}}
+	}
+}
+
+// sendDesktopNotification shows a desktop notification with the first
+// available tool
+func sendDesktopNotification(title, message string) error {
+	var tried []string
+	for _, args := range desktopNotifyCommands(title, message) {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			tried = append(tried, args[0])
+			continue
+		}
+
+		var stderr bytes.Buffer
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("notification tool %s failed: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+	return fmt.Errorf("no notification tool found (tried: %s)", strings.Join(tried, ", "))
+}
+
+// NotifyRun sends a notification for a finished run if the command's rules
+// ask for one, or always if force is set. Commands with email recipients are
+// notified by mail, which also works on headless servers; others with a
+// desktop notification. A webhook from config.yaml is notified as well.
+func NotifyRun(command *Command, record RunRecord, force bool) error {
+	if !force && !shouldNotify(command.Notify, record) {
+		return nil
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	if len(command.NotifyEmail) > 0 {
+		err = sendEmailNotification(cfg.SMTP, command.NotifyEmail, record)
+	} else {
+		title, message := notificationText(record)
+		err = sendDesktopNotification(title, message)
+	}
+	if cfg.Notify.Webhook != "" {
+		err = errors.Join(err, sendWebhookNotification(cfg.Notify.Webhook, record))
+	}
+	return err
+}
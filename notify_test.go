@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyRuleValidate(t *testing.T) {
+	rule := NotifyRule{On: " Failure ", MinDuration: " 5m "}
+	if err := rule.validate(); err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+	if rule.On != NotifyFailure || rule.MinDuration != "5m" {
+		t.Errorf("Expected normalized rule, got %+v", rule)
+	}
+
+	if err := (&NotifyRule{On: "sometimes"}).validate(); err == nil {
+		t.Error("Expected error for an unknown condition")
+	}
+	if err := (&NotifyRule{MinDuration: "soon"}).validate(); err == nil {
+		t.Error("Expected error for an invalid duration")
+	}
+}
+
+func TestShouldNotify(t *testing.T) {
+	quickOK := RunRecord{Success: true, Duration: 2 * time.Second}
+	quickFail := RunRecord{Success: false, Duration: 2 * time.Second}
+	slowOK := RunRecord{Success: true, Duration: 10 * time.Minute}
+
+	tests := []struct {
+		name   string
+		rules  []NotifyRule
+		record RunRecord
+		want   bool
+	}{
+		{"no rules", nil, quickFail, false},
+		{"always", []NotifyRule{{}}, quickOK, true},
+		{"failure only, success", []NotifyRule{{On: NotifyFailure}}, quickOK, false},
+		{"failure only, failure", []NotifyRule{{On: NotifyFailure}}, quickFail, true},
+		{"slow only, quick", []NotifyRule{{MinDuration: "5m"}}, quickOK, false},
+		{"slow only, slow", []NotifyRule{{MinDuration: "5m"}}, slowOK, true},
+		{"slow failure, slow success", []NotifyRule{{On: NotifyFailure, MinDuration: "5m"}}, slowOK, false},
+		{"failure or slow", []NotifyRule{{On: NotifyFailure}, {MinDuration: "5m"}}, slowOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldNotify(tt.rules, tt.record); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNotificationText(t *testing.T) {
+	title, message := notificationText(RunRecord{Name: "build", ExitCode: 2, Duration: 90 * time.Second})
+	if title != "afv: build failed" || !strings.Contains(message, "Exit status 2") {
+		t.Errorf("Unexpected failure notification %q / %q", title, message)
+	}
+
+	title, _ = notificationText(RunRecord{Name: "build", Success: true})
+	if title != "afv: build succeeded" {
+		t.Errorf("Unexpected success notification %q", title)
+	}
+}
+
+func TestNotifyRunSkipsWithoutMatch(t *testing.T) {
+	// No notification tool is reachable, so a matching rule would fail
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv(configDirEnv, t.TempDir())
+
+	cmd := &Command{Name: "quick", Notify: []NotifyRule{{MinDuration: "1h"}}}
+	if err := NotifyRun(cmd, RunRecord{Name: "quick", Success: true}, false); err != nil {
+		t.Errorf("Expected no notification attempt, got %v", err)
+	}
+
+	cmd.Notify = []NotifyRule{{}}
+	if err := NotifyRun(cmd, RunRecord{Name: "quick", Success: true}, false); err == nil {
+		t.Error("Expected error without a notification tool")
+	}
+}
+
+func TestInsertCommandValidatesNotify(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	err := db.InsertCommand(Command{Name: "bad", Command: "true", Notify: []NotifyRule{{On: "never"}}})
+	if err == nil {
+		t.Error("Expected error for an invalid notify rule")
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// RunStep is one stored command of a multi-command run with its options
+type RunStep struct {
+	Name string
+	Opts RunOptions
+}
+
+// RunFailure reports the commands of a multi-command run that failed. It
+// unwraps to the first failure so afv exits with that command's status.
+type RunFailure struct {
+	Failed []string
+	Total  int
+	Err    error
+}
+
+func (e *RunFailure) Error() string {
+	if len(e.Failed) == 1 {
+		return fmt.Sprintf("command '%s' failed: %v", e.Failed[0], e.Err)
+	}
+	return fmt.Sprintf("%d of %d commands failed: %s", len(e.Failed), e.Total, strings.Join(e.Failed, ", "))
+}
+
+func (e *RunFailure) Unwrap() error {
+	return e.Err
+}
+
+// RunSequence runs steps one after another, stopping at the first failure
+// unless keepGoing is set, in which case every step runs and all failures
+// are reported together
+func RunSequence(db *Database, steps []RunStep, keepGoing bool) error {
+	err := runFailure(runSequence(db, steps, keepGoing))
+	var failure *RunFailure
+	if len(steps) == 1 && errors.As(err, &failure) {
+		return failure.Err
+	}
+	return err
+}
+
+// runSequence runs steps one after another and returns the outcome of each;
+// steps after a failure are left unrun unless keepGoing is set
+func runSequence(db *Database, steps []RunStep, keepGoing bool) []runOutcome {
+	outcomes := make([]runOutcome, len(steps))
+	failed := false
+	for i, step := range steps {
+		outcomes[i].Name = step.Name
+		if failed && !keepGoing {
+			continue
+		}
+		start := time.Now()
+		err := RunStored(db, step.Name, step.Opts)
+		outcomes[i].Ran, outcomes[i].Err, outcomes[i].Duration = true, err, time.Since(start)
+		if err == nil {
+			continue
+		}
+		failed = true
+		if keepGoing {
+			fmt.Fprintf(os.Stderr, "Error running %s: %v\n", step.Name, err)
+		}
+	}
+	return outcomes
+}
+
+// runOutcome is the result of one step of a multi-command run
+type runOutcome struct {
+	Name     string
+	Ran      bool
+	Err      error
+	Duration time.Duration
+}
+
+// runFailure returns a RunFailure listing the failed outcomes, or nil if
+// none failed
+func runFailure(outcomes []runOutcome) error {
+	var failure *RunFailure
+	for _, outcome := range outcomes {
+		if outcome.Err == nil {
+			continue
+		}
+		if failure == nil {
+			failure = &RunFailure{Total: len(outcomes), Err: outcome.Err}
+		}
+		failure.Failed = append(failure.Failed, outcome.Name)
+	}
+	if failure == nil {
+		return nil
+	}
+	return failure
+}
+
+// printRunSummary writes a table with the status, exit status and duration
+// of every step of a multi-command run
+func printRunSummary(w io.Writer, outcomes []runOutcome) {
+	width := len("NAME")
+	for _, outcome := range outcomes {
+		width = max(width, len(outcome.Name))
+	}
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintf(w, "  %-*s  %-8s  %4s  %s\n", width, "NAME", "STATUS", "EXIT", "DURATION")
+	for _, outcome := range outcomes {
+		if !outcome.Ran {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, outcome.Name, "skipped")
+			continue
+		}
+		status := "ok"
+		if outcome.Err != nil {
+			status = "failed"
+		}
+		fmt.Fprintf(w, "  %-*s  %-8s  %4d  %s\n", width, outcome.Name, status, exitCode(outcome.Err), formatDuration(outcome.Duration))
+	}
+}
+
+// RunBatch runs steps one after another, or at the same time when parallel
+// is set, and prints a summary table of the results afterwards
+func RunBatch(db *Database, steps []RunStep, parallel, keepGoing bool) error {
+	var outcomes []runOutcome
+	if parallel {
+		outcomes = runParallel(db, steps)
+	} else {
+		outcomes = runSequence(db, steps, keepGoing)
+	}
+	fmt.Println()
+	printRunSummary(os.Stdout, outcomes)
+	return runFailure(outcomes)
+}
+
+// prefixColors are the ANSI colors cycled through for the commands of a
+// parallel run
+var prefixColors = []string{"36", "32", "33", "35", "34", "31"}
+
+// useColor reports whether output to f should be colored
+func useColor(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(f.Fd()))
+}
+
+// prefixWriter writes each line of a command's output to out with the
+// command's name in front. Writers sharing mu never interleave their lines.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes a final line that did not end in a newline
+func (w *prefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeLine(append(w.buf, '\n'))
+	w.buf = nil
+	return err
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := fmt.Fprintf(w.out, "%s%s", w.prefix, line)
+	return err
+}
+
+// outputPrefixes returns the line prefix of each name, padded to the same
+// width and colored when color is set
+func outputPrefixes(names []string, color bool) []string {
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	prefixes := make([]string, len(names))
+	for i, name := range names {
+		label := fmt.Sprintf("%-*s |", width, name)
+		if color {
+			label = fmt.Sprintf("\033[%sm%s\033[0m", prefixColors[i%len(prefixColors)], label)
+		}
+		prefixes[i] = label + " "
+	}
+	return prefixes
+}
+
+// RunParallel runs steps at the same time, prefixing every line of their
+// output with the command name. It waits for all of them and reports every
+// failure; afv exits with the status of the first failed step in order.
+func RunParallel(db *Database, steps []RunStep) error {
+	return runFailure(runParallel(db, steps))
+}
+
+// runParallel runs steps at the same time and returns the outcome of each
+func runParallel(db *Database, steps []RunStep) []runOutcome {
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = step.Name
+	}
+	prefixes := outputPrefixes(names, useColor(os.Stdout))
+
+	// Other afv processes need the database while the batch runs, such as
+	// the scheduler or a second run of a singleton command. A batch started
+	// from afv tui finds it shared already.
+	if !db.Shared() {
+		if err := db.Share(); err != nil {
+			warn("failed to close database: %v", err)
+		} else {
+			defer func() {
+				if err := db.Reopen(); err != nil {
+					warn("%v", err)
+				}
+			}()
+		}
+	}
+
+	var mu sync.Mutex
+	outcomes := make([]runOutcome, len(steps))
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		stdout := &prefixWriter{mu: &mu, out: os.Stdout, prefix: prefixes[i]}
+		stderr := &prefixWriter{mu: &mu, out: os.Stderr, prefix: prefixes[i]}
+		step.Opts.Stdout, step.Opts.Stderr = stdout, stderr
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := RunStored(db, step.Name, step.Opts)
+			outcomes[i] = runOutcome{Name: step.Name, Ran: true, Err: err, Duration: time.Since(start)}
+			stdout.Flush()
+			stderr.Flush()
+		}()
+	}
+	wg.Wait()
+
+	for i, outcome := range outcomes {
+		if outcome.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s%v\n", prefixes[i], outcome.Err)
+		}
+	}
+	return outcomes
+}
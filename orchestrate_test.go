@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	prefixes := outputPrefixes([]string{"api", "worker"}, false)
+	if prefixes[0] != "api    | " || prefixes[1] != "worker | " {
+		t.Fatalf("Expected padded prefixes, got %q", prefixes)
+	}
+
+	w := &prefixWriter{mu: &mu, out: &out, prefix: prefixes[0]}
+	w.Write([]byte("one\ntw"))
+	w.Write([]byte("o\nthree"))
+	if got := out.String(); got != "api    | one\napi    | two\n" {
+		t.Errorf("Expected complete lines only, got %q", got)
+	}
+	w.Flush()
+	if got := out.String(); got != "api    | one\napi    | two\napi    | three\n" {
+		t.Errorf("Expected Flush to write the last partial line, got %q", got)
+	}
+
+	colored := outputPrefixes([]string{"api"}, true)
+	if colored[0] != "\033[36mapi |\033[0m " {
+		t.Errorf("Unexpected colored prefix %q", colored[0])
+	}
+}
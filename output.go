@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// outputMux fans the stdout and stderr of a run out to the console and any
+// number of shared destinations such as the run log. Writes from both
+// streams are serialized, so every destination sees the chunks in the same
+// order, and a destination that fails is dropped instead of stopping the
+// others.
+type outputMux struct {
+	mu     sync.Mutex
+	shared []io.Writer
+	failed []bool
+}
+
+// newOutputMux returns a multiplexer writing to every shared destination
+func newOutputMux(shared ...io.Writer) *outputMux {
+	return &outputMux{shared: shared, failed: make([]bool, len(shared))}
+}
+
+// Stream returns a writer for one output stream that writes to console and
+// to the shared destinations
+func (m *outputMux) Stream(console io.Writer) io.Writer {
+	return &muxStream{mux: m, console: console}
+}
+
+// muxStream is one output stream of an outputMux
+type muxStream struct {
+	mux           *outputMux
+	console       io.Writer
+	consoleFailed bool
+}
+
+// Write sends p to the console and the shared destinations that have not
+// failed, returning an error only once every destination has failed
+func (s *muxStream) Write(p []byte) (int, error) {
+	m := s.mux
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var firstErr error
+	written := false
+	if !s.consoleFailed {
+		if _, err := s.console.Write(p); err != nil {
+			s.consoleFailed, firstErr = true, err
+		} else {
+			written = true
+		}
+	}
+	for i, w := range m.shared {
+		if m.failed[i] {
+			continue
+		}
+		if _, err := w.Write(p); err != nil {
+			m.failed[i] = true
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		written = true
+	}
+	if !written && firstErr != nil {
+		return 0, firstErr
+	}
+	return len(p), nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestOutputMux(t *testing.T) {
+	t.Run("Keeps the order of both streams", func(t *testing.T) {
+		var stdout, stderr, log bytes.Buffer
+		mux := newOutputMux(&log)
+		out, errOut := mux.Stream(&stdout), mux.Stream(&stderr)
+		out.Write([]byte("one\n"))
+		errOut.Write([]byte("two\n"))
+		out.Write([]byte("three\n"))
+
+		if stdout.String() != "one\nthree\n" || stderr.String() != "two\n" {
+			t.Errorf("Expected each stream on its own console, got %q and %q", stdout.String(), stderr.String())
+		}
+		if log.String() != "one\ntwo\nthree\n" {
+			t.Errorf("Expected the log in write order, got %q", log.String())
+		}
+	})
+
+	t.Run("Writes the lines of concurrent streams whole", func(t *testing.T) {
+		var console, log bytes.Buffer
+		mux := newOutputMux(&log)
+		var wg sync.WaitGroup
+		for _, line := range []string{"aaaa\n", "bbbb\n"} {
+			w := mux.Stream(&console)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 100 {
+					w.Write([]byte(line))
+				}
+			}()
+		}
+		wg.Wait()
+		for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+			if line != "aaaa" && line != "bbbb" {
+				t.Fatalf("Expected whole lines, got %q", line)
+			}
+		}
+		if console.String() != log.String() {
+			t.Error("Expected the console and log to see the same order")
+		}
+	})
+
+	t.Run("Drops a failing destination", func(t *testing.T) {
+		var log bytes.Buffer
+		out := newOutputMux(&log).Stream(failingWriter{})
+		for _, chunk := range []string{"one\n", "two\n"} {
+			if n, err := out.Write([]byte(chunk)); err != nil || n != len(chunk) {
+				t.Fatalf("Expected the write to succeed while the log works, got %d, %v", n, err)
+			}
+		}
+		if log.String() != "one\ntwo\n" {
+			t.Errorf("Expected the log to keep every chunk, got %q", log.String())
+		}
+	})
+
+	t.Run("Fails once every destination failed", func(t *testing.T) {
+		out := newOutputMux(failingWriter{}).Stream(failingWriter{})
+		if _, err := out.Write([]byte("lost\n")); err == nil {
+			t.Error("Expected an error when no destination could be written")
+		}
+	})
+}
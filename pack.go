@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// PackInfo identifies an export document as an installable command pack.
+// Version must be a semantic version.
+type PackInfo struct {
+	Name      string           `json:"name" yaml:"name"`
+	Version   string           `json:"version" yaml:"version"`
+	Changelog []ChangelogEntry `json:"changelog,omitempty" yaml:"changelog,omitempty"`
+}
+
+// ChangelogEntry lists the changes a pack version introduced
+type ChangelogEntry struct {
+	Version string   `json:"version" yaml:"version"`
+	Changes []string `json:"changes" yaml:"changes"`
+}
+
+// PackRecord tracks an installed pack and the commands it owns
+type PackRecord struct {
+	Name        string   `json:"name"`
+	Version     string   `json:"version"`
+	Source      string   `json:"source"`
+	Commands    []string `json:"commands"`
+	InstalledAt string   `json:"installed_at"`
+}
+
+// SavePack stores the record of an installed pack
+func (d *Database) SavePack(pack PackRecord) error {
+	return d.update("SavePack", func(tx *bbolt.Tx) error {
+		data, err := json.Marshal(pack)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(packsBucket).Put([]byte(pack.Name), data)
+	})
+}
+
+// GetPack returns the record of an installed pack
+func (d *Database) GetPack(name string) (*PackRecord, error) {
+	var pack PackRecord
+	err := d.view("GetPack", func(tx *bbolt.Tx) error {
+		data := tx.Bucket(packsBucket).Get([]byte(name))
+		if data == nil {
+			return fmt.Errorf("pack '%s' is not installed", name)
+		}
+		return json.Unmarshal(data, &pack)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pack, nil
+}
+
+// GetAllPacks returns the records of all installed packs
+func (d *Database) GetAllPacks() ([]PackRecord, error) {
+	var packs []PackRecord
+	err := d.view("GetAllPacks", func(tx *bbolt.Tx) error {
+		return tx.Bucket(packsBucket).ForEach(func(k, v []byte) error {
+			var pack PackRecord
+			if err := json.Unmarshal(v, &pack); err != nil {
+				return err
+			}
+			packs = append(packs, pack)
+			return nil
+		})
+	})
+	return packs, err
+}
+
+// loadPack reads and parses a pack document from a file or URL
+func loadPack(source string) (*ExportDocument, error) {
+	data, err := readImportSource(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pack: %v", err)
+	}
+
+	doc, err := decodeExportDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Pack == nil || strings.TrimSpace(doc.Pack.Name) == "" || strings.TrimSpace(doc.Pack.Version) == "" {
+		return nil, fmt.Errorf("%s is not a pack: it needs a 'pack' section with a name and version", source)
+	}
+	if _, err := parseSemver(doc.Pack.Version); err != nil {
+		return nil, fmt.Errorf("pack '%s': %v", doc.Pack.Name, err)
+	}
+	for _, entry := range doc.Pack.Changelog {
+		if _, err := parseSemver(entry.Version); err != nil {
+			return nil, fmt.Errorf("pack '%s' changelog: %v", doc.Pack.Name, err)
+		}
+	}
+	return doc, nil
+}
+
+// packSource normalizes a pack source so it can be fetched again later
+func packSource(source string) (string, error) {
+	if isImportURL(source) {
+		return source, nil
+	}
+	abs, err := filepath.Abs(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve pack path: %v", err)
+	}
+	return abs, nil
+}
+
+// applyPack imports the commands of a pack document and records the pack.
+// Commands already owned by a previous version of the pack are updated in
+// place; other name collisions are resolved with strategy.
+func applyPack(db *Database, doc *ExportDocument, source string, previous *PackRecord, strategy string) (*ImportResult, error) {
+	var owned []string
+	if previous != nil {
+		owned = previous.Commands
+	}
+
+	result, err := ImportCommands(db, doc.Commands, strategy, func(name string) bool {
+		return slices.Contains(owned, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	commands := append(slices.Clone(result.Added), result.Updated...)
+	for _, renamed := range result.Renamed {
+		commands = append(commands, renamed)
+	}
+	slices.Sort(commands)
+
+	record := PackRecord{
+		Name:        doc.Pack.Name,
+		Version:     doc.Pack.Version,
+		Source:      source,
+		Commands:    commands,
+		InstalledAt: time.Now().Format(timeLayout),
+	}
+	if err := db.SavePack(record); err != nil {
+		return nil, fmt.Errorf("failed to record pack: %v", err)
+	}
+	return result, nil
+}
+
+// InstallPack installs a pack from a file or URL. Replacing an installed pack
+// with a lower version requires force.
+func InstallPack(db *Database, source, strategy string, force bool) (*PackInfo, *ImportResult, error) {
+	source, err := packSource(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := loadPack(source)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	previous, _ := db.GetPack(doc.Pack.Name)
+	if previous != nil && !force {
+		if c, err := compareVersions(doc.Pack.Version, previous.Version); err == nil && c < 0 {
+			return nil, nil, fmt.Errorf("refusing to downgrade pack '%s' from %s to %s (use --force)", doc.Pack.Name, previous.Version, doc.Pack.Version)
+		}
+	}
+
+	result, err := applyPack(db, doc, source, previous, strategy)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc.Pack, result, nil
+}
+
+// PackUpdate describes the version of a pack available at its source
+type PackUpdate struct {
+	Installed PackRecord
+	Available *ExportDocument
+	// Change is 1 if the source has a newer version, -1 if it has an older
+	// one and 0 if the versions are equal
+	Change int
+}
+
+// Outdated reports whether the source offers a newer version
+func (u PackUpdate) Outdated() bool {
+	return u.Change > 0
+}
+
+// Downgrade reports whether the source offers an older version
+func (u PackUpdate) Downgrade() bool {
+	return u.Change < 0
+}
+
+// Changes returns the changelog entries between the installed and the
+// available version, newest first
+func (u PackUpdate) Changes() []ChangelogEntry {
+	var changes []ChangelogEntry
+	for _, entry := range u.Available.Pack.Changelog {
+		newer, _ := compareVersions(entry.Version, u.Installed.Version)
+		notAhead, _ := compareVersions(entry.Version, u.Available.Pack.Version)
+		if newer > 0 && notAhead <= 0 {
+			changes = append(changes, entry)
+		}
+	}
+	slices.SortStableFunc(changes, func(a, b ChangelogEntry) int {
+		c, _ := compareVersions(b.Version, a.Version)
+		return c
+	})
+	return changes
+}
+
+// CheckPackUpdate fetches the current version of an installed pack from its source
+func CheckPackUpdate(pack PackRecord) (*PackUpdate, error) {
+	doc, err := loadPack(pack.Source)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Pack.Name != pack.Name {
+		return nil, fmt.Errorf("source of pack '%s' now serves pack '%s'", pack.Name, doc.Pack.Name)
+	}
+
+	change, err := compareVersions(doc.Pack.Version, pack.Version)
+	if err != nil {
+		return nil, fmt.Errorf("installed version of pack '%s': %v", pack.Name, err)
+	}
+	return &PackUpdate{Installed: pack, Available: doc, Change: change}, nil
+}
+
+// UpgradePack applies the version fetched by CheckPackUpdate. Applying an
+// older version requires force.
+func UpgradePack(db *Database, update *PackUpdate, strategy string, force bool) (*ImportResult, error) {
+	if update.Downgrade() && !force {
+		return nil, fmt.Errorf("refusing to downgrade pack '%s' from %s to %s (use --force)",
+			update.Installed.Name, update.Installed.Version, update.Available.Pack.Version)
+	}
+	return applyPack(db, update.Available, update.Installed.Source, &update.Installed, strategy)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writePack writes a pack document with a single command to dir
+func writePack(t *testing.T, dir, version, command string) string {
+	t.Helper()
+	path := filepath.Join(dir, "pack.yaml")
+	doc := "version: 1\npack:\n  name: tools\n  version: \"" + version + "\"\ncommands:\n  - name: hello\n    description: Say hello\n    command: " + command + "\n"
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatalf("Failed to write pack: %v", err)
+	}
+	return path
+}
+
+func TestInstallAndUpgradePack(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	path := writePack(t, tempDir, "1.0.0", "echo hello")
+	pack, _, err := InstallPack(db, path, ConflictSkip, false)
+	if err != nil {
+		t.Fatalf("InstallPack failed: %v", err)
+	}
+	if pack.Name != "tools" || pack.Version != "1.0.0" {
+		t.Errorf("Unexpected pack info: %+v", pack)
+	}
+
+	record, err := db.GetPack("tools")
+	if err != nil {
+		t.Fatalf("GetPack failed: %v", err)
+	}
+	if record.Source != path || !reflect.DeepEqual(record.Commands, []string{"hello"}) {
+		t.Errorf("Unexpected pack record: %+v", record)
+	}
+
+	update, err := CheckPackUpdate(*record)
+	if err != nil {
+		t.Fatalf("CheckPackUpdate failed: %v", err)
+	}
+	if update.Outdated() {
+		t.Error("Pack should be up to date")
+	}
+
+	writePack(t, tempDir, "1.1.0", "echo hello again")
+	update, err = CheckPackUpdate(*record)
+	if err != nil {
+		t.Fatalf("CheckPackUpdate failed: %v", err)
+	}
+	if !update.Outdated() {
+		t.Fatal("Pack should be outdated after the source changed")
+	}
+
+	// The pack owns 'hello', so it is updated even with the skip strategy
+	result, err := UpgradePack(db, update, ConflictSkip, false)
+	if err != nil {
+		t.Fatalf("UpgradePack failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Updated, []string{"hello"}) {
+		t.Errorf("Expected 'hello' to be updated, got %+v", result)
+	}
+
+	cmd, err := db.GetCommand("hello")
+	if err != nil {
+		t.Fatalf("GetCommand failed: %v", err)
+	}
+	if cmd.Command != "echo hello again" {
+		t.Errorf("Expected upgraded command, got '%s'", cmd.Command)
+	}
+	if record, _ := db.GetPack("tools"); record.Version != "1.1.0" {
+		t.Errorf("Expected recorded version 1.1.0, got %s", record.Version)
+	}
+}
+
+func TestPackDowngradeRequiresForce(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	path := writePack(t, tempDir, "2.0.0", "echo two")
+	if _, _, err := InstallPack(db, path, ConflictSkip, false); err != nil {
+		t.Fatalf("InstallPack failed: %v", err)
+	}
+
+	writePack(t, tempDir, "1.9.0", "echo one")
+	if _, _, err := InstallPack(db, path, ConflictSkip, false); err == nil {
+		t.Error("Expected installing an older version to be refused")
+	}
+
+	record, _ := db.GetPack("tools")
+	update, err := CheckPackUpdate(*record)
+	if err != nil {
+		t.Fatalf("CheckPackUpdate failed: %v", err)
+	}
+	if !update.Downgrade() || update.Outdated() {
+		t.Fatalf("Expected a downgrade, got change %d", update.Change)
+	}
+	if _, err := UpgradePack(db, update, ConflictSkip, false); err == nil {
+		t.Error("Expected downgrade without force to be refused")
+	}
+	if _, err := UpgradePack(db, update, ConflictSkip, true); err != nil {
+		t.Fatalf("Forced downgrade failed: %v", err)
+	}
+	if record, _ := db.GetPack("tools"); record.Version != "1.9.0" {
+		t.Errorf("Expected recorded version 1.9.0, got %s", record.Version)
+	}
+}
+
+func TestPackUpdateChanges(t *testing.T) {
+	update := PackUpdate{
+		Installed: PackRecord{Name: "tools", Version: "1.0.0"},
+		Available: &ExportDocument{Pack: &PackInfo{
+			Name:    "tools",
+			Version: "1.2.0",
+			Changelog: []ChangelogEntry{
+				{Version: "1.0.0", Changes: []string{"Initial release"}},
+				{Version: "1.1.0", Changes: []string{"Add lint"}},
+				{Version: "1.2.0", Changes: []string{"Add vet"}},
+				{Version: "1.3.0-rc.1", Changes: []string{"Not released yet"}},
+			},
+		}},
+		Change: 1,
+	}
+
+	var versions []string
+	for _, entry := range update.Changes() {
+		versions = append(versions, entry.Version)
+	}
+	if !reflect.DeepEqual(versions, []string{"1.2.0", "1.1.0"}) {
+		t.Errorf("Expected changes for 1.2.0 and 1.1.0, got %v", versions)
+	}
+}
+
+func TestLoadPackRequiresPackSection(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "plain.yaml")
+	if err := os.WriteFile(path, []byte("version: 1\ncommands:\n  - name: a\n    command: echo a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	if _, err := loadPack(path); err == nil {
+		t.Error("Expected error for a document without a pack section")
+	}
+
+	path = writePack(t, tempDir, "latest", "echo a")
+	if _, err := loadPack(path); err == nil {
+		t.Error("Expected error for a pack version that is not semver")
+	}
+}
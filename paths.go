@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// windowsEnvPattern matches %VAR% references in Windows paths
+var windowsEnvPattern = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_()]*)%`)
+
+// homeDir returns the user's home directory from $HOME or %USERPROFILE%,
+// falling back to the account database
+func homeDir() (string, error) {
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		return home, nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %v", err)
+	}
+	return usr.HomeDir, nil
+}
+
+// pathExpander expands the home and environment shortcuts in a directory.
+// The platform and lookups are fields so the Windows rules can be tested
+// anywhere.
+type pathExpander struct {
+	windows bool
+	getenv  func(string) string
+	home    func() (string, error)
+}
+
+// localPaths expands paths for the platform afv runs on
+var localPaths = pathExpander{windows: filepath.Separator == '\\', getenv: os.Getenv, home: homeDir}
+
+// expand replaces a leading ~ with the home directory and, on Windows,
+// %VAR% references with their values. On Windows ~\ works like ~/ and
+// unknown variables are kept literally, as cmd.exe does. Drive-relative
+// (C:dir) and UNC (\\server\share) paths are left for filepath.Abs, which
+// resolves them against the drive's current directory or keeps them as is.
+func (e pathExpander) expand(dir string) (string, error) {
+	if e.windows {
+		dir = windowsEnvPattern.ReplaceAllStringFunc(dir, func(ref string) string {
+			if value := e.getenv(strings.Trim(ref, "%")); value != "" {
+				return value
+			}
+			return ref
+		})
+	}
+
+	rest, ok := strings.CutPrefix(dir, "~")
+	if !ok {
+		return dir, nil
+	}
+	if rest != "" && rest[0] != '/' && !(e.windows && rest[0] == '\\') {
+		// ~user and names like ~backup are not home shortcuts
+		return dir, nil
+	}
+	home, err := e.home()
+	if err != nil {
+		return "", err
+	}
+	if rest == "" {
+		return home, nil
+	}
+	return home + rest, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPathExpanderWindows(t *testing.T) {
+	env := map[string]string{"USERPROFILE": `C:\Users\ada`, "ProgramFiles(x86)": `C:\Program Files (x86)`}
+	e := pathExpander{
+		windows: true,
+		getenv:  func(key string) string { return env[key] },
+		home:    func() (string, error) { return `C:\Users\ada`, nil },
+	}
+
+	tests := map[string]string{
+		`~`:                         `C:\Users\ada`,
+		`~\src\afv`:                 `C:\Users\ada\src\afv`,
+		`~/src`:                     `C:\Users\ada/src`,
+		`%USERPROFILE%\Documents`:   `C:\Users\ada\Documents`,
+		`%ProgramFiles(x86)%\Tool`:  `C:\Program Files (x86)\Tool`,
+		`%UNSET%\dir`:               `%UNSET%\dir`,
+		`C:project`:                 `C:project`,
+		`\\fileserver\share\builds`: `\\fileserver\share\builds`,
+		`~backup`:                   `~backup`,
+	}
+	for input, expected := range tests {
+		got, err := e.expand(input)
+		if err != nil || got != expected {
+			t.Errorf("expand(%q) = %q, %v; expected %q", input, got, err, expected)
+		}
+	}
+}
+
+func TestPathExpanderUnix(t *testing.T) {
+	e := pathExpander{
+		getenv: func(string) string { return "/should/not/expand" },
+		home:   func() (string, error) { return "/home/ada", nil },
+	}
+
+	tests := map[string]string{
+		"~":         "/home/ada",
+		"~/src":     "/home/ada/src",
+		`~\src`:     `~\src`,
+		"%HOME%/x":  "%HOME%/x",
+		"/srv/data": "/srv/data",
+	}
+	for input, expected := range tests {
+		got, err := e.expand(input)
+		if err != nil || got != expected {
+			t.Errorf("expand(%q) = %q, %v; expected %q", input, got, err, expected)
+		}
+	}
+
+	e.home = func() (string, error) { return "", errors.New("no home") }
+	if _, err := e.expand("~/src"); err == nil {
+		t.Error("Expected error without a home directory")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// pickerHeight is the number of matches the picker shows at once
+const pickerHeight = 10
+
+// errPickerCancelled is returned when the picker is closed without a choice
+var errPickerCancelled = fmt.Errorf("no command selected")
+
+// Keys the picker reacts to
+const (
+	keyNone = iota
+	keyRune
+	keyEnter
+	keyBackspace
+	keyUp
+	keyDown
+	keyCancel
+)
+
+// fuzzyScore reports whether the characters of query appear in text in
+// order, ignoring case. Lower scores are better: matches that start early
+// and have fewer gaps come first.
+func fuzzyScore(query, text string) (int, bool) {
+	q, t := []rune(strings.ToLower(query)), []rune(strings.ToLower(text))
+	score, last, qi := 0, -1, 0
+	for ti := 0; ti < len(t) && qi < len(q); ti++ {
+		if t[ti] != q[qi] {
+			continue
+		}
+		if last == -1 {
+			score += ti
+		} else if ti != last+1 {
+			score += ti - last
+		}
+		last = ti
+		qi++
+	}
+	return score, qi == len(q)
+}
+
+// filterCommands returns the commands matching query, best match first.
+// Matches in the name rank ahead of matches only in the description, and
+// pinned commands ahead of the others matching the same way.
+func filterCommands(commands []CommandMeta, query string) []CommandMeta {
+	type scored struct {
+		cmd   CommandMeta
+		score int
+	}
+	var matches []scored
+	for _, cmd := range commands {
+		if score, ok := fuzzyScore(query, cmd.Name); ok {
+			matches = append(matches, scored{cmd, score})
+		} else if score, ok := fuzzyScore(query, cmd.Description); ok {
+			matches = append(matches, scored{cmd, 1000 + score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b scored) int {
+		return a.score - b.score
+	})
+	pinnedFirst(matches, func(m scored) bool { return m.cmd.Pinned && m.score < 1000 })
+
+	filtered := make([]CommandMeta, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.cmd
+	}
+	return filtered
+}
+
+// readKey reads one key press from a terminal in raw mode
+func readKey(r *bufio.Reader) (int, rune, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return keyNone, 0, err
+	}
+	switch c {
+	case '\r', '\n':
+		return keyEnter, 0, nil
+	case 127, 8:
+		return keyBackspace, 0, nil
+	case 3, 4:
+		return keyCancel, 0, nil
+	case 16: // Ctrl-P
+		return keyUp, 0, nil
+	case 14: // Ctrl-N
+		return keyDown, 0, nil
+	case 27:
+		// Arrow keys arrive as ESC [ A; a lone ESC cancels
+		if r.Buffered() == 0 {
+			return keyCancel, 0, nil
+		}
+		if next, _, _ := r.ReadRune(); next != '[' && next != 'O' {
+			return keyNone, 0, nil
+		}
+		switch code, _, _ := r.ReadRune(); code {
+		case 'A':
+			return keyUp, 0, nil
+		case 'B':
+			return keyDown, 0, nil
+		}
+		return keyNone, 0, nil
+	}
+	if c < 32 {
+		return keyNone, 0, nil
+	}
+	return keyRune, c, nil
+}
+
+// runPicker lets the user choose one of commands by typing a fuzzy query
+// and moving the selection with the arrow keys, reading key presses from in
+// and drawing to out
+func runPicker(commands []CommandMeta, in io.Reader, out io.Writer) (string, error) {
+	r := bufio.NewReader(in)
+	query := ""
+	selected := 0
+
+	for {
+		matches := filterCommands(commands, query)
+		selected = max(0, min(selected, len(matches)-1))
+		drawPicker(out, query, matches, selected, len(commands))
+
+		key, c, err := readKey(r)
+		if err != nil {
+			clearPicker(out)
+			if err == io.EOF {
+				return "", errPickerCancelled
+			}
+			return "", err
+		}
+		switch key {
+		case keyRune:
+			query += string(c)
+			selected = 0
+		case keyBackspace:
+			if q := []rune(query); len(q) > 0 {
+				query = string(q[:len(q)-1])
+				selected = 0
+			}
+		case keyUp:
+			selected--
+		case keyDown:
+			selected++
+		case keyCancel:
+			clearPicker(out)
+			return "", errPickerCancelled
+		case keyEnter:
+			if len(matches) == 0 {
+				continue
+			}
+			clearPicker(out)
+			return matches[selected].Name, nil
+		}
+	}
+}
+
+// drawPicker draws the query line, the visible matches and a match count
+// below it, leaving the cursor after the query
+func drawPicker(out io.Writer, query string, matches []CommandMeta, selected, total int) {
+	var b strings.Builder
+	b.WriteString("\r\033[J")
+	fmt.Fprintf(&b, "> %s", query)
+
+	// Scroll so the selection stays visible
+	start := max(0, selected-pickerHeight+1)
+	end := min(len(matches), start+pickerHeight)
+	for i := start; i < end; i++ {
+		line := fmt.Sprintf("  %-15s %s", matches[i].Name, matches[i].Description)
+		if i == selected {
+			line = "\033[7m" + line + "\033[0m"
+		}
+		b.WriteString("\r\n" + line)
+	}
+	fmt.Fprintf(&b, "\r\n  %d/%d", len(matches), total)
+	fmt.Fprintf(&b, "\033[%dA\r\033[%dC", end-start+1, len([]rune(query))+2)
+	io.WriteString(out, b.String())
+}
+
+// clearPicker removes the picker from the screen
+func clearPicker(out io.Writer) {
+	io.WriteString(out, "\r\033[J")
+}
+
+// PickCommand opens the picker over the stored commands on the terminal
+func PickCommand(db *Database) (string, error) {
+	return pickCommand(db, os.Stdin, os.Stdout)
+}
+
+// pickCommand opens the picker reading key presses from the terminal in
+// and drawing to out, which may be another file than stdout so the choice
+// can be printed there
+func pickCommand(db *Database, in *os.File, out io.Writer) (string, error) {
+	commands, err := db.GetCommandMeta()
+	if err != nil {
+		return "", fmt.Errorf("failed to get commands: %v", err)
+	}
+	if len(commands) == 0 {
+		return "", fmt.Errorf("no commands found. Use 'afv add' to add commands")
+	}
+	// The order shown before anything is typed
+	if err := sortCommands(commands); err != nil {
+		return "", err
+	}
+
+	fd := int(in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from the terminal: %v", err)
+	}
+	defer term.Restore(fd, state)
+	return runPicker(commands, in, out)
+}
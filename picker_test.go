@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFilterCommands(t *testing.T) {
+	commands := []CommandMeta{
+		{Name: "build", Description: "Compile the project"},
+		{Name: "deploy-staging", Description: "Ship to staging"},
+		{Name: "deploy", Description: "Ship to production"},
+		{Name: "lint", Description: "Run the linters"},
+	}
+
+	var names []string
+	for _, cmd := range filterCommands(commands, "dpl") {
+		names = append(names, cmd.Name)
+	}
+	if strings.Join(names, ",") != "deploy-staging,deploy" {
+		t.Errorf("Expected both deploy commands in order, got %v", names)
+	}
+
+	// Commands also match by their description
+	names = nil
+	for _, cmd := range filterCommands(commands, "comp") {
+		names = append(names, cmd.Name)
+	}
+	if strings.Join(names, ",") != "build" {
+		t.Errorf("Expected build to match by description, got %v", names)
+	}
+	names = nil
+	for _, cmd := range filterCommands(commands, "ship") {
+		names = append(names, cmd.Name)
+	}
+	if len(names) != 2 {
+		t.Errorf("Expected both deploy commands to match by description, got %v", names)
+	}
+
+	if got := filterCommands(commands, ""); len(got) != len(commands) {
+		t.Errorf("An empty query should match everything, got %d", len(got))
+	}
+
+	// Pinned commands come first among the name matches, but not ahead of
+	// better matches when they only match by description
+	commands[2].Pinned = true
+	commands[0].Pinned = true
+	names = nil
+	for _, cmd := range filterCommands(commands, "dpl") {
+		names = append(names, cmd.Name)
+	}
+	if strings.Join(names, ",") != "deploy,deploy-staging" {
+		t.Errorf("Expected the pinned deploy first, got %v", names)
+	}
+	names = nil
+	for _, cmd := range filterCommands(commands, "") {
+		names = append(names, cmd.Name)
+	}
+	if strings.Join(names, ",") != "build,deploy,deploy-staging,lint" {
+		t.Errorf("Expected pinned commands first for an empty query, got %v", names)
+	}
+}
+
+func TestRunPicker(t *testing.T) {
+	commands := []CommandMeta{{Name: "build"}, {Name: "deploy"}, {Name: "deploy-staging"}}
+	var out bytes.Buffer
+
+	// Type a query, move down with the arrow key and confirm
+	name, err := runPicker(commands, strings.NewReader("dep\x1b[B\r"), &out)
+	if err != nil || name != "deploy-staging" {
+		t.Errorf("Expected deploy-staging, got %q, %v", name, err)
+	}
+
+	// Backspace widens the query again
+	name, err = runPicker(commands, strings.NewReader("x\x7f\r"), &out)
+	if err != nil || name != "build" {
+		t.Errorf("Expected the first command after backspace, got %q, %v", name, err)
+	}
+
+	if _, err := runPicker(commands, strings.NewReader("\x03"), &out); err != errPickerCancelled {
+		t.Errorf("Expected Ctrl-C to cancel, got %v", err)
+	}
+	if _, err := runPicker(commands, strings.NewReader("zzz"), &out); err != errPickerCancelled {
+		t.Errorf("Expected end of input to cancel, got %v", err)
+	}
+}
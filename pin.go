@@ -0,0 +1,26 @@
+package main
+
+import "slices"
+
+// PinCommand pins a stored command, or unpins it when pinned is false.
+// Pinned commands are listed first and offered first by the picker.
+func PinCommand(db *Database, name string, pinned bool) error {
+	return db.ModifyCommand(name, func(cmd *Command) error {
+		cmd.Pinned = pinned
+		return nil
+	})
+}
+
+// pinnedFirst moves the pinned items to the front, keeping the order within
+// the pinned and the unpinned items
+func pinnedFirst[T any](items []T, pinned func(T) bool) {
+	slices.SortStableFunc(items, func(a, b T) int {
+		switch pa, pb := pinned(a), pinned(b); {
+		case pa && !pb:
+			return -1
+		case pb && !pa:
+			return 1
+		}
+		return 0
+	})
+}
@@ -0,0 +1,78 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detachProcess starts the child in its own session so it outlives afv and
+// is not affected by signals sent to the terminal
+func detachProcess(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+}
+
+// processAlive reports whether a process with the given pid exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// terminateProcess asks a detached process and its children to exit
+func terminateProcess(pid int) error {
+	return signalProcessGroup(pid, syscall.SIGTERM)
+}
+
+// killProcess forcefully kills a detached process and its children
+func killProcess(pid int) error {
+	return signalProcessGroup(pid, syscall.SIGKILL)
+}
+
+// signalProcessGroup signals the process group led by pid, falling back to
+// the process itself
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(-pid, sig); err == nil {
+		return nil
+	}
+	err := syscall.Kill(pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	return err
+}
+
+// signalExitCode returns the shell-style exit code 128+N for a process that
+// was killed by signal N
+func signalExitCode(state *os.ProcessState) (int, bool) {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+	return 128 + int(status.Signal()), true
+}
+
+// interruptProcess asks a foreground child to exit with SIGTERM
+func interruptProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
+
+// forwardedSignals are passed on to a foreground child instead of stopping afv
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+
+// forwardSignal passes a signal afv received on to a foreground child
+func forwardSignal(p *os.Process, sig os.Signal) error {
+	err := p.Signal(sig)
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code Windows reports for a running process
+const stillActive = 259
+
+// detachProcess starts the child without a console in its own process group
+// so it outlives afv and does not receive the terminal's Ctrl-C
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: windows.CREATE_NEW_PROCESS_GROUP | windows.DETACHED_PROCESS,
+	}
+}
+
+// processAlive reports whether a process with the given pid is still running
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// terminateProcess stops a detached process. Windows has no SIGTERM for
+// console-less processes, so this kills it directly.
+func terminateProcess(pid int) error {
+	return killProcess(pid)
+}
+
+// killProcess forcefully kills a detached process
+func killProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	return p.Kill()
+}
+
+// signalExitCode reports false as Windows processes always have an exit code
+func signalExitCode(state *os.ProcessState) (int, bool) {
+	return 0, false
+}
+
+// interruptProcess stops a foreground child. Windows has no SIGTERM, so the
+// process is killed right away.
+func interruptProcess(p *os.Process) error {
+	return p.Kill()
+}
+
+// forwardedSignals are caught while a foreground child runs so afv waits for
+// it instead of exiting
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+// forwardSignal does nothing: the console already delivers Ctrl-C to every
+// process attached to it, including the child
+func forwardSignal(p *os.Process, sig os.Signal) error {
+	return nil
+}
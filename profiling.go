@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime/trace"
+)
+
+// Hidden global flags for profiling afv in the field. --pprof serves the
+// pprof endpoints while a long-running mode is active; --trace records an
+// execution trace of a single invocation.
+const (
+	pprofFlag = "pprof"
+	traceFlag = "trace"
+)
+
+// profileOptions holds the values of the global profiling flags
+type profileOptions struct {
+	PprofAddr string
+	TraceFile string
+}
+
+// extractProfileOptions removes --pprof and --trace from args
+func extractProfileOptions(args []string) ([]string, profileOptions, error) {
+	rest, values, err := extractGlobalFlags(args, pprofFlag, traceFlag)
+	if err != nil {
+		return nil, profileOptions{}, err
+	}
+	return rest, profileOptions{PprofAddr: values[pprofFlag], TraceFile: values[traceFlag]}, nil
+}
+
+// pprofHandler serves the pprof endpoints under /debug/pprof/
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// startProfiling starts the pprof server and the execution trace requested
+// by opts. The returned function stops both.
+func startProfiling(opts profileOptions) (func(), error) {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if opts.PprofAddr != "" {
+		ln, err := net.Listen("tcp", opts.PprofAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start pprof server: %v", err)
+		}
+		server := &http.Server{Handler: pprofHandler()}
+		go server.Serve(ln)
+		slog.Info("pprof server listening", "addr", ln.Addr().String())
+		stops = append(stops, func() { server.Close() })
+	}
+
+	if opts.TraceFile != "" {
+		f, err := os.Create(opts.TraceFile)
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("failed to create trace file: %v", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stop()
+			return nil, fmt.Errorf("failed to start trace: %v", err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	return stop, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractProfileOptions(t *testing.T) {
+	args, opts, err := extractProfileOptions([]string{"apply", "commands.yaml", "--watch", "--pprof", ":6060", "--trace=out.trace"})
+	if err != nil {
+		t.Fatalf("extractProfileOptions failed: %v", err)
+	}
+	if !reflect.DeepEqual(args, []string{"apply", "commands.yaml", "--watch"}) {
+		t.Errorf("Expected profiling flags to be removed, got %v", args)
+	}
+	if opts.PprofAddr != ":6060" || opts.TraceFile != "out.trace" {
+		t.Errorf("Unexpected options %+v", opts)
+	}
+}
+
+func TestPprofHandler(t *testing.T) {
+	server := httptest.NewServer(pprofHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/debug/pprof/goroutine?debug=1")
+	if err != nil {
+		t.Fatalf("GET goroutine profile failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goroutine") {
+		t.Errorf("Unexpected goroutine profile (%d):\n%s", resp.StatusCode, body)
+	}
+}
+
+func TestStartProfilingTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.trace")
+	stop, err := startProfiling(profileOptions{TraceFile: path, PprofAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("startProfiling failed: %v", err)
+	}
+	stop()
+
+	info, err := os.Stat(path)
+	if err != nil || info.Size() == 0 {
+		t.Errorf("Expected a trace to be written, got %v, %v", info, err)
+	}
+
+	if _, err := startProfiling(profileOptions{PprofAddr: "not an address"}); err == nil {
+		t.Error("Expected error for an invalid pprof address")
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// projectFileName is the file declaring the shared commands of a project,
+// found in the working directory or the nearest parent holding one
+const projectFileName = ".afvikle.yaml"
+
+// findProjectFile returns the nearest project file in dir or its parents,
+// or "" if there is none
+func findProjectFile(dir string) string {
+	for {
+		path := filepath.Join(dir, projectFileName)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadProject merges the commands of the nearest project file above dir
+// into the commands looked up, listed and run through db. Project commands
+// shadow stored commands of the same name and are changed by editing the
+// file, not through afv.
+func (d *Database) LoadProject(dir string) error {
+	path := findProjectFile(dir)
+	if path == "" {
+		return nil
+	}
+	declared, err := loadDeclaredCommands(path)
+	if err != nil {
+		return fmt.Errorf("project file %s: %v", path, err)
+	}
+
+	project := make(map[string]Command, len(declared))
+	for _, cmd := range declared {
+		cmd = exportCommand(cmd)
+		if err := normalizeCommand(&cmd); err != nil {
+			if cmd.Name == "" {
+				return fmt.Errorf("project file %s: %v", path, err)
+			}
+			return fmt.Errorf("project file %s: command '%s': %v", path, cmd.Name, err)
+		}
+		if _, ok := project[cmd.Name]; ok {
+			return fmt.Errorf("project file %s: command '%s' is declared more than once", path, cmd.Name)
+		}
+		project[cmd.Name] = cmd
+	}
+	d.project, d.projectFile = project, path
+	slog.Info("project file", "path", path, "commands", len(project))
+	return nil
+}
+
+// ProjectFile returns the project file loaded by LoadProject, or ""
+func (d *Database) ProjectFile() string {
+	return d.projectFile
+}
+
+// ProjectCommands returns the commands of the project file, sorted by name
+func (d *Database) ProjectCommands() []Command {
+	commands := make([]Command, 0, len(d.project))
+	for _, name := range slices.Sorted(maps.Keys(d.project)) {
+		commands = append(commands, d.project[name])
+	}
+	return commands
+}
+
+// isProjectCommand reports whether name is declared in the project file
+func (d *Database) isProjectCommand(name string) bool {
+	_, ok := d.project[name]
+	return ok
+}
+
+// checkStored fails for commands of the project file, which afv must not
+// change in the database
+func (d *Database) checkStored(name string) error {
+	if d.isProjectCommand(name) {
+		return fmt.Errorf("command '%s' is declared in %s; change it there", name, d.projectFile)
+	}
+	return nil
+}
+
+// mergeProject returns stored with the project commands added, replacing
+// stored commands of the same name, sorted by name
+func mergeProject(stored, project []Command) []Command {
+	merged := slices.DeleteFunc(slices.Clone(stored), func(cmd Command) bool {
+		return slices.ContainsFunc(project, func(p Command) bool { return p.Name == cmd.Name })
+	})
+	merged = append(merged, project...)
+	slices.SortFunc(merged, func(a, b Command) int { return strings.Compare(a.Name, b.Name) })
+	return merged
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindProjectFile(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "src", "pkg")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+	if path := findProjectFile(nested); path != "" {
+		t.Errorf("Expected no project file, got %q", path)
+	}
+
+	path := filepath.Join(root, projectFileName)
+	if err := os.WriteFile(path, []byte("commands: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+	if got := findProjectFile(nested); got != path {
+		t.Errorf("Expected %q from a subdirectory, got %q", path, got)
+	}
+	if got := findProjectFile(root); got != path {
+		t.Errorf("Expected %q, got %q", path, got)
+	}
+}
+
+func TestLoadProject(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.InsertCommand(Command{Name: "build", Command: "make", Tags: []string{"ci"}}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := db.InsertCommand(Command{Name: "deploy", Command: "./deploy.sh"}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	project := t.TempDir()
+	data := "commands:\n  - name: build\n    command: go build ./...\n    working_dir: .\n  - name: lint\n    command: go vet ./...\n"
+	if err := os.WriteFile(filepath.Join(project, projectFileName), []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+	if err := db.LoadProject(project); err != nil {
+		t.Fatalf("LoadProject failed: %v", err)
+	}
+
+	build, err := db.GetCommand("build")
+	if err != nil || build.Command != "go build ./..." {
+		t.Fatalf("Expected the project build to shadow the stored one, got %+v, %v", build, err)
+	}
+	if build.WorkingDir != project {
+		t.Errorf("Expected the working directory to resolve to %q, got %q", project, build.WorkingDir)
+	}
+	if deploy, err := db.GetCommand("deploy"); err != nil || deploy.Command != "./deploy.sh" {
+		t.Errorf("Expected the stored deploy, got %+v, %v", deploy, err)
+	}
+
+	metas, err := db.GetCommandMeta()
+	if err != nil {
+		t.Fatalf("GetCommandMeta failed: %v", err)
+	}
+	var names []string
+	for _, meta := range metas {
+		names = append(names, meta.Name)
+		if meta.Project != (meta.Name != "deploy") {
+			t.Errorf("Unexpected project flag on %+v", meta)
+		}
+		if meta.Name == "build" && len(meta.Tags) != 0 {
+			t.Errorf("Expected the stored tags of build to be shadowed, got %v", meta.Tags)
+		}
+	}
+	if len(names) != 3 || names[0] != "build" || names[1] != "deploy" || names[2] != "lint" {
+		t.Errorf("Expected build, deploy and lint, got %v", names)
+	}
+
+	// Project commands are changed in the file, not in the database
+	if err := db.ModifyCommand("lint", func(cmd *Command) error { cmd.Command = "true"; return nil }); err == nil {
+		t.Error("Expected modifying a project command to fail")
+	}
+	if err := db.TrashCommand("build"); err == nil {
+		t.Error("Expected deleting a project command to fail")
+	}
+	if _, err := db.GetCommand("build"); err != nil {
+		t.Errorf("Expected build to remain: %v", err)
+	}
+}
+
+func TestLoadProjectDuplicate(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	project := t.TempDir()
+	data := "commands:\n  - name: lint\n    command: go vet ./...\n  - name: lint\n    command: golangci-lint run\n"
+	if err := os.WriteFile(filepath.Join(project, projectFileName), []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write project file: %v", err)
+	}
+	if err := db.LoadProject(project); err == nil {
+		t.Error("Expected a duplicate name to be rejected")
+	}
+	if db.ProjectFile() != "" || len(db.ProjectCommands()) != 0 {
+		t.Error("Expected a rejected project file not to be loaded")
+	}
+}
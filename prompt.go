@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PromptStatus is the afv context shown in a shell prompt
+type PromptStatus struct {
+	Workspace string
+	Pinned    int
+	Jobs      int
+	Running   int
+}
+
+// collectPromptStatus gathers the prompt status. It is called on every shell
+// prompt, so failures leave the affected field empty instead of erroring.
+func collectPromptStatus(db *Database) PromptStatus {
+	var status PromptStatus
+	status.Workspace, _ = activeWorkspace()
+
+	// The metadata index avoids decoding every command
+	if metas, err := db.GetCommandMeta(); err == nil {
+		for _, meta := range metas {
+			if meta.Pinned {
+				status.Pinned++
+			}
+		}
+	}
+	if jobs, err := db.GetAllJobs(); err == nil {
+		for _, job := range jobs {
+			if job.Running() {
+				status.Jobs++
+			}
+		}
+	}
+	if states, err := db.GetAllServiceStates(); err == nil {
+		for _, state := range states {
+			if processAlive(state.PID) {
+				status.Running++
+			}
+		}
+	}
+	return status
+}
+
+// Render formats the status. An empty format gives a compact default that
+// only mentions pinned commands, running jobs and running services when
+// there are any; otherwise the placeholders {workspace}, {pinned}, {jobs}
+// and {running} are replaced.
+func (s PromptStatus) Render(format string) string {
+	if format == "" {
+		var counts []string
+		if s.Pinned > 0 {
+			counts = append(counts, fmt.Sprintf("%d pinned", s.Pinned))
+		}
+		if s.Jobs == 1 {
+			counts = append(counts, "1 job")
+		} else if s.Jobs > 1 {
+			counts = append(counts, fmt.Sprintf("%d jobs", s.Jobs))
+		}
+		if s.Running > 0 {
+			counts = append(counts, fmt.Sprintf("%d running", s.Running))
+		}
+		if len(counts) == 0 {
+			return s.Workspace
+		}
+		return fmt.Sprintf("%s [%s]", s.Workspace, strings.Join(counts, ", "))
+	}
+
+	return strings.NewReplacer(
+		"{workspace}", s.Workspace,
+		"{pinned}", strconv.Itoa(s.Pinned),
+		"{jobs}", strconv.Itoa(s.Jobs),
+		"{running}", strconv.Itoa(s.Running),
+	).Replace(format)
+}
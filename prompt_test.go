@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPromptStatusRender(t *testing.T) {
+	idle := PromptStatus{Workspace: "client-a"}
+	if got := idle.Render(""); got != "client-a" {
+		t.Errorf("Expected only the workspace when nothing runs, got '%s'", got)
+	}
+
+	busy := PromptStatus{Workspace: "client-a", Running: 2}
+	if got := busy.Render(""); got != "client-a [2 running]" {
+		t.Errorf("Unexpected default rendering: '%s'", got)
+	}
+	if got := busy.Render("afv:{workspace}/{running}"); got != "afv:client-a/2" {
+		t.Errorf("Unexpected custom rendering: '%s'", got)
+	}
+
+	all := PromptStatus{Workspace: "client-a", Pinned: 3, Jobs: 1, Running: 2}
+	if got := all.Render(""); got != "client-a [3 pinned, 1 job, 2 running]" {
+		t.Errorf("Unexpected default rendering: '%s'", got)
+	}
+	if got := all.Render("{pinned}p {jobs}j"); got != "3p 1j" {
+		t.Errorf("Unexpected custom rendering: '%s'", got)
+	}
+}
+
+func TestCollectPromptStatus(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, name := range []string{"build", "deploy", "test"} {
+		if err := db.AddCommand(name, "", "true", ""); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+	for _, name := range []string{"build", "deploy"} {
+		if err := PinCommand(db, name, true); err != nil {
+			t.Fatalf("PinCommand failed: %v", err)
+		}
+	}
+
+	// This process stands in for a running job; the finished one and the
+	// one whose process is gone are not counted
+	now := time.Now().Format(timeLayout)
+	jobs := []Job{
+		{ID: 1, Name: "build", PID: os.Getpid(), StartedAt: now},
+		{ID: 2, Name: "test", PID: os.Getpid(), StartedAt: now, FinishedAt: now},
+		{ID: 3, Name: "deploy", PID: 0, StartedAt: now},
+	}
+	for _, job := range jobs {
+		if err := db.SaveJob(job); err != nil {
+			t.Fatalf("SaveJob failed: %v", err)
+		}
+	}
+
+	status := collectPromptStatus(db)
+	if status.Pinned != 2 {
+		t.Errorf("Expected 2 pinned commands, got %d", status.Pinned)
+	}
+	if status.Jobs != 1 {
+		t.Errorf("Expected 1 running job, got %d", status.Jobs)
+	}
+}
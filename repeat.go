@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// RepeatOptions controls how often 'afv run --repeat' runs a command
+type RepeatOptions struct {
+	// Count is the number of runs; 0 repeats until a run fails, which
+	// needs UntilFail
+	Count     int
+	Interval  time.Duration
+	UntilFail bool
+}
+
+// repeatStats aggregates the outcome of repeated runs
+type repeatStats struct {
+	Runs      int
+	Failed    []int
+	Durations []time.Duration
+}
+
+// add records the outcome of a run
+func (s *repeatStats) add(err error, duration time.Duration) {
+	s.Runs++
+	s.Durations = append(s.Durations, duration)
+	if err != nil {
+		s.Failed = append(s.Failed, s.Runs)
+	}
+}
+
+// Print writes the aggregate results
+func (s *repeatStats) Print() {
+	runs := "runs"
+	if s.Runs == 1 {
+		runs = "run"
+	}
+	fmt.Printf("Repeat: %d %s, %d ok, %d failed", s.Runs, runs, s.Runs-len(s.Failed), len(s.Failed))
+	if len(s.Failed) > 0 {
+		numbers := make([]string, len(s.Failed))
+		for i, n := range s.Failed {
+			numbers[i] = fmt.Sprint(n)
+		}
+		fmt.Printf(" (%s %s)", runs, strings.Join(numbers, ", "))
+	}
+	fmt.Println()
+	if len(s.Durations) == 0 {
+		return
+	}
+
+	minimum, maximum, total := s.Durations[0], s.Durations[0], time.Duration(0)
+	for _, d := range s.Durations {
+		minimum, maximum, total = min(minimum, d), max(maximum, d), total+d
+	}
+	average := total / time.Duration(len(s.Durations))
+	fmt.Printf("Duration: min %s, avg %s, max %s\n", minimum.Round(time.Millisecond), average.Round(time.Millisecond), maximum.Round(time.Millisecond))
+}
+
+// RunRepeat runs a stored command repeatedly, waiting repeat.Interval
+// between runs, and reports aggregate results. Cooldown and confirmation
+// only apply to the first run. The database is closed while waiting so
+// other afv invocations can use it in the meantime.
+func RunRepeat(db *Database, name string, opts RunOptions, repeat RepeatOptions) error {
+	if repeat.Count < 0 || repeat.Count == 0 && !repeat.UntilFail {
+		return fmt.Errorf("--repeat must be at least 1")
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, forwardedSignals...)
+	defer signal.Stop(interrupt)
+
+	var stats repeatStats
+	var lastErr error
+	interrupted := func() bool {
+		select {
+		case <-interrupt:
+			return true
+		default:
+			return false
+		}
+	}
+	for n := 1; repeat.Count == 0 || n <= repeat.Count; n++ {
+		if n > 1 && repeat.Interval > 0 {
+			if err := db.Release(); err != nil {
+				return err
+			}
+			stopped := false
+			select {
+			case <-interrupt:
+				stopped = true
+			case <-time.After(repeat.Interval):
+			}
+			if err := db.Reopen(); err != nil {
+				return err
+			}
+			if stopped {
+				break
+			}
+		}
+
+		if repeat.Count > 0 {
+			fmt.Printf("=== Run %d/%d\n", n, repeat.Count)
+		} else {
+			fmt.Printf("=== Run %d\n", n)
+		}
+		start := time.Now()
+		err := RunStored(db, name, opts)
+		stats.add(err, time.Since(start))
+		opts.Force = true
+		if err != nil {
+			lastErr = err
+			fmt.Fprintf(os.Stderr, "Error in run %d: %v\n", n, err)
+			if repeat.UntilFail {
+				break
+			}
+		}
+		// A signal during the run was forwarded to the command and also
+		// ends the repetition
+		if interrupted() {
+			break
+		}
+	}
+
+	stats.Print()
+	if len(stats.Failed) > 0 {
+		return fmt.Errorf("%d of %d runs failed: %w", len(stats.Failed), stats.Runs, lastErr)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import "fmt"
+
+// recentRun returns the nth most recent run of the history, 1 being the last
+func recentRun(db *Database, nth int) (*RunRecord, error) {
+	if nth < 1 {
+		return nil, fmt.Errorf("--nth must be at least 1")
+	}
+	records, err := db.GetRunHistory("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run history: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no runs recorded yet")
+	}
+	if nth > len(records) {
+		return nil, fmt.Errorf("only %d run(s) recorded", len(records))
+	}
+	return &records[len(records)-nth], nil
+}
+
+// Rerun runs the nth most recent run again in the same directory with the
+// same arguments, placeholder values and env file
+func Rerun(db *Database, nth int) error {
+	record, err := recentRun(db, nth)
+	if err != nil {
+		return err
+	}
+
+	if record.Name == "" {
+		if len(record.Args) == 0 {
+			return fmt.Errorf("run of '%s' was recorded without its arguments and cannot be rerun", record.Command)
+		}
+		return RunAdhoc(db, record.Args, record.Dir, record.EnvFile, false)
+	}
+
+	fmt.Printf("Rerunning %s (run of %s)\n", record.Name, record.StartedAt.Format(timeLayout))
+	return RunStored(db, record.Name, RunOptions{
+		Dir:       record.Dir,
+		Args:      record.Args,
+		EnvFile:   record.EnvFile,
+		StdinFile: record.StdinFile,
+		Vars:      record.Vars,
+	})
+}
+
+// describeRun returns a one-line summary of a recorded run
+func describeRun(record RunRecord) string {
+	name := record.Name
+	if name == "" {
+		name = "(exec)"
+	}
+	status := "ok"
+	if !record.Success {
+		status = fmt.Sprintf("exit %d", record.ExitCode)
+	}
+	return fmt.Sprintf("%s  %-15s %-8s %s", record.StartedAt.Format(timeLayout), name, status, record.Command)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRerun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses touch")
+	}
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if _, err := recentRun(db, 1); err == nil {
+		t.Error("Expected error without any runs")
+	}
+
+	if err := db.AddCommand("touch", "", "touch", tempDir); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	first, second := filepath.Join(tempDir, "first"), filepath.Join(tempDir, "second")
+	for _, path := range []string{first, second} {
+		if err := RunStored(db, "touch", RunOptions{Args: []string{path}}); err != nil {
+			t.Fatalf("RunStored failed: %v", err)
+		}
+	}
+	os.Remove(first)
+	os.Remove(second)
+
+	// --nth 2 repeats the run before the last one, with its arguments
+	if err := Rerun(db, 2); err != nil {
+		t.Fatalf("Rerun failed: %v", err)
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("Expected the second to last run to be repeated: %v", err)
+	}
+	if _, err := os.Stat(second); err == nil {
+		t.Error("Only the selected run should be repeated")
+	}
+
+	// The rerun is recorded, so it is now the last run
+	record, err := recentRun(db, 1)
+	if err != nil || record.Args[0] != first {
+		t.Errorf("Expected the rerun to be the last run, got %+v, %v", record, err)
+	}
+	if _, err := recentRun(db, 4); err == nil {
+		t.Error("Expected error for a run beyond the history")
+	}
+}
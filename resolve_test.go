@@ -115,7 +115,7 @@ func TestResolveDirectory(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			result, err := resolveDirectory(tt.input)
-			
+
 			if tt.expectError {
 				if err == nil {
 					t.Errorf("Expected error but got none")
@@ -162,7 +162,7 @@ func TestResolveDirectoryPlatformSpecific(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			result, err := resolveDirectory(tt.input)
-			
+
 			if tt.expectError {
 				if err == nil {
 					t.Errorf("Expected error but got none")
@@ -210,7 +210,7 @@ func TestResolveDirectoryEdgeCases(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			result, err := resolveDirectory(tt.input)
-			
+
 			if tt.expectError {
 				if err == nil {
 					t.Errorf("Expected error but got none")
@@ -219,7 +219,7 @@ func TestResolveDirectoryEdgeCases(t *testing.T) {
 				if err != nil {
 					t.Errorf("Unexpected error for %s: %v", tt.description, err)
 				}
-				
+
 				// For "only whitespace" test, the function trims to empty and then
 				// follows the default case which resolves to current directory
 				if tt.input == "   " {
@@ -228,7 +228,7 @@ func TestResolveDirectoryEdgeCases(t *testing.T) {
 						t.Errorf("Expected current directory for whitespace input, got: %s", result)
 					}
 				}
-				
+
 				// For other tests, expect non-empty absolute path
 				if strings.TrimSpace(tt.input) != "" && tt.input != "   " {
 					if result == "" {
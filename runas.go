@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strings"
+)
+
+// validateRunAs checks the user a command runs as
+func validateRunAs(cmd *Command) error {
+	cmd.RunAs = strings.TrimSpace(cmd.RunAs)
+	if cmd.RunAs == "" {
+		return nil
+	}
+	if strings.ContainsAny(cmd.RunAs, " \t\n:") {
+		return fmt.Errorf("invalid user '%s'", cmd.RunAs)
+	}
+	// Scripts are written to afv's own cache directory, which the other
+	// user cannot read
+	if cmd.Script {
+		return fmt.Errorf("script commands cannot run as another user")
+	}
+	return nil
+}
+
+// lookupUser finds a user by name or numeric id
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		if byID, idErr := user.LookupId(name); idErr == nil {
+			return byID, nil
+		}
+		return nil, fmt.Errorf("unknown user '%s'", name)
+	}
+	return u, nil
+}
@@ -0,0 +1,70 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewExecCmdRunAs(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skipf("current user unknown: %v", err)
+	}
+
+	// Running as yourself needs no privileges
+	cmd, _, err := newExecCmd(&Command{Command: "id -u", RunAs: current.Username}, "")
+	if err != nil {
+		t.Fatalf("newExecCmd failed: %v", err)
+	}
+	if cred := cmd.SysProcAttr.Credential; cred == nil || cred.Uid != uint32(os.Getuid()) {
+		t.Errorf("Expected credentials of the current user, got %+v", cred)
+	}
+	if !slices.Contains(cmd.Env, "USER="+current.Username) || !slices.Contains(cmd.Env, "HOME="+current.HomeDir) {
+		t.Errorf("Expected the user's environment, got %v", cmd.Env)
+	}
+	out, err := cmd.Output()
+	if err != nil || strings.TrimSpace(string(out)) != current.Uid {
+		t.Errorf("Expected to run as uid %s, got %q (%v)", current.Uid, out, err)
+	}
+
+	if _, _, err := newExecCmd(&Command{Command: "id", RunAs: "no-such-user-afv"}, ""); err == nil {
+		t.Error("Expected error for an unknown user")
+	}
+
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		return
+	}
+	if os.Geteuid() == 0 {
+		cmd, _, err := newExecCmd(&Command{Command: "id -u", RunAs: nobody.Username}, "")
+		if err != nil {
+			t.Fatalf("newExecCmd failed: %v", err)
+		}
+		out, err := cmd.Output()
+		if err != nil || strings.TrimSpace(string(out)) != nobody.Uid {
+			t.Errorf("Expected to drop to uid %s, got %q (%v)", nobody.Uid, out, err)
+		}
+		return
+	}
+	if _, _, err := newExecCmd(&Command{Command: "id", RunAs: nobody.Username}, ""); err == nil || !strings.Contains(err.Error(), "root") {
+		t.Errorf("Expected switching users without root to fail, got %v", err)
+	}
+}
+
+func TestValidateRunAs(t *testing.T) {
+	cmd := &Command{RunAs: " www-data "}
+	if err := validateRunAs(cmd); err != nil || cmd.RunAs != "www-data" {
+		t.Errorf("Expected a trimmed user, got %q (%v)", cmd.RunAs, err)
+	}
+	if err := validateRunAs(&Command{RunAs: "www data"}); err == nil {
+		t.Error("Expected error for a user with whitespace")
+	}
+	if err := validateRunAs(&Command{RunAs: "www-data", Script: true}); err == nil {
+		t.Error("Expected error for a script run as another user")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// applyRunAs fails on Windows, where switching users needs the user's
+// password
+func applyRunAs(cmd *exec.Cmd, name string) error {
+	return fmt.Errorf("running as user '%s' is not supported on Windows", name)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Example is a documented invocation of a stored command
+type Example struct {
+	Run  string `json:"run" yaml:"run"`
+	Note string `json:"note,omitempty" yaml:"note,omitempty"`
+}
+
+// normalizeExamples trims examples and drops empty ones
+func normalizeExamples(examples []Example) []Example {
+	var normalized []Example
+	for _, ex := range examples {
+		ex.Run = strings.TrimSpace(ex.Run)
+		ex.Note = strings.TrimSpace(ex.Note)
+		if ex.Run != "" {
+			normalized = append(normalized, ex)
+		}
+	}
+	return normalized
+}
+
+// PrintCommandHelp renders the runbook page of a stored command
+func PrintCommandHelp(cmd *Command) {
+	fmt.Printf("%s - %s\n\n", cmd.Name, cmd.Description)
+	if cmd.Script {
+		fmt.Println("Script:")
+		for _, line := range strings.Split(strings.TrimRight(cmd.Command, "\n"), "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	} else if len(cmd.Steps) > 0 {
+		fmt.Println("Steps:")
+		for i, step := range cmd.Steps {
+			fmt.Printf("  %d. %s\n", i+1, step)
+		}
+	} else {
+		fmt.Printf("Command: %s\n", cmd.Command)
+	}
+	if cmd.PreHook != "" {
+		fmt.Printf("Pre hook: %s\n", cmd.PreHook)
+	}
+	if cmd.PostHook != "" {
+		fmt.Printf("Post hook: %s\n", cmd.PostHook)
+	}
+	if cmd.WorkingDir != "" {
+		fmt.Printf("Working directory: %s\n", cmd.WorkingDir)
+	}
+	if cmd.RunAs != "" {
+		fmt.Printf("Runs as: %s\n", cmd.RunAs)
+	}
+	if len(cmd.Tags) > 0 {
+		fmt.Printf("Tags: %s\n", strings.Join(cmd.Tags, ", "))
+	}
+	if cmd.Deprecated != nil {
+		fmt.Printf("Deprecated: %s\n", deprecationMessage(cmd))
+	}
+	if cmd.Confirm {
+		fmt.Println("Asks for confirmation before running (skip with --force)")
+	}
+
+	if len(cmd.Requires) > 0 {
+		fmt.Println("\nRequires:")
+		for _, req := range cmd.Requires {
+			fmt.Printf("  %s (service)\n", req)
+		}
+	}
+
+	if cmd.Notes != "" {
+		fmt.Println("\nNotes:")
+		for _, line := range strings.Split(cmd.Notes, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+
+	fmt.Println("\nExamples:")
+	if len(cmd.Examples) == 0 {
+		fmt.Printf("  afv run --name %s\n", cmd.Name)
+		return
+	}
+	for _, ex := range cmd.Examples {
+		fmt.Printf("  %s\n", ex.Run)
+		if ex.Note != "" {
+			fmt.Printf("      %s\n", ex.Note)
+		}
+	}
+}
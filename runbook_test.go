@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestInsertCommandNormalizesRunbook(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	err := db.InsertCommand(Command{
+		Name:    "deploy",
+		Command: "make deploy",
+		Notes:   "  Needs VPN.\n",
+		Examples: []Example{
+			{Run: " afv run --name deploy ", Note: " Staging "},
+			{Run: "  ", Note: "dropped"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("InsertCommand failed: %v", err)
+	}
+
+	cmd, err := db.GetCommand("deploy")
+	if err != nil {
+		t.Fatalf("GetCommand failed: %v", err)
+	}
+	if cmd.Notes != "Needs VPN." {
+		t.Errorf("Expected trimmed notes, got %q", cmd.Notes)
+	}
+	expected := []Example{{Run: "afv run --name deploy", Note: "Staging"}}
+	if !reflect.DeepEqual(cmd.Examples, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, cmd.Examples)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runLogLayout names the output log of a run after its start time
+const runLogLayout = "run-20060102-150405.000.log"
+
+// createRunLog creates the file capturing the output of a run in dir, the
+// log directory of the command or of ad-hoc runs
+func createRunLog(dir string, startedAt time.Time) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+	base := strings.TrimSuffix(startedAt.Format(runLogLayout), ".log")
+	path := base + ".log"
+	// Runs starting in the same millisecond get numbered logs
+	for n := 2; ; n++ {
+		f, err := os.OpenFile(filepath.Join(dir, path), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create run log: %v", err)
+		}
+		path = fmt.Sprintf("%s-%d.log", base, n)
+	}
+}
+
+// RunLogs returns the runs of a command whose output log still exists,
+// oldest first
+func RunLogs(db *Database, name string) ([]RunRecord, error) {
+	records, err := db.GetRunHistory(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run history: %v", err)
+	}
+	var logged []RunRecord
+	for _, record := range records {
+		if record.LogFile == "" {
+			continue
+		}
+		if _, err := os.Stat(record.LogFile); err == nil {
+			logged = append(logged, record)
+		}
+	}
+	return logged, nil
+}
+
+// ShowLogs prints the output of a background job given by id, or the latest
+// output of a command given by name: its last run or its most recent job,
+// whichever started later. follow keeps printing the output of a running job.
+func ShowLogs(db *Database, ref string, w io.Writer, follow bool) error {
+	if _, err := strconv.ParseUint(ref, 10, 64); err == nil {
+		job, err := db.FindJob(ref)
+		if err != nil {
+			return err
+		}
+		return PrintJobLog(job, w, follow)
+	}
+
+	runs, err := RunLogs(db, ref)
+	if err != nil {
+		return err
+	}
+	job, _ := db.FindJob(ref)
+	if job != nil {
+		jobStart, _ := time.ParseInLocation(timeLayout, job.StartedAt, time.Local)
+		if len(runs) == 0 || jobStart.After(runs[len(runs)-1].StartedAt) {
+			return PrintJobLog(job, w, follow)
+		}
+	}
+	if len(runs) == 0 {
+		return fmt.Errorf("no output recorded for '%s'", ref)
+	}
+
+	f, err := os.Open(runs[len(runs)-1].LogFile)
+	if err != nil {
+		return fmt.Errorf("failed to open run log: %v", err)
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunLogs(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("greet", "", "echo hello", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := ShowLogs(db, "greet", &bytes.Buffer{}, false); err == nil {
+		t.Error("Expected error before the first run")
+	}
+
+	if err := RunStored(db, "greet", RunOptions{}); err != nil {
+		t.Fatalf("RunStored failed: %v", err)
+	}
+	if err := RunStored(db, "greet", RunOptions{Args: []string{"again"}}); err != nil {
+		t.Fatalf("RunStored failed: %v", err)
+	}
+	if err := RunStored(db, "greet", RunOptions{NoLog: true}); err != nil {
+		t.Fatalf("RunStored failed: %v", err)
+	}
+
+	runs, err := RunLogs(db, "greet")
+	if err != nil || len(runs) != 2 {
+		t.Fatalf("Expected two captured runs, got %d, %v", len(runs), err)
+	}
+
+	var out bytes.Buffer
+	if err := ShowLogs(db, "greet", &out, false); err != nil {
+		t.Fatalf("ShowLogs failed: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != "hello again" {
+		t.Errorf("Expected the output of the last captured run, got %q", out.String())
+	}
+}
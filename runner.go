@@ -0,0 +1,554 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// resolveRunDirectory determines the working directory for a run. A runtime
+// override takes priority over the stored directory, which takes priority
+// over the current directory.
+func resolveRunDirectory(command *Command, override string) (string, error) {
+	if override != "" {
+		// Paths inside a WSL distro are resolved by the distro
+		if command.Wsl != "" && isLinuxPath(override) {
+			slog.Info("working directory", "dir", override, "from", "--dir", "wsl", command.Wsl)
+			return override, nil
+		}
+		// Use specified working directory (resolve shortcuts)
+		resolvedDir, err := resolveDirectory(override)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve working directory: %v", err)
+		}
+		slog.Info("working directory", "dir", resolvedDir, "from", "--dir")
+		return resolvedDir, nil
+	}
+	if command.WorkingDir != "" {
+		// Use stored working directory
+		slog.Info("working directory", "dir", command.WorkingDir, "from", "stored")
+		return command.WorkingDir, nil
+	}
+	// Use current directory
+	cwd, _ := os.Getwd()
+	slog.Info("working directory", "dir", cwd, "from", "current directory")
+	return cwd, nil
+}
+
+// newExecCmd parses a stored command line, or writes a stored script, into a
+// child process running in dir as the command's user. For a script it also
+// returns the file written, to be removed with removeScript once the
+// process has ended.
+func newExecCmd(command *Command, dir string) (*exec.Cmd, string, error) {
+	cmd, script, err := newChildCmd(command, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if command.RunAs != "" {
+		if err := applyRunAs(cmd, command.RunAs); err != nil {
+			removeScript(script)
+			return nil, "", err
+		}
+	}
+	return cmd, script, nil
+}
+
+// newChildCmd builds the child process of newExecCmd
+func newChildCmd(command *Command, dir string) (*exec.Cmd, string, error) {
+	if len(command.Steps) > 0 {
+		return nil, "", fmt.Errorf("command '%s' consists of steps and can only be run in the foreground", command.Name)
+	}
+	parts := strings.Fields(command.Command)
+	if len(parts) == 0 {
+		return nil, "", fmt.Errorf("empty command")
+	}
+	env, err := childEnv(command, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if command.Script {
+		return newScriptCmd(command, dir, env)
+	}
+	if command.Wsl != "" {
+		return newWslCmd(command, dir, parts, env), "", nil
+	}
+	if command.Container != "" {
+		cmd, err := newContainerCmd(command, dir, env)
+		return cmd, "", err
+	}
+
+	cmd := exec.Command(parts[0], parts[1:]...)
+	if path, ok := lookPathIn(parts[0], pathPrependDirs(command, dir)); ok {
+		cmd = exec.Command(path, parts[1:]...)
+		cmd.Args[0] = parts[0]
+	}
+
+	// Set working directory if specified
+	if dir != "" {
+		cmd.Dir = dir
+	}
+
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	return cmd, "", nil
+}
+
+// commandLine returns a stored command line with extra arguments appended,
+// quoting those that would not survive being split on whitespace
+func commandLine(line string, args []string) string {
+	parts := []string{line}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`") {
+			arg = shellQuote("bash", arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// RunOptions holds the run-time overrides of 'afv run'
+type RunOptions struct {
+	Dir       string
+	Force     bool
+	Wait      bool
+	Exclusive bool
+	WaitFor   string
+	WaitAfter string
+	StopDeps  bool
+	// Args are appended to the stored command line, as given after --
+	Args []string
+	// EnvFile overrides the dotenv file of the command
+	EnvFile string
+	// StdinFile overrides the file fed to the command's standard input
+	StdinFile string
+	// Timeout overrides the stored timeout, e.g. 30s
+	Timeout string
+	// Container overrides the image the command runs in
+	Container string
+	// Env adds to or overrides the command's environment variables
+	Env map[string]string
+	// FromStep and OnlyStep select the steps of a step command to run,
+	// counting from 1
+	FromStep, OnlyStep int
+	// Time shows how long each phase of the run took
+	Time bool
+	// Notify sends a notification when the run finishes, whatever the
+	// command's notification rules say
+	Notify bool
+	// Edit opens the command line in the user's editor and runs the edited
+	// version once without storing it
+	Edit bool
+	// NoHooks skips the command's pre and post hooks
+	NoHooks bool
+	// NoLog leaves the output uncaptured, so the command writes to the
+	// terminal directly
+	NoLog bool
+	// Stdout and Stderr replace the terminal for the run's output, as in
+	// parallel runs; stdin is then not connected and placeholder values are
+	// not asked for
+	Stdout, Stderr io.Writer `json:"-"`
+	// Vars fill {{name}} placeholders; missing values are asked for on a
+	// terminal. Remember stores the values used as the command's defaults.
+	Vars     map[string]string
+	Remember bool
+	// Confirmed means a command marked with --confirm was confirmed before
+	// its run was started in the background
+	Confirmed bool
+}
+
+// preparedCommand is a stored command ready to execute
+type preparedCommand struct {
+	*Command
+	// Display is the command line to show and record, which keeps
+	// {{env:NAME}} values hidden
+	Display string
+	// Vars holds the {{name}} values that were filled in
+	Vars map[string]string
+	// StepDisplays holds the display line of each step of a step command
+	StepDisplays []string
+}
+
+// prepareCommand inlines references to other stored commands, applies the
+// env file, stdin file, container and environment overrides and fills
+// placeholders
+func prepareCommand(db *Database, command *Command, opts RunOptions, prompt valuePrompter) (*preparedCommand, error) {
+	if len(command.Steps) > 0 {
+		return prepareSteps(db, command, opts, prompt)
+	}
+	expanded, err := ExpandCommand(db, command)
+	if err != nil {
+		return nil, err
+	}
+	if opts.EnvFile != "" || opts.StdinFile != "" || opts.Container != "" || len(opts.Env) > 0 {
+		if expanded == command {
+			copied := *command
+			expanded = &copied
+		}
+		expanded.EnvFile = firstNonEmpty(opts.EnvFile, expanded.EnvFile)
+		expanded.StdinFile = firstNonEmpty(opts.StdinFile, expanded.StdinFile)
+		expanded.Container = firstNonEmpty(opts.Container, expanded.Container)
+		if err := validateContainer(expanded); err != nil {
+			return nil, err
+		}
+		if len(opts.Env) > 0 {
+			slog.Debug("environment overrides", "name", command.Name, "keys", slices.Sorted(maps.Keys(opts.Env)))
+			expanded.Env = maps.Clone(expanded.Env)
+			if expanded.Env == nil {
+				expanded.Env = map[string]string{}
+			}
+			maps.Copy(expanded.Env, opts.Env)
+		}
+	}
+
+	if !placeholderPattern.MatchString(expanded.Command) {
+		return &preparedCommand{Command: expanded, Display: expanded.Command}, nil
+	}
+	filled, err := fillPlaceholders(expanded, opts.Vars, prompt)
+	if err != nil {
+		return nil, err
+	}
+	if expanded == command {
+		copied := *command
+		expanded = &copied
+	}
+	expanded.Command = filled.Line
+	if opts.Remember && len(filled.Used) > 0 {
+		if err := rememberVars(db, command.Name, filled.Used); err != nil {
+			return nil, err
+		}
+	}
+	return &preparedCommand{Command: expanded, Display: filled.Display, Vars: filled.Used}, nil
+}
+
+// confirmRun asks before running a command marked with --confirm, showing
+// the resolved command line. Force skips the question.
+func confirmRun(command *Command, line string, opts RunOptions) error {
+	if !command.Confirm || opts.Force || opts.Confirmed {
+		return nil
+	}
+	if opts.Stdout != nil {
+		return fmt.Errorf("command '%s' asks for confirmation; use --force to run it without asking", command.Name)
+	}
+	fmt.Printf("Command '%s' will run:\n  %s\n", command.Name, line)
+	if !confirm("Are you sure?") {
+		return fmt.Errorf("run of '%s' cancelled", command.Name)
+	}
+	return nil
+}
+
+// RunStored runs a stored command in the foreground with its cooldown,
+// singleton lock, required services, readiness probes, health check and
+// cleanup, and records the run in the history
+func RunStored(db *Database, name string, opts RunOptions) error {
+	command, err := db.GetCommand(name)
+	if err != nil {
+		return fmt.Errorf("failed to get command: %v", err)
+	}
+	if err := checkDeprecation(command); err != nil {
+		return err
+	}
+	if command.Eval {
+		return fmt.Errorf("command '%s' must be evaluated by your shell to take effect; set up the wrapper with eval \"$(afv shell-init bash)\"", command.Name)
+	}
+	if opts.Edit {
+		if command, err = editForRun(command); err != nil {
+			return err
+		}
+	}
+
+	timeout, err := parseTimeout(firstNonEmpty(opts.Timeout, command.Timeout))
+	if err != nil {
+		return err
+	}
+
+	startedAt := time.Now()
+	if !opts.Force {
+		if err := checkCooldown(command, startedAt); err != nil {
+			return err
+		}
+	}
+
+	// Singleton commands, and exclusive runs of any command, hold a
+	// per-command lock for the duration of the run
+	if command.Singleton || opts.Exclusive {
+		var lock *CommandLock
+		lockDir := db.LockDir()
+		acquire := func() (err error) {
+			lock, err = AcquireCommandLock(lockDir, command.Name, opts.Wait)
+			return err
+		}
+		if opts.Wait && opts.Stdout == nil {
+			// The running instance needs the database once it finishes
+			err = releaseDuring(db, acquire)
+		} else {
+			err = acquire()
+		}
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+
+	// Determine working directory with resolution
+	cmdDir, err := resolveRunDirectory(command, opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	stdout, stderr, stdin := io.Writer(os.Stdout), io.Writer(os.Stderr), io.Reader(os.Stdin)
+	prompt := terminalPrompter()
+	if opts.Stdout != nil {
+		stdout, stderr, stdin, prompt = opts.Stdout, opts.Stderr, nil, nil
+	}
+
+	prepared, err := prepareCommand(db, command, opts, prompt)
+	if err != nil {
+		return err
+	}
+	expanded := prepared.Command
+
+	var steps []int
+	if len(expanded.Steps) > 0 {
+		if len(opts.Args) > 0 {
+			return fmt.Errorf("arguments cannot be passed to command '%s' because it consists of steps", command.Name)
+		}
+		if steps, err = selectSteps(len(expanded.Steps), opts.FromStep, opts.OnlyStep); err != nil {
+			return err
+		}
+	} else if opts.FromStep != 0 || opts.OnlyStep != 0 {
+		return fmt.Errorf("command '%s' has no steps", command.Name)
+	}
+
+	line := commandLine(prepared.Display, opts.Args)
+	if err := confirmRun(command, line, opts); err != nil {
+		return err
+	}
+	timer := newRunTimer()
+	fmt.Fprintf(stdout, "Executing: %s\n", line)
+	if cmdDir != "" {
+		fmt.Fprintf(stdout, "Working directory: %s\n", cmdDir)
+	}
+
+	if expanded.StdinFile != "" {
+		file, err := openStdinFile(expanded.StdinFile, cmdDir)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		stdin = file
+	}
+
+	// Start required services that are not running yet
+	var startedDeps []string
+	if len(command.Requires) > 0 {
+		err = timer.phase("requirements", func() (err error) {
+			startedDeps, err = StartRequirements(db, command)
+			return err
+		})
+		if opts.StopDeps {
+			defer StopServices(db, startedDeps)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if waitFor := firstNonEmpty(opts.WaitFor, command.WaitFor); waitFor != "" {
+		if err := timer.phase("wait for "+waitFor, func() error { return WaitForPort(waitFor) }); err != nil {
+			return err
+		}
+	}
+
+	// Capture the output of the hooks and the command while still showing it
+	console := stdout
+	var logFile string
+	if !opts.NoLog {
+		runLog, err := createRunLog(db.CommandLogDir(command.Name), time.Now())
+		if err != nil {
+			return err
+		}
+		defer runLog.Close()
+		logFile = runLog.Name()
+		mux := newOutputMux(runLog)
+		stdout, stderr = mux.Stream(stdout), mux.Stream(stderr)
+	}
+
+	if command.PreHook != "" && !opts.NoHooks {
+		err := timer.phase("pre hook", func() error {
+			return runHook(expanded, "pre", command.PreHook, cmdDir, stdout, stderr)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Parse the command; steps are parsed one by one as they run
+	var cmd *exec.Cmd
+	if steps == nil {
+		var script string
+		cmd, script, err = newExecCmd(expanded, cmdDir)
+		if err != nil {
+			return err
+		}
+		defer removeScript(script)
+		cmd.Args = append(cmd.Args, opts.Args...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		cmd.Stdin = stdin
+	}
+
+	// Record the run before starting so the cooldown also covers runs in progress
+	if err := db.RecordRun(command.Name, startedAt); err != nil {
+		return fmt.Errorf("failed to record run: %v", err)
+	}
+
+	run := func() error {
+		if steps != nil {
+			return runSteps(prepared, cmdDir, steps, stdout, stderr, stdin, timeout, timer)
+		}
+		return runForeground(cmd, expanded.Limits(), timeout, timeoutGrace)
+	}
+	runStart := time.Now()
+	s := startSpan("exec.run", "name", command.Name, "command", line, "dir", cmdDir)
+	if opts.Stdout == nil {
+		// Other afv processes, such as a second run of a singleton command
+		// or afv list, need the database while this one runs
+		err = releaseDuring(db, run)
+	} else {
+		// Runs sharing the process, as in parallel runs, keep it open
+		err = run()
+	}
+	duration := time.Since(runStart)
+	if steps == nil {
+		timer.add("command", duration)
+	}
+	code := exitCode(err)
+	s.End(err, "exit_code", code)
+	if waitAfter := firstNonEmpty(opts.WaitAfter, command.WaitAfter); err == nil && waitAfter != "" {
+		err = timer.phase("wait after "+waitAfter, func() error { return WaitForPort(waitAfter) })
+	}
+	if err == nil && command.HealthCheck != nil {
+		err = timer.phase("health check", func() error { return RunHealthCheck(command.HealthCheck, cmdDir) })
+	}
+	if err == nil && command.PostHook != "" && !opts.NoHooks {
+		err = timer.phase("post hook", func() error {
+			return runHook(expanded, "post", command.PostHook, cmdDir, stdout, stderr)
+		})
+	}
+
+	// Cleanup runs regardless of the outcome, like a defer
+	if command.Cleanup != "" {
+		cleanupErr := timer.phase("cleanup", func() error { return RunCleanup(command, cmdDir) })
+		if cleanupErr != nil {
+			if err != nil {
+				warn("%v", cleanupErr)
+			} else {
+				err = cleanupErr
+			}
+		}
+	}
+
+	record := RunRecord{
+		Name:      command.Name,
+		Command:   line,
+		Dir:       cmdDir,
+		StartedAt: runStart,
+		Duration:  duration,
+		Elapsed:   timer.Elapsed(),
+		ExitCode:  code,
+		Success:   err == nil,
+		Args:      opts.Args,
+		Vars:      prepared.Vars,
+		EnvFile:   opts.EnvFile,
+		StdinFile: opts.StdinFile,
+		LogFile:   logFile,
+	}
+	timer.Print(console, err, opts.Time)
+	if recordErr := db.AddRunRecord(record); recordErr != nil {
+		warn("failed to record run history: %v", recordErr)
+	}
+	if notifyErr := NotifyRun(command, record, opts.Notify); notifyErr != nil {
+		warn("%v", notifyErr)
+	}
+	return err
+}
+
+// releaseDuring closes the database while fn runs and opens it again
+// afterwards. A failure to reopen is added to the error of fn rather than
+// replacing it, so the exit status of a failed command survives.
+func releaseDuring(db *Database, fn func() error) error {
+	if err := db.Release(); err != nil {
+		return fmt.Errorf("failed to close database: %v", err)
+	}
+	err := fn()
+	if reopenErr := db.Reopen(); reopenErr != nil {
+		if err == nil {
+			return reopenErr
+		}
+		return fmt.Errorf("%w (and %v)", err, reopenErr)
+	}
+	return err
+}
+
+// RunAdhoc runs args as a command in dir without storing it, loading envFile
+// (relative to dir) if set, and records the run in the history. Unless noLog
+// is set, the output is captured like that of a stored command.
+func RunAdhoc(db *Database, args []string, dir, envFile string, noLog bool) error {
+	line := strings.Join(args, " ")
+	fmt.Printf("Executing: %s\n", line)
+	fmt.Printf("Working directory: %s\n", dir)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	if envFile != "" {
+		vars, err := LoadEnvFile(envFile, dir)
+		if err != nil {
+			return err
+		}
+		cmd.Env = append(os.Environ(), vars...)
+	}
+
+	var logFile string
+	if !noLog {
+		runLog, err := createRunLog(db.AdhocLogDir(), time.Now())
+		if err != nil {
+			return err
+		}
+		defer runLog.Close()
+		logFile = runLog.Name()
+		mux := newOutputMux(runLog)
+		cmd.Stdout, cmd.Stderr = mux.Stream(cmd.Stdout), mux.Stream(cmd.Stderr)
+	}
+
+	runStart := time.Now()
+	s := startSpan("exec.exec", "command", line, "dir", dir)
+	err := releaseDuring(db, func() error { return runForeground(cmd, ResourceLimits{}, 0, 0) })
+	s.End(err, "exit_code", exitCode(err))
+	record := RunRecord{
+		Command:   line,
+		Dir:       dir,
+		StartedAt: runStart,
+		Duration:  time.Since(runStart),
+		ExitCode:  exitCode(err),
+		Success:   err == nil,
+		Args:      args,
+		EnvFile:   envFile,
+		LogFile:   logFile,
+	}
+	if recordErr := db.AddRunRecord(record); recordErr != nil {
+		warn("failed to record run history: %v", recordErr)
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestReleaseDuringKeepsRunError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+	path := db.Path()
+
+	// Another process holds the database when the command ends, so it
+	// cannot be reopened
+	var holder *bbolt.DB
+	err := releaseDuring(db, func() error {
+		var err error
+		if holder, err = bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second}); err != nil {
+			t.Fatalf("Failed to hold the database: %v", err)
+		}
+		return exec.Command("sh", "-c", "exit 3").Run()
+	})
+	holder.Close()
+
+	if code := exitCode(err); code != 3 {
+		t.Errorf("Expected the command's exit status 3, got %d (%v)", code, err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "failed to open database") {
+		t.Errorf("Expected the reopen failure to be reported, got %v", err)
+	}
+	if err := db.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Task runner files afv import can read recipes and tasks from, looked up
+// in the working directory when no file is given
+var (
+	justfileNames = []string{"justfile", "Justfile", ".justfile"}
+	taskfileNames = []string{"Taskfile.yml", "taskfile.yml", "Taskfile.yaml", "taskfile.yaml", "Taskfile.dist.yml", "Taskfile.dist.yaml"}
+)
+
+// findRunnerFile returns the path of the first of names present in dir
+func findRunnerFile(dir string, names []string) (string, error) {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no %s found in %s", names[0], dir)
+}
+
+// loadRunnerFile reads the recipes of the justfile at path, or the tasks
+// of the Taskfile with taskfile set, as commands with the line of each.
+// Without a path the file is looked up in the working directory.
+func loadRunnerFile(path string, taskfile bool) ([]Command, []int, error) {
+	parse, names := parseJustfile, justfileNames
+	if taskfile {
+		parse, names = parseTaskfile, taskfileNames
+	}
+	if path == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get current directory: %v", err)
+		}
+		if path, err = findRunnerFile(cwd, names); err != nil {
+			return nil, nil, err
+		}
+	}
+	return parse(path)
+}
+
+// runnerCommand returns the command running target with tool. The file is
+// only named when the command runs elsewhere than the file's directory,
+// where the tool finds it by itself.
+func runnerCommand(tool, fileFlag, path, dir, target string) string {
+	if dir == filepath.Dir(path) {
+		return tool + " " + target
+	}
+	return fmt.Sprintf("%s %s %s %s", tool, fileFlag, shellQuote("sh", path), target)
+}
+
+// justRecipePattern matches a recipe header: an optional quiet marker, the
+// name, the parameters and the colon before the dependencies. Assignments
+// and settings (:=) are told apart by the character following the colon.
+var justRecipePattern = regexp.MustCompile(`^@?([A-Za-z_][A-Za-z0-9_-]*)((?:\s+[^:]*)?)\s*:($|[^=].*$)`)
+
+// justAttributePattern matches one attribute with an optional argument,
+// given as [name('arg')] or [name: 'arg']
+var justAttributePattern = regexp.MustCompile(`([a-z-]+)\s*(?:\(\s*(.*?)\s*\)|:\s*(.*?)\s*)?(?:,|$)`)
+
+// parseJustfile reads the public recipes of a justfile as commands run
+// through just, with the line of each recipe. Doc comments and [doc]
+// become descriptions, [group] tags, [confirm] confirmations and recipe
+// parameters placeholders.
+func parseJustfile(path string) ([]Command, []int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve path: %v", err)
+	}
+	base := filepath.Dir(abs)
+
+	var commands []Command
+	var lines []int
+	var doc string
+	var attrs [][2]string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		switch {
+		case line == "":
+			doc, attrs = "", nil
+			continue
+		case strings.HasPrefix(line, " "), strings.HasPrefix(line, "\t"):
+			// Recipe bodies and continued expressions
+			continue
+		case strings.HasPrefix(line, "#"):
+			doc = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			continue
+		case strings.HasPrefix(line, "["):
+			for _, m := range justAttributePattern.FindAllStringSubmatch(strings.Trim(line, "[]"), -1) {
+				attrs = append(attrs, [2]string{m[1], unquoteJust(m[2] + m[3])})
+			}
+			continue
+		}
+
+		m := justRecipePattern.FindStringSubmatch(line)
+		if m == nil {
+			doc, attrs = "", nil
+			continue
+		}
+		cmd := Command{Name: m[1], Description: doc, WorkingDir: base}
+		private := strings.HasPrefix(cmd.Name, "_")
+		for _, attr := range attrs {
+			switch attr[0] {
+			case "private":
+				private = true
+			case "doc":
+				cmd.Description = attr[1]
+			case "group":
+				cmd.Tags = append(cmd.Tags, attr[1])
+			case "confirm":
+				cmd.Confirm = true
+			case "no-cd":
+				cmd.WorkingDir = ""
+			case "working-directory":
+				cmd.WorkingDir = filepath.Join(base, attr[1])
+			}
+		}
+		doc, attrs = "", nil
+		if private {
+			continue
+		}
+
+		target := cmd.Name
+		for _, param := range splitJustParams(m[2]) {
+			name, def, hasDefault := strings.Cut(strings.TrimLeft(param, "+*$"), "=")
+			if !varNamePattern.MatchString(name) {
+				continue
+			}
+			target += " {{" + name + "}}"
+			switch {
+			case hasDefault:
+				if cmd.Vars == nil {
+					cmd.Vars = map[string]string{}
+				}
+				cmd.Vars[name] = unquoteJust(def)
+			case strings.HasPrefix(param, "*"):
+				// Variadic parameters marked * may be left empty
+				if cmd.Vars == nil {
+					cmd.Vars = map[string]string{}
+				}
+				cmd.Vars[name] = ""
+			}
+		}
+		cmd.Command = runnerCommand("just", "--justfile", abs, cmd.WorkingDir, target)
+		commands = append(commands, cmd)
+		lines = append(lines, i+1)
+	}
+	return commands, lines, nil
+}
+
+// splitJustParams splits recipe parameters at whitespace outside quotes
+// and parentheses
+func splitJustParams(s string) []string {
+	var params []string
+	var current strings.Builder
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"', c == '\'', c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case (c == ' ' || c == '\t') && depth == 0:
+			if current.Len() > 0 {
+				params = append(params, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteByte(c)
+	}
+	if current.Len() > 0 {
+		params = append(params, current.String())
+	}
+	return params
+}
+
+// unquoteJust strips the quotes of a just string literal
+func unquoteJust(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// taskDefinition holds the fields of a go-task task that map to a command
+type taskDefinition struct {
+	Desc     string    `yaml:"desc"`
+	Summary  string    `yaml:"summary"`
+	Dir      string    `yaml:"dir"`
+	Internal bool      `yaml:"internal"`
+	Prompt   yaml.Node `yaml:"prompt"`
+}
+
+// parseTaskfile reads the public tasks of a go-task Taskfile as commands
+// run through task, with the line of each task. Task names like docker:build
+// land in afv namespaces of the same name.
+func parseTaskfile(path string) ([]Command, []int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve path: %v", err)
+	}
+	base := filepath.Dir(abs)
+
+	var file struct {
+		Tasks yaml.Node `yaml:"tasks"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if file.Tasks.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("%s declares no tasks", path)
+	}
+
+	var commands []Command
+	var lines []int
+	for i := 0; i+1 < len(file.Tasks.Content); i += 2 {
+		key, value := file.Tasks.Content[i], file.Tasks.Content[i+1]
+		// Tasks given as a command or a list of commands have no settings
+		var task taskDefinition
+		if value.Kind == yaml.MappingNode {
+			if err := value.Decode(&task); err != nil {
+				return nil, nil, fmt.Errorf("%s: task '%s': %v", path, key.Value, err)
+			}
+		}
+		if task.Internal {
+			continue
+		}
+
+		cmd := Command{Name: key.Value, Description: task.Desc, WorkingDir: base, Confirm: !task.Prompt.IsZero()}
+		if cmd.Description == "" {
+			cmd.Description, _, _ = strings.Cut(strings.TrimSpace(task.Summary), "\n")
+		}
+		// Directories computed by task templates are left to task
+		if task.Dir != "" && !strings.Contains(task.Dir, "{{") {
+			cmd.WorkingDir = task.Dir
+			if !filepath.IsAbs(task.Dir) {
+				cmd.WorkingDir = filepath.Join(base, task.Dir)
+			}
+		}
+		cmd.Command = runnerCommand("task", "--taskfile", abs, cmd.WorkingDir, key.Value)
+		commands = append(commands, cmd)
+		lines = append(lines, key.Line)
+	}
+	return commands, lines, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseJustfile(t *testing.T) {
+	dir := t.TempDir()
+	justfile := `set shell := ["bash", "-c"]
+version := "1.2.3"
+alias b := build
+
+# Build the binary
+build:
+    go build ./...
+
+[group('ci')]
+[confirm]
+deploy env="staging" *flags: build
+    ./deploy.sh {{env}} {{flags}}
+
+[private]
+helper:
+    true
+
+_hidden:
+    true
+
+[no-cd]
+[doc('Format the files in the current directory')]
+fmt:
+    gofmt -w .
+
+[working-directory: 'web']
+serve port:
+    npm run serve -- --port {{port}}
+`
+	path := filepath.Join(dir, "justfile")
+	if err := os.WriteFile(path, []byte(justfile), 0644); err != nil {
+		t.Fatalf("Failed to write justfile: %v", err)
+	}
+
+	commands, lines, err := parseJustfile(path)
+	if err != nil {
+		t.Fatalf("parseJustfile failed: %v", err)
+	}
+	if len(commands) != 4 {
+		t.Fatalf("Expected build, deploy, fmt and serve, got %+v", commands)
+	}
+
+	build := commands[0]
+	if build.Name != "build" || build.Description != "Build the binary" || build.Command != "just build" || build.WorkingDir != dir || lines[0] != 6 {
+		t.Errorf("Unexpected build %+v at line %d", build, lines[0])
+	}
+
+	deploy := commands[1]
+	if deploy.Command != "just deploy {{env}} {{flags}}" || !deploy.Confirm || len(deploy.Tags) != 1 || deploy.Tags[0] != "ci" {
+		t.Errorf("Unexpected deploy %+v", deploy)
+	}
+	if deploy.Vars["env"] != "staging" || deploy.Vars["flags"] != "" {
+		t.Errorf("Expected parameter defaults as vars, got %v", deploy.Vars)
+	}
+
+	fmtCmd := commands[2]
+	want := "just --justfile '" + path + "' fmt"
+	if fmtCmd.Description != "Format the files in the current directory" || fmtCmd.WorkingDir != "" || fmtCmd.Command != want {
+		t.Errorf("Unexpected fmt %+v", fmtCmd)
+	}
+
+	serve := commands[3]
+	if serve.WorkingDir != filepath.Join(dir, "web") || serve.Command != "just --justfile '"+path+"' serve {{port}}" {
+		t.Errorf("Unexpected serve %+v", serve)
+	}
+	if _, ok := serve.Vars["port"]; ok {
+		t.Errorf("Expected a required parameter without a default, got %v", serve.Vars)
+	}
+}
+
+func TestParseTaskfile(t *testing.T) {
+	dir := t.TempDir()
+	taskfile := `version: '3'
+
+tasks:
+  build:
+    desc: Build the binary
+    cmds:
+      - go build ./...
+  docker:build:
+    summary: |
+      Build the image.
+
+      Uses the Dockerfile at the root.
+    dir: docker
+    cmds:
+      - docker build .
+  lint: golangci-lint run
+  release:
+    prompt: Publish a release?
+    cmds:
+      - goreleaser
+  setup:
+    internal: true
+    cmds:
+      - go mod download
+`
+	path := filepath.Join(dir, "Taskfile.yml")
+	if err := os.WriteFile(path, []byte(taskfile), 0644); err != nil {
+		t.Fatalf("Failed to write Taskfile: %v", err)
+	}
+
+	found, err := findRunnerFile(dir, taskfileNames)
+	if err != nil || found != path {
+		t.Fatalf("Expected to find %s, got %q, %v", path, found, err)
+	}
+
+	commands, lines, err := parseTaskfile(path)
+	if err != nil {
+		t.Fatalf("parseTaskfile failed: %v", err)
+	}
+	if len(commands) != 4 {
+		t.Fatalf("Expected build, docker:build, lint and release, got %+v", commands)
+	}
+	if build := commands[0]; build.Description != "Build the binary" || build.Command != "task build" || build.WorkingDir != dir || lines[0] != 4 {
+		t.Errorf("Unexpected build %+v at line %d", build, lines[0])
+	}
+	docker := commands[1]
+	if docker.Name != "docker:build" || docker.Description != "Build the image." || docker.WorkingDir != filepath.Join(dir, "docker") {
+		t.Errorf("Unexpected docker:build %+v", docker)
+	}
+	if docker.Command != "task --taskfile '"+path+"' docker:build" {
+		t.Errorf("Expected the Taskfile to be named for another directory, got %q", docker.Command)
+	}
+	if lint := commands[2]; lint.Name != "lint" || lint.Command != "task lint" {
+		t.Errorf("Unexpected lint %+v", lint)
+	}
+	if release := commands[3]; !release.Confirm {
+		t.Errorf("Expected a task with a prompt to ask for confirmation, got %+v", release)
+	}
+
+	if _, err := findRunnerFile(t.TempDir(), justfileNames); err == nil {
+		t.Error("Expected an error without a justfile")
+	}
+}
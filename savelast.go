@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// lastCommandEnv is the environment variable the shell hook exports
+const lastCommandEnv = "AFV_LAST_COMMAND"
+
+// saveLastHooks holds the per-shell snippets that export the previous command
+var saveLastHooks = map[string]string{
+	"bash": `__afv_save_last() {
+  export ` + lastCommandEnv + `="$(HISTTIMEFORMAT= history 1 | sed -e 's/^ *[0-9]\+ *//')"
+}
+PROMPT_COMMAND="__afv_save_last${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+`,
+	"zsh": `__afv_save_last() {
+  export ` + lastCommandEnv + `="$(fc -ln -1)"
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook precmd __afv_save_last
+`,
+	"fish": `function __afv_save_last --on-event fish_postexec
+    set -gx ` + lastCommandEnv + ` $argv[1]
+end
+`,
+}
+
+// saveLastHook returns the hook snippet for the given shell
+func saveLastHook(shell string) (string, error) {
+	hook, ok := saveLastHooks[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell '%s' (expected bash, zsh or fish)", shell)
+	}
+	return hook, nil
+}
+
+// lastShellCommand returns the previous interactive command exported by the shell hook
+func lastShellCommand() (string, error) {
+	last := strings.TrimSpace(os.Getenv(lastCommandEnv))
+	if last == "" {
+		return "", fmt.Errorf("no previous command found; add the shell hook first, e.g. eval \"$(afv save-last --hook bash)\"")
+	}
+	if strings.HasPrefix(last, "afv save-last") {
+		return "", fmt.Errorf("the previous command was 'afv save-last' itself")
+	}
+	return last, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSaveLastHook(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		hook, err := saveLastHook(shell)
+		if err != nil {
+			t.Errorf("Expected hook for %s: %v", shell, err)
+		}
+		if !strings.Contains(hook, lastCommandEnv) {
+			t.Errorf("Hook for %s should export %s, got: %s", shell, lastCommandEnv, hook)
+		}
+	}
+
+	if _, err := saveLastHook("tcsh"); err == nil {
+		t.Error("Expected error for unsupported shell")
+	}
+}
+
+func TestLastShellCommand(t *testing.T) {
+	t.Setenv(lastCommandEnv, "")
+	if _, err := lastShellCommand(); err == nil {
+		t.Error("Expected error when the hook has not exported anything")
+	}
+
+	t.Setenv(lastCommandEnv, "  make build  ")
+	last, err := lastShellCommand()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if last != "make build" {
+		t.Errorf("Expected 'make build', got '%s'", last)
+	}
+
+	t.Setenv(lastCommandEnv, "afv save-last --name x")
+	if _, err := lastShellCommand(); err == nil {
+		t.Error("Saving 'afv save-last' itself should be refused")
+	}
+}
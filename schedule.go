@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// schedulesBucket holds the cron schedules of stored commands, keyed by
+// command name
+var schedulesBucket = []byte("schedules")
+
+// Schedule triggers a stored command whenever its cron expression matches
+type Schedule struct {
+	Name      string `json:"name"`
+	Cron      string `json:"cron"`
+	Dir       string `json:"dir,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// AddSchedule stores the schedule of an existing command, replacing a
+// previous schedule of the same command
+func (d *Database) AddSchedule(schedule Schedule) error {
+	schedule.Cron = strings.TrimSpace(schedule.Cron)
+	if _, err := parseCron(schedule.Cron); err != nil {
+		return err
+	}
+	schedule.CreatedAt = time.Now().Format(timeLayout)
+
+	return d.update("AddSchedule", func(tx *bbolt.Tx) error {
+		if tx.Bucket(commandsBucket).Get([]byte(schedule.Name)) == nil {
+			return fmt.Errorf("command '%s' not found", schedule.Name)
+		}
+		data, err := json.Marshal(schedule)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(schedulesBucket).Put([]byte(schedule.Name), data)
+	})
+}
+
+// GetAllSchedules returns all schedules in command name order
+func (d *Database) GetAllSchedules() ([]Schedule, error) {
+	var schedules []Schedule
+	err := d.view("GetAllSchedules", func(tx *bbolt.Tx) error {
+		return tx.Bucket(schedulesBucket).ForEach(func(k, v []byte) error {
+			var schedule Schedule
+			if err := json.Unmarshal(v, &schedule); err != nil {
+				return err
+			}
+			schedules = append(schedules, schedule)
+			return nil
+		})
+	})
+	return schedules, err
+}
+
+// DeleteSchedule removes the schedule of a command
+func (d *Database) DeleteSchedule(name string) error {
+	return d.update("DeleteSchedule", func(tx *bbolt.Tx) error {
+		b := tx.Bucket(schedulesBucket)
+		if b.Get([]byte(name)) == nil {
+			return fmt.Errorf("command '%s' has no schedule", name)
+		}
+		return b.Delete([]byte(name))
+	})
+}
+
+// schedulerPIDFile returns the file recording the pid of the scheduler daemon
+func (d *Database) schedulerPIDFile() string {
+	return filepath.Join(d.DataDir(), "scheduler.pid")
+}
+
+// schedulerLogFile returns the log file of the scheduler daemon
+func (d *Database) schedulerLogFile() string {
+	return filepath.Join(d.DataDir(), "scheduler.log")
+}
+
+// SchedulerPID returns the pid of the running scheduler daemon, or 0 if it is
+// not running. A pid file left behind by a daemon that died is removed.
+func SchedulerPID(db *Database) (int, error) {
+	data, err := os.ReadFile(db.schedulerPIDFile())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read scheduler pid: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err == nil && processAlive(pid) {
+		return pid, nil
+	}
+	return 0, os.Remove(db.schedulerPIDFile())
+}
+
+// StartScheduler starts the scheduler daemon detached from the terminal. The
+// daemon is pinned to the active workspace.
+func StartScheduler(db *Database) (int, error) {
+	pid, err := SchedulerPID(db)
+	if err != nil {
+		return 0, err
+	}
+	if pid != 0 {
+		return 0, fmt.Errorf("scheduler is already running (pid %d)", pid)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the afv executable: %v", err)
+	}
+	workspace, err := activeWorkspace()
+	if err != nil {
+		return 0, err
+	}
+	logFile, err := os.OpenFile(db.schedulerLogFile(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open scheduler log: %v", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, "scheduler", "run")
+	cmd.Env = append(os.Environ(), workspaceEnv+"="+workspace)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start scheduler: %v", err)
+	}
+	// Record the pid right away so status does not depend on how fast the
+	// daemon comes up; the daemon writes the same file
+	if err := os.WriteFile(db.schedulerPIDFile(), []byte(strconv.Itoa(cmd.Process.Pid)), 0644); err != nil {
+		warn("failed to write scheduler pid: %v", err)
+	}
+	go func() { _ = cmd.Wait() }()
+	return cmd.Process.Pid, nil
+}
+
+// StopScheduler stops the scheduler daemon. It returns false if it was not
+// running.
+func StopScheduler(db *Database) (bool, error) {
+	pid, err := SchedulerPID(db)
+	if err != nil || pid == 0 {
+		return false, err
+	}
+	if err := terminateProcess(pid); err != nil {
+		return false, fmt.Errorf("failed to stop scheduler: %v", err)
+	}
+	deadline := time.Now().Add(serviceStopTimeout)
+	for processAlive(pid) && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if processAlive(pid) {
+		if err := killProcess(pid); err != nil {
+			return false, fmt.Errorf("failed to kill scheduler: %v", err)
+		}
+	}
+	os.Remove(db.schedulerPIDFile())
+	return true, nil
+}
+
+// RunScheduler is the scheduler daemon: at the start of every minute it
+// starts the commands whose schedule matches as background jobs, until it is
+// stopped. The database is closed between minutes so other afv invocations
+// can use it.
+func RunScheduler(db *Database) error {
+	if err := os.WriteFile(db.schedulerPIDFile(), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return fmt.Errorf("failed to write scheduler pid: %v", err)
+	}
+	defer os.Remove(db.schedulerPIDFile())
+	if err := db.Release(); err != nil {
+		return err
+	}
+	defer db.Reopen()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	fmt.Printf("%s scheduler started (pid %d)\n", time.Now().Format(timeLayout), os.Getpid())
+	for {
+		now := time.Now()
+		select {
+		case <-stop:
+			fmt.Printf("%s scheduler stopped\n", time.Now().Format(timeLayout))
+			return nil
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(now)):
+		}
+		runDueSchedules(db, time.Now())
+	}
+}
+
+// runDueSchedules starts the commands scheduled for the minute of now as
+// jobs, which run them like afv run and record them in the history
+func runDueSchedules(db *Database, now time.Time) {
+	if err := db.Reopen(); err != nil {
+		fmt.Printf("%s error: %v\n", now.Format(timeLayout), err)
+		return
+	}
+	defer db.Release()
+
+	schedules, err := db.GetAllSchedules()
+	if err != nil {
+		fmt.Printf("%s error: %v\n", now.Format(timeLayout), err)
+		return
+	}
+	for _, schedule := range schedules {
+		spec, err := parseCron(schedule.Cron)
+		if err != nil || !spec.Matches(now) {
+			continue
+		}
+		// Scheduling a command that asks for confirmation already confirms
+		// its runs, and nobody is there to answer. Its cooldown and
+		// singleton lock still apply.
+		job, err := StartJob(db, schedule.Name, RunOptions{Dir: schedule.Dir, Confirmed: true})
+		if err != nil {
+			fmt.Printf("%s %s failed to start: %v\n", now.Format(timeLayout), schedule.Name, err)
+			continue
+		}
+		fmt.Printf("%s %s started as job %d (log %s)\n", now.Format(timeLayout), schedule.Name, job.ID, job.LogFile)
+	}
+}
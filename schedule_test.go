@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSchedules(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("backup", "", "true", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := db.AddSchedule(Schedule{Name: "backup", Cron: "0 2 * * *"}); err != nil {
+		t.Fatalf("AddSchedule failed: %v", err)
+	}
+	if err := db.AddSchedule(Schedule{Name: "backup", Cron: "@hourly"}); err != nil {
+		t.Fatalf("Expected a schedule to be replaced, got %v", err)
+	}
+	if err := db.AddSchedule(Schedule{Name: "missing", Cron: "@hourly"}); err == nil {
+		t.Error("Expected error for an unknown command")
+	}
+	if err := db.AddSchedule(Schedule{Name: "backup", Cron: "every day"}); err == nil {
+		t.Error("Expected error for an invalid cron expression")
+	}
+
+	schedules, err := db.GetAllSchedules()
+	if err != nil || len(schedules) != 1 || schedules[0].Cron != "@hourly" {
+		t.Fatalf("Expected one hourly schedule, got %+v, %v", schedules, err)
+	}
+
+	if pid, err := SchedulerPID(db); err != nil || pid != 0 {
+		t.Errorf("Expected no scheduler to run, got %d, %v", pid, err)
+	}
+
+	if err := db.DeleteSchedule("backup"); err != nil {
+		t.Fatalf("DeleteSchedule failed: %v", err)
+	}
+	if err := db.DeleteSchedule("backup"); err == nil {
+		t.Error("Expected error deleting a missing schedule")
+	}
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses true")
+	}
+	t.Setenv(runAsAfvEnv, "1")
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, cmd := range []Command{
+		{Name: "backup", Command: "true", Confirm: true, Cooldown: "1h"},
+		{Name: "report", Command: "true"},
+	} {
+		if err := db.InsertCommand(cmd); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+	for _, schedule := range []Schedule{{Name: "backup", Cron: "* * * * *"}, {Name: "report", Cron: "0 2 * * *"}} {
+		if err := db.AddSchedule(schedule); err != nil {
+			t.Fatalf("AddSchedule failed: %v", err)
+		}
+	}
+
+	// The daemon keeps the database closed between minutes
+	if err := db.Release(); err != nil {
+		t.Fatal(err)
+	}
+	runDueSchedules(db, time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local))
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := db.GetAllJobs()
+	if err != nil || len(jobs) != 1 || jobs[0].Name != "backup" {
+		t.Fatalf("Expected a job for the due schedule only, got %+v, %v", jobs, err)
+	}
+	if err := db.Release(); err != nil {
+		t.Fatal(err)
+	}
+	waitForJob(t, &jobs[0])
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+
+	job, _ := db.FindJob("backup")
+	if job.Status() != "succeeded" {
+		t.Errorf("Expected the scheduled run to succeed without confirmation, got %q (%s)", job.Status(), job.Error)
+	}
+	records, err := db.GetRunHistory("backup")
+	if err != nil || len(records) != 1 || !records[0].Success {
+		t.Errorf("Expected the scheduled run in the history, got %+v, %v", records, err)
+	}
+
+	// The next minute falls into the cooldown of the first run
+	if err := db.Release(); err != nil {
+		t.Fatal(err)
+	}
+	runDueSchedules(db, time.Date(2026, 1, 1, 12, 1, 0, 0, time.Local))
+	if err := db.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	if jobs, _ := db.GetAllJobs(); len(jobs) != 1 {
+		t.Errorf("Expected the cooldown to skip the second run, got %+v", jobs)
+	}
+}
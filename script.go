@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// defaultShebang is put in front of scripts that do not start with one
+const defaultShebang = "#!/bin/sh"
+
+// readScript reads a script body from path, or from stdin if path is "-"
+// so a heredoc can be stored
+func readScript(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read script: %v", err)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", fmt.Errorf("script is empty")
+	}
+	return string(data), nil
+}
+
+// editScript opens the user's editor on a new script and returns what was
+// saved
+func editScript() (string, error) {
+	body, err := editText(defaultShebang+"\n\n", "afv-script-*.sh")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(body) == "" || strings.TrimSpace(body) == defaultShebang {
+		return "", fmt.Errorf("script is empty")
+	}
+	return body, nil
+}
+
+// validateScript checks that a script command runs on the host
+func validateScript(cmd *Command) error {
+	if !cmd.Script {
+		return nil
+	}
+	if cmd.Wsl != "" || cmd.Container != "" {
+		return fmt.Errorf("script commands cannot run in WSL or a container")
+	}
+	return nil
+}
+
+// scriptShebang returns the interpreter line of a script body, if any
+func scriptShebang(body string) (string, bool) {
+	first, _, _ := strings.Cut(body, "\n")
+	first = strings.TrimSpace(first)
+	return first, strings.HasPrefix(first, "#!")
+}
+
+// writeScript writes a script body to a new file in afv's cache directory,
+// where it can be executed even if the temp directory cannot, and returns
+// its path. The caller removes the file once the script has run. Bodies
+// without a shebang are run by sh, or by cmd.exe on Windows.
+func writeScript(body string) (string, error) {
+	_, hasShebang := scriptShebang(body)
+	ext := ""
+	if runtime.GOOS == "windows" && !hasShebang {
+		ext = ".cmd"
+	} else if !hasShebang {
+		body = defaultShebang + "\n" + body
+	}
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %v", err)
+	}
+	dir := filepath.Join(cacheDir, "afv", "scripts")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create script directory: %v", err)
+	}
+	f, err := os.CreateTemp(dir, "script-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to write script: %v", err)
+	}
+	_, err = f.WriteString(body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o700)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write script: %v", err)
+	}
+	return f.Name(), nil
+}
+
+// removeScript removes a script file written by writeScript, if any
+func removeScript(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		warn("failed to remove script: %v", err)
+	}
+}
+
+// newScriptCmd writes the body of a script command to a file and runs it,
+// returning the file for removeScript. Windows does not honor shebangs, so
+// the interpreter is started explicitly there.
+func newScriptCmd(command *Command, dir string, env []string) (*exec.Cmd, string, error) {
+	path, err := writeScript(command.Command)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cmd := exec.Command(path)
+	if runtime.GOOS == "windows" {
+		if shebang, ok := scriptShebang(command.Command); ok {
+			args := strings.Fields(strings.TrimPrefix(shebang, "#!"))
+			if len(args) > 1 && filepath.Base(args[0]) == "env" {
+				args = args[1:]
+			}
+			if len(args) > 0 {
+				args[0] = filepath.Base(args[0])
+				cmd = exec.Command(args[0], append(args[1:], path)...)
+			}
+		} else {
+			cmd = exec.Command("cmd", "/c", path)
+		}
+	}
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd, path, nil
+}
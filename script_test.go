@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteScript(t *testing.T) {
+	// Keep the scripts out of the user's cache directory on every platform
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	t.Setenv("HOME", cache)
+	t.Setenv("LocalAppData", cache)
+
+	path, err := writeScript("echo hi")
+	if err != nil {
+		t.Fatalf("writeScript failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read script: %v", err)
+	}
+	if !strings.HasSuffix(string(data), "echo hi\n") {
+		t.Errorf("Expected the body to end the script, got %q", data)
+	}
+
+	// Every run gets its own file, removed once it has run
+	again, err := writeScript("echo hi")
+	if err != nil || again == path {
+		t.Errorf("Expected a new file for every write, got %s (%v)", again, err)
+	}
+	removeScript(again)
+	if _, err := os.Stat(again); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", again, err)
+	}
+
+	other, err := writeScript("#!/usr/bin/env bash\necho hi\n")
+	if err != nil {
+		t.Fatalf("writeScript failed: %v", err)
+	}
+	data, _ = os.ReadFile(other)
+	if string(data) != "#!/usr/bin/env bash\necho hi\n" {
+		t.Errorf("Expected a script with a shebang to be kept as is, got %q", data)
+	}
+}
+
+func TestScriptShebangAndValidation(t *testing.T) {
+	if shebang, ok := scriptShebang("#!/usr/bin/env python3\nprint(1)"); !ok || shebang != "#!/usr/bin/env python3" {
+		t.Errorf("Unexpected shebang %q, %v", shebang, ok)
+	}
+	if _, ok := scriptShebang("echo hi"); ok {
+		t.Error("Expected no shebang")
+	}
+	if err := validateScript(&Command{Script: true, Container: "alpine"}); err == nil {
+		t.Error("Expected error for a script in a container")
+	}
+	if err := validateScript(&Command{Script: true}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
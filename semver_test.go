@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestParseSemver(t *testing.T) {
+	valid := []string{"1.0.0", "v2.3.4", "1.0.0-alpha.1", "1.0.0+build.5", "0.1.0-rc.1+sha.abc"}
+	for _, s := range valid {
+		if _, err := parseSemver(s); err != nil {
+			t.Errorf("Expected '%s' to parse, got %v", s, err)
+		}
+	}
+
+	invalid := []string{"", "1.0", "1.0.0.0", "01.0.0", "1.a.0", "1.0.0-", "1.0.0+", "1.0.0-alpha..1"}
+	for _, s := range invalid {
+		if _, err := parseSemver(s); err == nil {
+			t.Errorf("Expected error for '%s'", s)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	// Ordered from lowest to highest as in the semver spec
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.2.0", "2.0.0",
+	}
+	for i := 0; i < len(ordered)-1; i++ {
+		c, err := compareVersions(ordered[i], ordered[i+1])
+		if err != nil {
+			t.Fatalf("compareVersions failed: %v", err)
+		}
+		if c != -1 {
+			t.Errorf("Expected %s < %s", ordered[i], ordered[i+1])
+		}
+	}
+
+	if c, _ := compareVersions("v1.2.3+build.1", "1.2.3"); c != 0 {
+		t.Errorf("Build metadata should be ignored, got %d", c)
+	}
+}
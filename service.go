@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// serviceStopTimeout is how long stop waits for a graceful exit before killing
+const serviceStopTimeout = 10 * time.Second
+
+// ServiceState tracks a detached service process
+type ServiceState struct {
+	Name      string `json:"name"`
+	PID       int    `json:"pid"`
+	StartedAt string `json:"started_at"`
+	LogFile   string `json:"log_file"`
+	// Script is the file of a script service, removed once it has ended
+	Script string `json:"script,omitempty"`
+}
+
+// SaveServiceState stores the state of a started service
+func (d *Database) SaveServiceState(state ServiceState) error {
+	return d.update("SaveServiceState", func(tx *bbolt.Tx) error {
+		data, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(servicesBucket).Put([]byte(state.Name), data)
+	})
+}
+
+// GetServiceState returns the recorded state of a service, or nil if it was never started
+func (d *Database) GetServiceState(name string) (*ServiceState, error) {
+	var state *ServiceState
+	err := d.view("GetServiceState", func(tx *bbolt.Tx) error {
+		data := tx.Bucket(servicesBucket).Get([]byte(name))
+		if data == nil {
+			return nil
+		}
+		state = &ServiceState{}
+		return json.Unmarshal(data, state)
+	})
+	return state, err
+}
+
+// GetAllServiceStates returns the recorded state of every started service
+func (d *Database) GetAllServiceStates() ([]ServiceState, error) {
+	var states []ServiceState
+	err := d.view("GetAllServiceStates", func(tx *bbolt.Tx) error {
+		return tx.Bucket(servicesBucket).ForEach(func(k, v []byte) error {
+			var state ServiceState
+			if err := json.Unmarshal(v, &state); err != nil {
+				return err
+			}
+			states = append(states, state)
+			return nil
+		})
+	})
+	return states, err
+}
+
+// DeleteServiceState forgets the recorded state of a service
+func (d *Database) DeleteServiceState(name string) error {
+	return d.update("DeleteServiceState", func(tx *bbolt.Tx) error {
+		return tx.Bucket(servicesBucket).Delete([]byte(name))
+	})
+}
+
+// getService loads a command and makes sure it is a service
+func getService(db *Database, name string) (*Command, error) {
+	command, err := db.GetCommand(name)
+	if err != nil {
+		return nil, err
+	}
+	if command.Type != CommandTypeService {
+		return nil, fmt.Errorf("command '%s' is not a service (add it with --type service)", name)
+	}
+	return command, nil
+}
+
+// runningService returns the state of a service if its process is alive.
+// Stale state left behind by a process that has exited is removed.
+func runningService(db *Database, name string) (*ServiceState, error) {
+	state, err := db.GetServiceState(name)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	if processAlive(state.PID) {
+		return state, nil
+	}
+	removeScript(state.Script)
+	return nil, db.DeleteServiceState(name)
+}
+
+// StartService launches a service command detached from the terminal,
+// writing its output to a log file
+func StartService(db *Database, name, dirOverride string) (*ServiceState, error) {
+	command, err := getService(db, name)
+	if err != nil {
+		return nil, err
+	}
+
+	running, err := runningService(db, command.Name)
+	if err != nil {
+		return nil, err
+	}
+	if running != nil {
+		return nil, fmt.Errorf("service '%s' is already running (pid %d)", command.Name, running.PID)
+	}
+
+	dir, err := resolveRunDirectory(command, dirOverride)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded, err := ExpandCommand(db, command)
+	if err != nil {
+		return nil, err
+	}
+	cmd, script, err := newExecCmd(expanded, dir)
+	if err != nil {
+		return nil, err
+	}
+	// The script outlives afv while the service runs
+	started := false
+	defer func() {
+		if !started {
+			removeScript(script)
+		}
+	}()
+
+	logDir := db.CommandLogDir(command.Name)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create service log directory: %v", err)
+	}
+	logPath := filepath.Join(logDir, serviceLogName)
+	if err := rotateServiceLog(logPath); err != nil {
+		warn("%v", err)
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open service log: %v", err)
+	}
+	defer logFile.Close()
+
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+
+	startedAt := time.Now()
+	s := startSpan("exec.start", "name", command.Name, "command", command.Command, "dir", dir)
+	err = startLimited(cmd, expanded.Limits())
+	s.End(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start service '%s': %v", command.Name, err)
+	}
+	started = true
+
+	state := ServiceState{
+		Name:      command.Name,
+		PID:       cmd.Process.Pid,
+		StartedAt: startedAt.Format(timeLayout),
+		LogFile:   logPath,
+		Script:    script,
+	}
+	if err := db.SaveServiceState(state); err != nil {
+		return nil, fmt.Errorf("failed to record service state: %v", err)
+	}
+	if err := db.RecordRun(command.Name, startedAt); err != nil {
+		return nil, fmt.Errorf("failed to record run: %v", err)
+	}
+
+	// Reap the child if it exits while afv is still running so it does not
+	// linger as a zombie that still looks alive
+	go func() { _ = cmd.Wait() }()
+	return &state, nil
+}
+
+// StopService terminates a running service, escalating to a forced kill if it
+// does not exit within serviceStopTimeout. It returns false if the service was
+// not running.
+func StopService(db *Database, name string) (bool, error) {
+	state, err := runningService(db, name)
+	if err != nil {
+		return false, err
+	}
+	if state == nil {
+		return false, nil
+	}
+
+	if err := terminateProcess(state.PID); err != nil {
+		return false, fmt.Errorf("failed to stop service '%s': %v", name, err)
+	}
+
+	deadline := time.Now().Add(serviceStopTimeout)
+	for processAlive(state.PID) && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if processAlive(state.PID) {
+		if err := killProcess(state.PID); err != nil {
+			return false, fmt.Errorf("failed to kill service '%s': %v", name, err)
+		}
+	}
+
+	removeScript(state.Script)
+	return true, db.DeleteServiceState(name)
+}
+
+// StartServiceAndWait starts a service together with any services it requires
+// and blocks until its readiness probe and health check pass. It returns the
+// names of all services it started, in start order.
+func StartServiceAndWait(db *Database, name, dirOverride string) ([]string, error) {
+	return startServiceAndWait(db, name, dirOverride, []string{name})
+}
+
+// StartRequirements starts every service the command requires that is not
+// already running. It returns the names of the services it started, in start
+// order, even when a later one fails.
+func StartRequirements(db *Database, command *Command) ([]string, error) {
+	return startRequirements(db, command, []string{command.Name})
+}
+
+// StopServices stops the named services in reverse order
+func StopServices(db *Database, names []string) {
+	for i := len(names) - 1; i >= 0; i-- {
+		stopped, err := StopService(db, names[i])
+		if err != nil {
+			warn("%v", err)
+			continue
+		}
+		if stopped {
+			fmt.Printf("Service '%s' stopped.\n", names[i])
+		}
+	}
+}
+
+// startServiceAndWait starts a service after its requirements; chain holds
+// the dependency path used for cycle detection
+func startServiceAndWait(db *Database, name, dirOverride string, chain []string) ([]string, error) {
+	command, err := getService(db, name)
+	if err != nil {
+		return nil, err
+	}
+
+	started, err := startRequirements(db, command, chain)
+	if err != nil {
+		return started, err
+	}
+
+	if command.WaitFor != "" {
+		if err := WaitForPort(command.WaitFor); err != nil {
+			return started, err
+		}
+	}
+
+	state, err := StartService(db, command.Name, dirOverride)
+	if err != nil {
+		return started, err
+	}
+	started = append(started, state.Name)
+
+	fmt.Printf("Service '%s' started (pid %d).\n", state.Name, state.PID)
+	fmt.Printf("Log file: %s\n", state.LogFile)
+
+	if command.WaitAfter != "" {
+		if err := WaitForPort(command.WaitAfter); err != nil {
+			return started, fmt.Errorf("service '%s' did not become ready (see %s): %v", state.Name, state.LogFile, err)
+		}
+	}
+	if command.HealthCheck != nil {
+		dir, err := resolveRunDirectory(command, dirOverride)
+		if err != nil {
+			return started, err
+		}
+		if err := RunHealthCheck(command.HealthCheck, dir); err != nil {
+			return started, fmt.Errorf("service '%s' is running but unhealthy (see %s): %v", state.Name, state.LogFile, err)
+		}
+	}
+
+	return started, nil
+}
+
+// startRequirements starts the missing requirements of a command
+func startRequirements(db *Database, command *Command, chain []string) ([]string, error) {
+	var started []string
+	for _, req := range command.Requires {
+		if slices.Contains(chain, req) {
+			return started, fmt.Errorf("dependency cycle: %s", strings.Join(append(chain, req), " -> "))
+		}
+
+		running, err := runningService(db, req)
+		if err != nil {
+			return started, err
+		}
+		if running != nil {
+			continue
+		}
+
+		fmt.Printf("Starting required service '%s'...\n", req)
+		sub, err := startServiceAndWait(db, req, "", append(slices.Clone(chain), req))
+		started = append(started, sub...)
+		if err != nil {
+			return started, fmt.Errorf("failed to start required service '%s': %v", req, err)
+		}
+	}
+	return started, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestServiceState(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	state, err := db.GetServiceState("web")
+	if err != nil {
+		t.Fatalf("GetServiceState failed: %v", err)
+	}
+	if state != nil {
+		t.Fatalf("Expected no state for a service that was never started, got %+v", state)
+	}
+
+	err = db.SaveServiceState(ServiceState{Name: "web", PID: os.Getpid(), StartedAt: "2025-01-01 10:00:00"})
+	if err != nil {
+		t.Fatalf("SaveServiceState failed: %v", err)
+	}
+
+	running, err := runningService(db, "web")
+	if err != nil {
+		t.Fatalf("runningService failed: %v", err)
+	}
+	if running == nil || running.PID != os.Getpid() {
+		t.Fatalf("Expected service to be reported as running, got %+v", running)
+	}
+
+	states, err := db.GetAllServiceStates()
+	if err != nil {
+		t.Fatalf("GetAllServiceStates failed: %v", err)
+	}
+	if len(states) != 1 {
+		t.Errorf("Expected 1 service state, got %d", len(states))
+	}
+}
+
+func TestRunningServiceRemovesStaleState(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	// A pid that cannot belong to a live process
+	err := db.SaveServiceState(ServiceState{Name: "gone", PID: 1 << 30})
+	if err != nil {
+		t.Fatalf("SaveServiceState failed: %v", err)
+	}
+
+	running, err := runningService(db, "gone")
+	if err != nil {
+		t.Fatalf("runningService failed: %v", err)
+	}
+	if running != nil {
+		t.Errorf("Dead process should not be reported as running")
+	}
+
+	state, _ := db.GetServiceState("gone")
+	if state != nil {
+		t.Errorf("Stale state should have been removed")
+	}
+}
+
+func TestStartServiceRequiresServiceType(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("plain", "", "echo hi", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	if _, err := StartService(db, "plain", ""); err == nil {
+		t.Error("Starting a non-service command should fail")
+	}
+
+	if err := db.InsertCommand(Command{Name: "bad", Command: "echo", Type: "daemon"}); err == nil {
+		t.Error("Unknown command type should be rejected")
+	}
+}
+
+func TestStartRequirements(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	// Services that are already running are left alone
+	if err := db.InsertCommand(Command{Name: "db", Command: "sleep 30", Type: CommandTypeService}); err != nil {
+		t.Fatalf("Failed to add service: %v", err)
+	}
+	if err := db.SaveServiceState(ServiceState{Name: "db", PID: os.Getpid()}); err != nil {
+		t.Fatalf("Failed to save service state: %v", err)
+	}
+
+	started, err := StartRequirements(db, &Command{Name: "test", Requires: []string{"db"}})
+	if err != nil {
+		t.Fatalf("StartRequirements failed: %v", err)
+	}
+	if len(started) != 0 {
+		t.Errorf("Running requirement should not be started again, started: %v", started)
+	}
+
+	// Requirements must be services
+	if err := db.AddCommand("plain", "", "echo hi", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if _, err := StartRequirements(db, &Command{Name: "test", Requires: []string{"plain"}}); err == nil {
+		t.Error("Requiring a non-service command should fail")
+	}
+}
+
+func TestStartRequirementsCycle(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, svc := range []Command{
+		{Name: "a", Command: "sleep 30", Type: CommandTypeService, Requires: []string{"b"}},
+		{Name: "b", Command: "sleep 30", Type: CommandTypeService, Requires: []string{"a"}},
+	} {
+		if err := db.InsertCommand(svc); err != nil {
+			t.Fatalf("Failed to add service: %v", err)
+		}
+	}
+
+	a, _ := db.GetCommand("a")
+	_, err := StartRequirements(db, a)
+	if err == nil || !strings.Contains(err.Error(), "dependency cycle: a -> b -> a") {
+		t.Errorf("Expected dependency cycle error, got: %v", err)
+	}
+
+	if err := db.InsertCommand(Command{Name: "self", Command: "echo", Requires: []string{"self"}}); err == nil {
+		t.Error("A command requiring itself should be rejected")
+	}
+}
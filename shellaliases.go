@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// shellNamePattern matches the command names that work as alias and
+// function names in every supported shell
+var shellNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// defaultShell returns the login shell if afv can write aliases for it,
+// and bash otherwise
+func defaultShell() string {
+	shell := filepath.Base(os.Getenv("SHELL"))
+	if _, ok := shellInitScripts[shell]; ok {
+		return shell
+	}
+	return "bash"
+}
+
+// writeShellAliases writes an alias running each of names through afv run
+// for shell, or a function with functions set. Arguments given to either
+// are passed through to the command after --. Names the shell cannot use
+// are listed in a comment instead.
+func writeShellAliases(w io.Writer, shell string, names []string, functions bool) error {
+	if _, ok := shellInitScripts[shell]; !ok {
+		return fmt.Errorf("unsupported shell '%s' (expected bash, zsh or fish)", shell)
+	}
+	names = slices.Sorted(slices.Values(names))
+
+	fmt.Fprintln(w, "# Generated by 'afv export --as-aliases'; run it again after adding commands")
+	for _, name := range names {
+		if !shellNamePattern.MatchString(name) {
+			fmt.Fprintf(w, "# skipped '%s': not usable as a %s name\n", name, shell)
+			continue
+		}
+		run := "afv run " + name + " --"
+		var err error
+		switch {
+		case functions && shell == "fish":
+			_, err = fmt.Fprintf(w, "function %s\n    %s $argv\nend\n", name, run)
+		case functions:
+			_, err = fmt.Fprintf(w, "%s() {\n  %s \"$@\"\n}\n", name, run)
+		case shell == "fish":
+			_, err = fmt.Fprintf(w, "alias %s %s\n", name, shellQuote(shell, run))
+		default:
+			_, err = fmt.Fprintf(w, "alias %s=%s\n", name, shellQuote(shell, run))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestWriteShellAliases(t *testing.T) {
+	names := []string{"test", "build", "docker:build"}
+	tests := []struct {
+		shell     string
+		functions bool
+		want      string
+	}{
+		{"bash", false, "alias test='afv run test --'\n"},
+		{"zsh", true, "build() {\n  afv run build -- \"$@\"\n}\n"},
+		{"fish", false, "alias build 'afv run build --'\n"},
+		{"fish", true, "function build\n    afv run build -- $argv\nend\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeShellAliases(&buf, tt.shell, names, tt.functions); err != nil {
+			t.Fatalf("writeShellAliases(%s) failed: %v", tt.shell, err)
+		}
+		out := buf.String()
+		if !strings.Contains(out, tt.want) {
+			t.Errorf("Expected %s output to contain %q, got:\n%s", tt.shell, tt.want, out)
+		}
+		if !strings.Contains(out, "# skipped 'docker:build'") {
+			t.Errorf("Expected docker:build to be skipped for %s, got:\n%s", tt.shell, out)
+		}
+	}
+
+	if err := writeShellAliases(&bytes.Buffer{}, "powershell", names, false); err == nil {
+		t.Error("Expected an error for an unsupported shell")
+	}
+}
+
+func TestWriteShellAliasesPassesArguments(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+	for _, functions := range []bool{false, true} {
+		var buf bytes.Buffer
+		if err := writeShellAliases(&buf, "bash", []string{"build"}, functions); err != nil {
+			t.Fatalf("writeShellAliases failed: %v", err)
+		}
+		// Stand in for afv to see the arguments the alias passes on
+		script := "shopt -s expand_aliases\nafv() { echo \"$*\"; }\n" + buf.String() + "build -v 'two words'\n"
+		out, err := exec.Command(bash, "-c", script).CombinedOutput()
+		if err != nil {
+			t.Fatalf("bash failed: %v: %s", err, out)
+		}
+		if got := strings.TrimSpace(string(out)); got != "run build -- -v two words" {
+			t.Errorf("Unexpected arguments with functions=%v: %q", functions, got)
+		}
+	}
+}
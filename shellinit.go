@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shellInitScripts holds the per-shell wrapper functions. The wrapper asks
+// afv whether a run targets a command marked --eval and, if so, evaluates
+// the printed script in the calling shell instead of starting a subprocess.
+var shellInitScripts = map[string]string{
+	"bash": posixShellInit("bash"),
+	"zsh":  posixShellInit("zsh"),
+	"fish": `function afv
+    if test "$argv[1]" = run
+        set -l afv_script (command afv shell-eval fish -- $argv | string collect)
+        if test -n "$afv_script"
+            eval $afv_script
+            return
+        end
+    end
+    command afv $argv
+end
+`,
+}
+
+// posixShellInit returns the wrapper function for bash and zsh
+func posixShellInit(shell string) string {
+	return `afv() {
+  if [ "$1" = "run" ]; then
+    local afv_script
+    afv_script="$(command afv shell-eval ` + shell + ` -- "$@")"
+    if [ -n "$afv_script" ]; then
+      eval "$afv_script"
+      return
+    fi
+  fi
+  command afv "$@"
+}
+`
+}
+
+// shellInit returns the wrapper function for the given shell
+func shellInit(shell string) (string, error) {
+	script, ok := shellInitScripts[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell '%s' (expected bash, zsh or fish)", shell)
+	}
+	return script, nil
+}
+
+// runTarget extracts the command name and --dir override from the
+// arguments of an 'afv run' invocation as seen by the shell wrapper
+func runTarget(args []string) (string, string) {
+	if len(args) > 0 && args[0] == "run" {
+		args = args[1:]
+	}
+
+	var name, dir string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			if name == "" {
+				name = arg
+			}
+			continue
+		}
+
+		flag, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch flag {
+		case "name", "dir", "wait-for", "wait-after":
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+		}
+		switch flag {
+		case "name":
+			name = value
+		case "dir":
+			dir = value
+		}
+	}
+	return name, dir
+}
+
+// shellQuote quotes s as a single word for the given shell
+func shellQuote(shell, s string) string {
+	if shell == "fish" {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// evalScript returns the script the shell wrapper evaluates for a command,
+// changing into dir first if one is given
+func evalScript(shell string, command *Command, dir string) string {
+	if dir == "" {
+		return command.Command
+	}
+	return "cd " + shellQuote(shell, dir) + " && " + command.Command
+}
+
+// ShellEvalScript returns the script to evaluate for an 'afv run' invocation,
+// or an empty string if the run should go through afv as usual
+func ShellEvalScript(db *Database, shell string, runArgs []string) string {
+	runArgs, extra := splitPassthrough(runArgs)
+	name, dirOverride := runTarget(runArgs)
+	if name == "" {
+		return ""
+	}
+	command, err := db.GetCommand(name)
+	if err != nil || !command.Eval {
+		return ""
+	}
+	if command, err = ExpandCommand(db, command); err != nil {
+		return "echo " + shellQuote(shell, "afv: "+err.Error()) + " >&2; false"
+	}
+
+	dir := command.WorkingDir
+	if dirOverride != "" {
+		resolved, err := resolveDirectory(dirOverride)
+		if err != nil {
+			return ""
+		}
+		dir = resolved
+	}
+	for _, arg := range extra {
+		command.Command += " " + shellQuote(shell, arg)
+	}
+	return evalScript(shell, command, dir)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRunTarget(t *testing.T) {
+	tests := []struct {
+		args []string
+		name string
+		dir  string
+	}{
+		{[]string{"run", "--name", "venv"}, "venv", ""},
+		{[]string{"run", "-name=venv", "--dir", "/src"}, "venv", "/src"},
+		{[]string{"run", "--force", "--wait-for", "db:5432", "venv"}, "venv", ""},
+		{[]string{"run"}, "", ""},
+	}
+	for _, tt := range tests {
+		name, dir := runTarget(tt.args)
+		if name != tt.name || dir != tt.dir {
+			t.Errorf("runTarget(%q) = (%q, %q), want (%q, %q)", tt.args, name, dir, tt.name, tt.dir)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got := shellQuote("bash", "/it's here"); got != `'/it'\''s here'` {
+		t.Errorf("Unexpected bash quoting: %s", got)
+	}
+	if got := shellQuote("fish", `/it's\here`); got != `'/it\'s\\here'` {
+		t.Errorf("Unexpected fish quoting: %s", got)
+	}
+}
+
+func TestShellEvalScript(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.InsertCommand(Command{Name: "venv", Command: "source .venv/bin/activate", WorkingDir: tempDir, Eval: true}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := db.AddCommand("build", "Build", "go build", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	script := ShellEvalScript(db, "bash", []string{"run", "--name", "venv"})
+	if script != "cd '"+tempDir+"' && source .venv/bin/activate" {
+		t.Errorf("Unexpected script: %s", script)
+	}
+	script = ShellEvalScript(db, "bash", []string{"run", "venv", "--", "--prompt", "my env"})
+	if script != "cd '"+tempDir+"' && source .venv/bin/activate '--prompt' 'my env'" {
+		t.Errorf("Unexpected script with extra arguments: %s", script)
+	}
+	if script := ShellEvalScript(db, "bash", []string{"run", "--name", "build"}); script != "" {
+		t.Errorf("Regular commands should not be evaluated, got: %s", script)
+	}
+	if script := ShellEvalScript(db, "bash", []string{"run", "--name", "missing"}); script != "" {
+		t.Errorf("Unknown commands should be left to afv, got: %s", script)
+	}
+}
+
+func TestShellInitUnsupported(t *testing.T) {
+	if _, err := shellInit("tcsh"); err == nil {
+		t.Error("Expected error for an unsupported shell")
+	}
+}
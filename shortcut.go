@@ -0,0 +1,57 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// builtinCommands lists afv's own top-level commands. They take precedence
+// over stored commands of the same name in 'afv NAME', which then only run
+// through afv run.
+var builtinCommands = []string{
+	"list", "add", "run", "rerun", "help", "which", "env", "cleanup", "exec",
+	"save-last", "shell-init", "shell-eval", "completion", "__complete",
+	"init", "__project-hook", "tui", "pick", "show", "update", "edit",
+	"delete", "undelete", "trash", "deprecate", "pin", "unpin", "bulk-edit",
+	"export", "import", "apply", "plan", "sync", "serve", "tag", "alias",
+	"group", "pack", "workspace", "profile", "stats", "logs", "jobs",
+	"schedule", "scheduler", "kill", "prompt", "info", "backup", "restore",
+	"start", "stop", "status", "auth", jobCommand,
+}
+
+// splitShortcut recognizes 'afv NAME args...' as a run of a stored command.
+// It returns afv's global flags given before NAME, the name and the
+// arguments following it, which are passed to the command untouched rather
+// than read as afv flags. The name is empty if the first word is one of
+// afv's own commands or there is none.
+func splitShortcut(args []string) ([]string, string, []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			if slices.Contains(builtinCommands, arg) {
+				return args, "", nil
+			}
+			return args[:i], arg, args[i+1:]
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		switch {
+		case slices.Contains(globalBoolFlags, name):
+		case slices.Contains(globalFlags, name):
+			if !hasValue {
+				i++
+			}
+		default:
+			// Flags of afv itself, such as --help
+			return args, "", nil
+		}
+	}
+	return args, "", nil
+}
+
+// warnBuiltinName warns that a stored command named like one of afv's own
+// commands does not run as 'afv NAME'
+func warnBuiltinName(name string) {
+	if slices.Contains(builtinCommands, name) {
+		warn("'afv %s' runs afv's own command; run this one with 'afv run %s'", name, name)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitShortcut(t *testing.T) {
+	tests := []struct {
+		args []string
+		head []string
+		name string
+		rest []string
+	}{
+		{[]string{"build"}, []string{}, "build", []string{}},
+		{[]string{"build", "-o", "out", "--help"}, []string{}, "build", []string{"-o", "out", "--help"}},
+		{[]string{"--db", "x.db", "-o=json", "build", "a"}, []string{"--db", "x.db", "-o=json"}, "build", []string{"a"}},
+		{[]string{"--verbose", "build", "--debug"}, []string{"--verbose"}, "build", []string{"--debug"}},
+		{[]string{"--workspace", "list"}, nil, "", nil},
+		{[]string{"list", "--tag", "ci"}, nil, "", nil},
+		{[]string{"--db", "x.db", "run", "build"}, nil, "", nil},
+		{[]string{"--help", "build"}, nil, "", nil},
+		{[]string{"--db", "x.db"}, nil, "", nil},
+		{nil, nil, "", nil},
+	}
+	for _, tt := range tests {
+		head, name, rest := splitShortcut(tt.args)
+		if name != tt.name {
+			t.Errorf("splitShortcut(%q) name = %q, want %q", tt.args, name, tt.name)
+			continue
+		}
+		if name == "" {
+			if !slices.Equal(head, tt.args) || rest != nil {
+				t.Errorf("splitShortcut(%q) = %q, %q, want the arguments unchanged", tt.args, head, rest)
+			}
+			continue
+		}
+		if !slices.Equal(head, tt.head) || !slices.Equal(rest, tt.rest) {
+			t.Errorf("splitShortcut(%q) = %q, %q, want %q, %q", tt.args, head, rest, tt.head, tt.rest)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// showData is what 'afv show' writes as JSON or YAML: every field of the
+// command and its most recent run
+type showData struct {
+	Command `yaml:",inline"`
+	LastRun *RunRecord `json:"last_run,omitempty" yaml:"last_run,omitempty"`
+}
+
+// ShowCommand prints every field of a stored command and its most recent
+// run, as a readable layout or as JSON or YAML
+func ShowCommand(db *Database, name, format string) error {
+	cmd, err := db.GetCommand(name)
+	if err != nil {
+		return err
+	}
+	records, err := db.GetRunHistory(name)
+	if err != nil {
+		return fmt.Errorf("failed to read run history: %v", err)
+	}
+	var last *RunRecord
+	if len(records) > 0 {
+		last = &records[len(records)-1]
+	}
+
+	if format != outputTable {
+		return writeData(os.Stdout, format, showData{Command: *cmd, LastRun: last})
+	}
+	writeCommandDetails(os.Stdout, cmd, last)
+	return nil
+}
+
+// writeCommandDetails writes the fields of cmd that are set as labeled
+// values, followed by the ones spanning several lines
+func writeCommandDetails(w io.Writer, cmd *Command, last *RunRecord) {
+	fields := &table{}
+	field := func(label, value string) {
+		if value != "" {
+			fields.add(tableCell{Text: label + ":"}, tableCell{Text: value})
+		}
+	}
+	flag := func(label string, set bool) {
+		if set {
+			field(label, "yes")
+		}
+	}
+
+	field("Name", cmd.Name)
+	field("Description", cmd.Description)
+	field("Type", cmd.Type)
+	if !cmd.Script && len(cmd.Steps) == 0 {
+		field("Command", cmd.Command)
+	}
+	field("Working dir", cmd.WorkingDir)
+	field("Tags", strings.Join(cmd.Tags, ", "))
+	field("Env file", cmd.EnvFile)
+	field("Stdin file", cmd.StdinFile)
+	field("Path prepend", strings.Join(cmd.PathPrepend, ", "))
+	field("WSL distro", cmd.Wsl)
+	field("Container", cmd.Container)
+	field("Runs as", cmd.RunAs)
+	field("Cooldown", cmd.Cooldown)
+	field("Timeout", cmd.Timeout)
+	flag("Singleton", cmd.Singleton)
+	flag("Confirm", cmd.Confirm)
+	flag("Eval", cmd.Eval)
+	flag("Pinned", cmd.Pinned)
+	field("Wait for", cmd.WaitFor)
+	field("Wait after", cmd.WaitAfter)
+	field("Requires", strings.Join(cmd.Requires, ", "))
+	if hc := cmd.HealthCheck; hc != nil {
+		field("Health check", healthCheckSummary(hc))
+	}
+	field("Pre hook", cmd.PreHook)
+	field("Post hook", cmd.PostHook)
+	field("Cleanup", cmd.Cleanup)
+	if cmd.Nice != 0 {
+		field("Nice", strconv.Itoa(cmd.Nice))
+	}
+	field("Max memory", cmd.MaxMem)
+	if cmd.CPULimit != 0 {
+		field("CPU limit", strconv.Itoa(cmd.CPULimit))
+	}
+	for _, rule := range cmd.Notify {
+		field("Notify", notifyRuleSummary(rule))
+	}
+	field("Notify email", strings.Join(cmd.NotifyEmail, ", "))
+	if cmd.Deprecated != nil {
+		field("Deprecated", deprecationMessage(cmd))
+	}
+	field("Created", cmd.CreatedAt)
+	field("Updated", cmd.UpdatedAt)
+	switch {
+	case last != nil:
+		field("Last run", strings.TrimPrefix(lastRunCell(*last).Text, "last run "))
+	case cmd.LastRunAt != "":
+		field("Last run", cmd.LastRunAt)
+	default:
+		field("Last run", "never")
+	}
+	field("Run count", strconv.Itoa(cmd.RunCount))
+	fields.write(w, "")
+
+	block := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s:\n", title)
+		for _, line := range lines {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	if cmd.Script {
+		block("Script", strings.Split(strings.TrimRight(cmd.Command, "\n"), "\n"))
+	}
+	var steps []string
+	for i, step := range cmd.Steps {
+		steps = append(steps, fmt.Sprintf("%d. %s", i+1, step))
+	}
+	block("Steps", steps)
+	block("Environment", sortedAssignments(cmd.Env))
+	block("Placeholder defaults", sortedAssignments(cmd.Vars))
+	if cmd.Notes != "" {
+		block("Notes", strings.Split(cmd.Notes, "\n"))
+	}
+	var examples []string
+	for _, ex := range cmd.Examples {
+		examples = append(examples, ex.Run)
+		if ex.Note != "" {
+			examples = append(examples, "    "+ex.Note)
+		}
+	}
+	block("Examples", examples)
+}
+
+// sortedAssignments returns the entries of m as KEY=VALUE lines in key order
+func sortedAssignments(m map[string]string) []string {
+	var lines []string
+	for key, value := range m {
+		lines = append(lines, key+"="+value)
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// healthCheckSummary describes a health check on one line
+func healthCheckSummary(hc *HealthCheck) string {
+	var opts []string
+	if hc.Retries != 0 {
+		opts = append(opts, fmt.Sprintf("%d retries", hc.Retries))
+	}
+	if hc.Interval != "" {
+		opts = append(opts, "every "+hc.Interval)
+	}
+	if hc.Timeout != "" {
+		opts = append(opts, "timeout "+hc.Timeout)
+	}
+	if len(opts) == 0 {
+		return hc.Check
+	}
+	return fmt.Sprintf("%s (%s)", hc.Check, strings.Join(opts, ", "))
+}
+
+// notifyRuleSummary describes a notification rule on one line
+func notifyRuleSummary(rule NotifyRule) string {
+	summary := "on " + firstNonEmpty(rule.On, "always")
+	if rule.MinDuration != "" {
+		summary += ", after " + rule.MinDuration
+	}
+	return summary
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteCommandDetails(t *testing.T) {
+	cmd := &Command{
+		Name:        "deploy",
+		Description: "Deploy the app",
+		Command:     "./deploy.sh",
+		Tags:        []string{"release"},
+		Confirm:     true,
+		Env:         map[string]string{"REGION": "eu", "APP": "web"},
+		CreatedAt:   "2026-09-01 10:12:44",
+		RunCount:    2,
+	}
+	last := &RunRecord{ID: 3, StartedAt: time.Date(2026, 10, 14, 17, 2, 11, 0, time.Local), Duration: 41300 * time.Millisecond}
+
+	var out bytes.Buffer
+	writeCommandDetails(&out, cmd, last)
+	want := `Name:         deploy
+Description:  Deploy the app
+Command:      ./deploy.sh
+Tags:         release
+Confirm:      yes
+Created:      2026-09-01 10:12:44
+Last run:     2026-10-14 17:02:11, took 41.3s, exit status 0
+Run count:    2
+
+Environment:
+  APP=web
+  REGION=eu
+`
+	if out.String() != want {
+		t.Errorf("Unexpected details:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	writeCommandDetails(&out, &Command{Name: "lint", Steps: []string{"go vet ./...", "staticcheck ./..."}}, nil)
+	want = `Name:       lint
+Last run:   never
+Run count:  0
+
+Steps:
+  1. go vet ./...
+  2. staticcheck ./...
+`
+	if out.String() != want {
+		t.Errorf("Unexpected details:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestShowDataJSON(t *testing.T) {
+	data, err := json.Marshal(showData{Command: Command{Name: "build", Command: "go build"}, LastRun: &RunRecord{ID: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["name"] != "build" || fields["command"] != "go build" || fields["last_run"] == nil {
+		t.Errorf("Expected the command fields at the top level next to last_run, got %s", data)
+	}
+}
+
+func TestShowDataYAML(t *testing.T) {
+	run := &RunRecord{ID: 1, Duration: 1500 * time.Millisecond, Elapsed: 2 * time.Second}
+	data, err := yaml.Marshal(showData{Command: Command{Name: "build", Command: "go build"}, LastRun: run})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "duration: 1.5s") || !strings.Contains(string(data), "elapsed: 2s") {
+		t.Errorf("Expected the durations of the last run by name, got %s", data)
+	}
+	var got showData
+	if err := yaml.Unmarshal(data, &got); err != nil || got.LastRun == nil || got.LastRun.Duration != run.Duration || got.LastRun.Elapsed != run.Elapsed {
+		t.Errorf("Expected the last run to read back, got %+v, %v", got.LastRun, err)
+	}
+}
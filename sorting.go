@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Sort orders for list output
+const (
+	SortNatural = "natural"
+	SortLocale  = "locale"
+	SortBytes   = "bytes"
+)
+
+// SortConfig controls how names are ordered in list output
+type SortConfig struct {
+	// Order is natural (default), locale or bytes
+	Order string `yaml:"order,omitempty"`
+	// Locale is a BCP 47 tag such as da or de-DE; the default comes from
+	// LC_ALL, LC_COLLATE or LANG
+	Locale string `yaml:"locale,omitempty"`
+}
+
+// validate checks the sort order and locale
+func (c SortConfig) validate() error {
+	switch c.Order {
+	case "", SortNatural, SortLocale, SortBytes:
+	default:
+		return fmt.Errorf("unknown sort order '%s' (expected natural, locale or bytes)", c.Order)
+	}
+	if c.Locale != "" {
+		if _, err := language.Parse(c.Locale); err != nil {
+			return fmt.Errorf("invalid sort locale '%s': %v", c.Locale, err)
+		}
+	}
+	return nil
+}
+
+// environmentLocale returns the collation locale of the environment as a
+// language tag, falling back to the root locale
+func environmentLocale() language.Tag {
+	for _, key := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		value := os.Getenv(key)
+		if value == "" {
+			continue
+		}
+		// POSIX locales look like da_DK.UTF-8@euro
+		value, _, _ = strings.Cut(value, ".")
+		value, _, _ = strings.Cut(value, "@")
+		if value == "C" || value == "POSIX" {
+			return language.Und
+		}
+		if tag, err := language.Parse(strings.ReplaceAll(value, "_", "-")); err == nil {
+			return tag
+		}
+		return language.Und
+	}
+	return language.Und
+}
+
+// nameComparer returns the function comparing command names for cfg
+func nameComparer(cfg SortConfig) (func(a, b string) int, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Order == SortBytes {
+		return strings.Compare, nil
+	}
+
+	tag := environmentLocale()
+	if cfg.Locale != "" {
+		tag = language.Make(cfg.Locale)
+	}
+	options := []collate.Option{collate.IgnoreCase}
+	if cfg.Order != SortLocale {
+		options = append(options, collate.Numeric)
+	}
+	collator := collate.New(tag, options...)
+
+	return func(a, b string) int {
+		if c := collator.CompareString(a, b); c != 0 {
+			return c
+		}
+		// Keep names that collate equally, like Build and build, stable
+		return strings.Compare(a, b)
+	}, nil
+}
+
+// sortByName orders items by the name returned for each as configured
+func sortByName[T any](items []T, cfg SortConfig, name func(item T) string) error {
+	compare, err := nameComparer(cfg)
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(items, func(a, b T) int {
+		return compare(name(a), name(b))
+	})
+	return nil
+}
+
+// sortCommands orders commands as afv list shows them: by name as configured
+// in config.yaml, with pinned commands first
+func sortCommands(commands []CommandMeta) error {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	if err := sortByName(commands, cfg.Sort, func(cmd CommandMeta) string { return cmd.Name }); err != nil {
+		return err
+	}
+	pinnedFirst(commands, func(cmd CommandMeta) bool { return cmd.Pinned })
+	return nil
+}
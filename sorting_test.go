@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSortCommands(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_COLLATE", "")
+	t.Setenv("LANG", "en_US.UTF-8")
+
+	input := func(names ...string) []Command {
+		commands := make([]Command, len(names))
+		for i, name := range names {
+			commands[i] = Command{Name: name}
+		}
+		return commands
+	}
+
+	tests := []struct {
+		name string
+		cfg  SortConfig
+		in   []Command
+		want []string
+	}{
+		{"natural numbers", SortConfig{}, input("cmd10", "cmd2", "cmd1"), []string{"cmd1", "cmd2", "cmd10"}},
+		{"case insensitive", SortConfig{}, input("build", "Deploy", "api"), []string{"api", "build", "Deploy"}},
+		{"locale without numbers", SortConfig{Order: SortLocale}, input("cmd10", "cmd2"), []string{"cmd10", "cmd2"}},
+		{"bytes", SortConfig{Order: SortBytes}, input("build", "Deploy", "api"), []string{"Deploy", "api", "build"}},
+		{"danish letters", SortConfig{Locale: "da"}, input("øl", "zip", "æble", "ål"), []string{"zip", "æble", "øl", "ål"}},
+		{"english letters", SortConfig{}, input("zip", "ål", "apt"), []string{"ål", "apt", "zip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := sortByName(tt.in, tt.cfg, func(cmd Command) string { return cmd.Name }); err != nil {
+				t.Fatalf("sortByName failed: %v", err)
+			}
+			if got := commandNames(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if err := sortByName([]string{"a"}, SortConfig{Order: "random"}, func(s string) string { return s }); err == nil {
+		t.Error("Expected error for an unknown sort order")
+	}
+}
+
+func TestSortCommandMeta(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(configDirEnv, dir)
+	commands := []CommandMeta{{Name: "cmd10"}, {Name: "api"}, {Name: "deploy", Pinned: true}, {Name: "cmd2"}}
+
+	if err := sortCommands(commands); err != nil {
+		t.Fatalf("sortCommands failed: %v", err)
+	}
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+	}
+	if want := []string{"deploy", "api", "cmd2", "cmd10"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected %v, got %v", want, names)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte("sort:\n  order: random\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sortCommands(commands); err == nil {
+		t.Error("Expected the sort order of config.yaml to be used")
+	}
+}
+
+func TestEnvironmentLocale(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_COLLATE", "da_DK.UTF-8")
+	t.Setenv("LANG", "en_US.UTF-8")
+	if tag := environmentLocale(); tag.String() != "da-DK" {
+		t.Errorf("Expected da-DK from LC_COLLATE, got %s", tag)
+	}
+
+	t.Setenv("LC_ALL", "C")
+	if tag := environmentLocale(); tag.String() != "und" {
+		t.Errorf("Expected the root locale for C, got %s", tag)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// trendRuns is how many of the most recent runs the sparkline covers
+const trendRuns = 30
+
+// sparkLevels are the bar characters of a sparkline, lowest first
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// RunStats summarizes the run history of a command
+type RunStats struct {
+	Runs     int
+	Failures int
+	P50      time.Duration
+	P90      time.Duration
+	P99      time.Duration
+	Min      time.Duration
+	Max      time.Duration
+	// Trend holds the durations of the most recent runs, oldest first
+	Trend   []time.Duration
+	LastRun time.Time
+}
+
+// computeStats summarizes records given oldest first
+func computeStats(records []RunRecord) RunStats {
+	stats := RunStats{Runs: len(records)}
+	if len(records) == 0 {
+		return stats
+	}
+
+	durations := make([]time.Duration, len(records))
+	for i, record := range records {
+		durations[i] = record.Duration
+		if !record.Success {
+			stats.Failures++
+		}
+	}
+	stats.LastRun = records[len(records)-1].StartedAt
+	stats.Trend = slices.Clone(durations[max(0, len(durations)-trendRuns):])
+
+	slices.Sort(durations)
+	stats.Min = durations[0]
+	stats.Max = durations[len(durations)-1]
+	stats.P50 = percentile(durations, 50)
+	stats.P90 = percentile(durations, 90)
+	stats.P99 = percentile(durations, 99)
+	return stats
+}
+
+// percentile returns the nearest-rank percentile p of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank-1, 0), len(sorted)-1)]
+}
+
+// sparkline renders durations as a row of bars scaled from zero to their
+// maximum, so bar heights stay proportional to the durations
+func sparkline(durations []time.Duration) string {
+	if len(durations) == 0 {
+		return ""
+	}
+	hi := slices.Max(durations)
+
+	var b strings.Builder
+	for _, d := range durations {
+		level := 0
+		if hi > 0 {
+			level = int(float64(d) / float64(hi) * float64(len(sparkLevels)-1))
+		}
+		b.WriteRune(sparkLevels[level])
+	}
+	return b.String()
+}
+
+// formatDuration rounds a duration to a precision that suits its size
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(10 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+// recentChange compares the median of the last few runs with the median of
+// all earlier runs. It reports false if there are too few runs to compare.
+func recentChange(records []RunRecord, recent int) (time.Duration, time.Duration, bool) {
+	if len(records) < 2*recent {
+		return 0, 0, false
+	}
+	split := len(records) - recent
+	return computeStats(records[:split]).P50, computeStats(records[split:]).P50, true
+}
+
+// PrintCommandStats writes the detailed statistics of one command
+func PrintCommandStats(name string, records []RunRecord) {
+	if len(records) == 0 {
+		fmt.Printf("No runs recorded for '%s' yet.\n", name)
+		return
+	}
+
+	stats := computeStats(records)
+	fmt.Printf("Runs: %d (%d succeeded, %d failed)\n", stats.Runs, stats.Runs-stats.Failures, stats.Failures)
+	fmt.Printf("Duration: p50 %s, p90 %s, p99 %s (min %s, max %s)\n",
+		formatDuration(stats.P50), formatDuration(stats.P90), formatDuration(stats.P99),
+		formatDuration(stats.Min), formatDuration(stats.Max))
+	fmt.Printf("Trend (last %d runs): %s\n", len(stats.Trend), sparkline(stats.Trend))
+	if earlier, recent, ok := recentChange(records, 5); ok && earlier > 0 {
+		change := (float64(recent) - float64(earlier)) / float64(earlier) * 100
+		fmt.Printf("Last 5 runs: p50 %s vs %s before (%+.0f%%)\n", formatDuration(recent), formatDuration(earlier), change)
+	}
+	fmt.Printf("Last run: %s\n", stats.LastRun.Local().Format(timeLayout))
+}
+
+// PrintStatsOverview writes one summary line per command with recorded runs
+func PrintStatsOverview(records []RunRecord) {
+	byName := map[string][]RunRecord{}
+	for _, record := range records {
+		if record.Name != "" {
+			byName[record.Name] = append(byName[record.Name], record)
+		}
+	}
+	if len(byName) == 0 {
+		fmt.Println("No runs recorded yet.")
+		return
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		stats := computeStats(byName[name])
+		fmt.Printf("  %-15s %4d runs  %3d%% ok  p50 %-9s p90 %-9s %s\n",
+			name, stats.Runs, (stats.Runs-stats.Failures)*100/stats.Runs,
+			formatDuration(stats.P50), formatDuration(stats.P90), sparkline(stats.Trend))
+	}
+}
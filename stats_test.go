@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	var records []RunRecord
+	for i := 1; i <= 100; i++ {
+		records = append(records, RunRecord{Duration: time.Duration(i) * time.Millisecond, Success: i%10 != 0})
+	}
+
+	stats := computeStats(records)
+	if stats.Runs != 100 || stats.Failures != 10 {
+		t.Errorf("Expected 100 runs with 10 failures, got %d and %d", stats.Runs, stats.Failures)
+	}
+	if stats.P50 != 50*time.Millisecond || stats.P90 != 90*time.Millisecond || stats.P99 != 99*time.Millisecond {
+		t.Errorf("Unexpected percentiles: p50 %v, p90 %v, p99 %v", stats.P50, stats.P90, stats.P99)
+	}
+	if stats.Min != time.Millisecond || stats.Max != 100*time.Millisecond {
+		t.Errorf("Unexpected min/max: %v/%v", stats.Min, stats.Max)
+	}
+	if len(stats.Trend) != trendRuns || stats.Trend[len(stats.Trend)-1] != 100*time.Millisecond {
+		t.Errorf("Trend should hold the last %d runs, got %v", trendRuns, stats.Trend)
+	}
+
+	if empty := computeStats(nil); empty.Runs != 0 || empty.P50 != 0 {
+		t.Errorf("Expected empty stats, got %+v", empty)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	got := sparkline([]time.Duration{0, 4 * time.Second, 8 * time.Second})
+	if got != "▁▄█" {
+		t.Errorf("Expected ▁▄█, got %s", got)
+	}
+	if sparkline(nil) != "" {
+		t.Error("Expected an empty sparkline for no runs")
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// openStdinFile opens the file fed to a command's standard input. Relative
+// paths are resolved against dir, like env files.
+func openStdinFile(path, dir string) (*os.File, error) {
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stdin file: %v", err)
+	}
+	return file, nil
+}
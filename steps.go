@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// validateSteps checks the steps of a step command
+func validateSteps(cmd *Command) error {
+	if len(cmd.Steps) == 0 {
+		return nil
+	}
+	if cmd.Command != "" {
+		return fmt.Errorf("a command cannot have both a command line and steps")
+	}
+	if cmd.Script {
+		return fmt.Errorf("a script command cannot have steps")
+	}
+	if cmd.Type == CommandTypeService {
+		return fmt.Errorf("a service cannot have steps")
+	}
+	for i, step := range cmd.Steps {
+		step = strings.TrimSpace(step)
+		if step == "" {
+			return fmt.Errorf("step %d is empty", i+1)
+		}
+		if strings.Contains(step, "\n") {
+			return fmt.Errorf("step %d spans multiple lines", i+1)
+		}
+		cmd.Steps[i] = step
+	}
+	return nil
+}
+
+// prepareSteps prepares every step of a step command like a command line.
+// The steps are joined by newlines so references and placeholders are
+// resolved once for all of them.
+func prepareSteps(db *Database, command *Command, opts RunOptions, prompt valuePrompter) (*preparedCommand, error) {
+	lined := *command
+	lined.Command = strings.Join(command.Steps, "\n")
+	lined.Steps = nil
+	prepared, err := prepareCommand(db, &lined, opts, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := *prepared.Command
+	steps.Steps = strings.Split(steps.Command, "\n")
+	steps.Command = ""
+	prepared.Command = &steps
+	prepared.StepDisplays = strings.Split(prepared.Display, "\n")
+	prepared.Display = strings.Join(prepared.StepDisplays, " && ")
+	return prepared, nil
+}
+
+// selectSteps returns the indexes of the steps to run for --from-step and
+// --only-step, which count from 1
+func selectSteps(total, from, only int) ([]int, error) {
+	if from != 0 && only != 0 {
+		return nil, fmt.Errorf("--from-step and --only-step cannot be used together")
+	}
+	first, last := 1, total
+	if from != 0 {
+		first = from
+	}
+	if only != 0 {
+		first, last = only, only
+	}
+	if first < 1 || first > total {
+		return nil, fmt.Errorf("step %d does not exist (the command has %d steps)", first, total)
+	}
+	var indexes []int
+	for i := first - 1; i < last; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
+}
+
+// stepResult is the outcome of one step of a run
+type stepResult struct {
+	Status   string
+	Duration time.Duration
+}
+
+// runSteps runs the selected steps of a prepared step command in order,
+// showing progress and stopping at the first failure. The timeout covers all
+// steps together. The time of each step is added to timer.
+func runSteps(prepared *preparedCommand, dir string, indexes []int, stdout, stderr io.Writer, stdin io.Reader, timeout time.Duration, timer *runTimer) error {
+	command := prepared.Command
+	total := len(command.Steps)
+	results := make([]stepResult, total)
+	for i := range results {
+		results[i].Status = "skipped"
+	}
+
+	start := time.Now()
+	var err error
+	for _, i := range indexes {
+		fmt.Fprintf(stdout, "[%d/%d] %s\n", i+1, total, prepared.StepDisplays[i])
+
+		remaining := time.Duration(0)
+		if timeout > 0 {
+			if remaining = timeout - time.Since(start); remaining <= 0 {
+				err = &TimeoutError{Timeout: timeout}
+				break
+			}
+		}
+
+		step := *command
+		step.Command = command.Steps[i]
+		step.Steps = nil
+		var cmd *exec.Cmd
+		var script string
+		if cmd, script, err = newExecCmd(&step, dir); err != nil {
+			break
+		}
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		cmd.Stdin = stdin
+
+		stepStart := time.Now()
+		err = runForeground(cmd, command.Limits(), remaining, timeoutGrace)
+		removeScript(script)
+		results[i] = stepResult{Status: "ok", Duration: time.Since(stepStart)}
+		timer.add(fmt.Sprintf("step %d", i+1), results[i].Duration)
+		if err != nil {
+			results[i].Status = "failed"
+			err = fmt.Errorf("step %d/%d failed: %w", i+1, total, err)
+			break
+		}
+	}
+
+	fmt.Fprintln(stdout, "Steps:")
+	for i, result := range results {
+		if result.Status == "skipped" {
+			fmt.Fprintf(stdout, "  %-8s %d. %s\n", result.Status, i+1, prepared.StepDisplays[i])
+			continue
+		}
+		fmt.Fprintf(stdout, "  %-8s %d. %s (%s)\n", result.Status, i+1, prepared.StepDisplays[i], result.Duration.Round(time.Millisecond))
+	}
+	return err
+}
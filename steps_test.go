@@ -0,0 +1,54 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectSteps(t *testing.T) {
+	tests := []struct {
+		from, only int
+		expected   []int
+	}{
+		{0, 0, []int{0, 1, 2}},
+		{2, 0, []int{1, 2}},
+		{0, 3, []int{2}},
+	}
+	for _, tt := range tests {
+		got, err := selectSteps(3, tt.from, tt.only)
+		if err != nil {
+			t.Errorf("selectSteps(3, %d, %d) failed: %v", tt.from, tt.only, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("selectSteps(3, %d, %d) = %v, expected %v", tt.from, tt.only, got, tt.expected)
+		}
+	}
+
+	for _, invalid := range [][2]int{{4, 0}, {0, -1}, {1, 2}} {
+		if _, err := selectSteps(3, invalid[0], invalid[1]); err == nil {
+			t.Errorf("Expected error for from %d, only %d", invalid[0], invalid[1])
+		}
+	}
+}
+
+func TestValidateSteps(t *testing.T) {
+	cmd := &Command{Name: "deploy", Steps: []string{" git pull ", "make build"}}
+	if err := validateSteps(cmd); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cmd.Steps[0] != "git pull" {
+		t.Errorf("Expected steps to be trimmed, got %q", cmd.Steps[0])
+	}
+
+	invalid := []*Command{
+		{Steps: []string{"make", " "}},
+		{Steps: []string{"make"}, Command: "make"},
+		{Steps: []string{"make"}, Type: CommandTypeService},
+	}
+	for _, cmd := range invalid {
+		if err := validateSteps(cmd); err == nil {
+			t.Errorf("Expected error for %+v", cmd)
+		}
+	}
+}
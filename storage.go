@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"go.etcd.io/bbolt"
+)
+
+// StorageConfig is the storage section of config.yaml: the object the
+// commands are kept in instead of the local database, an s3://bucket/key,
+// a gs://bucket/object or the URL of a server run with afv serve
+type StorageConfig struct {
+	Remote string `yaml:"remote,omitempty"`
+}
+
+// remoteCommands keeps the commands of a database as one export document in
+// a store. The commands bucket is a copy of the document refreshed when the
+// database is opened, and every transaction that changes a command writes
+// the document back at the revision it was read, so a change made by
+// another machine in the meantime fails the transaction instead of being
+// overwritten. Runtime state such as run counts is not part of the
+// document and stays local.
+type remoteCommands struct {
+	remote   string
+	store    syncStore
+	data     []byte
+	revision string
+
+	// err is why the document could not be read: the cached commands are
+	// used, but cannot be changed until the store is reachable again
+	err error
+}
+
+// openStorage keeps the commands of db in the store configured in
+// config.yaml, if any
+func openStorage(db *Database) error {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Storage.Remote == "" {
+		return nil
+	}
+	slog.Info("command storage", "remote", cfg.Storage.Remote)
+	store, err := openSyncStore(cfg.Storage.Remote)
+	if err != nil {
+		return fmt.Errorf("storage: %v", err)
+	}
+	return db.UseStore(cfg.Storage.Remote, store)
+}
+
+// UseStore makes the document in store, named by remote, the commands of
+// the database. The local commands become the document if there is none
+// yet.
+func (d *Database) UseStore(remote string, store syncStore) error {
+	r := &remoteCommands{remote: remote, store: store}
+	data, revision, err := store.Read()
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Warning: using the commands cached from %s: %v\n", remote, err)
+		r.err = err
+	case data != nil:
+		doc, err := decodeExportDocument(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", remote, err)
+		}
+		local, err := d.GetAllCommands()
+		if err != nil {
+			return fmt.Errorf("failed to get commands: %v", err)
+		}
+		cs, err := planChanges(local, doc.Commands, true)
+		if err != nil {
+			return fmt.Errorf("%s: %v", remote, err)
+		}
+		if err := d.ApplyChangeSet(cs); err != nil {
+			return fmt.Errorf("failed to apply the commands of %s: %v", remote, err)
+		}
+		r.revision = revision
+	}
+
+	// Compare later changes with the document as encoded here, so a copy
+	// written by another version is not rewritten when nothing changed
+	if data != nil || r.err != nil {
+		if err := d.view("UseStore", func(tx *bbolt.Tx) error {
+			r.data, err = commandDocumentTx(tx)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	d.remote = r
+	if data == nil && r.err == nil {
+		// Nothing is stored yet, so the local commands become the document
+		return d.update("UseStore", func(tx *bbolt.Tx) error { return nil })
+	}
+	return nil
+}
+
+// commandDocumentTx returns the commands bucket as a json export document
+func commandDocumentTx(tx *bbolt.Tx) ([]byte, error) {
+	var buf bytes.Buffer
+	ew, err := newExportWriter(&buf, "json")
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Bucket(commandsBucket).ForEach(func(k, v []byte) error {
+		var cmd Command
+		if err := json.Unmarshal(v, &cmd); err != nil {
+			return err
+		}
+		return ew.Write(cmd)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ew.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// push writes the commands of tx to the store if they changed. An error
+// rolls back tx.
+func (r *remoteCommands) push(tx *bbolt.Tx) error {
+	data, err := commandDocumentTx(tx)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, r.data) {
+		return nil
+	}
+	if r.err != nil {
+		return fmt.Errorf("cannot change commands while %s is unreachable: %v", r.remote, r.err)
+	}
+	revision, err := r.store.Write(data, r.revision)
+	if errors.Is(err, errStaleRevision) {
+		return fmt.Errorf("the commands in %s changed since they were read; run the command again", r.remote)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store the commands in %s: %v", r.remote, err)
+	}
+	r.data, r.revision = data, revision
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memStore is a syncStore held in memory
+type memStore struct {
+	data     []byte
+	revision int
+	writes   int
+	err      error
+}
+
+func (s *memStore) Read() ([]byte, string, error) {
+	if s.err != nil {
+		return nil, "", s.err
+	}
+	return s.data, strconv.Itoa(s.revision), nil
+}
+
+func (s *memStore) Write(data []byte, revision string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	if revision != strconv.Itoa(s.revision) && !(revision == "" && s.data == nil) {
+		return "", errStaleRevision
+	}
+	s.data = data
+	s.revision++
+	s.writes++
+	return strconv.Itoa(s.revision), nil
+}
+
+func TestUseStore(t *testing.T) {
+	store := &memStore{}
+	laptopDB, laptopDir := createTempDB(t)
+	desktopDB, desktopDir := createTempDB(t)
+	defer func() {
+		laptopDB.Close()
+		desktopDB.Close()
+		os.RemoveAll(laptopDir)
+		os.RemoveAll(desktopDir)
+	}()
+
+	// The first database fills the empty store with its commands
+	if err := laptopDB.InsertCommand(Command{Name: "build", Command: "make"}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := laptopDB.UseStore("mem://fleet", store); err != nil {
+		t.Fatalf("UseStore failed: %v", err)
+	}
+	if !strings.Contains(string(store.data), `"make"`) {
+		t.Fatalf("Expected the local commands in the store, got %s", store.data)
+	}
+
+	// Others replace their commands with those of the store
+	if err := desktopDB.InsertCommand(Command{Name: "local", Command: "true"}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := desktopDB.UseStore("mem://fleet", store); err != nil {
+		t.Fatalf("UseStore failed: %v", err)
+	}
+	if _, err := desktopDB.GetCommand("build"); err != nil {
+		t.Errorf("Expected build from the store: %v", err)
+	}
+	if _, err := desktopDB.GetCommand("local"); err == nil {
+		t.Error("Expected the command missing from the store to be removed")
+	}
+
+	// Changes are written to the store
+	if err := desktopDB.InsertCommand(Command{Name: "test", Command: "go test ./..."}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if !strings.Contains(string(store.data), "go test ./...") {
+		t.Errorf("Expected the new command in the store, got %s", store.data)
+	}
+
+	// A change based on the document read before that is rejected and not
+	// kept locally either
+	err := laptopDB.InsertCommand(Command{Name: "lint", Command: "golangci-lint run"})
+	if err == nil || !strings.Contains(err.Error(), "changed since they were read") {
+		t.Errorf("Expected a stale write to fail, got %v", err)
+	}
+	if _, err := laptopDB.GetCommand("lint"); err == nil {
+		t.Error("Expected the rejected command to be rolled back")
+	}
+
+	// Runs only change local state
+	writes := store.writes
+	if err := desktopDB.RecordRun("build", time.Now()); err != nil {
+		t.Fatalf("RecordRun failed: %v", err)
+	}
+	if store.writes != writes {
+		t.Error("Expected recording a run not to write the store")
+	}
+}
+
+func TestUseStoreUnreachable(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+	if err := db.InsertCommand(Command{Name: "build", Command: "make"}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+
+	store := &memStore{err: errors.New("connection refused")}
+	if err := db.UseStore("mem://fleet", store); err != nil {
+		t.Fatalf("UseStore failed: %v", err)
+	}
+	if _, err := db.GetCommand("build"); err != nil {
+		t.Errorf("Expected the cached command: %v", err)
+	}
+	if err := db.RecordRun("build", time.Now()); err != nil {
+		t.Errorf("Expected runs to be recorded: %v", err)
+	}
+	err := db.InsertCommand(Command{Name: "test", Command: "go test ./..."})
+	if err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("Expected changes to be refused, got %v", err)
+	}
+}
+
+func TestStorageConfig(t *testing.T) {
+	var object fakeObject
+	server := fakeGCSServer(&object)
+	defer server.Close()
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	dir := t.TempDir()
+	t.Setenv(configDirEnv, dir)
+	config := "storage:\n  remote: gs://fleet/teams/afvikle.json\n"
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	open := func(name string) *Database {
+		t.Setenv(databaseEnv, filepath.Join(dir, name))
+		db, err := NewDatabase()
+		if err != nil {
+			t.Fatalf("NewDatabase failed: %v", err)
+		}
+		return db
+	}
+
+	first := open("first.db")
+	if err := first.InsertCommand(Command{Name: "deploy", Command: "./deploy.sh"}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	first.Close()
+	if !strings.Contains(string(object.data), "./deploy.sh") {
+		t.Fatalf("Expected the command in the bucket, got %s", object.data)
+	}
+
+	second := open("second.db")
+	defer second.Close()
+	if _, err := second.GetCommand("deploy"); err != nil {
+		t.Errorf("Expected the command from the bucket: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// suggestionScore rates how well candidate matches a mistyped name, lower is
+// better. It reports false if the candidate is not similar enough to suggest.
+func suggestionScore(name, candidate string) (int, bool) {
+	name, candidate = strings.ToLower(name), strings.ToLower(candidate)
+	if name == candidate {
+		return 0, true
+	}
+	distance := levenshtein(name, candidate)
+	if distance <= max(1, len([]rune(name))/3) {
+		return distance, true
+	}
+	// A name that is part of the candidate, like dep for deploy, is a
+	// weaker match than a small typo
+	if strings.Contains(candidate, name) {
+		return 10 + len(candidate) - len(name), true
+	}
+	return 0, false
+}
+
+// nameMatch is a stored name similar to a mistyped one
+type nameMatch struct {
+	Name  string
+	Score int
+}
+
+// suggestNames returns the candidates similar to name, best match first
+func suggestNames(name string, candidates []string) []nameMatch {
+	var matches []nameMatch
+	for _, candidate := range candidates {
+		if score, ok := suggestionScore(name, candidate); ok {
+			matches = append(matches, nameMatch{candidate, score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b nameMatch) int {
+		if a.Score != b.Score {
+			return a.Score - b.Score
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return matches
+}
+
+// resolveCommandName returns name if it is a stored command, or the command
+// it stands for if it is an alias. Otherwise it fails with did-you-mean
+// suggestions or, with fuzzy, resolves to the best match if there is exactly
+// one.
+func resolveCommandName(db *Database, name string, fuzzy bool) (string, error) {
+	metas, err := db.GetCommandMeta()
+	if err != nil {
+		return "", fmt.Errorf("failed to get commands: %v", err)
+	}
+	names := make([]string, len(metas))
+	for i, meta := range metas {
+		if meta.Name == name {
+			return name, nil
+		}
+		names[i] = meta.Name
+	}
+	target, err := db.GetAliasTarget(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get aliases: %v", err)
+	}
+	if target != "" {
+		return target, nil
+	}
+
+	matches := suggestNames(name, names)
+	if fuzzy && len(matches) > 0 && (len(matches) == 1 || matches[0].Score < matches[1].Score) {
+		fmt.Printf("Using '%s' for '%s'.\n", matches[0].Name, name)
+		return matches[0].Name, nil
+	}
+
+	var suggestions []string
+	for _, m := range matches[:min(len(matches), 3)] {
+		suggestions = append(suggestions, m.Name)
+	}
+	switch len(suggestions) {
+	case 0:
+		return "", fmt.Errorf("command '%s' not found", name)
+	case 1:
+		return "", fmt.Errorf("command '%s' not found. Did you mean '%s'?", name, suggestions[0])
+	default:
+		return "", fmt.Errorf("command '%s' not found. Did you mean one of: %s?", name, strings.Join(suggestions, ", "))
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{{"test", "test", 0}, {"tst", "test", 1}, {"biuld", "build", 2}, {"", "abc", 3}, {"kitten", "sitting", 3}} {
+		if got := levenshtein(tc.a, tc.b); got != tc.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestResolveCommandName(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, name := range []string{"test", "text", "deploy", "deploy-staging", "lint"} {
+		if err := db.AddCommand(name, "", "true", ""); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+
+	if name, err := resolveCommandName(db, "lint", false); err != nil || name != "lint" {
+		t.Errorf("Expected an exact name to resolve, got %q, %v", name, err)
+	}
+
+	_, err := resolveCommandName(db, "tet", false)
+	if err == nil || !strings.Contains(err.Error(), "Did you mean one of: test, text?") {
+		t.Errorf("Expected suggestions for 'tet', got %v", err)
+	}
+	// Two equally good matches are not picked automatically
+	if _, err := resolveCommandName(db, "tet", true); err == nil {
+		t.Error("Expected --fuzzy not to choose between test and text")
+	}
+	if name, err := resolveCommandName(db, "lnt", true); err != nil || name != "lint" {
+		t.Errorf("Expected --fuzzy to resolve 'lnt' to 'lint', got %q, %v", name, err)
+	}
+	if name, err := resolveCommandName(db, "dep", true); err != nil || name != "deploy" {
+		t.Errorf("Expected the shortest command containing 'dep', got %q, %v", name, err)
+	}
+
+	_, err = resolveCommandName(db, "format", false)
+	if err == nil || strings.Contains(err.Error(), "Did you mean") {
+		t.Errorf("Expected a plain not found error, got %v", err)
+	}
+}
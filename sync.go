@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// defaultSyncFile is the file in the sync repository holding the commands
+const defaultSyncFile = "afvikle.yaml"
+
+// SyncConfig is the sync section of config.yaml: the git repository the
+// database is mirrored to and the file within it, or the URL of a sync
+// server run with afv serve
+type SyncConfig struct {
+	Repo   string `yaml:"repo,omitempty"`
+	File   string `yaml:"file,omitempty"`
+	Remote string `yaml:"remote,omitempty"`
+}
+
+// Sides of a sync that win commands changed on both since the last sync
+const (
+	syncPreferLocal  = "local"
+	syncPreferRemote = "remote"
+)
+
+// SyncConflict is a command changed differently in the database and in the
+// repository since the last sync. A nil side means it was deleted there.
+type SyncConflict struct {
+	Name   string
+	Local  *Command
+	Remote *Command
+}
+
+// syncIndex normalizes commands for comparison and keys them by name
+func syncIndex(commands []Command) (map[string]Command, error) {
+	index := make(map[string]Command, len(commands))
+	for _, cmd := range commands {
+		cmd = exportCommand(cmd)
+		if err := normalizeCommand(&cmd); err != nil {
+			if cmd.Name == "" {
+				return nil, err
+			}
+			return nil, fmt.Errorf("command '%s': %v", cmd.Name, err)
+		}
+		if _, ok := index[cmd.Name]; ok {
+			return nil, fmt.Errorf("command '%s' is declared more than once", cmd.Name)
+		}
+		index[cmd.Name] = cmd
+	}
+	return index, nil
+}
+
+// mergeCommands merges the local and remote commands against base, the
+// commands both sides had after the last sync. A side that left a command
+// as it was in base takes the change of the other side, including a
+// deletion. Commands changed on both sides are conflicts, resolved in favor
+// of prefer or, without it, kept as they are locally.
+func mergeCommands(base, local, remote []Command, prefer string) ([]Command, []SyncConflict, error) {
+	baseIndex, err := syncIndex(base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("last synced state: %v", err)
+	}
+	localIndex, err := syncIndex(local)
+	if err != nil {
+		return nil, nil, fmt.Errorf("database: %v", err)
+	}
+	remoteIndex, err := syncIndex(remote)
+	if err != nil {
+		return nil, nil, fmt.Errorf("repository: %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, index := range []map[string]Command{baseIndex, localIndex, remoteIndex} {
+		for name := range index {
+			names[name] = true
+		}
+	}
+
+	var merged []Command
+	var conflicts []SyncConflict
+	lookup := func(index map[string]Command, name string) *Command {
+		if cmd, ok := index[name]; ok {
+			return &cmd
+		}
+		return nil
+	}
+	for name := range names {
+		b, l, r := lookup(baseIndex, name), lookup(localIndex, name), lookup(remoteIndex, name)
+		result := l
+		switch {
+		case reflect.DeepEqual(l, r), reflect.DeepEqual(r, b):
+		case reflect.DeepEqual(l, b):
+			result = r
+		default:
+			conflicts = append(conflicts, SyncConflict{Name: name, Local: l, Remote: r})
+			if prefer == syncPreferRemote {
+				result = r
+			}
+		}
+		if result != nil {
+			merged = append(merged, *result)
+		}
+	}
+	slices.SortFunc(merged, func(a, b Command) int { return strings.Compare(a.Name, b.Name) })
+	slices.SortFunc(conflicts, func(a, b SyncConflict) int { return strings.Compare(a.Name, b.Name) })
+	return merged, conflicts, nil
+}
+
+// printConflicts describes each conflict with the fields the remote
+// version changes compared to the local one
+func printConflicts(conflicts []SyncConflict) {
+	fmt.Println("Changed on both sides since the last sync:")
+	for _, c := range conflicts {
+		fmt.Printf("  ! %s\n", c.Name)
+		switch {
+		case c.Local == nil:
+			fmt.Println("      deleted locally, changed in the repository")
+		case c.Remote == nil:
+			fmt.Println("      changed locally, deleted in the repository")
+		default:
+			for _, change := range diffCommandFields(*c.Local, *c.Remote) {
+				fmt.Printf("      %s\n", change)
+			}
+		}
+	}
+}
+
+// runGit runs git in repo and returns its trimmed output
+func runGit(repo string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// syncRemote returns the git remote to push to, preferring origin, or ""
+// if the repository has none
+func syncRemote(repo string) (string, error) {
+	out, err := runGit(repo, "remote")
+	if err != nil {
+		return "", err
+	}
+	remotes := strings.Fields(out)
+	switch {
+	case len(remotes) == 0:
+		return "", nil
+	case slices.Contains(remotes, "origin"):
+		return "origin", nil
+	}
+	return remotes[0], nil
+}
+
+// syncBasePath returns the file recording the commands as of the last
+// sync, which tells apart changes made locally from those made elsewhere
+func (d *Database) syncBasePath() string {
+	name := strings.TrimSuffix(filepath.Base(d.Path()), filepath.Ext(d.Path()))
+	return filepath.Join(d.DataDir(), name+".sync-base.yaml")
+}
+
+// readSyncFile returns the commands of an export document, or none if the
+// file does not exist yet
+func readSyncFile(path string) ([]Command, []byte, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	doc, err := decodeExportDocument(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return doc.Commands, data, nil
+}
+
+// checkPrefer validates the side picked to win conflicts, if any
+func checkPrefer(prefer string) error {
+	if prefer != "" && prefer != syncPreferLocal && prefer != syncPreferRemote {
+		return fmt.Errorf("unknown side '%s' (expected %s or %s)", prefer, syncPreferLocal, syncPreferRemote)
+	}
+	return nil
+}
+
+// mergeSync merges the commands of the other side of a sync, named by
+// source, into the database against the state of the last sync. It returns
+// the merged commands both sides end up with and the changes it made to
+// the database. Conflicts leave the database alone unless prefer is set.
+func mergeSync(db *Database, source string, remote []Command, prefer string) ([]Command, ChangeSet, error) {
+	base, _, err := readSyncFile(db.syncBasePath())
+	if err != nil {
+		return nil, ChangeSet{}, err
+	}
+	local, err := db.GetAllCommands()
+	if err != nil {
+		return nil, ChangeSet{}, fmt.Errorf("failed to get commands: %v", err)
+	}
+
+	merged, conflicts, err := mergeCommands(base, local, remote, prefer)
+	if err != nil {
+		return nil, ChangeSet{}, err
+	}
+	if len(conflicts) > 0 {
+		printConflicts(conflicts)
+		if prefer == "" {
+			return nil, ChangeSet{}, fmt.Errorf("%d command(s) changed on both sides; rerun with --prefer %s or --prefer %s", len(conflicts), syncPreferLocal, syncPreferRemote)
+		}
+		fmt.Printf("Kept the %s version of %d command(s).\n", prefer, len(conflicts))
+	}
+
+	cs, err := planChanges(local, merged, true)
+	if err != nil {
+		return nil, ChangeSet{}, err
+	}
+	if !cs.Empty() {
+		fmt.Printf("Pulled from %s:\n", source)
+		cs.Print()
+		if err := db.ApplyChangeSet(cs); err != nil {
+			return nil, ChangeSet{}, fmt.Errorf("failed to apply changes: %v", err)
+		}
+	}
+	return merged, cs, nil
+}
+
+// recordSync keeps data, the export document of the merged commands, as
+// the state of the last sync
+func (d *Database) recordSync(data []byte) error {
+	if err := os.WriteFile(d.syncBasePath(), data, 0600); err != nil {
+		return fmt.Errorf("failed to record the synced state: %v", err)
+	}
+	return nil
+}
+
+// Sync mirrors the database to file in the git repository repo: it pulls
+// the repository, merges the commands changed on either side since the
+// last sync into both, then commits and pushes the file. Conflicts stop
+// the sync before anything is changed unless prefer picks a side.
+func Sync(db *Database, repo, file, prefer string) error {
+	if err := checkPrefer(prefer); err != nil {
+		return err
+	}
+	if _, err := runGit(repo, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return fmt.Errorf("%s is not a git repository: %v", repo, err)
+	}
+	remote, err := syncRemote(repo)
+	if err != nil {
+		return err
+	}
+	if remote != "" {
+		if _, err := runGit(repo, "fetch", remote); err != nil {
+			return err
+		}
+		// Without an upstream nothing has been pushed yet
+		if _, err := runGit(repo, "rev-parse", "--verify", "--quiet", "@{upstream}"); err == nil {
+			if _, err := runGit(repo, "merge", "--ff-only", "@{upstream}"); err != nil {
+				return fmt.Errorf("the repository has diverged from %s, resolve it with git first: %v", remote, err)
+			}
+		}
+	}
+
+	path := filepath.Join(repo, file)
+	remoteCommands, remoteData, err := readSyncFile(path)
+	if err != nil {
+		return err
+	}
+	merged, cs, err := mergeSync(db, path, remoteCommands, prefer)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeExport(&buf, merged, "yaml", false); err != nil {
+		return err
+	}
+	committed := false
+	if !bytes.Equal(buf.Bytes(), remoteData) {
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		if _, err := runGit(repo, "add", "--", file); err != nil {
+			return err
+		}
+		host, _ := os.Hostname()
+		if _, err := runGit(repo, "commit", "-m", "afv sync from "+host, "--", file); err != nil {
+			return err
+		}
+		committed = true
+	}
+	if err := db.recordSync(buf.Bytes()); err != nil {
+		return err
+	}
+
+	pushed := false
+	if remote != "" {
+		// Also push commits a failed push left behind
+		ahead, err := runGit(repo, "rev-list", "--count", "@{upstream}..HEAD")
+		switch {
+		case err != nil:
+			if _, err := runGit(repo, "push", "--set-upstream", remote, "HEAD"); err != nil {
+				return err
+			}
+			pushed = true
+		case ahead != "0":
+			if _, err := runGit(repo, "push"); err != nil {
+				return err
+			}
+			pushed = true
+		}
+	}
+
+	switch {
+	case pushed:
+		fmt.Printf("Pushed %s with %d command(s) to %s.\n", file, len(merged), remote)
+	case committed:
+		fmt.Printf("Committed %s with %d command(s); the repository has no remote to push to.\n", file, len(merged))
+	case cs.Empty():
+		fmt.Println("Already in sync.")
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeCommands(t *testing.T) {
+	base := []Command{
+		{Name: "build", Command: "make"},
+		{Name: "deploy", Command: "./deploy.sh"},
+		{Name: "lint", Command: "go vet ./..."},
+		{Name: "test", Command: "go test ./..."},
+	}
+	local := []Command{
+		{Name: "build", Command: "make all"},
+		{Name: "deploy", Command: "./deploy.sh"},
+		{Name: "test", Command: "go test -race ./..."},
+		{Name: "fmt", Command: "gofmt -l ."},
+	}
+	remote := []Command{
+		{Name: "build", Command: "make"},
+		{Name: "lint", Command: "go vet ./..."},
+		{Name: "test", Command: "go test -v ./..."},
+		{Name: "bench", Command: "go test -bench ."},
+	}
+
+	merged, conflicts, err := mergeCommands(base, local, remote, "")
+	if err != nil {
+		t.Fatalf("mergeCommands failed: %v", err)
+	}
+	want := map[string]string{
+		"bench": "go test -bench .",    // added remotely
+		"build": "make all",            // changed locally
+		"fmt":   "gofmt -l .",          // added locally
+		"test":  "go test -race ./...", // conflict, kept local
+	}
+	if len(merged) != len(want) {
+		t.Fatalf("Expected %d merged commands, got %+v", len(want), merged)
+	}
+	for _, cmd := range merged {
+		if want[cmd.Name] != cmd.Command {
+			t.Errorf("Unexpected merged %s: %q", cmd.Name, cmd.Command)
+		}
+	}
+	if len(conflicts) != 1 || conflicts[0].Name != "test" || conflicts[0].Remote.Command != "go test -v ./..." {
+		t.Errorf("Expected a conflict on test, got %+v", conflicts)
+	}
+
+	merged, _, err = mergeCommands(base, local, remote, syncPreferRemote)
+	if err != nil {
+		t.Fatalf("mergeCommands failed: %v", err)
+	}
+	for _, cmd := range merged {
+		if cmd.Name == "test" && cmd.Command != "go test -v ./..." {
+			t.Errorf("Expected the remote test with --prefer remote, got %q", cmd.Command)
+		}
+	}
+
+	// A deletion on one side against a change on the other is a conflict
+	_, conflicts, err = mergeCommands(base[:1], nil, []Command{{Name: "build", Command: "make -j4"}}, "")
+	if err != nil || len(conflicts) != 1 || conflicts[0].Local != nil {
+		t.Errorf("Expected a delete/change conflict, got %+v, %v", conflicts, err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "afv")
+	t.Setenv("GIT_AUTHOR_EMAIL", "afv@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "afv")
+	t.Setenv("GIT_COMMITTER_EMAIL", "afv@example.com")
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	remote := filepath.Join(dir, "remote.git")
+	laptop, desktop := filepath.Join(dir, "laptop"), filepath.Join(dir, "desktop")
+	git("init", "--quiet", "--bare", remote)
+	git("clone", "--quiet", remote, laptop)
+	git("clone", "--quiet", remote, desktop)
+
+	laptopDB, laptopDir := createTempDB(t)
+	desktopDB, desktopDir := createTempDB(t)
+	defer func() {
+		laptopDB.Close()
+		desktopDB.Close()
+		os.RemoveAll(laptopDir)
+		os.RemoveAll(desktopDir)
+	}()
+
+	if err := laptopDB.InsertCommand(Command{Name: "build", Command: "make"}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := Sync(laptopDB, laptop, defaultSyncFile, ""); err != nil {
+		t.Fatalf("Sync from the laptop failed: %v", err)
+	}
+	if err := desktopDB.InsertCommand(Command{Name: "test", Command: "go test ./..."}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := Sync(desktopDB, desktop, defaultSyncFile, ""); err != nil {
+		t.Fatalf("Sync from the desktop failed: %v", err)
+	}
+	if err := Sync(laptopDB, laptop, defaultSyncFile, ""); err != nil {
+		t.Fatalf("Second sync from the laptop failed: %v", err)
+	}
+	for _, db := range []*Database{laptopDB, desktopDB} {
+		for _, name := range []string{"build", "test"} {
+			if _, err := db.GetCommand(name); err != nil {
+				t.Errorf("Expected %s on both machines: %v", name, err)
+			}
+		}
+	}
+
+	// A deletion travels like any other change
+	if err := desktopDB.DeleteCommand("build"); err != nil {
+		t.Fatalf("Failed to delete command: %v", err)
+	}
+	if err := Sync(desktopDB, desktop, defaultSyncFile, ""); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := Sync(laptopDB, laptop, defaultSyncFile, ""); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if _, err := laptopDB.GetCommand("build"); err == nil {
+		t.Error("Expected build to be deleted on the laptop too")
+	}
+
+	// Changes to the same command on both machines stop the sync until a
+	// side is picked
+	if err := laptopDB.ModifyCommand("test", func(cmd *Command) error { cmd.Command = "go test -race ./..."; return nil }); err != nil {
+		t.Fatalf("Failed to modify command: %v", err)
+	}
+	if err := desktopDB.ModifyCommand("test", func(cmd *Command) error { cmd.Command = "go test -v ./..."; return nil }); err != nil {
+		t.Fatalf("Failed to modify command: %v", err)
+	}
+	if err := Sync(desktopDB, desktop, defaultSyncFile, ""); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := Sync(laptopDB, laptop, defaultSyncFile, ""); err == nil {
+		t.Fatal("Expected a conflict")
+	}
+	if test, _ := laptopDB.GetCommand("test"); test.Command != "go test -race ./..." {
+		t.Errorf("Expected a conflict to leave the database alone, got %q", test.Command)
+	}
+	if err := Sync(laptopDB, laptop, defaultSyncFile, syncPreferRemote); err != nil {
+		t.Fatalf("Sync with --prefer remote failed: %v", err)
+	}
+	if test, _ := laptopDB.GetCommand("test"); test.Command != "go test -v ./..." {
+		t.Errorf("Expected the remote version, got %q", test.Command)
+	}
+}
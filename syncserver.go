@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// syncTokenEnv holds the token sync clients present to afv serve. Clients
+// use it too when set, before looking in the keyring.
+const syncTokenEnv = "AFVIKLE_SYNC_TOKEN"
+
+// syncCommandsPath is the API endpoint serving the commands of the database
+const syncCommandsPath = "/v1/commands"
+
+// syncRevision identifies the state of the commands a client last read,
+// so writes based on an outdated state can be rejected
+func syncRevision(data []byte) string {
+	return `"` + checksum(data) + `"`
+}
+
+// syncServer serves the commands of a database over HTTP to clients
+// presenting token. Writes replace every command at once and must name the
+// revision they are based on. The database is released between requests,
+// so other afv invocations on the host can use it while the server runs.
+type syncServer struct {
+	db    *Database
+	token string
+	// mu keeps a write from interleaving with the revision check of another,
+	// and requests from reopening the database at the same time
+	mu sync.Mutex
+}
+
+// newSyncServer returns the handler of afv serve for a released database
+func newSyncServer(db *Database, token string) http.Handler {
+	mux := http.NewServeMux()
+	s := &syncServer{db: db, token: token}
+	mux.HandleFunc(syncCommandsPath, s.handleCommands)
+	return mux
+}
+
+// document encodes the stored commands as the export document clients
+// read, along with its revision
+func (s *syncServer) document() ([]byte, []Command, error) {
+	commands, err := s.db.GetAllCommands()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get commands: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := encodeExport(&buf, commands, "json", false); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), commands, nil
+}
+
+func (s *syncServer) handleCommands(w http.ResponseWriter, r *http.Request) {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(auth), []byte(s.token)) != 1 {
+		http.Error(w, "invalid or missing sync token", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.db.Reopen(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer s.db.Release()
+
+	data, current, err := s.document()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", syncRevision(data))
+		w.Write(data)
+	case http.MethodPut:
+		if r.Header.Get("If-Match") != syncRevision(data) {
+			http.Error(w, "the commands changed since they were read", http.StatusPreconditionFailed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxImportSize+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxImportSize {
+			http.Error(w, fmt.Sprintf("document exceeds %d bytes", maxImportSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		doc, err := decodeExportDocument(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cs, err := planChanges(current, doc.Commands, true)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err := s.db.ApplyChangeSet(cs); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if data, _, err = s.document(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("ETag", syncRevision(data))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Serve exposes the database to sync clients on addr until the server
+// fails. With cert and key set it serves HTTPS.
+func Serve(db *Database, addr, cert, key string) error {
+	token := os.Getenv(syncTokenEnv)
+	if token == "" {
+		return fmt.Errorf("set %s to the token clients must present", syncTokenEnv)
+	}
+	if (cert == "") != (key == "") {
+		return fmt.Errorf("--cert and --key must be given together")
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	path := db.Path()
+	if err := db.Release(); err != nil {
+		listener.Close()
+		return err
+	}
+	defer db.Reopen()
+
+	server := &http.Server{Handler: newSyncServer(db, token), ReadHeaderTimeout: 10 * time.Second}
+	fmt.Printf("Serving %s on %s\n", path, listener.Addr())
+	if cert != "" {
+		return server.ServeTLS(listener, cert, key)
+	}
+	return server.Serve(listener)
+}
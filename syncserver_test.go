@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// whileOpen opens a database released for a sync server while fn reads it
+func whileOpen(t *testing.T, db *Database, fn func()) {
+	if err := db.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer db.Release()
+	fn()
+}
+
+func TestSyncServer(t *testing.T) {
+	serverDB, serverDir := createTempDB(t)
+	laptopDB, laptopDir := createTempDB(t)
+	desktopDB, desktopDir := createTempDB(t)
+	defer func() {
+		for _, db := range []*Database{serverDB, laptopDB, desktopDB} {
+			db.Close()
+		}
+		for _, dir := range []string{serverDir, laptopDir, desktopDir} {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	if err := serverDB.Release(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newSyncServer(serverDB, "secret"))
+	defer server.Close()
+
+	t.Setenv(syncTokenEnv, "wrong")
+	if err := SyncRemote(laptopDB, server.URL, ""); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Expected a wrong token to be rejected, got %v", err)
+	}
+	t.Setenv(syncTokenEnv, "secret")
+
+	if err := laptopDB.InsertCommand(Command{Name: "build", Command: "make"}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := SyncRemote(laptopDB, server.URL, ""); err != nil {
+		t.Fatalf("Sync from the laptop failed: %v", err)
+	}
+	whileOpen(t, serverDB, func() {
+		if _, err := serverDB.GetCommand("build"); err != nil {
+			t.Errorf("Expected build on the server: %v", err)
+		}
+	})
+	if err := SyncRemote(desktopDB, server.URL, ""); err != nil {
+		t.Fatalf("Sync from the desktop failed: %v", err)
+	}
+	if _, err := desktopDB.GetCommand("build"); err != nil {
+		t.Errorf("Expected build on the desktop: %v", err)
+	}
+
+	// Both clients change build; the second one to sync hits a conflict
+	if err := laptopDB.ModifyCommand("build", func(cmd *Command) error { cmd.Command = "make all"; return nil }); err != nil {
+		t.Fatalf("Failed to modify command: %v", err)
+	}
+	if err := desktopDB.ModifyCommand("build", func(cmd *Command) error { cmd.Command = "make -j4"; return nil }); err != nil {
+		t.Fatalf("Failed to modify command: %v", err)
+	}
+	if err := SyncRemote(laptopDB, server.URL, ""); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if err := SyncRemote(desktopDB, server.URL, ""); err == nil {
+		t.Fatal("Expected a conflict")
+	}
+	if err := SyncRemote(desktopDB, server.URL, syncPreferLocal); err != nil {
+		t.Fatalf("Sync with --prefer local failed: %v", err)
+	}
+	whileOpen(t, serverDB, func() {
+		if build, _ := serverDB.GetCommand("build"); build.Command != "make -j4" {
+			t.Errorf("Expected the desktop version on the server, got %q", build.Command)
+		}
+	})
+}
+
+func TestSyncServerRevision(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.Release(); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newSyncServer(db, "secret"))
+	defer server.Close()
+
+	// A write must be based on the current revision
+	doc := `{"version": 1, "commands": [{"name": "build", "command": "make"}]}`
+	req, _ := http.NewRequest(http.MethodPut, server.URL+syncCommandsPath, strings.NewReader(doc))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("If-Match", `"stale"`)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale revision, got %s", resp.Status)
+	}
+	whileOpen(t, db, func() {
+		if _, err := db.GetCommand("build"); err == nil {
+			t.Error("Expected a stale write to change nothing")
+		}
+	})
+}
+
+func TestSyncEndpoint(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   string
+	}{
+		{"https://afv.example.com/", "https://afv.example.com/v1/commands"},
+		{"http://127.0.0.1:8787", "http://127.0.0.1:8787/v1/commands"},
+		{"http://localhost:8787", "http://localhost:8787/v1/commands"},
+		{"http://afv.example.com", ""},
+		{"afv.example.com", ""},
+	}
+	for _, tt := range tests {
+		got, err := syncEndpoint(tt.remote)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("syncEndpoint(%q) = %q, expected an error", tt.remote, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("syncEndpoint(%q) = %q, %v, expected %q", tt.remote, got, err, tt.want)
+		}
+	}
+}
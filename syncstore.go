@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// syncHTTPClient talks to sync servers and object stores
+var syncHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// errStaleRevision is returned by writes based on a revision that is no
+// longer current because another client wrote in the meantime
+var errStaleRevision = errors.New("the document changed since it was read")
+
+// syncStore holds the commands a database is synced with as one export
+// document. Writes are conditional on the revision that was read, so two
+// clients syncing at once cannot overwrite each other's changes.
+type syncStore interface {
+	// Read returns the document and its revision, or nil and "" if there
+	// is no document yet
+	Read() ([]byte, string, error)
+	// Write replaces the document if it is still at revision ("" for none)
+	// and returns its new revision, and fails with errStaleRevision otherwise
+	Write(data []byte, revision string) (string, error)
+}
+
+// openSyncStore returns the store at remote: the URL of a server run with
+// afv serve, an s3://bucket/key or a gs://bucket/object
+func openSyncStore(remote string) (syncStore, error) {
+	u, err := url.Parse(remote)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid sync remote '%s' (expected https://host, s3://bucket/key or gs://bucket/object)", remote)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return openServerStore(remote)
+	case "s3":
+		return openS3Store(u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "gs":
+		return openGCSStore(u.Host, strings.TrimPrefix(u.Path, "/"))
+	}
+	return nil, fmt.Errorf("invalid sync remote '%s' (expected https://host, s3://bucket/key or gs://bucket/object)", remote)
+}
+
+// storeRequest sends req and returns the response with its body read
+func storeRequest(req *http.Request) (*http.Response, []byte, error) {
+	resp, err := syncHTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reach %s: %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImportSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the response of %s: %v", req.URL.Host, err)
+	}
+	if len(data) > maxImportSize {
+		return nil, nil, fmt.Errorf("the response of %s exceeds %d bytes", req.URL.Host, maxImportSize)
+	}
+	return resp, data, nil
+}
+
+// storeError describes an unexpected response of a store
+func storeError(resp *http.Response, body []byte) error {
+	return fmt.Errorf("%s: %s: %s", resp.Request.URL.Host, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// serverStore is the database of a server run with afv serve
+type serverStore struct {
+	endpoint string
+	token    string
+}
+
+// syncEndpoint returns the commands endpoint of the server at remote. The
+// token is only sent over plain HTTP to the local machine.
+func syncEndpoint(remote string) (string, error) {
+	u, err := url.Parse(strings.TrimSuffix(remote, "/"))
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid sync server URL '%s'", remote)
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if ip := net.ParseIP(u.Hostname()); u.Hostname() != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return "", fmt.Errorf("refusing to send the sync token over http to %s, use https", u.Host)
+		}
+	default:
+		return "", fmt.Errorf("invalid sync server URL '%s' (expected https://host)", remote)
+	}
+	return u.String() + syncCommandsPath, nil
+}
+
+// syncToken returns the token for the sync server at remote, from the
+// environment or else from the keyring
+func syncToken(remote string) (string, error) {
+	if token := os.Getenv(syncTokenEnv); token != "" {
+		return token, nil
+	}
+	return GetCredential(remote, CredentialSyncToken)
+}
+
+// openServerStore returns the store of the sync server at remote
+func openServerStore(remote string) (*serverStore, error) {
+	endpoint, err := syncEndpoint(remote)
+	if err != nil {
+		return nil, err
+	}
+	token, err := syncToken(remote)
+	if err != nil {
+		return nil, err
+	}
+	return &serverStore{endpoint: endpoint, token: token}, nil
+}
+
+func (s *serverStore) Read() ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, s.endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	resp, data, err := storeRequest(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", storeError(resp, data)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+func (s *serverStore) Write(data []byte, revision string) (string, error) {
+	req, err := http.NewRequest(http.MethodPut, s.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", revision)
+	resp, body, err := storeRequest(req)
+	if err != nil {
+		return "", err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return resp.Header.Get("ETag"), nil
+	case http.StatusPreconditionFailed:
+		return "", errStaleRevision
+	}
+	return "", storeError(resp, body)
+}
+
+// SyncRemote merges the database with the commands in the store at remote
+// like Sync does with a git repository. A write that races with another
+// client is rejected by the store and nothing is overwritten.
+func SyncRemote(db *Database, remote, prefer string) error {
+	if err := checkPrefer(prefer); err != nil {
+		return err
+	}
+	store, err := openSyncStore(remote)
+	if err != nil {
+		return err
+	}
+
+	data, revision, err := store.Read()
+	if err != nil {
+		return err
+	}
+	var remoteCommands []Command
+	if data != nil {
+		doc, err := decodeExportDocument(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", remote, err)
+		}
+		remoteCommands = doc.Commands
+	}
+
+	merged, cs, err := mergeSync(db, remote, remoteCommands, prefer)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := encodeExport(&buf, merged, "json", false); err != nil {
+		return err
+	}
+	pushed := false
+	if !bytes.Equal(buf.Bytes(), data) {
+		_, err := store.Write(buf.Bytes(), revision)
+		if errors.Is(err, errStaleRevision) {
+			return fmt.Errorf("the commands on %s changed during the sync; run 'afv sync' again", remote)
+		}
+		if err != nil {
+			return err
+		}
+		pushed = true
+	}
+	if err := db.recordSync(buf.Bytes()); err != nil {
+		return err
+	}
+
+	switch {
+	case pushed:
+		fmt.Printf("Pushed %d command(s) to %s.\n", len(merged), remote)
+	case cs.Empty():
+		fmt.Println("Already in sync.")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"go.etcd.io/bbolt"
+)
+
+// tagsBucket indexes command names by tag. It holds one nested bucket per
+// tag whose keys are the names of the commands carrying it, so tag queries
+// only read the matching commands.
+var tagsBucket = []byte("tags")
+
+// indexTagsTx moves a command in the tag index from its old tags to its new
+// ones within tx
+func indexTagsTx(tx *bbolt.Tx, name string, oldTags, newTags []string) error {
+	index := tx.Bucket(tagsBucket)
+	for _, tag := range oldTags {
+		if slices.Contains(newTags, tag) {
+			continue
+		}
+		b := index.Bucket([]byte(tag))
+		if b == nil {
+			continue
+		}
+		if err := b.Delete([]byte(name)); err != nil {
+			return err
+		}
+		if k, _ := b.Cursor().First(); k == nil {
+			if err := index.DeleteBucket([]byte(tag)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, tag := range newTags {
+		b, err := index.CreateBucketIfNotExists([]byte(tag))
+		if err != nil {
+			return fmt.Errorf("failed to index tag '%s': %v", tag, err)
+		}
+		if err := b.Put([]byte(name), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storedTags returns the tags of a stored command record
+func storedTags(data []byte) ([]string, error) {
+	var cmd struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return nil, err
+	}
+	return cmd.Tags, nil
+}
+
+// deleteCommandTx removes a command, its tag and metadata index entries and
+// its aliases within tx
+func deleteCommandTx(tx *bbolt.Tx, name string) error {
+	b := tx.Bucket(commandsBucket)
+	data := b.Get([]byte(name))
+	if data == nil {
+		return fmt.Errorf("command '%s' not found", name)
+	}
+	tags, err := storedTags(data)
+	if err != nil {
+		return err
+	}
+	if err := indexTagsTx(tx, name, tags, nil); err != nil {
+		return err
+	}
+	if err := tx.Bucket(metaBucket).Delete([]byte(name)); err != nil {
+		return err
+	}
+	if err := deleteAliasesTx(tx, name); err != nil {
+		return err
+	}
+	return b.Delete([]byte(name))
+}
+
+// rebuildTagIndexTx recreates the tag index from the stored commands, used
+// for databases created before the index existed
+func rebuildTagIndexTx(tx *bbolt.Tx) error {
+	if tx.Bucket(tagsBucket) != nil {
+		if err := tx.DeleteBucket(tagsBucket); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.CreateBucket(tagsBucket); err != nil {
+		return err
+	}
+	return tx.Bucket(commandsBucket).ForEach(func(k, v []byte) error {
+		tags, err := storedTags(v)
+		if err != nil {
+			return err
+		}
+		return indexTagsTx(tx, string(k), nil, tags)
+	})
+}
+
+// GetCommandsByTag returns the commands carrying tag, sorted by name, or all
+// commands if tag is empty
+func (d *Database) GetCommandsByTag(tag string) ([]Command, error) {
+	if tag == "" {
+		return d.GetAllCommands()
+	}
+
+	var commands []Command
+	err := d.view("GetCommandsByTag", func(tx *bbolt.Tx) error {
+		names := tx.Bucket(tagsBucket).Bucket([]byte(tag))
+		if names == nil {
+			return nil
+		}
+		b := tx.Bucket(commandsBucket)
+		return names.ForEach(func(k, _ []byte) error {
+			data := b.Get(k)
+			if data == nil {
+				return fmt.Errorf("tag index refers to missing command '%s'", k)
+			}
+			var cmd Command
+			if err := json.Unmarshal(data, &cmd); err != nil {
+				return err
+			}
+			commands = append(commands, cmd)
+			return nil
+		})
+	})
+	return commands, err
+}
+
+// GetCommandNamesByTag returns the names of the commands carrying tag,
+// sorted by name, from the tag index alone
+func (d *Database) GetCommandNamesByTag(tag string) ([]string, error) {
+	var names []string
+	err := d.view("GetCommandNamesByTag", func(tx *bbolt.Tx) error {
+		tagged := tx.Bucket(tagsBucket).Bucket([]byte(tag))
+		if tagged == nil {
+			return nil
+		}
+		return tagged.ForEach(func(k, _ []byte) error {
+			names = append(names, string(k))
+			return nil
+		})
+	})
+	return names, err
+}
+
+// GetTags returns every tag in use with the number of commands carrying it
+func (d *Database) GetTags() (map[string]int, error) {
+	tags := map[string]int{}
+	err := d.view("GetTags", func(tx *bbolt.Tx) error {
+		return tx.Bucket(tagsBucket).ForEachBucket(func(k []byte) error {
+			tags[string(k)] = tx.Bucket(tagsBucket).Bucket(k).Stats().KeyN
+			return nil
+		})
+	})
+	return tags, err
+}
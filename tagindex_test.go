@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func commandNames(commands []Command) []string {
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+	}
+	return names
+}
+
+func TestTagIndexFollowsWrites(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	for _, cmd := range []Command{
+		{Name: "up", Command: "docker compose up", Tags: []string{"docker", "dev"}},
+		{Name: "down", Command: "docker compose down", Tags: []string{"docker"}},
+		{Name: "test", Command: "go test ./...", Tags: []string{"dev"}},
+	} {
+		if err := db.InsertCommand(cmd); err != nil {
+			t.Fatalf("InsertCommand failed: %v", err)
+		}
+	}
+
+	docker, err := db.GetCommandsByTag("docker")
+	if err != nil {
+		t.Fatalf("GetCommandsByTag failed: %v", err)
+	}
+	if !reflect.DeepEqual(commandNames(docker), []string{"down", "up"}) {
+		t.Errorf("Expected [down up], got %v", commandNames(docker))
+	}
+
+	// Replacing moves the command between tags
+	if err := db.ReplaceCommand(Command{Name: "up", Command: "docker compose up -d", Tags: []string{"docker"}}); err != nil {
+		t.Fatalf("ReplaceCommand failed: %v", err)
+	}
+	dev, _ := db.GetCommandsByTag("dev")
+	if !reflect.DeepEqual(commandNames(dev), []string{"test"}) {
+		t.Errorf("Expected [test] after replace, got %v", commandNames(dev))
+	}
+
+	// Modifying in place updates the index too
+	err = db.ModifyCommand("test", func(cmd *Command) error {
+		cmd.Tags = append(cmd.Tags, "ci")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ModifyCommand failed: %v", err)
+	}
+	ci, _ := db.GetCommandsByTag("ci")
+	if !reflect.DeepEqual(commandNames(ci), []string{"test"}) {
+		t.Errorf("Expected [test] for ci, got %v", commandNames(ci))
+	}
+
+	if err := db.DeleteCommand("down"); err != nil {
+		t.Fatalf("DeleteCommand failed: %v", err)
+	}
+	tags, err := db.GetTags()
+	if err != nil {
+		t.Fatalf("GetTags failed: %v", err)
+	}
+	if !reflect.DeepEqual(tags, map[string]int{"docker": 1, "dev": 1, "ci": 1}) {
+		t.Errorf("Unexpected tag counts %v", tags)
+	}
+
+	if err := db.DeleteCommand("test"); err != nil {
+		t.Fatalf("DeleteCommand failed: %v", err)
+	}
+	if tags, _ := db.GetTags(); !reflect.DeepEqual(tags, map[string]int{"docker": 1}) {
+		t.Errorf("Expected emptied tags to be dropped, got %v", tags)
+	}
+	if none, err := db.GetCommandsByTag("dev"); err != nil || len(none) != 0 {
+		t.Errorf("Expected no commands for an unused tag, got %v, %v", none, err)
+	}
+}
+
+func TestTagIndexChangeSet(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.InsertCommand(Command{Name: "old", Command: "true", Tags: []string{"ops"}}); err != nil {
+		t.Fatal(err)
+	}
+	cs := ChangeSet{
+		Create: []Command{{Name: "new", Command: "true", Tags: []string{"ops"}}},
+		Delete: []string{"old"},
+	}
+	if err := db.ApplyChangeSet(cs); err != nil {
+		t.Fatalf("ApplyChangeSet failed: %v", err)
+	}
+	ops, _ := db.GetCommandsByTag("ops")
+	if !reflect.DeepEqual(commandNames(ops), []string{"new"}) {
+		t.Errorf("Expected [new], got %v", commandNames(ops))
+	}
+}
+
+func TestTagIndexRebuiltForOldDatabase(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer os.RemoveAll(tempDir)
+
+	if err := db.InsertCommand(Command{Name: "up", Command: "true", Tags: []string{"docker"}}); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a database from before the index existed
+	err := db.db.Update(func(tx *bbolt.Tx) error {
+		return tx.DeleteBucket(tagsBucket)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := db.db.Path()
+	db.Close()
+
+	db, err = openDatabase(path)
+	if err != nil {
+		t.Fatalf("openDatabase failed: %v", err)
+	}
+	defer db.Close()
+
+	docker, err := db.GetCommandsByTag("docker")
+	if err != nil || !reflect.DeepEqual(commandNames(docker), []string{"up"}) {
+		t.Errorf("Expected rebuilt index to find [up], got %v, %v", commandNames(docker), err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// normalizeTags trims and deduplicates tags, keeping their order. Tags must
+// not contain whitespace or commas so they can be given as a list on the
+// command line.
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || slices.Contains(normalized, tag) {
+			continue
+		}
+		if strings.ContainsAny(tag, " \t\n,") {
+			return nil, fmt.Errorf("invalid tag '%s': tags must not contain whitespace or commas", tag)
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+// HasTag reports whether the command carries tag
+func (c *Command) HasTag(tag string) bool {
+	return slices.Contains(c.Tags, tag)
+}
+
+// splitTags returns the tags given with repeatable --tag flags, each of
+// which may hold a comma-separated list
+func splitTags(values []string) []string {
+	var tags []string
+	for _, value := range values {
+		tags = append(tags, splitList(value)...)
+	}
+	return tags
+}
+
+// TagCommand adds the tags in add to a stored command and removes those in
+// remove
+func TagCommand(db *Database, name string, add, remove []string) error {
+	return db.ModifyCommand(name, func(cmd *Command) error {
+		kept := slices.DeleteFunc(slices.Clone(cmd.Tags), func(tag string) bool {
+			return slices.Contains(remove, tag)
+		})
+		tags, err := normalizeTags(append(kept, add...))
+		if err != nil {
+			return err
+		}
+		cmd.Tags = tags
+		return nil
+	})
+}
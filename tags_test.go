@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{" docker ", "ci", "", "docker"})
+	if err != nil {
+		t.Fatalf("normalizeTags failed: %v", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"docker", "ci"}) {
+		t.Errorf("Expected [docker ci], got %v", tags)
+	}
+
+	if _, err := normalizeTags([]string{"two words"}); err == nil {
+		t.Error("Expected error for a tag with whitespace")
+	}
+	if _, err := normalizeTags([]string{"a,b"}); err == nil {
+		t.Error("Expected error for a tag with a comma")
+	}
+}
+
+func TestSplitTags(t *testing.T) {
+	tags := splitTags([]string{"ci, docker", "go", ""})
+	if !reflect.DeepEqual(tags, []string{"ci", "docker", "go"}) {
+		t.Errorf("Expected [ci docker go], got %v", tags)
+	}
+}
+
+func TestTagCommand(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.InsertCommand(Command{Name: "build", Command: "go build", Tags: []string{"go"}}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := TagCommand(db, "build", []string{"ci", "go"}, nil); err != nil {
+		t.Fatalf("TagCommand failed: %v", err)
+	}
+	if names, _ := db.GetCommandNamesByTag("ci"); !reflect.DeepEqual(names, []string{"build"}) {
+		t.Errorf("Expected build to be indexed under ci, got %v", names)
+	}
+
+	if err := TagCommand(db, "build", nil, []string{"go"}); err != nil {
+		t.Fatalf("TagCommand failed: %v", err)
+	}
+	cmd, _ := db.GetCommand("build")
+	if !reflect.DeepEqual(cmd.Tags, []string{"ci"}) {
+		t.Errorf("Expected [ci] after removing go, got %v", cmd.Tags)
+	}
+	if names, _ := db.GetCommandNamesByTag("go"); len(names) != 0 {
+		t.Errorf("Expected no commands tagged go, got %v", names)
+	}
+
+	if err := TagCommand(db, "build", []string{"two words"}, nil); err == nil {
+		t.Error("Expected an error for an invalid tag")
+	}
+	if err := TagCommand(db, "missing", []string{"ci"}, nil); err == nil {
+		t.Error("Expected an error for a missing command")
+	}
+}
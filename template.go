@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// placeholderPattern matches {{name}} and {{env:NAME}} placeholders; command
+// references ({{cmd:NAME}}) are expanded before placeholders are filled
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(env:)?([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// varNamePattern matches the names of {{name}} placeholders
+var varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// valuePrompter asks for the value of a placeholder, offering def as the
+// default. Secret values are read without echo.
+type valuePrompter func(name, def string, secret bool) (string, error)
+
+// parseVarAssignments parses name=value pairs as given with --var
+func parseVarAssignments(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid variable '%s' (expected name=value)", pair)
+		}
+		vars[strings.TrimSpace(name)] = value
+	}
+	return vars, validateVars(vars)
+}
+
+// validateVars checks that all placeholder default names are valid
+func validateVars(vars map[string]string) error {
+	for name := range vars {
+		if !varNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid variable name '%s'", name)
+		}
+	}
+	return nil
+}
+
+// filledLine is a command line with its placeholders substituted
+type filledLine struct {
+	Line string
+	// Display keeps {{env:NAME}} placeholders so secrets are not printed
+	// or recorded in the run history
+	Display string
+	// Used holds the {{name}} values that were substituted
+	Used map[string]string
+}
+
+// fillPlaceholders substitutes the placeholders of a command line. {{name}}
+// takes its value from given, then from prompt (if set) and then from the
+// command's stored defaults; {{env:NAME}} from the command's env, then afv's
+// environment and then prompt.
+func fillPlaceholders(command *Command, given map[string]string, prompt valuePrompter) (*filledLine, error) {
+	used := map[string]string{}
+	env := map[string]string{}
+	var fillErr error
+	line := placeholderPattern.ReplaceAllStringFunc(command.Command, func(placeholder string) string {
+		if fillErr != nil {
+			return placeholder
+		}
+		m := placeholderPattern.FindStringSubmatch(placeholder)
+		isEnv, name := m[1] != "", m[2]
+
+		if isEnv {
+			if value, ok := env[name]; ok {
+				return value
+			}
+			value, ok := command.Env[name]
+			if !ok {
+				value, ok = os.LookupEnv(name)
+			}
+			if !ok && prompt != nil {
+				if value, fillErr = prompt(name, "", true); fillErr != nil {
+					return placeholder
+				}
+				ok = true
+			}
+			if !ok {
+				fillErr = fmt.Errorf("environment variable %s is not set for {{env:%s}}", name, name)
+				return placeholder
+			}
+			env[name] = value
+			return value
+		}
+
+		if value, ok := used[name]; ok {
+			return value
+		}
+		value, ok := given[name]
+		if !ok && prompt != nil {
+			if value, fillErr = prompt(name, command.Vars[name], false); fillErr != nil {
+				return placeholder
+			}
+			ok = true
+		}
+		if !ok {
+			value, ok = command.Vars[name]
+		}
+		if !ok {
+			fillErr = fmt.Errorf("no value for {{%s}} (use --var %s=VALUE)", name, name)
+			return placeholder
+		}
+		used[name] = value
+		return value
+	})
+	if fillErr != nil {
+		return nil, fillErr
+	}
+
+	display := placeholderPattern.ReplaceAllStringFunc(command.Command, func(placeholder string) string {
+		m := placeholderPattern.FindStringSubmatch(placeholder)
+		if m[1] != "" {
+			return placeholder
+		}
+		return used[m[2]]
+	})
+	return &filledLine{Line: line, Display: display, Used: used}, nil
+}
+
+// terminalPrompter returns a prompter reading from stdin, or nil if stdin is
+// not a terminal so runs from scripts never block
+func terminalPrompter() valuePrompter {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	reader := bufio.NewReader(os.Stdin)
+	return func(name, def string, secret bool) (string, error) {
+		if secret {
+			return readSecret(fmt.Sprintf("Value for %s: ", name))
+		}
+		if def != "" {
+			fmt.Printf("Value for %s [%s]: ", name, def)
+		} else {
+			fmt.Printf("Value for %s: ", name)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read value for %s: %v", name, err)
+		}
+		if value := strings.TrimSpace(line); value != "" {
+			return value, nil
+		}
+		return def, nil
+	}
+}
+
+// rememberVars stores values as the placeholder defaults of a command
+func rememberVars(db *Database, name string, values map[string]string) error {
+	return db.ModifyCommand(name, func(cmd *Command) error {
+		if cmd.Vars == nil {
+			cmd.Vars = map[string]string{}
+		}
+		for key, value := range values {
+			cmd.Vars[key] = value
+		}
+		return nil
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFillPlaceholders(t *testing.T) {
+	t.Setenv("AFV_TEST_TOKEN", "s3cret")
+	cmd := &Command{
+		Command: "git push {{remote}} {{ branch }} --token {{env:AFV_TEST_TOKEN}} {{branch}}",
+		Vars:    map[string]string{"remote": "origin", "branch": "main"},
+	}
+
+	filled, err := fillPlaceholders(cmd, map[string]string{"branch": "dev"}, nil)
+	if err != nil {
+		t.Fatalf("fillPlaceholders failed: %v", err)
+	}
+	if filled.Line != "git push origin dev --token s3cret dev" {
+		t.Errorf("Unexpected line: %s", filled.Line)
+	}
+	if filled.Display != "git push origin dev --token {{env:AFV_TEST_TOKEN}} dev" {
+		t.Errorf("Environment values must not be displayed: %s", filled.Display)
+	}
+	if !reflect.DeepEqual(filled.Used, map[string]string{"remote": "origin", "branch": "dev"}) {
+		t.Errorf("Unexpected values used: %v", filled.Used)
+	}
+
+	// The prompter is asked once per placeholder, with the stored default
+	var asked []string
+	prompt := func(name, def string, secret bool) (string, error) {
+		asked = append(asked, fmt.Sprintf("%s=%s secret=%v", name, def, secret))
+		return "prompted-" + name, nil
+	}
+	filled, err = fillPlaceholders(&Command{Command: "deploy {{env:AFV_TEST_UNSET}} {{branch}} {{branch}}", Vars: map[string]string{"branch": "main"}}, nil, prompt)
+	if err != nil {
+		t.Fatalf("fillPlaceholders failed: %v", err)
+	}
+	if filled.Line != "deploy prompted-AFV_TEST_UNSET prompted-branch prompted-branch" {
+		t.Errorf("Unexpected line: %s", filled.Line)
+	}
+	if !reflect.DeepEqual(asked, []string{"AFV_TEST_UNSET= secret=true", "branch=main secret=false"}) {
+		t.Errorf("Unexpected prompts: %v", asked)
+	}
+
+	// Without a prompter missing values are errors
+	if _, err := fillPlaceholders(&Command{Command: "echo {{missing}}"}, nil, nil); err == nil {
+		t.Error("Expected error for a placeholder without a value")
+	}
+	if _, err := fillPlaceholders(&Command{Command: "echo {{env:AFV_TEST_UNSET}}"}, nil, nil); err == nil {
+		t.Error("Expected error for an unset environment variable")
+	}
+}
+
+func TestParseVarAssignments(t *testing.T) {
+	vars, err := parseVarAssignments([]string{"branch=main", "msg=a=b"})
+	if err != nil {
+		t.Fatalf("parseVarAssignments failed: %v", err)
+	}
+	if !reflect.DeepEqual(vars, map[string]string{"branch": "main", "msg": "a=b"}) {
+		t.Errorf("Unexpected variables: %v", vars)
+	}
+	if _, err := parseVarAssignments([]string{"branch"}); err == nil {
+		t.Error("Expected error for a missing value")
+	}
+	if _, err := parseVarAssignments([]string{"1x=y"}); err == nil {
+		t.Error("Expected error for an invalid name")
+	}
+}
+
+func TestRememberVars(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.InsertCommand(Command{Name: "push", Command: "git push {{remote}} {{branch}}", Vars: map[string]string{"remote": "origin"}}); err != nil {
+		t.Fatalf("InsertCommand failed: %v", err)
+	}
+	if err := rememberVars(db, "push", map[string]string{"branch": "dev"}); err != nil {
+		t.Fatalf("rememberVars failed: %v", err)
+	}
+	cmd, _ := db.GetCommand("push")
+	if !reflect.DeepEqual(cmd.Vars, map[string]string{"remote": "origin", "branch": "dev"}) {
+		t.Errorf("Unexpected defaults: %v", cmd.Vars)
+	}
+}
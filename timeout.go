@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// timeoutGrace is how long a timed out command may take to exit after
+// SIGTERM before it is killed
+const timeoutGrace = 5 * time.Second
+
+// timeoutExitCode is afv's exit status for a timed out command, as used by
+// GNU timeout
+const timeoutExitCode = 124
+
+// TimeoutError reports that a command was stopped because it ran too long
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("command timed out after %s", e.Timeout)
+}
+
+// parseTimeout parses a timeout; an empty value means no timeout
+func parseTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout '%s': %v", value, err)
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("timeout must be positive")
+	}
+	return timeout, nil
+}
+
+// isTimeout reports whether err comes from a command that timed out
+func isTimeout(err error) bool {
+	var timeoutErr *TimeoutError
+	return errors.As(err, &timeoutErr)
+}
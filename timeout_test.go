@@ -0,0 +1,51 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunForegroundTimeout(t *testing.T) {
+	if err := runForeground(exec.Command("true"), ResourceLimits{}, time.Second, time.Second); err != nil {
+		t.Fatalf("Expected a quick command to succeed, got %v", err)
+	}
+
+	start := time.Now()
+	err := runForeground(exec.Command("sleep", "10"), ResourceLimits{}, 100*time.Millisecond, time.Second)
+	if !isTimeout(err) {
+		t.Fatalf("Expected a timeout error, got %v", err)
+	}
+	if code := exitCode(err); code != timeoutExitCode {
+		t.Errorf("Expected exit code %d, got %d", timeoutExitCode, code)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("SIGTERM should stop sleep right away, took %s", elapsed)
+	}
+
+	// A command ignoring SIGTERM is killed after the grace period
+	start = time.Now()
+	err = runForeground(exec.Command("sh", "-c", `trap "" TERM; sleep 10`), ResourceLimits{}, 100*time.Millisecond, 200*time.Millisecond)
+	if !isTimeout(err) {
+		t.Fatalf("Expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the command to be killed after the grace period, took %s", elapsed)
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	if timeout, err := parseTimeout(""); err != nil || timeout != 0 {
+		t.Errorf("Empty timeout should mean none, got %s, %v", timeout, err)
+	}
+	if timeout, err := parseTimeout("30s"); err != nil || timeout != 30*time.Second {
+		t.Errorf("Expected 30s, got %s, %v", timeout, err)
+	}
+	for _, value := range []string{"soon", "0s", "-1m"} {
+		if _, err := parseTimeout(value); err == nil {
+			t.Errorf("Expected error for timeout '%s'", value)
+		}
+	}
+}
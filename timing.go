@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// phaseTiming is how long one phase of a run took
+type phaseTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// runTimer measures the wall time of a run and of its phases, such as the
+// hooks, the command or each of its steps, for 'afv run --time'
+type runTimer struct {
+	start  time.Time
+	phases []phaseTiming
+}
+
+// newRunTimer starts timing a run
+func newRunTimer() *runTimer {
+	return &runTimer{start: time.Now()}
+}
+
+// phase runs fn and records how long it took under name
+func (t *runTimer) phase(name string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	t.add(name, time.Since(start))
+	return err
+}
+
+// add records a phase measured elsewhere
+func (t *runTimer) add(name string, d time.Duration) {
+	if t != nil {
+		t.phases = append(t.phases, phaseTiming{Name: name, Duration: d})
+	}
+}
+
+// Elapsed returns the wall time since the run started
+func (t *runTimer) Elapsed() time.Duration {
+	return time.Since(t.start)
+}
+
+// Print writes the wall time of the run and, if detailed, the time of every
+// phase
+func (t *runTimer) Print(w io.Writer, err error, detailed bool) {
+	elapsed := t.Elapsed()
+	if detailed && len(t.phases) > 0 {
+		width := len("total")
+		for _, p := range t.phases {
+			width = max(width, len(p.Name))
+		}
+		fmt.Fprintln(w, "Timing:")
+		for _, p := range t.phases {
+			fmt.Fprintf(w, "  %-*s  %s\n", width, p.Name, formatDuration(p.Duration))
+		}
+		fmt.Fprintf(w, "  %-*s  %s\n", width, "total", formatDuration(elapsed))
+	}
+	if err != nil {
+		fmt.Fprintf(w, "Failed after %s\n", formatDuration(elapsed))
+	} else {
+		fmt.Fprintf(w, "Finished in %s\n", formatDuration(elapsed))
+	}
+}
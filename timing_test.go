@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunTimerPrint(t *testing.T) {
+	timer := newRunTimer()
+	timer.add("pre hook", 120*time.Millisecond)
+	if err := timer.phase("command", func() error { return nil }); err != nil {
+		t.Fatalf("phase failed: %v", err)
+	}
+
+	var out strings.Builder
+	timer.Print(&out, nil, false)
+	if !strings.HasPrefix(out.String(), "Finished in ") || strings.Contains(out.String(), "Timing:") {
+		t.Errorf("Expected only the wall time, got %q", out.String())
+	}
+
+	out.Reset()
+	timer.Print(&out, errors.New("exit status 1"), true)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || lines[0] != "Timing:" || lines[1] != "  pre hook  120ms" ||
+		!strings.HasPrefix(lines[2], "  command ") || !strings.HasPrefix(lines[3], "  total ") ||
+		!strings.HasPrefix(lines[4], "Failed after ") {
+		t.Errorf("Unexpected breakdown:\n%s", out.String())
+	}
+}
+
+func TestLastRunByName(t *testing.T) {
+	records := []RunRecord{
+		{ID: 1, Name: "build", ExitCode: 1},
+		{ID: 2, Command: "ls"},
+		{ID: 3, Name: "build"},
+		{ID: 4, Name: "test"},
+	}
+	last := lastRunByName(records)
+	if len(last) != 2 || last["build"].ID != 3 || last["test"].ID != 4 {
+		t.Errorf("Unexpected last runs %+v", last)
+	}
+}
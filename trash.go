@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// trashBucket keeps deleted commands, keyed by name, until they are
+// restored with 'afv undelete' or the trash is emptied
+var trashBucket = []byte("trash")
+
+// TrashEntry is a deleted command together with the aliases it had
+type TrashEntry struct {
+	Command   Command  `json:"command"`
+	Aliases   []string `json:"aliases,omitempty"`
+	DeletedAt string   `json:"deleted_at"`
+}
+
+// TrashCommand deletes a command by moving it and its aliases into the
+// trash. A command deleted earlier under the same name is replaced.
+func (d *Database) TrashCommand(name string) error {
+	if err := d.checkStored(name); err != nil {
+		return err
+	}
+	return d.update("TrashCommand", func(tx *bbolt.Tx) error {
+		data := tx.Bucket(commandsBucket).Get([]byte(name))
+		if data == nil {
+			return fmt.Errorf("command '%s' not found", name)
+		}
+		entry := TrashEntry{DeletedAt: time.Now().Format(timeLayout)}
+		if err := json.Unmarshal(data, &entry.Command); err != nil {
+			return err
+		}
+		aliases, err := aliasesOfTx(tx, name)
+		if err != nil {
+			return err
+		}
+		entry.Aliases = aliases
+
+		if err := deleteCommandTx(tx, name); err != nil {
+			return err
+		}
+		data, err = json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(trashBucket).Put([]byte(name), data)
+	})
+}
+
+// UndeleteCommand restores a command from the trash as it was when it was
+// deleted. Its aliases come back too unless their names were taken since.
+func (d *Database) UndeleteCommand(name string) (*TrashEntry, error) {
+	var entry TrashEntry
+	err := d.update("UndeleteCommand", func(tx *bbolt.Tx) error {
+		trash := tx.Bucket(trashBucket)
+		data := trash.Get([]byte(name))
+		if data == nil {
+			return fmt.Errorf("command '%s' is not in the trash", name)
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+
+		commands := tx.Bucket(commandsBucket)
+		if commands.Get([]byte(name)) != nil {
+			return fmt.Errorf("a command named '%s' exists again; delete or rename it first", name)
+		}
+		aliases := tx.Bucket(aliasesBucket)
+		if target := aliases.Get([]byte(name)); target != nil {
+			return fmt.Errorf("'%s' is an alias of '%s' now; remove the alias first", name, target)
+		}
+		cmd, err := json.Marshal(entry.Command)
+		if err != nil {
+			return err
+		}
+		if err := indexTagsTx(tx, name, nil, entry.Command.Tags); err != nil {
+			return err
+		}
+		if err := indexMetaTx(tx, entry.Command); err != nil {
+			return err
+		}
+		if err := commands.Put([]byte(name), cmd); err != nil {
+			return err
+		}
+
+		var restored []string
+		for _, alias := range entry.Aliases {
+			if commands.Get([]byte(alias)) != nil || aliases.Get([]byte(alias)) != nil {
+				continue
+			}
+			if err := aliases.Put([]byte(alias), []byte(name)); err != nil {
+				return err
+			}
+			restored = append(restored, alias)
+		}
+		entry.Aliases = restored
+		return trash.Delete([]byte(name))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetTrash returns the deleted commands, sorted by name
+func (d *Database) GetTrash() ([]TrashEntry, error) {
+	var entries []TrashEntry
+	err := d.view("GetTrash", func(tx *bbolt.Tx) error {
+		return tx.Bucket(trashBucket).ForEach(func(_, v []byte) error {
+			var entry TrashEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// EmptyTrash deletes the commands in the trash for good and returns how
+// many there were
+func (d *Database) EmptyTrash() (int, error) {
+	var n int
+	err := d.update("EmptyTrash", func(tx *bbolt.Tx) error {
+		n = tx.Bucket(trashBucket).Stats().KeyN
+		if err := tx.DeleteBucket(trashBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(trashBucket)
+		return err
+	})
+	return n, err
+}
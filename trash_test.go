@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.InsertCommand(Command{Name: "build", Command: "make", Tags: []string{"ci"}}); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := db.RecordRun("build", time.Now()); err != nil {
+		t.Fatalf("Failed to record run: %v", err)
+	}
+	if err := db.AddAlias("b", "build"); err != nil {
+		t.Fatalf("Failed to add alias: %v", err)
+	}
+
+	if err := db.TrashCommand("build"); err != nil {
+		t.Fatalf("Failed to delete command: %v", err)
+	}
+	if _, err := db.GetCommand("build"); err == nil {
+		t.Error("Expected the deleted command to be gone")
+	}
+	entries, err := db.GetTrash()
+	if err != nil || len(entries) != 1 || entries[0].Command.Name != "build" || entries[0].DeletedAt == "" {
+		t.Fatalf("Expected build in the trash with its deletion time, got %+v, %v", entries, err)
+	}
+
+	// A command of the same name blocks the restore until it is gone
+	if err := db.AddCommand("build", "", "true", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if _, err := db.UndeleteCommand("build"); err == nil {
+		t.Error("Expected an error restoring over an existing command")
+	}
+	if err := db.DeleteCommand("build"); err != nil {
+		t.Fatalf("Failed to delete command: %v", err)
+	}
+
+	entry, err := db.UndeleteCommand("build")
+	if err != nil {
+		t.Fatalf("Failed to restore command: %v", err)
+	}
+	if len(entry.Aliases) != 1 || entry.Aliases[0] != "b" {
+		t.Errorf("Expected the alias to be restored, got %v", entry.Aliases)
+	}
+	cmd, err := db.GetCommand("build")
+	if err != nil || cmd.Command != "make" || cmd.RunCount != 1 {
+		t.Errorf("Expected the command restored as it was, got %+v, %v", cmd, err)
+	}
+	if names, _ := db.GetCommandNamesByTag("ci"); len(names) != 1 {
+		t.Errorf("Expected the restored command in the tag index, got %v", names)
+	}
+	if entries, _ := db.GetTrash(); len(entries) != 0 {
+		t.Errorf("Expected the trash to be empty after restoring, got %+v", entries)
+	}
+
+	if err := db.TrashCommand("build"); err != nil {
+		t.Fatalf("Failed to delete command: %v", err)
+	}
+	if n, err := db.EmptyTrash(); err != nil || n != 1 {
+		t.Errorf("Expected one command emptied from the trash, got %d, %v", n, err)
+	}
+	if _, err := db.UndeleteCommand("build"); err == nil {
+		t.Error("Expected an error restoring from an empty trash")
+	}
+}
@@ -0,0 +1,611 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+)
+
+// tuiOutputLines is the number of lines of output the manager keeps
+const tuiOutputLines = 1000
+
+// tuiPrompt is a line of input the manager asks for
+type tuiPrompt struct {
+	label string
+	value string
+	// done is called with the value once the input is confirmed
+	done func(value string)
+}
+
+// tuiEvent reports a line of output of the running command, or its end
+type tuiEvent struct {
+	line string
+	done bool
+	err  error
+}
+
+// tuiEdited reports that the editor opened for a command was closed
+type tuiEdited struct {
+	name string
+	err  error
+}
+
+// tuiExec runs a function with the terminal handed over by the manager, as
+// tea.Exec does for a process
+type tuiExec func() error
+
+func (fn tuiExec) Run() error       { return fn() }
+func (tuiExec) SetStdin(io.Reader)  {}
+func (tuiExec) SetStdout(io.Writer) {}
+func (tuiExec) SetStderr(io.Writer) {}
+
+// tui is the bubbletea model of the full-screen manager opened by afv tui:
+// the command list filtered by the search, an input prompt, and the output
+// pane of the command started from it
+type tui struct {
+	db        *Database
+	commands  []CommandMeta
+	matches   []CommandMeta
+	query     string
+	selected  int
+	searching bool
+	prompt    *tuiPrompt
+	status    string
+	quit      bool
+
+	width, height int
+
+	output      []string
+	running     bool
+	runningName string
+	events      chan tuiEvent
+
+	// runCommand runs the named command, writing its output to w
+	runCommand func(name string, w io.Writer) error
+	// editCommand opens the named command in the editor
+	editCommand func(name string) error
+}
+
+// newTUI returns the manager for the commands of db
+func newTUI(db *Database) *tui {
+	return &tui{
+		db:     db,
+		width:  80,
+		height: 24,
+		events: make(chan tuiEvent, 100),
+		runCommand: func(name string, w io.Writer) error {
+			return RunStored(db, name, RunOptions{Stdout: w, Stderr: w})
+		},
+		editCommand: func(name string) error { return EditCommand(db, name) },
+	}
+}
+
+// reload reads the commands again and reapplies the search, keeping the
+// selected command selected
+func (t *tui) reload() error {
+	commands, err := t.db.GetCommandMeta()
+	if err != nil {
+		return fmt.Errorf("failed to get commands: %v", err)
+	}
+	if err := sortCommands(commands); err != nil {
+		return err
+	}
+	t.commands = commands
+	t.filter()
+	return nil
+}
+
+// refresh reloads the commands after a change, showing a failure in the
+// status line
+func (t *tui) refresh() {
+	if err := t.reload(); err != nil {
+		t.fail(err)
+	}
+}
+
+// filter applies the search to the commands
+func (t *tui) filter() {
+	var name string
+	if cmd := t.current(); cmd != nil {
+		name = cmd.Name
+	}
+	if t.query == "" {
+		t.matches = t.commands
+	} else {
+		t.matches = filterCommands(t.commands, t.query)
+	}
+	if i := slices.IndexFunc(t.matches, func(cmd CommandMeta) bool { return cmd.Name == name }); i >= 0 {
+		t.selected = i
+	}
+	t.selected = max(0, min(t.selected, len(t.matches)-1))
+}
+
+// current returns the selected command, or nil if nothing matches
+func (t *tui) current() *CommandMeta {
+	if t.selected < 0 || t.selected >= len(t.matches) {
+		return nil
+	}
+	return &t.matches[t.selected]
+}
+
+// ask shows a prompt for a line of input, prefilled with value
+func (t *tui) ask(label, value string, done func(string)) {
+	t.prompt = &tuiPrompt{label: label, value: value, done: done}
+}
+
+// fail shows err in the status line
+func (t *tui) fail(err error) {
+	t.status = "Error: " + err.Error()
+}
+
+// Init implements tea.Model
+func (t *tui) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model
+func (t *tui) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Keep the default size for a terminal that reports none
+		if msg.Width > 0 && msg.Height > 0 {
+			t.width, t.height = msg.Width, msg.Height
+		}
+	case tea.KeyMsg:
+		if key, runes := tuiKey(msg); key == keyRune {
+			// Pasted text arrives as one message
+			for _, c := range runes {
+				cmds = append(cmds, t.handleKey(keyRune, c))
+			}
+		} else if key != keyNone {
+			cmds = append(cmds, t.handleKey(key, 0))
+		}
+	case tuiEvent:
+		t.handleEvent(msg)
+		if t.running {
+			cmds = append(cmds, t.waitEvent())
+		}
+	case tuiEdited:
+		if msg.err != nil {
+			t.fail(msg.err)
+		} else {
+			t.status = fmt.Sprintf("Command '%s' edited.", msg.name)
+		}
+		t.refresh()
+	}
+	if t.quit {
+		return t, tea.Quit
+	}
+	return t, tea.Batch(cmds...)
+}
+
+// tuiKey translates a key press into the keys of the picker, with the
+// typed characters for keyRune
+func tuiKey(msg tea.KeyMsg) (int, []rune) {
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		if msg.Alt {
+			return keyNone, nil
+		}
+		return keyRune, msg.Runes
+	case tea.KeyEnter:
+		return keyEnter, nil
+	case tea.KeyBackspace, tea.KeyCtrlH:
+		return keyBackspace, nil
+	case tea.KeyEsc, tea.KeyCtrlC, tea.KeyCtrlD:
+		return keyCancel, nil
+	case tea.KeyUp, tea.KeyCtrlP:
+		return keyUp, nil
+	case tea.KeyDown, tea.KeyCtrlN:
+		return keyDown, nil
+	}
+	return keyNone, nil
+}
+
+// handleKey reacts to a key press, returning the command to run for it
+func (t *tui) handleKey(key int, c rune) tea.Cmd {
+	switch {
+	case t.prompt != nil:
+		t.promptKey(key, c)
+	case t.searching:
+		t.searchKey(key, c)
+	default:
+		t.status = ""
+		return t.listKey(key, c)
+	}
+	return nil
+}
+
+// promptKey edits the input of the prompt
+func (t *tui) promptKey(key int, c rune) {
+	p := t.prompt
+	switch key {
+	case keyRune:
+		p.value += string(c)
+	case keyBackspace:
+		if v := []rune(p.value); len(v) > 0 {
+			p.value = string(v[:len(v)-1])
+		}
+	case keyCancel:
+		t.prompt = nil
+	case keyEnter:
+		// The callback may ask for the next input
+		t.prompt = nil
+		p.done(strings.TrimSpace(p.value))
+	}
+}
+
+// searchKey edits the search query
+func (t *tui) searchKey(key int, c rune) {
+	switch key {
+	case keyRune:
+		t.query += string(c)
+	case keyBackspace:
+		if q := []rune(t.query); len(q) > 0 {
+			t.query = string(q[:len(q)-1])
+		}
+	case keyUp:
+		t.selected--
+	case keyDown:
+		t.selected++
+	case keyEnter:
+		t.searching = false
+	case keyCancel:
+		t.searching, t.query = false, ""
+	}
+	t.filter()
+}
+
+// listKey runs the action bound to a key in the command list
+func (t *tui) listKey(key int, c rune) tea.Cmd {
+	if key == keyRune {
+		switch c {
+		case 'k':
+			key = keyUp
+		case 'j':
+			key = keyDown
+		case 'r':
+			key = keyEnter
+		}
+	}
+
+	switch key {
+	case keyUp:
+		t.selected = max(0, t.selected-1)
+	case keyDown:
+		t.selected = max(0, min(t.selected+1, len(t.matches)-1))
+	case keyEnter:
+		if cmd := t.current(); cmd != nil {
+			return t.run(cmd.Name)
+		}
+	case keyCancel:
+		if t.query != "" {
+			t.query = ""
+			t.filter()
+		} else {
+			t.quit = true
+		}
+	case keyRune:
+		switch c {
+		case '/':
+			t.searching = true
+		case 'a':
+			t.add()
+		case 'e':
+			return t.edit()
+		case 't':
+			t.tag()
+		case 'd':
+			t.delete()
+		case 's':
+			t.stop()
+		case 'q':
+			t.quit = true
+		}
+	}
+	return nil
+}
+
+// add asks for the name, command line and description of a new command
+func (t *tui) add() {
+	t.ask("Name: ", "", func(name string) {
+		t.ask("Command line: ", "", func(line string) {
+			t.ask("Description (optional): ", "", func(desc string) {
+				if desc == "" {
+					desc = "No description provided"
+				}
+				cmd := Command{Name: name, Command: line, Description: desc}
+				if err := t.db.InsertCommand(cmd); err != nil {
+					t.fail(fmt.Errorf("failed to add command: %v", err))
+					return
+				}
+				t.status = fmt.Sprintf("Command '%s' added.", name)
+				t.refresh()
+				if i := slices.IndexFunc(t.matches, func(cmd CommandMeta) bool { return cmd.Name == name }); i >= 0 {
+					t.selected = i
+				}
+			})
+		})
+	})
+}
+
+// edit hands the terminal to the editor for the selected command
+func (t *tui) edit() tea.Cmd {
+	cmd := t.current()
+	if cmd == nil {
+		return nil
+	}
+	name := cmd.Name
+	return tea.Exec(tuiExec(func() error { return t.editCommand(name) }), func(err error) tea.Msg {
+		return tuiEdited{name: name, err: err}
+	})
+}
+
+// tag asks for the tags of the selected command and replaces its tags
+func (t *tui) tag() {
+	cmd := t.current()
+	if cmd == nil {
+		return
+	}
+	name, old := cmd.Name, cmd.Tags
+	t.ask("Tags of "+name+": ", strings.Join(old, ", "), func(value string) {
+		if err := TagCommand(t.db, name, splitTags([]string{value}), old); err != nil {
+			t.fail(err)
+			return
+		}
+		t.status = fmt.Sprintf("Tags of '%s' updated.", name)
+		t.refresh()
+	})
+}
+
+// delete moves the selected command to the trash after confirmation
+func (t *tui) delete() {
+	cmd := t.current()
+	if cmd == nil {
+		return
+	}
+	name := cmd.Name
+	t.ask(fmt.Sprintf("Delete '%s'? (y/N) ", name), "", func(answer string) {
+		if answer = strings.ToLower(answer); answer != "y" && answer != "yes" {
+			return
+		}
+		if err := t.db.TrashCommand(name); err != nil {
+			t.fail(fmt.Errorf("failed to delete command: %v", err))
+			return
+		}
+		t.status = fmt.Sprintf("Command '%s' deleted; restore it with 'afv undelete %s'.", name, name)
+		t.refresh()
+	})
+}
+
+// run starts the named command in the background, streaming its output to
+// the output pane
+func (t *tui) run(name string) tea.Cmd {
+	if t.running {
+		t.status = fmt.Sprintf("'%s' is still running; press s to stop it.", t.runningName)
+		return nil
+	}
+	t.running, t.runningName = true, name
+	t.output = []string{"$ afv run " + name}
+
+	r, w := io.Pipe()
+	result := make(chan error, 1)
+	go func() {
+		err := t.runCommand(name, w)
+		w.Close()
+		result <- err
+	}()
+	events := t.events
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			events <- tuiEvent{line: scanner.Text()}
+		}
+		// Keep the command from blocking on a line too long to show
+		io.Copy(io.Discard, r)
+		events <- tuiEvent{done: true, err: <-result}
+	}()
+	return t.waitEvent()
+}
+
+// waitEvent returns the command delivering the next output of the running
+// command to Update
+func (t *tui) waitEvent() tea.Cmd {
+	events := t.events
+	return func() tea.Msg { return <-events }
+}
+
+// stop asks the running command to exit. Like a Ctrl-C outside the
+// manager, the signal afv receives is forwarded to the command's process.
+func (t *tui) stop() {
+	if !t.running {
+		return
+	}
+	if runtime.GOOS == "windows" {
+		t.status = "Stopping a command is not supported on Windows."
+		return
+	}
+	self, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = interruptProcess(self)
+	}
+	if err != nil {
+		t.fail(err)
+	}
+}
+
+// handleEvent adds output of the running command to the output pane
+func (t *tui) handleEvent(ev tuiEvent) {
+	if !ev.done {
+		t.output = append(t.output, tuiCleanLine(ev.line))
+		if len(t.output) > tuiOutputLines {
+			t.output = t.output[len(t.output)-tuiOutputLines:]
+		}
+		return
+	}
+	if ev.err != nil {
+		t.status = fmt.Sprintf("'%s' failed: %v", t.runningName, ev.err)
+	} else {
+		t.status = fmt.Sprintf("'%s' finished.", t.runningName)
+	}
+	t.running = false
+	t.refresh()
+}
+
+// tuiCleanLine makes a line of output safe to draw: tabs become spaces,
+// only the text after the last carriage return is kept, as a terminal
+// would show it, and other control characters are dropped
+func tuiCleanLine(line string) string {
+	if i := strings.LastIndex(line, "\r"); i >= 0 {
+		line = line[i+1:]
+	}
+	line = strings.ReplaceAll(line, "\t", "    ")
+	return strings.Map(func(r rune) rune {
+		if r < 32 || r == 127 {
+			return -1
+		}
+		return r
+	}, line)
+}
+
+// tuiFit cuts s to width characters and pads it to that width
+func tuiFit(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
+
+// View implements tea.Model, drawing the manager for the size of the
+// terminal
+func (t *tui) View() string {
+	width, height := t.width, t.height
+	var lines []string
+	header := fmt.Sprintf(" afv  %d/%d commands", len(t.matches), len(t.commands))
+	if t.query != "" || t.searching {
+		header += "  /" + t.query
+	}
+	lines = append(lines, "\033[1m"+tuiFit(header, width)+"\033[0m")
+
+	// The output pane takes the lower half once something ran
+	footer := 2
+	outputHeight := 0
+	if len(t.output) > 0 {
+		outputHeight = (height - 1 - footer) / 2
+	}
+	listHeight := max(1, height-1-footer-outputHeight)
+
+	start := max(0, t.selected-listHeight+1)
+	for i := start; i < start+listHeight; i++ {
+		if i >= len(t.matches) {
+			lines = append(lines, "")
+			continue
+		}
+		cmd := t.matches[i]
+		line := fmt.Sprintf("  %-20s %s", cmd.Name, cmd.Description)
+		switch {
+		case cmd.Project:
+			line += "  [project]"
+		case len(cmd.Tags) > 0:
+			line += "  [" + strings.Join(cmd.Tags, ", ") + "]"
+		}
+		if i == t.selected {
+			line = "\033[7m" + tuiFit(line, width) + "\033[0m"
+		} else {
+			line = tuiFit(line, width)
+		}
+		lines = append(lines, line)
+	}
+
+	if outputHeight > 0 {
+		title := "── output"
+		if t.running {
+			title += " (running " + t.runningName + ")"
+		}
+		lines = append(lines, tuiFit(title+" "+strings.Repeat("─", width), width))
+		shown := t.output[max(0, len(t.output)-(outputHeight-1)):]
+		for i := 0; i < outputHeight-1; i++ {
+			if i < len(shown) {
+				lines = append(lines, tuiFit(shown[i], width))
+			} else {
+				lines = append(lines, "")
+			}
+		}
+	}
+
+	switch {
+	case t.prompt != nil:
+		lines = append(lines, tuiFit(t.prompt.label+t.prompt.value+"█", width))
+	default:
+		lines = append(lines, tuiFit(t.status, width))
+	}
+	help := "enter run  / search  a add  e edit  t tag  d delete  s stop  q quit"
+	switch {
+	case t.prompt != nil:
+		help = "enter confirm  esc cancel"
+	case t.searching:
+		help = "type to search  ↑/↓ select  enter done  esc clear"
+	}
+	lines = append(lines, "\033[2m"+tuiFit(help, width)+"\033[0m")
+
+	return strings.Join(lines[:min(len(lines), height)], "\n")
+}
+
+// RunTUI opens the full-screen manager on the terminal until it is quit
+func RunTUI(db *Database) (err error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("afv tui needs a terminal")
+	}
+	t := newTUI(db)
+	if err := t.reload(); err != nil {
+		return err
+	}
+
+	// The manager stays open for long, so other afv processes such as the
+	// scheduler get at the database between its transactions
+	if err := db.Share(); err != nil {
+		return fmt.Errorf("failed to close database: %v", err)
+	}
+	defer func() {
+		if reopenErr := db.Reopen(); reopenErr != nil && err == nil {
+			err = reopenErr
+		}
+	}()
+
+	// The signals stop sends are meant for the running command, not afv
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	p := tea.NewProgram(t, tea.WithAltScreen(), tea.WithoutSignalHandler())
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("failed to run the manager: %v", err)
+	}
+
+	// Give a command still running the time to stop
+	if t.running {
+		t.stop()
+		deadline := time.After(timeoutGrace)
+		for t.running {
+			select {
+			case ev := <-t.events:
+				t.handleEvent(ev)
+			case <-deadline:
+				return nil
+			}
+		}
+	}
+	return nil
+}
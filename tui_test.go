@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.etcd.io/bbolt"
+)
+
+// typeKeys feeds the key presses of s to the manager, with \r for Enter
+// and \x1b for Escape, returning the command of the last one
+func typeKeys(t *tui, s string) tea.Cmd {
+	var cmd tea.Cmd
+	for _, c := range s {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{c}}
+		switch c {
+		case '\r':
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case '\x1b':
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case '\x7f':
+			msg = tea.KeyMsg{Type: tea.KeyBackspace}
+		}
+		_, cmd = t.Update(msg)
+	}
+	return cmd
+}
+
+func newTestTUI(t *testing.T) (*tui, *Database) {
+	t.Setenv(configDirEnv, t.TempDir())
+	db, tempDir := createTempDB(t)
+	t.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	})
+	for _, cmd := range []Command{
+		{Name: "build", Command: "make", Description: "Compile the project"},
+		{Name: "deploy", Command: "./deploy.sh", Description: "Ship it", Tags: []string{"ops"}},
+		{Name: "Lint", Command: "golangci-lint run", Description: "Run the linters"},
+	} {
+		if err := db.InsertCommand(cmd); err != nil {
+			t.Fatalf("Failed to add command: %v", err)
+		}
+	}
+	m := newTUI(db)
+	if err := m.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	// As in RunTUI, the database is only open during transactions
+	if err := db.Share(); err != nil {
+		t.Fatalf("Share failed: %v", err)
+	}
+	t.Cleanup(func() { db.Reopen() })
+	return m, db
+}
+
+func TestTUISearch(t *testing.T) {
+	m, _ := newTestTUI(t)
+	if len(m.matches) != 3 {
+		t.Fatalf("Expected all commands, got %+v", m.matches)
+	}
+	// Sorted as afv list sorts them, not in database order
+	if m.matches[2].Name != "Lint" {
+		t.Errorf("Expected the commands sorted by name, got %+v", m.matches)
+	}
+
+	typeKeys(m, "/dep")
+	if len(m.matches) != 1 || m.current().Name != "deploy" || !m.searching {
+		t.Errorf("Expected deploy to match the search, got %+v", m.matches)
+	}
+	typeKeys(m, "\r")
+	if m.searching || m.query != "dep" {
+		t.Errorf("Expected Enter to keep the query, got %q (searching %v)", m.query, m.searching)
+	}
+	typeKeys(m, "\x1b")
+	if m.query != "" || len(m.matches) != 3 || m.current().Name != "deploy" {
+		t.Errorf("Expected Escape to clear the query and keep the selection, got %q, %+v", m.query, m.current())
+	}
+	typeKeys(m, "jjjk")
+	if m.current().Name != "deploy" {
+		t.Errorf("Expected the selection to stop at the last command, got %+v", m.current())
+	}
+	typeKeys(m, "\x1b")
+	if !m.quit {
+		t.Error("Expected Escape without a query to quit")
+	}
+}
+
+func TestTUIManage(t *testing.T) {
+	m, db := newTestTUI(t)
+
+	typeKeys(m, "atest\rgo test ./...\r\r")
+	cmd, err := db.GetCommand("test")
+	if err != nil || cmd.Command != "go test ./..." || cmd.Description != "No description provided" {
+		t.Fatalf("Expected the added command, got %+v, %v", cmd, err)
+	}
+	if m.current().Name != "test" || !strings.Contains(m.status, "added") {
+		t.Errorf("Expected the new command selected, got %+v, %q", m.current(), m.status)
+	}
+
+	typeKeys(m, "t\x7f\x7f\x7fci, go\r")
+	if cmd, _ := db.GetCommand("test"); strings.Join(cmd.Tags, ",") != "ci,go" {
+		t.Errorf("Expected the tags to be replaced, got %v", cmd.Tags)
+	}
+
+	typeKeys(m, "d\r")
+	if _, err := db.GetCommand("test"); err != nil {
+		t.Error("Expected the command to be kept without confirmation")
+	}
+	typeKeys(m, "dy\r")
+	if _, err := db.GetCommand("test"); err == nil {
+		t.Error("Expected the command to be deleted")
+	}
+	if len(m.commands) != 3 {
+		t.Errorf("Expected the deleted command to leave the list, got %+v", m.commands)
+	}
+
+	typeKeys(m, "a\x1b")
+	if m.prompt != nil || m.quit {
+		t.Error("Expected Escape to cancel the prompt only")
+	}
+
+	if typeKeys(m, "e") == nil {
+		t.Fatal("Expected e to open the editor")
+	}
+	m.Update(tuiEdited{name: m.current().Name})
+	if !strings.Contains(m.status, "edited") {
+		t.Errorf("Expected the edit in the status line, got %q", m.status)
+	}
+
+	// Other processes can open the database between actions
+	other, err := bbolt.Open(db.Path(), 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Expected the database to be free: %v", err)
+	}
+	other.Close()
+}
+
+func TestTUIRun(t *testing.T) {
+	m, _ := newTestTUI(t)
+	started := make(chan string, 1)
+	release := make(chan struct{})
+	m.runCommand = func(name string, w io.Writer) error {
+		started <- name
+		fmt.Fprintf(w, "one\na\tb\n")
+		<-release
+		fmt.Fprintln(w, "err")
+		return errors.New("exit status 3")
+	}
+
+	if typeKeys(m, "\r") == nil {
+		t.Fatal("Expected Enter to wait for the output")
+	}
+	if name := <-started; name != "build" || !m.running {
+		t.Fatalf("Expected build to run, got %q", name)
+	}
+	typeKeys(m, "r")
+	if !strings.Contains(m.status, "still running") {
+		t.Errorf("Expected a second run to wait, got %q", m.status)
+	}
+	close(release)
+	for m.running {
+		m.Update(<-m.events)
+	}
+
+	want := []string{"$ afv run build", "one", "a    b", "err"}
+	if strings.Join(m.output, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected output %q", m.output)
+	}
+	if !strings.Contains(m.status, "'build' failed: exit status 3") {
+		t.Errorf("Expected the failure in the status line, got %q", m.status)
+	}
+}
+
+func TestTUIView(t *testing.T) {
+	m, _ := newTestTUI(t)
+	m.output = []string{"$ afv run build", strings.Repeat("x", 100)}
+
+	m.Update(tea.WindowSizeMsg{Width: 40, Height: 12})
+	screen := m.View()
+	lines := strings.Split(screen, "\n")
+	if len(lines) != 12 {
+		t.Fatalf("Expected 12 lines, got %d:\n%s", len(lines), screen)
+	}
+	for _, want := range []string{"3/3 commands", "deploy", "[ops]", "── output", "enter run"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("Expected the screen to contain %q, got:\n%s", want, screen)
+		}
+	}
+	if strings.Contains(screen, strings.Repeat("x", 41)) {
+		t.Errorf("Expected long output to be cut to the width, got:\n%s", screen)
+	}
+	if got := tuiCleanLine("50%\r100%\x1b[0m"); got != "100%[0m" {
+		t.Errorf("Unexpected cleaned line %q", got)
+	}
+}
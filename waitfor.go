@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// defaultWaitTimeout is used when a wait-for spec has no explicit timeout
+const defaultWaitTimeout = 30 * time.Second
+
+// waitPollInterval is the delay between connection attempts
+const waitPollInterval = 250 * time.Millisecond
+
+// parseWaitFor parses a "host:port[,timeout]" spec
+func parseWaitFor(spec string) (string, time.Duration, error) {
+	spec = strings.TrimSpace(spec)
+	address, timeoutStr, hasTimeout := strings.Cut(spec, ",")
+	address = strings.TrimSpace(address)
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || port == "" {
+		return "", 0, fmt.Errorf("invalid wait-for address '%s' (expected host:port[,timeout])", spec)
+	}
+	if host == "" {
+		address = net.JoinHostPort("localhost", port)
+	}
+
+	timeout := defaultWaitTimeout
+	if hasTimeout {
+		timeout, err = time.ParseDuration(strings.TrimSpace(timeoutStr))
+		if err != nil || timeout <= 0 {
+			return "", 0, fmt.Errorf("invalid wait-for timeout in '%s'", spec)
+		}
+	}
+
+	return address, timeout, nil
+}
+
+// WaitForPort blocks until the TCP address in spec accepts connections or its
+// timeout expires
+func WaitForPort(spec string) error {
+	address, timeout, err := parseWaitFor(spec)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Waiting for %s (timeout %s)...\n", address, timeout)
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", address, waitPollInterval)
+		if err == nil {
+			conn.Close()
+			fmt.Printf("%s is accepting connections.\n", address)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %s: %v", timeout, address, err)
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseWaitFor(t *testing.T) {
+	tests := []struct {
+		spec            string
+		expectedAddress string
+		expectedTimeout time.Duration
+		expectError     bool
+	}{
+		{"localhost:5432", "localhost:5432", defaultWaitTimeout, false},
+		{"db:5432,10s", "db:5432", 10 * time.Second, false},
+		{":8080, 1m", "localhost:8080", time.Minute, false},
+		{"localhost", "", 0, true},
+		{"localhost:", "", 0, true},
+		{"localhost:80,soon", "", 0, true},
+		{"localhost:80,-1s", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			address, timeout, err := parseWaitFor(tt.spec)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error for '%s'", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if address != tt.expectedAddress {
+				t.Errorf("Expected address '%s', got '%s'", tt.expectedAddress, address)
+			}
+			if timeout != tt.expectedTimeout {
+				t.Errorf("Expected timeout %s, got %s", tt.expectedTimeout, timeout)
+			}
+		})
+	}
+}
+
+func TestWaitForPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+
+	if err := WaitForPort(address + ",2s"); err != nil {
+		t.Errorf("Expected open port to be ready: %v", err)
+	}
+
+	listener.Close()
+	if err := WaitForPort(address + ",300ms"); err == nil {
+		t.Error("Expected timeout for a closed port")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// watchInterval is how often a watched file is checked for changes
+const watchInterval = 500 * time.Millisecond
+
+// watchFile calls onChange whenever the modification time or size of path
+// changes, until stop is closed. A file that is missing for a moment, as
+// happens while some editors save, is not reported until it is back.
+func watchFile(path string, interval time.Duration, stop <-chan struct{}, onChange func()) {
+	last, _ := os.Stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size() {
+			last = info
+			onChange()
+		}
+	}
+}
+
+// WatchApply applies source and then re-applies it every time the file
+// changes, until interrupted. The database is closed between runs so other
+// afv invocations can use it in the meantime.
+func WatchApply(db *Database, source string, prune bool) error {
+	if source == "-" || isImportURL(source) {
+		return fmt.Errorf("--watch needs a local file")
+	}
+	if _, err := os.Stat(source); err != nil {
+		return fmt.Errorf("failed to watch %s: %v", source, err)
+	}
+
+	if err := Apply(db, source, prune); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	if err := db.Release(); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	fmt.Printf("Watching %s for changes (Ctrl+C to stop)...\n", source)
+	watchFile(source, watchInterval, stop, func() {
+		fmt.Printf("\n%s changed at %s\n", source, time.Now().Format(timeLayout))
+		if err := db.Reopen(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		defer db.Release()
+		if err := Apply(db, source, prune); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commands.yaml")
+	if err := os.WriteFile(path, []byte("commands: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	changed := make(chan struct{}, 1)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		watchFile(path, 10*time.Millisecond, stop, func() { changed <- struct{}{} })
+		close(done)
+	}()
+
+	select {
+	case <-changed:
+		t.Fatal("Unchanged file should not be reported")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, []byte("commands:\n  - name: a\n    command: echo a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the change to be reported")
+	}
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watchFile should return once stopped")
+	}
+}
+
+func TestReleaseAndReopen(t *testing.T) {
+	db, tempDir := createTempDB(t)
+	defer func() {
+		db.Close()
+		os.RemoveAll(tempDir)
+	}()
+
+	if err := db.AddCommand("kept", "Kept", "echo kept", ""); err != nil {
+		t.Fatalf("Failed to add command: %v", err)
+	}
+	if err := db.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := db.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if _, err := db.GetCommand("kept"); err != nil {
+		t.Errorf("Expected command to survive release and reopen: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhookClient posts notifications; a slow endpoint must not hold up afv
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// NotifyConfig holds the notification settings of config.yaml
+type NotifyConfig struct {
+	// Webhook receives every notification as a JSON POST. The payload has a
+	// text field, so a Slack incoming webhook URL works as is.
+	Webhook string `yaml:"webhook,omitempty"`
+}
+
+// webhookPayload is the JSON body posted to the webhook
+type webhookPayload struct {
+	Text      string    `json:"text"`
+	Name      string    `json:"name"`
+	Command   string    `json:"command"`
+	Success   bool      `json:"success"`
+	ExitCode  int       `json:"exit_code"`
+	Duration  float64   `json:"duration_seconds"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// sendWebhookNotification posts a finished run to url
+func sendWebhookNotification(url string, record RunRecord) error {
+	title, message := notificationText(record)
+	data, err := json.Marshal(webhookPayload{
+		Text:      title + ": " + message,
+		Name:      record.Name,
+		Command:   record.Command,
+		Success:   record.Success,
+		ExitCode:  record.ExitCode,
+		Duration:  record.Duration.Seconds(),
+		StartedAt: record.StartedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to send webhook notification: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook notification failed: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendWebhookNotification(t *testing.T) {
+	var payload webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Invalid payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	record := RunRecord{Name: "build", Command: "make", ExitCode: 2, Duration: 90 * time.Second}
+	if err := sendWebhookNotification(server.URL, record); err != nil {
+		t.Fatalf("sendWebhookNotification failed: %v", err)
+	}
+	if payload.Name != "build" || payload.ExitCode != 2 || payload.Success || payload.Duration != 90 {
+		t.Errorf("Unexpected payload %+v", payload)
+	}
+	if !strings.HasPrefix(payload.Text, "afv: build failed") {
+		t.Errorf("Expected a Slack compatible text, got %q", payload.Text)
+	}
+}
+
+func TestNotifyRunWebhook(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	t.Setenv(configDirEnv, dir)
+	config := "notify:\n  webhook: " + server.URL + "\n"
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	// Without rules nothing is sent unless the run asks for it
+	cmd := &Command{Name: "build"}
+	if err := NotifyRun(cmd, RunRecord{Name: "build", Success: true}, false); err != nil || calls != 0 {
+		t.Errorf("Expected no notification, got %v after %d calls", err, calls)
+	}
+	err := NotifyRun(cmd, RunRecord{Name: "build", Success: true}, true)
+	if calls != 1 || err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected the webhook to be called and its failure reported, got %v after %d calls", err, calls)
+	}
+}
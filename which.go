@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Invocation is what running a stored command would execute
+type Invocation struct {
+	Executable string
+	Args       []string
+	Dir        string
+	// Env holds the variables afv adds to or overrides in its own environment
+	Env []string
+	// LookupErr is set when the executable cannot be found
+	LookupErr error
+}
+
+// ResolveInvocation builds the child process for a command without starting
+// it and describes what it would execute
+func ResolveInvocation(command *Command, dir string) (*Invocation, error) {
+	cmd, script, err := newExecCmd(command, dir)
+	if err != nil {
+		return nil, err
+	}
+	// Nothing runs the script, so its file is only named
+	removeScript(script)
+	return &Invocation{
+		Executable: cmd.Path,
+		Args:       cmd.Args,
+		Dir:        cmd.Dir,
+		Env:        envAdditions(cmd.Env),
+		LookupErr:  cmd.Err,
+	}, nil
+}
+
+// envAdditions returns the entries of env that are not inherited unchanged
+// from afv's own environment. A nil env means the child inherits everything.
+func envAdditions(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	inherited := os.Environ()
+	var additions []string
+	for _, entry := range env {
+		if !slices.Contains(inherited, entry) {
+			additions = append(additions, entry)
+		}
+	}
+	return additions
+}
+
+// Print writes the invocation in a readable layout
+func (inv *Invocation) Print() {
+	if inv.LookupErr != nil {
+		fmt.Printf("Executable: %s (not found: %v)\n", inv.Executable, inv.LookupErr)
+	} else {
+		fmt.Printf("Executable: %s\n", inv.Executable)
+	}
+
+	quoted := make([]string, len(inv.Args))
+	for i, arg := range inv.Args {
+		quoted[i] = strconv.Quote(arg)
+	}
+	fmt.Printf("Arguments: [%s]\n", strings.Join(quoted, ", "))
+	fmt.Printf("Working directory: %s\n", inv.Dir)
+
+	if len(inv.Env) == 0 {
+		fmt.Println("Environment: inherited, no additions")
+		return
+	}
+	fmt.Println("Environment additions:")
+	for _, entry := range inv.Env {
+		fmt.Printf("  %s\n", entry)
+	}
+}
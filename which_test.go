@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestResolveInvocation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX executable")
+	}
+
+	dir := t.TempDir()
+	inv, err := ResolveInvocation(&Command{Name: "greet", Command: "sh -c true"}, dir)
+	if err != nil {
+		t.Fatalf("ResolveInvocation failed: %v", err)
+	}
+	if inv.LookupErr != nil {
+		t.Fatalf("Expected sh to be found: %v", inv.LookupErr)
+	}
+	if !reflect.DeepEqual(inv.Args, []string{"sh", "-c", "true"}) {
+		t.Errorf("Unexpected arguments: %q", inv.Args)
+	}
+	if inv.Dir != dir {
+		t.Errorf("Expected working directory %s, got %s", dir, inv.Dir)
+	}
+
+	inv, err = ResolveInvocation(&Command{Name: "missing", Command: "afv-no-such-binary"}, dir)
+	if err != nil {
+		t.Fatalf("ResolveInvocation failed: %v", err)
+	}
+	if inv.LookupErr == nil {
+		t.Error("Expected a lookup error for a missing executable")
+	}
+}
+
+func TestEnvAdditions(t *testing.T) {
+	if envAdditions(nil) != nil {
+		t.Error("A nil environment inherits everything and adds nothing")
+	}
+
+	t.Setenv("AFV_WHICH_TEST", "inherited")
+	env := append(os.Environ(), "AFV_WHICH_TEST=overridden", "AFV_WHICH_NEW=1")
+	additions := envAdditions(env)
+	if !reflect.DeepEqual(additions, []string{"AFV_WHICH_TEST=overridden", "AFV_WHICH_NEW=1"}) {
+		t.Errorf("Unexpected additions: %v", additions)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Environment variables that override the config directory, the active
+// workspace and the database file
+const (
+	configDirEnv = "AFV_CONFIG_DIR"
+	workspaceEnv = "AFV_WORKSPACE"
+	databaseEnv  = "AFVIKLE_DB"
+)
+
+// databaseFlag is the global flag naming the database file, overriding
+// $AFVIKLE_DB and the workspace
+const databaseFlag = "db"
+
+// defaultWorkspace is the database in the afv data directory
+const defaultWorkspace = "default"
+
+// workspaceNamePattern restricts workspace names to safe directory names
+var workspaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// configDir returns the afv config directory, which holds the workspaces and
+// the pointer to the active one
+func configDir() (string, error) {
+	if dir := os.Getenv(configDirEnv); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %v", err)
+	}
+	return filepath.Join(dir, "afvikle"), nil
+}
+
+// dataDir returns the directory holding the database of the default
+// workspace: $XDG_DATA_HOME/afvikle, or the platform's equivalent when it is
+// not set
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "afvikle"), nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return filepath.Join(dir, "afvikle"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get data directory: %v", err)
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(home, "Library", "Application Support", "afvikle"), nil
+	case "windows":
+		return filepath.Join(home, "AppData", "Local", "afvikle"), nil
+	}
+	return filepath.Join(home, ".local", "share", "afvikle"), nil
+}
+
+// legacyDatabasePath returns where the default workspace's database was
+// kept before it moved to the data directory: next to the executable
+func legacyDatabasePath() (string, error) {
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %v", err)
+	}
+	return filepath.Join(filepath.Dir(execPath), "afvikle.db"), nil
+}
+
+// migrateDatabase moves the database at from to to, unless to exists
+// already or there is nothing to move. When from cannot be removed, for
+// example because the executable lies in a read-only directory, it is
+// copied instead. It reports whether a database was migrated.
+func migrateDatabase(from, to string) (bool, error) {
+	if _, err := os.Stat(to); err == nil {
+		return false, nil
+	}
+	if _, err := os.Stat(from); err != nil {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return false, fmt.Errorf("failed to create data directory: %v", err)
+	}
+	if err := os.Rename(from, to); err == nil {
+		return true, nil
+	}
+
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return false, fmt.Errorf("failed to read database: %v", err)
+	}
+	tmp := to + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("failed to copy database: %v", err)
+	}
+	if err := os.Rename(tmp, to); err != nil {
+		os.Remove(tmp)
+		return false, fmt.Errorf("failed to copy database: %v", err)
+	}
+	return true, nil
+}
+
+// activeWorkspaceFile returns the file naming the active workspace
+func activeWorkspaceFile() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "workspace"), nil
+}
+
+// activeWorkspace returns the workspace selected by $AFV_WORKSPACE or
+// 'afv workspace use', falling back to the default workspace
+func activeWorkspace() (string, error) {
+	if name := os.Getenv(workspaceEnv); name != "" {
+		slog.Debug("workspace", "name", name, "from", "$"+workspaceEnv)
+		return name, nil
+	}
+	file, err := activeWorkspaceFile()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		slog.Debug("workspace", "name", defaultWorkspace, "from", "default")
+		return defaultWorkspace, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read active workspace: %v", err)
+	}
+	if name := strings.TrimSpace(string(data)); name != "" {
+		slog.Debug("workspace", "name", name, "from", file)
+		return name, nil
+	}
+	slog.Debug("workspace", "name", defaultWorkspace, "from", "default")
+	return defaultWorkspace, nil
+}
+
+// extractDatabaseOption removes --db from args. Its value is exported as
+// $AFVIKLE_DB, so processes afv starts, such as the scheduler daemon, use the
+// same database.
+func extractDatabaseOption(args []string) ([]string, error) {
+	rest, values, err := extractGlobalFlags(args, databaseFlag)
+	if err != nil {
+		return nil, err
+	}
+	if path, ok := values[databaseFlag]; ok {
+		if path == "" {
+			return nil, fmt.Errorf("flag --%s needs a path", databaseFlag)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid database path '%s': %v", path, err)
+		}
+		os.Setenv(databaseEnv, abs)
+	}
+	return rest, nil
+}
+
+// Global flags selecting the workspace for one invocation
+const (
+	workspaceFlag = "workspace"
+	profileFlag   = "profile"
+)
+
+// extractWorkspaceOption removes --workspace and its alias --profile from
+// args. The workspace is exported as $AFV_WORKSPACE, so it also applies to
+// processes afv starts.
+func extractWorkspaceOption(args []string) ([]string, error) {
+	rest, values, err := extractGlobalFlags(args, workspaceFlag, profileFlag)
+	if err != nil {
+		return nil, err
+	}
+	name := firstNonEmpty(values[workspaceFlag], values[profileFlag])
+	if name == "" {
+		return rest, nil
+	}
+	if !workspaceExists(name) {
+		return nil, fmt.Errorf("workspace '%s' does not exist (create it with 'afv workspace create %s')", name, name)
+	}
+	os.Setenv(workspaceEnv, name)
+	return rest, nil
+}
+
+// databaseOverride returns the database file named by $AFVIKLE_DB, or ""
+func databaseOverride() (string, error) {
+	path := os.Getenv(databaseEnv)
+	if path == "" {
+		return "", nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s '%s': %v", databaseEnv, path, err)
+	}
+	return abs, nil
+}
+
+// workspaceDatabasePath returns the database file of a workspace
+func workspaceDatabasePath(name string) (string, error) {
+	if name == defaultWorkspace {
+		dir, err := dataDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "afvikle.db"), nil
+	}
+
+	if !workspaceNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid workspace name '%s' (use letters, digits, '.', '_' and '-')", name)
+	}
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "workspaces", name, "afvikle.db"), nil
+}
+
+// workspaceExists reports whether a workspace has been created
+func workspaceExists(name string) bool {
+	if name == defaultWorkspace {
+		return true
+	}
+	path, err := workspaceDatabasePath(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// CreateWorkspace creates a workspace with an empty database
+func CreateWorkspace(name string) error {
+	if workspaceExists(name) {
+		return fmt.Errorf("workspace '%s' already exists", name)
+	}
+	path, err := workspaceDatabasePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create workspace directory: %v", err)
+	}
+
+	db, err := openDatabase(path)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+// UseWorkspace makes name the active workspace
+func UseWorkspace(name string) error {
+	if !workspaceExists(name) {
+		return fmt.Errorf("workspace '%s' does not exist (create it with 'afv workspace create %s')", name, name)
+	}
+	file, err := activeWorkspaceFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(file, []byte(name+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to store active workspace: %v", err)
+	}
+	return nil
+}
+
+// ListWorkspaces returns the names of all workspaces, default first
+func ListWorkspaces() ([]string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "workspaces"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read workspaces: %v", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != defaultWorkspace && workspaceExists(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+	return append([]string{defaultWorkspace}, names...), nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkspaces(t *testing.T) {
+	t.Setenv(configDirEnv, t.TempDir())
+	t.Setenv(workspaceEnv, "")
+
+	if name, err := activeWorkspace(); err != nil || name != defaultWorkspace {
+		t.Fatalf("Expected default workspace, got '%s' (%v)", name, err)
+	}
+
+	if err := UseWorkspace("client-a"); err == nil {
+		t.Error("Expected error when using a workspace that does not exist")
+	}
+	if err := CreateWorkspace("client-a"); err != nil {
+		t.Fatalf("CreateWorkspace failed: %v", err)
+	}
+	if err := CreateWorkspace("client-a"); err == nil {
+		t.Error("Expected error when creating an existing workspace")
+	}
+	if err := CreateWorkspace("../escape"); err == nil {
+		t.Error("Expected error for an invalid workspace name")
+	}
+
+	if err := UseWorkspace("client-a"); err != nil {
+		t.Fatalf("UseWorkspace failed: %v", err)
+	}
+	if name, _ := activeWorkspace(); name != "client-a" {
+		t.Errorf("Expected active workspace 'client-a', got '%s'", name)
+	}
+
+	t.Setenv(workspaceEnv, "personal")
+	if name, _ := activeWorkspace(); name != "personal" {
+		t.Errorf("Expected %s to override the active workspace, got '%s'", workspaceEnv, name)
+	}
+
+	names, err := ListWorkspaces()
+	if err != nil {
+		t.Fatalf("ListWorkspaces failed: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"default", "client-a"}) {
+		t.Errorf("Unexpected workspaces: %v", names)
+	}
+
+	path, err := workspaceDatabasePath("client-a")
+	if err != nil {
+		t.Fatalf("workspaceDatabasePath failed: %v", err)
+	}
+	if filepath.Base(filepath.Dir(path)) != "client-a" || filepath.Base(path) != "afvikle.db" {
+		t.Errorf("Unexpected workspace database path: %s", path)
+	}
+}
+
+func TestMigrateDatabase(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "bin", "afvikle.db")
+	path := filepath.Join(dir, "data", "afvikle", "afvikle.db")
+
+	if moved, err := migrateDatabase(legacy, path); err != nil || moved {
+		t.Errorf("Expected nothing to migrate, got %v, %v", moved, err)
+	}
+
+	os.MkdirAll(filepath.Dir(legacy), 0755)
+	os.WriteFile(legacy, []byte("legacy"), 0600)
+	if moved, err := migrateDatabase(legacy, path); err != nil || !moved {
+		t.Fatalf("Expected the database to be migrated, got %v, %v", moved, err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "legacy" {
+		t.Errorf("Expected the legacy database at the new path, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Error("Expected the legacy database to be moved away")
+	}
+
+	// An existing database in the data directory is never replaced
+	os.WriteFile(legacy, []byte("older"), 0600)
+	if moved, err := migrateDatabase(legacy, path); err != nil || moved {
+		t.Errorf("Expected no migration over an existing database, got %v, %v", moved, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "legacy" {
+		t.Errorf("Expected the existing database to be kept, got %q", data)
+	}
+}
+
+func TestDataDir(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+	if dir, err := dataDir(); err != nil || dir != filepath.Join("/xdg/data", "afvikle") {
+		t.Errorf("Expected the XDG data directory, got %s, %v", dir, err)
+	}
+	path, err := workspaceDatabasePath(defaultWorkspace)
+	if err != nil || path != filepath.Join("/xdg/data", "afvikle", "afvikle.db") {
+		t.Errorf("Expected the default database in the data directory, got %s, %v", path, err)
+	}
+}
+
+func TestExtractDatabaseOption(t *testing.T) {
+	t.Setenv(databaseEnv, "")
+	rest, err := extractDatabaseOption([]string{"--db", "test.db", "list"})
+	if err != nil || !reflect.DeepEqual(rest, []string{"list"}) {
+		t.Fatalf("Unexpected result %v, %v", rest, err)
+	}
+	path, err := databaseOverride()
+	if err != nil || !filepath.IsAbs(path) || filepath.Base(path) != "test.db" {
+		t.Errorf("Expected --db to set an absolute override, got %s, %v", path, err)
+	}
+
+	if _, err := extractDatabaseOption([]string{"--db="}); err == nil {
+		t.Error("Expected an error for an empty path")
+	}
+
+	t.Setenv(databaseEnv, "")
+	if path, err := databaseOverride(); err != nil || path != "" {
+		t.Errorf("Expected no override, got %s, %v", path, err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// wslDistroPattern matches the names WSL allows for distributions
+var wslDistroPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// wslUNCPattern matches the Windows view of a distro's file system,
+// \\wsl$\Distro\... or \\wsl.localhost\Distro\...
+var wslUNCPattern = regexp.MustCompile(`(?i)^[\\/]{2}(wsl\$|wsl\.localhost)[\\/][^\\/]+(.*)$`)
+
+// validateWsl checks the distro name of a WSL command
+func validateWsl(distro string) error {
+	if distro != "" && !wslDistroPattern.MatchString(distro) {
+		return fmt.Errorf("invalid WSL distro name '%s'", distro)
+	}
+	return nil
+}
+
+// isLinuxPath reports whether dir is already a path inside the distro, such
+// as /home/ada/src or ~/src, which must not be resolved as a Windows path
+func isLinuxPath(dir string) bool {
+	return strings.HasPrefix(dir, "/") || dir == "~" || strings.HasPrefix(dir, "~/")
+}
+
+// wslPath translates a Windows directory to the path the distro sees:
+// C:\src becomes /mnt/c/src and \\wsl$\Ubuntu\home\ada becomes /home/ada.
+// Linux paths are kept; other paths are returned unchanged.
+func wslPath(dir string) string {
+	if dir == "" || isLinuxPath(dir) {
+		return dir
+	}
+	if m := wslUNCPattern.FindStringSubmatch(dir); m != nil {
+		return "/" + strings.TrimLeft(strings.ReplaceAll(m[2], `\`, "/"), "/")
+	}
+	if len(dir) >= 2 && dir[1] == ':' && isDriveLetter(dir[0]) {
+		rest := strings.TrimLeft(strings.ReplaceAll(dir[2:], `\`, "/"), "/")
+		path := "/mnt/" + strings.ToLower(dir[:1])
+		if rest != "" {
+			path += "/" + rest
+		}
+		return path
+	}
+	return dir
+}
+
+// isDriveLetter reports whether c can name a Windows drive
+func isDriveLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// wslEnv returns the WSLENV value that forwards the given KEY=VALUE
+// variables into the distro, keeping the entries already listed
+func wslEnv(vars []string) string {
+	var entries []string
+	if existing := os.Getenv("WSLENV"); existing != "" {
+		entries = strings.Split(existing, ":")
+	}
+	for _, v := range vars {
+		key, _, _ := strings.Cut(v, "=")
+		entries = append(entries, key)
+	}
+	return strings.Join(entries, ":")
+}
+
+// newWslCmd wraps a command line in wsl.exe so it runs inside the command's
+// distro, in dir translated to the distro's view, forwarding env into it
+func newWslCmd(command *Command, dir string, parts, env []string) *exec.Cmd {
+	args := []string{"-d", command.Wsl}
+	if path := wslPath(dir); path != "" {
+		args = append(args, "--cd", path)
+	}
+	args = append(append(args, "--"), parts...)
+
+	cmd := exec.Command("wsl.exe", args...)
+	// The Windows side only needs a valid directory for wsl.exe itself
+	if dir != "" && !isLinuxPath(dir) {
+		cmd.Dir = dir
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Env = append(cmd.Env, "WSLENV="+wslEnv(env))
+	}
+	return cmd
+}
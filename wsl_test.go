@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestWslPath(t *testing.T) {
+	tests := map[string]string{
+		"":                            "",
+		`C:\Users\ada\src`:            "/mnt/c/Users/ada/src",
+		`D:/data`:                     "/mnt/d/data",
+		`E:\`:                         "/mnt/e",
+		`\\wsl$\Ubuntu\home\ada\proj`: "/home/ada/proj",
+		`\\wsl.localhost\Debian\srv`:  "/srv",
+		"/home/ada/proj":              "/home/ada/proj",
+		"~/proj":                      "~/proj",
+		`\\fileserver\share`:          `\\fileserver\share`,
+	}
+	for input, expected := range tests {
+		if got := wslPath(input); got != expected {
+			t.Errorf("wslPath(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestNewExecCmdWsl(t *testing.T) {
+	t.Setenv("WSLENV", "USERPROFILE/p")
+	command := &Command{
+		Command: "make test",
+		Wsl:     "Ubuntu",
+		Env:     map[string]string{"GOFLAGS": "-count=1"},
+	}
+
+	cmd, _, err := newExecCmd(command, `C:\src\afv`)
+	if err != nil {
+		t.Fatalf("newExecCmd failed: %v", err)
+	}
+	expected := []string{"wsl.exe", "-d", "Ubuntu", "--cd", "/mnt/c/src/afv", "--", "make", "test"}
+	if !reflect.DeepEqual(cmd.Args, expected) {
+		t.Errorf("Expected %v, got %v", expected, cmd.Args)
+	}
+	if cmd.Dir != `C:\src\afv` {
+		t.Errorf("Expected Windows directory for wsl.exe, got %q", cmd.Dir)
+	}
+	if !slices.Contains(cmd.Env, "WSLENV=USERPROFILE/p:GOFLAGS") || !slices.Contains(cmd.Env, "GOFLAGS=-count=1") {
+		t.Errorf("Expected GOFLAGS to be forwarded into the distro, got %v", cmd.Env)
+	}
+
+	// A directory inside the distro is only passed to wsl.exe
+	cmd, _, _ = newExecCmd(command, "/home/ada/proj")
+	if cmd.Dir != "" || !slices.Contains(cmd.Args, "/home/ada/proj") {
+		t.Errorf("Expected distro directory to be passed with --cd only, got dir %q args %v", cmd.Dir, cmd.Args)
+	}
+}
+
+func TestWslValidationAndDirectories(t *testing.T) {
+	if err := validateWsl("Ubuntu-22.04"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := validateWsl("two words"); err == nil {
+		t.Error("Expected error for an invalid distro name")
+	}
+
+	dir, err := resolveRunDirectory(&Command{Wsl: "Ubuntu"}, "~/proj")
+	if err != nil || dir != "~/proj" {
+		t.Errorf("Expected distro home path to be kept, got %q, %v", dir, err)
+	}
+}